@@ -144,7 +144,11 @@ information is displayed once every 5 seconds.`,
 		if context.Bool("stats") {
 			s, err := container.Stats()
 			if err != nil {
-				return err
+				if s == nil || s.CgroupStats == nil {
+					return err
+				}
+				// Report the stats of the subsystems that could be read.
+				logrus.Error(err)
 			}
 			events <- &event{Type: "stats", ID: container.ID(), Data: convertLibcontainerStats(s)}
 			close(events)
@@ -156,7 +160,9 @@ information is displayed once every 5 seconds.`,
 				s, err := container.Stats()
 				if err != nil {
 					logrus.Error(err)
-					continue
+					if s == nil || s.CgroupStats == nil {
+						continue
+					}
 				}
 				stats <- s
 			}
@@ -3,6 +3,7 @@
 package libcontainer
 
 import (
+	"encoding/binary"
 	"fmt"
 	"os"
 	"strings"
@@ -77,12 +78,13 @@ func newContainerCapList(capConfig *configs.Capabilities) (*containerCapabilitie
 		return nil, err
 	}
 	return &containerCapabilities{
-		bounding:    bounding,
-		effective:   effective,
-		inheritable: inheritable,
-		permitted:   permitted,
-		ambient:     ambient,
-		pid:         pid,
+		bounding:     bounding,
+		effective:    effective,
+		inheritable:  inheritable,
+		permitted:    permitted,
+		ambient:      ambient,
+		boundingOnly: capConfig.BoundingOnly,
+		pid:          pid,
 	}, nil
 }
 
@@ -93,6 +95,9 @@ type containerCapabilities struct {
 	inheritable []capability.Cap
 	permitted   []capability.Cap
 	ambient     []capability.Cap
+	// boundingOnly leaves the sets loaded from pid as they were, except for
+	// the bounding and ambient ones.
+	boundingOnly bool
 }
 
 // ApplyBoundingSet sets the capability bounding set to those specified in the whitelist.
@@ -104,6 +109,17 @@ func (c *containerCapabilities) ApplyBoundingSet() error {
 
 // Apply sets all the capabilities for the current process in the config.
 func (c *containerCapabilities) ApplyCaps() error {
+	if c.boundingOnly {
+		// pid was loaded before the user change, which may have cleared the
+		// effective set, so applying it restores the inherited sets. The
+		// kernel only raises ambient capabilities that are inheritable, so
+		// they are added to the inherited inheritable set.
+		c.pid.Clear(capability.BOUNDS | capability.AMBS)
+		c.pid.Set(capability.BOUNDS, c.bounding...)
+		c.pid.Set(capability.INHERITABLE, c.ambient...)
+		c.pid.Set(capability.AMBIENT, c.ambient...)
+		return c.pid.Apply(allCapabilityTypes)
+	}
 	c.pid.Clear(allCapabilityTypes)
 	c.pid.Set(capability.BOUNDS, c.bounding...)
 	c.pid.Set(capability.PERMITTED, c.permitted...)
@@ -112,3 +128,27 @@ func (c *containerCapabilities) ApplyCaps() error {
 	c.pid.Set(capability.AMBIENT, c.ambient...)
 	return c.pid.Apply(allCapabilityTypes)
 }
+
+const (
+	vfsCapRevision2     = 0x02000000
+	vfsCapFlagEffective = 0x000001
+)
+
+// fileCapabilityData encodes the named capabilities as the value of a
+// security.capability xattr (struct vfs_cap_data, revision 2), with the
+// capabilities in the permitted set and the effective bit set.
+func fileCapabilityData(names []string) ([]byte, error) {
+	var permitted uint64
+	for _, c := range names {
+		v, ok := capabilityMap[c]
+		if !ok {
+			return nil, fmt.Errorf("unknown capability %q", c)
+		}
+		permitted |= 1 << uint(v)
+	}
+	data := make([]byte, 20)
+	binary.LittleEndian.PutUint32(data[0:], vfsCapRevision2|vfsCapFlagEffective)
+	binary.LittleEndian.PutUint32(data[4:], uint32(permitted))
+	binary.LittleEndian.PutUint32(data[12:], uint32(permitted>>32))
+	return data, nil
+}
@@ -0,0 +1,104 @@
+// +build linux
+
+package libcontainer
+
+import (
+	"os"
+	"os/exec"
+	"runtime"
+	"syscall"
+	"testing"
+
+	"github.com/opencontainers/runc/libcontainer/configs"
+	"github.com/syndtr/gocapability/capability"
+)
+
+const (
+	prCapAmbient      = 47
+	prCapAmbientIsSet = 1
+)
+
+// TestBoundingOnlyCaps runs TestBoundingOnlyCapsHelper in a child process, as
+// capabilities dropped from the bounding set can not be gained back.
+func TestBoundingOnlyCaps(t *testing.T) {
+	if os.Getuid() != 0 {
+		t.Skip("test requires root")
+	}
+	cmd := exec.Command(os.Args[0], "-test.run=^TestBoundingOnlyCapsHelper$")
+	cmd.Env = append(os.Environ(), "LIBCONTAINER_TEST_CAPS_HELPER=1")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("%v: %s", err, out)
+	}
+}
+
+func TestBoundingOnlyCapsHelper(t *testing.T) {
+	if os.Getenv("LIBCONTAINER_TEST_CAPS_HELPER") != "1" {
+		return
+	}
+	// Capabilities are per thread.
+	runtime.LockOSThread()
+
+	bounding := []string{}
+	for name := range capabilityMap {
+		if name != "CAP_NET_RAW" {
+			bounding = append(bounding, name)
+		}
+	}
+	w, err := newContainerCapList(&configs.Capabilities{
+		Bounding:     bounding,
+		Ambient:      []string{"CAP_KILL"},
+		BoundingOnly: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w.pid, err = capability.NewPid(0); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.ApplyBoundingSet(); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.ApplyCaps(); err != nil {
+		t.Fatal(err)
+	}
+
+	caps, err := capability.NewPid(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if caps.Get(capability.BOUNDING, capability.CAP_NET_RAW) {
+		t.Fatal("expected CAP_NET_RAW to be dropped from the bounding set")
+	}
+	if !caps.Get(capability.EFFECTIVE, capability.CAP_NET_RAW) || !caps.Get(capability.PERMITTED, capability.CAP_NET_RAW) {
+		t.Fatal("expected CAP_NET_RAW to be kept in the effective and permitted sets")
+	}
+	// The test runs with an empty inheritable set, which raising an ambient
+	// capability needs CAP_KILL to be added to. The ambient set is read with
+	// prctl, as capability reads it from the status of the main thread.
+	if !caps.Get(capability.INHERITABLE, capability.CAP_KILL) {
+		t.Fatal("expected CAP_KILL to be added to the inheritable set")
+	}
+	if set, _, errno := syscall.RawSyscall(syscall.SYS_PRCTL, prCapAmbient, prCapAmbientIsSet, uintptr(capability.CAP_KILL)); errno != 0 || set != 1 {
+		t.Fatalf("expected CAP_KILL to be raised in the ambient set: %v", errno)
+	}
+	fd, err := syscall.Socket(syscall.AF_INET, syscall.SOCK_RAW, syscall.IPPROTO_ICMP)
+	if err != nil {
+		t.Fatalf("expected CAP_NET_RAW to still be usable: %v", err)
+	}
+	syscall.Close(fd)
+
+	// Only capabilities in the bounding set can be made inheritable.
+	caps.Set(capability.INHERITABLE, capability.CAP_NET_RAW)
+	if err := caps.Apply(capability.CAPS); err == nil {
+		t.Fatal("expected CAP_NET_RAW not to be gained back")
+	}
+
+	caps.Unset(capability.INHERITABLE|capability.EFFECTIVE|capability.PERMITTED, capability.CAP_NET_RAW)
+	if err := caps.Apply(capability.CAPS); err != nil {
+		t.Fatal(err)
+	}
+	if fd, err := syscall.Socket(syscall.AF_INET, syscall.SOCK_RAW, syscall.IPPROTO_ICMP); err == nil {
+		syscall.Close(fd)
+		t.Fatal("expected CAP_NET_RAW to be unusable once dropped")
+	}
+}
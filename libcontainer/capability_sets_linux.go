@@ -0,0 +1,99 @@
+// +build linux
+
+package libcontainer
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// CapabilitySets are the capability sets of a running process.
+type CapabilitySets struct {
+	Effective   []string
+	Permitted   []string
+	Inheritable []string
+	Bounding    []string
+	Ambient     []string
+}
+
+func (c *linuxContainer) Capabilities() (*CapabilitySets, error) {
+	c.m.Lock()
+	defer c.m.Unlock()
+	if _, err := c.runningState(); err != nil {
+		return nil, err
+	}
+	f, err := os.Open(fmt.Sprintf("/proc/%d/status", c.initProcess.pid()))
+	if err != nil {
+		return nil, newSystemErrorWithCause(err, "opening status of init process")
+	}
+	defer f.Close()
+	sets, err := parseCapabilitySets(f)
+	if err != nil {
+		return nil, newSystemErrorWithCause(err, "parsing capabilities of init process")
+	}
+	return sets, nil
+}
+
+// parseCapabilitySets decodes the capability sets in the status file of a
+// process. The ambient set is left empty on kernels without one.
+func parseCapabilitySets(r io.Reader) (*CapabilitySets, error) {
+	sets := &CapabilitySets{}
+	fields := map[string]*[]string{
+		"CapEff": &sets.Effective,
+		"CapPrm": &sets.Permitted,
+		"CapInh": &sets.Inheritable,
+		"CapBnd": &sets.Bounding,
+		"CapAmb": &sets.Ambient,
+	}
+	found := 0
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		parts := strings.SplitN(s.Text(), ":", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		set, ok := fields[parts[0]]
+		if !ok {
+			continue
+		}
+		mask, err := strconv.ParseUint(strings.TrimSpace(parts[1]), 16, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s %q: %v", parts[0], parts[1], err)
+		}
+		*set = capabilityNames(mask)
+		found++
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+	if found == 0 {
+		return nil, fmt.Errorf("no capability sets found")
+	}
+	return sets, nil
+}
+
+// capabilityNames returns the names of the capabilities in mask, ordered by
+// number. Capabilities that are newer than the ones known are named by their
+// number.
+func capabilityNames(mask uint64) []string {
+	names := make(map[uint]string, len(capabilityMap))
+	for name, cap := range capabilityMap {
+		names[uint(cap)] = name
+	}
+	caps := []string{}
+	for bit := uint(0); bit < 64; bit++ {
+		if mask&(1<<bit) == 0 {
+			continue
+		}
+		name, ok := names[bit]
+		if !ok {
+			name = fmt.Sprintf("CAP_%d", bit)
+		}
+		caps = append(caps, name)
+	}
+	return caps
+}
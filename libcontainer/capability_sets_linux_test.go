@@ -0,0 +1,43 @@
+// +build linux
+
+package libcontainer
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseCapabilitySets(t *testing.T) {
+	status := `Name:	sh
+Umask:	0022
+State:	S (sleeping)
+CapInh:	0000000000000000
+CapPrm:	0000000000000401
+CapEff:	0000000000000400
+CapBnd:	8000000000000401
+CapAmb:	0000000000000000
+NoNewPrivs:	0
+`
+	sets, err := parseCapabilitySets(strings.NewReader(status))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := &CapabilitySets{
+		Effective:   []string{"CAP_NET_BIND_SERVICE"},
+		Permitted:   []string{"CAP_CHOWN", "CAP_NET_BIND_SERVICE"},
+		Inheritable: []string{},
+		Bounding:    []string{"CAP_CHOWN", "CAP_NET_BIND_SERVICE", "CAP_63"},
+		Ambient:     []string{},
+	}
+	if !reflect.DeepEqual(sets, expected) {
+		t.Fatalf("expected capability sets %+v, got %+v", expected, sets)
+	}
+
+	if _, err := parseCapabilitySets(strings.NewReader("CapEff:\tnothex\n")); err == nil {
+		t.Fatal("expected an invalid capability set to be rejected")
+	}
+	if _, err := parseCapabilitySets(strings.NewReader("Name:\tsh\n")); err == nil {
+		t.Fatal("expected a status without capability sets to be rejected")
+	}
+}
@@ -4,6 +4,8 @@ package cgroups
 
 import (
 	"fmt"
+	"sort"
+	"strings"
 
 	"github.com/opencontainers/runc/libcontainer/configs"
 )
@@ -18,7 +20,9 @@ type Manager interface {
 	// Returns the PIDs inside the cgroup set & all sub-cgroups
 	GetAllPids() ([]int, error)
 
-	// Returns statistics for the cgroup set
+	// Returns statistics for the cgroup set. If the statistics of only some
+	// of the subsystems could be read, the statistics of the others are
+	// returned along with a *StatsError.
 	GetStats() (*Stats, error)
 
 	// Toggles the freezer cgroup according with specified state
@@ -55,6 +59,88 @@ func NewNotFoundError(sub string) error {
 	}
 }
 
+// EnterPidError is returned by EnterPid when a pid could not be added to the
+// cgroups of some of the subsystems.
+type EnterPidError struct {
+	Pid int
+
+	// Errors maps each subsystem that the pid could not be added to onto the
+	// error that occurred.
+	Errors map[string]error
+
+	// Entered lists the subsystems whose cgroups the pid was added to.
+	Entered []string
+}
+
+func (e *EnterPidError) Error() string {
+	var failed []string
+	for subsystem := range e.Errors {
+		failed = append(failed, subsystem)
+	}
+	sort.Strings(failed)
+	for i, subsystem := range failed {
+		failed[i] = fmt.Sprintf("%s: %v", subsystem, e.Errors[subsystem])
+	}
+	msg := fmt.Sprintf("failed to add pid %d to cgroups: %s", e.Pid, strings.Join(failed, "; "))
+	if len(e.Entered) > 0 {
+		msg += fmt.Sprintf(" (pid was added to %s)", strings.Join(e.Entered, ", "))
+	}
+	return msg
+}
+
+// StatsError is returned by GetStats along with the statistics that could be
+// read when the statistics of some of the subsystems could not.
+type StatsError struct {
+	// Errors maps each subsystem whose statistics could not be read onto the
+	// error that occurred.
+	Errors map[string]error
+}
+
+func (e *StatsError) Error() string {
+	var failed []string
+	for subsystem := range e.Errors {
+		failed = append(failed, subsystem)
+	}
+	sort.Strings(failed)
+	for i, subsystem := range failed {
+		failed[i] = fmt.Sprintf("%s: %v", subsystem, e.Errors[subsystem])
+	}
+	return fmt.Sprintf("failed to get stats: %s", strings.Join(failed, "; "))
+}
+
+// FreezeError is returned by Freeze when the tasks of the cgroup were not
+// frozen in time. The cgroup is thawed again.
+type FreezeError struct {
+	Path string
+
+	// Blocked lists the tasks that were in uninterruptible sleep, which the
+	// freezer has to wait for.
+	Blocked []BlockedTask
+}
+
+// BlockedTask is a task in uninterruptible sleep.
+type BlockedTask struct {
+	Pid  int
+	Name string
+	// Wchan is the kernel function the task sleeps in, if known.
+	Wchan string
+}
+
+func (e *FreezeError) Error() string {
+	msg := fmt.Sprintf("timed out freezing cgroup %s", e.Path)
+	if len(e.Blocked) == 0 {
+		return msg
+	}
+	blocked := make([]string, len(e.Blocked))
+	for i, t := range e.Blocked {
+		blocked[i] = fmt.Sprintf("%d (%s)", t.Pid, t.Name)
+		if t.Wchan != "" {
+			blocked[i] += " in " + t.Wchan
+		}
+	}
+	return fmt.Sprintf("%s, tasks in uninterruptible sleep: %s", msg, strings.Join(blocked, ", "))
+}
+
 func IsNotFound(err error) bool {
 	if err == nil {
 		return false
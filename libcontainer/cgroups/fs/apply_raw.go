@@ -65,6 +65,9 @@ type Manager struct {
 	mu      sync.Mutex
 	Cgroups *configs.Cgroup
 	Paths   map[string]string
+	// transaction records the changes of a transactional Set. It is guarded
+	// by mu, which Set holds.
+	transaction *Transaction
 }
 
 // The absolute path to the root of the cgroup hierarchies.
@@ -175,15 +178,19 @@ func (m *Manager) GetStats() (*cgroups.Stats, error) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 	stats := cgroups.NewStats()
+	errs := make(map[string]error)
 	for name, path := range m.Paths {
 		sys, err := subsystems.Get(name)
 		if err == errSubsystemDoesNotExist || !cgroups.PathExists(path) {
 			continue
 		}
 		if err := sys.GetStats(path, stats); err != nil {
-			return nil, err
+			errs[name] = err
 		}
 	}
+	if len(errs) > 0 {
+		return stats, &cgroups.StatsError{Errors: errs}
+	}
 	return stats, nil
 }
 
@@ -195,9 +202,20 @@ func (m *Manager) Set(container *configs.Config) error {
 	}
 
 	paths := m.GetPaths()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !container.Cgroups.Transactional {
+		return m.set(paths, container)
+	}
+	m.transaction = &Transaction{}
+	defer func() { m.transaction = nil }()
+	return m.transaction.Finish(m.set(paths, container))
+}
+
+func (m *Manager) set(paths map[string]string, container *configs.Config) error {
 	for _, sys := range subsystems {
 		path := paths[sys.Name()]
-		if err := sys.Set(path, container.Cgroups); err != nil {
+		if err := m.transaction.Record(path, func() error { return sys.Set(path, container.Cgroups) }); err != nil {
 			return err
 		}
 	}
@@ -276,8 +294,9 @@ func (raw *cgroupData) path(subsystem string) (string, error) {
 
 	// If the cgroup name/path is absolute do not look relative to the cgroup of the init process.
 	if filepath.IsAbs(raw.innerPath) {
-		// Sometimes subsystems can be mounted together as 'cpu,cpuacct'.
-		return filepath.Join(raw.root, filepath.Base(mnt), raw.innerPath), nil
+		// Subsystems mounted together as 'cpu,cpuacct' share mnt, and the
+		// hierarchies need not be mounted side by side under raw.root.
+		return filepath.Join(mnt, raw.innerPath), nil
 	}
 
 	// Use GetOwnCgroupPath instead of GetInitCgroupPath, because the creating
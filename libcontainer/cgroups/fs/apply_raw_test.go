@@ -3,10 +3,12 @@
 package fs
 
 import (
+	"os"
 	"path/filepath"
 	"strings"
 	"testing"
 
+	"github.com/opencontainers/runc/libcontainer/cgroups"
 	"github.com/opencontainers/runc/libcontainer/configs"
 )
 
@@ -270,3 +272,111 @@ func TestInvalidAbsoluteCgroupNameAndParent(t *testing.T) {
 		t.Errorf("SECURITY: cgroup path() is outside cgroup mountpoint!")
 	}
 }
+
+func TestComountedCgroupPath(t *testing.T) {
+	cpuMnt, err := cgroups.FindCgroupMountpoint("cpu")
+	if err != nil {
+		t.Skipf("cpu cgroup is not mounted: %v", err)
+	}
+	cpuacctMnt, err := cgroups.FindCgroupMountpoint("cpuacct")
+	if err != nil || cpuMnt != cpuacctMnt {
+		t.Skip("cpu and cpuacct are not co-mounted")
+	}
+
+	data, err := getCgroupData(&configs.Cgroup{Path: "/some/path"}, 0)
+	if err != nil {
+		t.Fatalf("couldn't get cgroup data: %v", err)
+	}
+	cpuPath, err := data.path("cpu")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cpuacctPath, err := data.path("cpuacct")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := filepath.Join(cpuMnt, "/some/path"); cpuPath != expected || cpuacctPath != expected {
+		t.Fatalf("expected cpu and cpuacct to share %s, got %s and %s", expected, cpuPath, cpuacctPath)
+	}
+}
+
+func TestComountedCgroupSetAndStats(t *testing.T) {
+	helper := NewCgroupTestUtil("cpu,cpuacct", t)
+	defer helper.cleanup()
+	helper.writeFileContents(map[string]string{
+		"cpu.shares":           "1024",
+		"cpuacct.usage":        "12345",
+		"cpuacct.usage_percpu": "12345",
+		"cpuacct.stat":         "user 1\nsystem 2",
+	})
+
+	config := &configs.Config{
+		Cgroups: &configs.Cgroup{
+			Resources: &configs.Resources{
+				CpuShares: 512,
+			},
+		},
+	}
+	m := &Manager{
+		Cgroups: config.Cgroups,
+		Paths: map[string]string{
+			"cpu":     helper.CgroupPath,
+			"cpuacct": helper.CgroupPath,
+		},
+	}
+	if err := m.Set(config); err != nil {
+		t.Fatal(err)
+	}
+	shares, err := getCgroupParamUint(helper.CgroupPath, "cpu.shares")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if shares != 512 {
+		t.Fatalf("expected cpu.shares to be 512 but was %d", shares)
+	}
+
+	stats, err := m.GetStats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if usage := stats.CpuStats.CpuUsage.TotalUsage; usage != 12345 {
+		t.Fatalf("expected cpuacct usage 12345 but was %d", usage)
+	}
+}
+
+func TestPartialStats(t *testing.T) {
+	helper := NewCgroupTestUtil("memory", t)
+	defer helper.cleanup()
+	helper.writeFileContents(map[string]string{
+		"memory.stat":               memoryStatContents,
+		"memory.usage_in_bytes":     memoryUsageContents,
+		"memory.max_usage_in_bytes": memoryMaxUsageContents,
+		"memory.limit_in_bytes":     memoryLimitContents,
+		"memory.failcnt":            memoryFailcnt,
+	})
+	// The cpuacct cgroup exists but its files cannot be read, and blkio is
+	// not mounted at all.
+	cpuacct := filepath.Join(helper.tempDir, "cpuacct")
+	if err := os.Mkdir(cpuacct, 0755); err != nil {
+		t.Fatal(err)
+	}
+	m := &Manager{
+		Cgroups: &configs.Cgroup{Resources: &configs.Resources{}},
+		Paths: map[string]string{
+			"memory":  helper.CgroupPath,
+			"cpuacct": cpuacct,
+			"blkio":   filepath.Join(helper.tempDir, "blkio"),
+		},
+	}
+	stats, err := m.GetStats()
+	serr, ok := err.(*cgroups.StatsError)
+	if !ok {
+		t.Fatalf("expected a *cgroups.StatsError, got %v", err)
+	}
+	if len(serr.Errors) != 1 || serr.Errors["cpuacct"] == nil {
+		t.Fatalf("expected only cpuacct to fail, got %v", serr.Errors)
+	}
+	if stats == nil || stats.MemoryStats.Usage.Usage != 2048 || stats.MemoryStats.Usage.Limit != 8192 {
+		t.Fatalf("expected the memory stats to be returned, got %+v", stats)
+	}
+}
@@ -4,6 +4,9 @@ package fs
 
 import (
 	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 
@@ -11,6 +14,14 @@ import (
 	"github.com/opencontainers/runc/libcontainer/configs"
 )
 
+var (
+	// freezeTimeout is how long Set waits for the cgroup to reach the state.
+	freezeTimeout = 10 * time.Second
+
+	// procRoot is where the states of the tasks in the cgroup are read.
+	procRoot = "/proc"
+)
+
 type FreezerGroup struct {
 }
 
@@ -33,7 +44,7 @@ func (s *FreezerGroup) Set(path string, cgroup *configs.Cgroup) error {
 			return err
 		}
 
-		for {
+		for deadline := time.Now().Add(freezeTimeout); ; {
 			state, err := readFile(path, "freezer.state")
 			if err != nil {
 				return err
@@ -41,6 +52,17 @@ func (s *FreezerGroup) Set(path string, cgroup *configs.Cgroup) error {
 			if strings.TrimSpace(state) == string(cgroup.Resources.Freezer) {
 				break
 			}
+			if time.Now().After(deadline) {
+				if cgroup.Resources.Freezer != configs.Frozen {
+					return fmt.Errorf("timed out setting freezer.state of %s to %s", path, cgroup.Resources.Freezer)
+				}
+				// Look for the blocked tasks before thawing wakes them.
+				ferr := &cgroups.FreezeError{Path: path, Blocked: blockedTasks(path)}
+				if err := writeFile(path, "freezer.state", string(configs.Thawed)); err != nil {
+					return fmt.Errorf("%v, thawing failed: %v", ferr, err)
+				}
+				return ferr
+			}
 			time.Sleep(1 * time.Millisecond)
 		}
 	case configs.Undefined:
@@ -59,3 +81,47 @@ func (s *FreezerGroup) Remove(d *cgroupData) error {
 func (s *FreezerGroup) GetStats(path string, stats *cgroups.Stats) error {
 	return nil
 }
+
+// blockedTasks returns the tasks in the cgroup that are in uninterruptible
+// sleep. Tasks that exit meanwhile are left out.
+func blockedTasks(path string) []cgroups.BlockedTask {
+	data, err := readFile(path, "tasks")
+	if err != nil {
+		return nil
+	}
+	var blocked []cgroups.BlockedTask
+	for _, field := range strings.Fields(data) {
+		pid, err := strconv.Atoi(field)
+		if err != nil {
+			continue
+		}
+		stat, err := ioutil.ReadFile(filepath.Join(procRoot, field, "stat"))
+		if err != nil {
+			continue
+		}
+		name, state, ok := parseTaskStat(string(stat))
+		if !ok || state != "D" {
+			continue
+		}
+		task := cgroups.BlockedTask{Pid: pid, Name: name}
+		if wchan, err := ioutil.ReadFile(filepath.Join(procRoot, field, "wchan")); err == nil && string(wchan) != "0" {
+			task.Wchan = string(wchan)
+		}
+		blocked = append(blocked, task)
+	}
+	return blocked
+}
+
+// parseTaskStat returns the name and state of a task from its stat file. The
+// name is in parentheses and may contain spaces and parentheses itself.
+func parseTaskStat(stat string) (string, string, bool) {
+	start, end := strings.IndexByte(stat, '('), strings.LastIndexByte(stat, ')')
+	if start < 0 || end < start {
+		return "", "", false
+	}
+	fields := strings.Fields(stat[end+1:])
+	if len(fields) == 0 {
+		return "", "", false
+	}
+	return stat[start+1 : end], fields[0], true
+}
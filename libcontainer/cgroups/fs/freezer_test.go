@@ -3,8 +3,14 @@
 package fs
 
 import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/opencontainers/runc/libcontainer/cgroups"
 	"github.com/opencontainers/runc/libcontainer/configs"
 )
 
@@ -45,3 +51,55 @@ func TestFreezerSetInvalidState(t *testing.T) {
 		t.Fatal("Failed to return invalid argument error")
 	}
 }
+
+func TestFreezerSetTimeout(t *testing.T) {
+	helper := NewCgroupTestUtil("freezer", t)
+	defer helper.cleanup()
+
+	// The state never reads back as frozen, like with a task that blocks the
+	// freezer.
+	if err := os.Symlink("/dev/null", filepath.Join(helper.CgroupPath, "freezer.state")); err != nil {
+		t.Fatal(err)
+	}
+	helper.writeFileContents(map[string]string{
+		"tasks": "100\n101\n102\n",
+	})
+	proc, err := ioutil.TempDir("", "proc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(proc)
+	for pid, stat := range map[string]string{
+		"100": "100 (sh) S 1 100 100 0 -1",
+		"101": "101 (stuck (io) task) D 100 100 100 0 -1",
+	} {
+		if err := os.Mkdir(filepath.Join(proc, pid), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(filepath.Join(proc, pid, "stat"), []byte(stat), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := ioutil.WriteFile(filepath.Join(proc, "101", "wchan"), []byte("io_schedule"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	defer func(timeout time.Duration, root string) {
+		freezeTimeout, procRoot = timeout, root
+	}(freezeTimeout, procRoot)
+	freezeTimeout, procRoot = 10*time.Millisecond, proc
+
+	helper.CgroupData.config.Resources.Freezer = configs.Frozen
+	freezer := &FreezerGroup{}
+	err = freezer.Set(helper.CgroupPath, helper.CgroupData.config)
+	ferr, ok := err.(*cgroups.FreezeError)
+	if !ok {
+		t.Fatalf("expected a FreezeError, got %v", err)
+	}
+	expected := cgroups.BlockedTask{Pid: 101, Name: "stuck (io) task", Wchan: "io_schedule"}
+	if len(ferr.Blocked) != 1 || ferr.Blocked[0] != expected {
+		t.Fatalf("expected blocked tasks %+v, got %+v", expected, ferr.Blocked)
+	}
+	if msg := ferr.Error(); !strings.Contains(msg, "101 (stuck (io) task) in io_schedule") {
+		t.Fatalf("expected the blocked task to be named in %q", msg)
+	}
+}
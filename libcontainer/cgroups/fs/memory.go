@@ -6,6 +6,7 @@ import (
 	"bufio"
 	"fmt"
 	"io/ioutil"
+	"math"
 	"os"
 	"path/filepath"
 	"strconv"
@@ -154,7 +155,30 @@ func setMemoryAndSwap(path string, cgroup *configs.Cgroup) error {
 		}
 	}
 
-	return nil
+	return checkMemoryLimit(path, cgroup.Resources.Memory)
+}
+
+// checkMemoryLimit reads back the memory limit of the cgroup at path to make
+// sure that the requested limit took effect.
+func checkMemoryLimit(path string, requested uint64) error {
+	if requested == 0 || requested == math.MaxUint64 {
+		return nil
+	}
+	effective, err := getCgroupParamUint(path, cgroupMemoryLimit)
+	if err != nil {
+		return err
+	}
+	return compareMemoryLimit(requested, effective, uint64(os.Getpagesize()))
+}
+
+// compareMemoryLimit returns an error if the effective memory limit differs
+// from the requested one by more than the kernel rounding it down to a
+// multiple of the page size.
+func compareMemoryLimit(requested, effective, pageSize uint64) error {
+	if effective <= requested && requested-effective < pageSize {
+		return nil
+	}
+	return fmt.Errorf("memory limit of %d bytes was not applied, the effective limit is %d bytes", requested, effective)
 }
 
 func (s *MemoryGroup) Set(path string, cgroup *configs.Cgroup) error {
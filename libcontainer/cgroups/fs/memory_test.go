@@ -3,10 +3,13 @@
 package fs
 
 import (
+	"os"
+	"path/filepath"
 	"strconv"
 	"testing"
 
 	"github.com/opencontainers/runc/libcontainer/cgroups"
+	"github.com/opencontainers/runc/libcontainer/configs"
 )
 
 const (
@@ -451,3 +454,52 @@ func TestMemorySetOomControl(t *testing.T) {
 		t.Fatalf("Got the wrong value, set memory.oom_control failed.")
 	}
 }
+
+func TestCompareMemoryLimit(t *testing.T) {
+	for _, test := range []struct {
+		requested, effective uint64
+		valid                bool
+	}{
+		{8192, 8192, true},
+		{8193, 8192, true},
+		{12287, 8192, true},
+		{12288, 8192, false},
+		{8192, 12288, false},
+		{1 << 40, 1 << 30, false},
+	} {
+		err := compareMemoryLimit(test.requested, test.effective, 4096)
+		if test.valid && err != nil {
+			t.Errorf("requested %d, effective %d: %v", test.requested, test.effective, err)
+		}
+		if !test.valid && err == nil {
+			t.Errorf("requested %d, effective %d: expected an error", test.requested, test.effective)
+		}
+	}
+}
+
+func TestMemorySetMemoryNotPageAligned(t *testing.T) {
+	root, err := getCgroupRoot()
+	if err != nil {
+		t.Skip(err)
+	}
+	path := filepath.Join(root, "memory", "runc-test-memory-limit")
+	if err := os.Mkdir(path, 0755); err != nil {
+		t.Skipf("cannot create a memory cgroup: %v", err)
+	}
+	defer os.Remove(path)
+
+	pageSize := uint64(os.Getpagesize())
+	requested := 64<<20 + pageSize/2
+	cgroup := &configs.Cgroup{Resources: &configs.Resources{Memory: requested}}
+	memory := &MemoryGroup{}
+	if err := memory.Set(path, cgroup); err != nil {
+		t.Fatal(err)
+	}
+	stats := cgroups.NewStats()
+	if err := memory.GetStats(path, stats); err != nil {
+		t.Fatal(err)
+	}
+	if effective := stats.MemoryStats.Usage.Limit; effective != 64<<20 {
+		t.Fatalf("expected the effective limit to be rounded down to %d but was %d", 64<<20, effective)
+	}
+}
@@ -0,0 +1,115 @@
+// +build linux
+
+package fs
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Transaction records the content that the files of cgroup directories had
+// before an update changed them, so that a failed update can write it back.
+// A nil Transaction records nothing.
+type Transaction struct {
+	priors []prior
+}
+
+type prior struct {
+	dir, file, data string
+}
+
+// Record runs set, which changes the cgroup files below dir, and remembers
+// the prior content of every file whose content set changed. Files that
+// cannot be read back, such as devices.allow, are not recorded.
+func (t *Transaction) Record(dir string, set func() error) error {
+	if t == nil || dir == "" {
+		return set()
+	}
+	before := restorableFiles(dir)
+	err := set()
+	after := restorableFiles(dir)
+	var files []string
+	for file := range before {
+		files = append(files, file)
+	}
+	sort.Strings(files)
+	for _, file := range files {
+		if data, ok := after[file]; ok && data != before[file] {
+			t.priors = append(t.priors, prior{dir: dir, file: file, data: before[file]})
+		}
+	}
+	return err
+}
+
+// Finish writes the recorded content back if err, the error of the update,
+// is not nil, and returns err.
+func (t *Transaction) Finish(err error) error {
+	if err == nil || t == nil {
+		return err
+	}
+	if rerr := t.rollback(); rerr != nil {
+		return fmt.Errorf("%v (restoring prior values: %v)", err, rerr)
+	}
+	return err
+}
+
+// rollback writes the recorded content back, the last recorded first. The
+// kernel rejects some values until others are restored, such as a memory
+// limit above the memory+swap limit, so rollback retries the files it
+// failed to write for as long as it restores any.
+func (t *Transaction) rollback() error {
+	pending := make([]prior, 0, len(t.priors))
+	for i := len(t.priors) - 1; i >= 0; i-- {
+		pending = append(pending, t.priors[i])
+	}
+	var errs []string
+	for len(pending) > 0 {
+		var failed []prior
+		errs = nil
+		for _, p := range pending {
+			if err := ioutil.WriteFile(filepath.Join(p.dir, p.file), []byte(p.data), 0700); err != nil {
+				failed = append(failed, p)
+				errs = append(errs, fmt.Sprintf("%s: %v", p.file, err))
+			}
+		}
+		if len(failed) == len(pending) {
+			break
+		}
+		pending = failed
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("%s", strings.Join(errs, ", "))
+	}
+	return nil
+}
+
+// restorableFiles returns the content of the files below dir that can be
+// read and written back. Process lists and counters that the kernel only
+// lets be reset are left out.
+func restorableFiles(dir string) map[string]string {
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+	files := map[string]string{}
+	for _, info := range infos {
+		name := info.Name()
+		if !info.Mode().IsRegular() || info.Mode().Perm()&0600 != 0600 {
+			continue
+		}
+		switch {
+		case name == "cgroup.procs", name == "tasks", name == "cgroup.event_control",
+			strings.HasSuffix(name, "max_usage_in_bytes"), strings.HasSuffix(name, "failcnt"):
+			continue
+		}
+		data, err := readFile(dir, name)
+		if err != nil {
+			continue
+		}
+		files[name] = strings.TrimSpace(data)
+	}
+	return files
+}
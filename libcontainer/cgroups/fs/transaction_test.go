@@ -0,0 +1,119 @@
+// +build linux
+
+package fs
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTransactionRestoresPriorValues(t *testing.T) {
+	helper := NewCgroupTestUtil("memory", t)
+	defer helper.cleanup()
+	helper.writeFileContents(map[string]string{
+		"memory.limit_in_bytes":      "8192",
+		"memory.soft_limit_in_bytes": "4096",
+	})
+
+	tx := &Transaction{}
+	err := tx.Finish(tx.Record(helper.CgroupPath, func() error {
+		if err := writeFile(helper.CgroupPath, "memory.limit_in_bytes", "16384"); err != nil {
+			return err
+		}
+		if err := writeFile(helper.CgroupPath, "memory.limit_in_bytes", "32768"); err != nil {
+			return err
+		}
+		if err := writeFile(helper.CgroupPath, "memory.soft_limit_in_bytes", "8192"); err != nil {
+			return err
+		}
+		return writeFile("", "memory.swappiness", "10")
+	}))
+	if err == nil || !strings.Contains(err.Error(), "memory.swappiness") {
+		t.Fatalf("expected the update to fail writing memory.swappiness but received %v", err)
+	}
+	for file, expected := range map[string]string{
+		"memory.limit_in_bytes":      "8192",
+		"memory.soft_limit_in_bytes": "4096",
+	} {
+		value, err := getCgroupParamString(helper.CgroupPath, file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if value != expected {
+			t.Errorf("expected %s to be restored to %s but was %s", file, expected, value)
+		}
+	}
+}
+
+func TestTransactionKeepsValuesOnSuccess(t *testing.T) {
+	helper := NewCgroupTestUtil("memory", t)
+	defer helper.cleanup()
+	helper.writeFileContents(map[string]string{"memory.limit_in_bytes": "8192"})
+
+	tx := &Transaction{}
+	if err := tx.Finish(tx.Record(helper.CgroupPath, func() error {
+		return writeFile(helper.CgroupPath, "memory.limit_in_bytes", "16384")
+	})); err != nil {
+		t.Fatal(err)
+	}
+	value, err := getCgroupParamString(helper.CgroupPath, "memory.limit_in_bytes")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if value != "16384" {
+		t.Fatalf("expected memory.limit_in_bytes to be 16384 but was %s", value)
+	}
+}
+
+func TestTransactionSkipsCounters(t *testing.T) {
+	helper := NewCgroupTestUtil("memory", t)
+	defer helper.cleanup()
+	helper.writeFileContents(map[string]string{
+		"memory.limit_in_bytes":     "8192",
+		"memory.max_usage_in_bytes": "4096",
+		"memory.failcnt":            "0",
+	})
+
+	tx := &Transaction{}
+	err := tx.Finish(tx.Record(helper.CgroupPath, func() error {
+		if err := writeFile(helper.CgroupPath, "memory.limit_in_bytes", "16384"); err != nil {
+			return err
+		}
+		// The workload goes on using memory during the update.
+		helper.writeFileContents(map[string]string{
+			"memory.max_usage_in_bytes": "16384",
+			"memory.failcnt":            "1",
+		})
+		return writeFile("", "memory.swappiness", "10")
+	}))
+	if err == nil {
+		t.Fatal("expected the update to fail")
+	}
+	for file, expected := range map[string]string{
+		"memory.limit_in_bytes":     "8192",
+		"memory.max_usage_in_bytes": "16384",
+		"memory.failcnt":            "1",
+	} {
+		value, err := getCgroupParamString(helper.CgroupPath, file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if value != expected {
+			t.Errorf("expected %s to be %s but was %s", file, expected, value)
+		}
+	}
+}
+
+func TestNilTransactionRecordsNothing(t *testing.T) {
+	var tx *Transaction
+	called := false
+	if err := tx.Record("/nonexistent", func() error {
+		called = true
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if !called {
+		t.Fatal("expected set to be run")
+	}
+}
@@ -23,6 +23,9 @@ type Manager struct {
 	mu      sync.Mutex
 	Cgroups *configs.Cgroup
 	Paths   map[string]string
+	// transaction records the changes of a transactional Set. It is guarded
+	// by mu, which Set holds.
+	transaction *fs.Transaction
 }
 
 type subsystem interface {
@@ -485,15 +488,19 @@ func (m *Manager) GetStats() (*cgroups.Stats, error) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 	stats := cgroups.NewStats()
+	errs := make(map[string]error)
 	for name, path := range m.Paths {
 		sys, err := subsystems.Get(name)
 		if err == errSubsystemDoesNotExist || !cgroups.PathExists(path) {
 			continue
 		}
 		if err := sys.GetStats(path, stats); err != nil {
-			return nil, err
+			errs[name] = err
 		}
 	}
+	if len(errs) > 0 {
+		return stats, &cgroups.StatsError{Errors: errs}
+	}
 
 	return stats, nil
 }
@@ -504,6 +511,17 @@ func (m *Manager) Set(container *configs.Config) error {
 	if m.Cgroups.Paths != nil {
 		return nil
 	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !container.Cgroups.Transactional {
+		return m.set(container)
+	}
+	m.transaction = &fs.Transaction{}
+	defer func() { m.transaction = nil }()
+	return m.transaction.Finish(m.set(container))
+}
+
+func (m *Manager) set(container *configs.Config) error {
 	for _, sys := range subsystems {
 		// Get the subsystem path, but don't error out for not found cgroups.
 		path, err := getSubsystemPath(container.Cgroups, sys.Name())
@@ -511,7 +529,7 @@ func (m *Manager) Set(container *configs.Config) error {
 			return err
 		}
 
-		if err := sys.Set(path, container.Cgroups); err != nil {
+		if err := m.transaction.Record(path, func() error { return sys.Set(path, container.Cgroups) }); err != nil {
 			return err
 		}
 	}
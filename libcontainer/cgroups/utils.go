@@ -9,8 +9,11 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
+	"syscall"
 	"time"
 
 	"github.com/docker/go-units"
@@ -27,34 +30,113 @@ func FindCgroupMountpoint(subsystem string) (string, error) {
 	return mnt, err
 }
 
+// FindCgroupMountpointAndRoot returns the mountpoint and the root of the
+// hierarchy the subsystem is attached to, as found in /proc/self/mountinfo.
+// The result of parsing mountinfo is cached for the mount namespace of the
+// caller, and refreshed when the caller is in another one or a subsystem
+// cannot be found in it.
 func FindCgroupMountpointAndRoot(subsystem string) (string, string, error) {
-	// We are not using mount.GetMounts() because it's super-inefficient,
-	// parsing it directly sped up x10 times because of not using Sscanf.
-	// It was one of two major performance drawbacks in container start.
 	if !isSubsystemAvailable(subsystem) {
 		return "", "", NewNotFoundError(subsystem)
 	}
-	f, err := os.Open("/proc/self/mountinfo")
+	ns, err := mountNamespace()
 	if err != nil {
 		return "", "", err
 	}
+	subsystemMountsLock.Lock()
+	defer subsystemMountsLock.Unlock()
+	m, ok := subsystemMounts[subsystem]
+	if !ok || ns != subsystemMountsNs {
+		mounts, err := readSubsystemMounts()
+		if err != nil {
+			return "", "", err
+		}
+		subsystemMounts, subsystemMountsNs = mounts, ns
+		m, ok = mounts[subsystem]
+	}
+	if !ok {
+		return "", "", NewNotFoundError(subsystem)
+	}
+	return m.Mountpoint, m.Root, nil
+}
+
+var (
+	subsystemMountsLock sync.Mutex
+	// subsystemMounts caches the cgroup v1 mount of every subsystem, as seen
+	// in the mount namespace with the inode subsystemMountsNs.
+	subsystemMounts   map[string]Mount
+	subsystemMountsNs uint64
+)
+
+// mountNamespace returns the inode of the mount namespace of the caller.
+func mountNamespace() (uint64, error) {
+	fi, err := os.Stat("/proc/self/ns/mnt")
+	if err != nil {
+		return 0, err
+	}
+	return fi.Sys().(*syscall.Stat_t).Ino, nil
+}
+
+func readSubsystemMounts() (map[string]Mount, error) {
+	// We are not using mount.GetMounts() because it's super-inefficient,
+	// parsing it directly sped up x10 times because of not using Sscanf.
+	// It was one of two major performance drawbacks in container start.
+	f, err := os.Open("/proc/self/mountinfo")
+	if err != nil {
+		return nil, err
+	}
 	defer f.Close()
+	return parseSubsystemMounts(f)
+}
 
-	scanner := bufio.NewScanner(f)
+// cgroupMountOptions are super options of cgroup v1 mounts that do not name
+// a subsystem.
+var cgroupMountOptions = map[string]bool{
+	"rw":             true,
+	"ro":             true,
+	"noprefix":       true,
+	"clone_children": true,
+	"xattr":          true,
+	"cpuset_v2_mode": true,
+}
+
+// parseSubsystemMounts maps every subsystem found in the mountinfo mi to the
+// first cgroup v1 mount it is attached to. Subsystems that are co-mounted,
+// such as cpu and cpuacct, map to the same Mount.
+func parseSubsystemMounts(mi io.Reader) (map[string]Mount, error) {
+	mounts := make(map[string]Mount)
+	scanner := bufio.NewScanner(mi)
 	for scanner.Scan() {
 		txt := scanner.Text()
+		sepIdx := strings.Index(txt, " - ")
+		if sepIdx == -1 {
+			return nil, fmt.Errorf("invalid mountinfo format")
+		}
+		post := strings.Split(txt[sepIdx+3:], " ")
+		if post[0] != "cgroup" || len(post) < 3 {
+			continue
+		}
 		fields := strings.Split(txt, " ")
-		for _, opt := range strings.Split(fields[len(fields)-1], ",") {
-			if opt == subsystem {
-				return fields[4], fields[3], nil
+		m := Mount{
+			Mountpoint: fields[4],
+			Root:       fields[3],
+		}
+		for _, opt := range strings.Split(post[2], ",") {
+			if cgroupMountOptions[opt] || (strings.Contains(opt, "=") && !strings.HasPrefix(opt, cgroupNamePrefix)) {
+				continue
+			}
+			m.Subsystems = append(m.Subsystems, opt)
+		}
+		for _, ss := range m.Subsystems {
+			if _, ok := mounts[ss]; !ok {
+				mounts[ss] = m
 			}
 		}
 	}
 	if err := scanner.Err(); err != nil {
-		return "", "", err
+		return nil, err
 	}
-
-	return "", "", NewNotFoundError(subsystem)
+	return mounts, nil
 }
 
 func isSubsystemAvailable(subsystem string) bool {
@@ -341,10 +423,55 @@ func PathExists(path string) bool {
 	return true
 }
 
+// EnterPid adds pid to the cgroup of each subsystem in cgroupPaths. It does
+// not stop at the first failure, so that the pid ends up in as many cgroups
+// as possible, and returns an *EnterPidError naming the subsystems that
+// failed.
 func EnterPid(cgroupPaths map[string]string, pid int) error {
+	subsystems := make([]string, 0, len(cgroupPaths))
+	for subsystem := range cgroupPaths {
+		subsystems = append(subsystems, subsystem)
+	}
+	sort.Strings(subsystems)
+	var entered []string
+	errs := make(map[string]error)
+	for _, subsystem := range subsystems {
+		path := cgroupPaths[subsystem]
+		if !PathExists(path) {
+			continue
+		}
+		if err := WriteCgroupProc(path, pid); err != nil {
+			errs[subsystem] = err
+			continue
+		}
+		entered = append(entered, subsystem)
+	}
+	if len(errs) > 0 {
+		return &EnterPidError{Pid: pid, Errors: errs, Entered: entered}
+	}
+	return nil
+}
+
+// delegatedFiles are the files of a cgroup that have to be writable for the
+// owner of a delegated cgroup to move processes into it. On a cgroup2
+// hierarchy the owner also enables controllers for its child cgroups and
+// manages threaded subtrees, which the kernel allows delegates to do.
+var delegatedFiles = []string{"cgroup.procs", "tasks", "cgroup.threads", "cgroup.subtree_control"}
+
+// Delegate hands the cgroups at the provided paths over to uid and gid, so
+// that they can create child cgroups and move their processes between them.
+// The limits of the cgroups themselves are left owned by root, which keeps
+// them out of the delegate's control.
+func Delegate(cgroupPaths map[string]string, uid, gid int) error {
 	for _, path := range cgroupPaths {
-		if PathExists(path) {
-			if err := WriteCgroupProc(path, pid); err != nil {
+		if !PathExists(path) {
+			continue
+		}
+		if err := os.Chown(path, uid, gid); err != nil {
+			return err
+		}
+		for _, file := range delegatedFiles {
+			if err := os.Chown(filepath.Join(path, file), uid, gid); err != nil && !os.IsNotExist(err) {
 				return err
 			}
 		}
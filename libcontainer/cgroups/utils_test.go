@@ -5,8 +5,12 @@ package cgroups
 import (
 	"bytes"
 	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"reflect"
 	"strings"
+	"syscall"
 	"testing"
 )
 
@@ -300,3 +304,141 @@ func TestIgnoreCgroup2Mount(t *testing.T) {
 		}
 	}
 }
+
+const unusualMountinfo = `18 64 0:18 / /sys rw,nosuid,nodev,noexec,relatime shared:6 - sysfs sysfs rw
+25 18 0:21 / /opt/cg/cpu,cpuacct rw,nosuid,nodev,noexec,relatime shared:9 - cgroup cgroup rw,cpu,cpuacct
+26 18 0:22 / /opt/cg/mem rw,nosuid,nodev,noexec,relatime shared:10 - cgroup memory rw,memory,clone_children
+27 18 0:23 /nested /cgroups/devices rw,nosuid,nodev,noexec,relatime shared:11 - cgroup cgroup rw,devices
+28 18 0:24 / /cgroups/systemd rw,nosuid,nodev,noexec,relatime shared:12 - cgroup cgroup rw,xattr,release_agent=/lib/systemd/systemd-cgroups-agent,name=systemd
+29 18 0:25 / /opt/cg/mem2 rw,nosuid,nodev,noexec,relatime shared:13 - cgroup memory rw,memory
+30 18 0:26 / /sys/fs/cgroup/unified rw,nosuid,nodev,noexec,relatime shared:14 - cgroup2 cgroup rw`
+
+func TestFindCgroupMountpointCache(t *testing.T) {
+	if !isSubsystemAvailable("memory") {
+		t.Skip("memory cgroup is not available")
+	}
+	ns, err := mountNamespace()
+	if err != nil {
+		t.Fatal(err)
+	}
+	subsystemMountsLock.Lock()
+	subsystemMounts = map[string]Mount{"memory": {Mountpoint: "/cached/memory", Root: "/"}}
+	subsystemMountsNs = ns
+	subsystemMountsLock.Unlock()
+	defer func() {
+		subsystemMountsLock.Lock()
+		subsystemMounts = nil
+		subsystemMountsLock.Unlock()
+	}()
+
+	if mnt, err := FindCgroupMountpoint("memory"); err != nil || mnt != "/cached/memory" {
+		t.Fatalf("expected the cached mountpoint, got %q, %v", mnt, err)
+	}
+	// The cache of another mount namespace is not used.
+	subsystemMountsLock.Lock()
+	subsystemMountsNs = ns + 1
+	subsystemMountsLock.Unlock()
+	if mnt, _ := FindCgroupMountpoint("memory"); mnt == "/cached/memory" {
+		t.Fatal("expected the mounts to be read again in another mount namespace")
+	}
+}
+
+func TestParseSubsystemMountsUnusualPaths(t *testing.T) {
+	mounts, err := parseSubsystemMounts(bytes.NewBufferString(unusualMountinfo))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]Mount{
+		"cpu":          {Mountpoint: "/opt/cg/cpu,cpuacct", Root: "/", Subsystems: []string{"cpu", "cpuacct"}},
+		"cpuacct":      {Mountpoint: "/opt/cg/cpu,cpuacct", Root: "/", Subsystems: []string{"cpu", "cpuacct"}},
+		"memory":       {Mountpoint: "/opt/cg/mem", Root: "/", Subsystems: []string{"memory"}},
+		"devices":      {Mountpoint: "/cgroups/devices", Root: "/nested", Subsystems: []string{"devices"}},
+		"name=systemd": {Mountpoint: "/cgroups/systemd", Root: "/", Subsystems: []string{"name=systemd"}},
+	}
+	if !reflect.DeepEqual(mounts, expected) {
+		t.Fatalf("expected mounts %v but received %v", expected, mounts)
+	}
+}
+
+func TestDelegate(t *testing.T) {
+	if os.Getuid() != 0 {
+		t.Skip("changing ownership requires root")
+	}
+	root, err := ioutil.TempDir("", "cgroup-delegate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	for _, file := range []string{"cgroup.procs", "tasks", "cgroup.threads", "cgroup.subtree_control", "memory.limit_in_bytes", "memory.max"} {
+		if err := ioutil.WriteFile(filepath.Join(root, file), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	paths := map[string]string{
+		"memory":  root,
+		"missing": filepath.Join(root, "missing"),
+	}
+	if err := Delegate(paths, 1000, 1000); err != nil {
+		t.Fatal(err)
+	}
+	for file, owner := range map[string]uint32{
+		"":                       1000,
+		"cgroup.procs":           1000,
+		"tasks":                  1000,
+		"cgroup.threads":         1000,
+		"cgroup.subtree_control": 1000,
+		"memory.limit_in_bytes":  0,
+		"memory.max":             0,
+	} {
+		fi, err := os.Stat(filepath.Join(root, file))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if uid := fi.Sys().(*syscall.Stat_t).Uid; uid != owner {
+			t.Errorf("expected %q to be owned by %d but was owned by %d", file, owner, uid)
+		}
+	}
+}
+
+func TestEnterPidAttemptsAllSubsystems(t *testing.T) {
+	root, err := ioutil.TempDir("", "cgroup-enter")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	paths := make(map[string]string)
+	for _, subsystem := range []string{"cpu", "devices", "memory"} {
+		paths[subsystem] = filepath.Join(root, subsystem)
+		if err := os.Mkdir(paths[subsystem], 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// A directory in place of cgroup.procs makes writing the pid fail.
+	if err := os.Mkdir(filepath.Join(paths["devices"], CgroupProcesses), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	err = EnterPid(paths, 1234)
+	enterErr, ok := err.(*EnterPidError)
+	if !ok {
+		t.Fatalf("expected an *EnterPidError but got %v", err)
+	}
+	if _, ok := enterErr.Errors["devices"]; !ok || len(enterErr.Errors) != 1 {
+		t.Fatalf("expected only the devices subsystem to fail, got %v", enterErr.Errors)
+	}
+	if !reflect.DeepEqual(enterErr.Entered, []string{"cpu", "memory"}) {
+		t.Fatalf("expected the pid to be added to cpu and memory, got %v", enterErr.Entered)
+	}
+	if !strings.Contains(err.Error(), "devices:") {
+		t.Fatalf("expected the error to name the devices subsystem: %v", err)
+	}
+	for _, subsystem := range []string{"cpu", "memory"} {
+		data, err := ioutil.ReadFile(filepath.Join(paths[subsystem], CgroupProcesses))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != "1234" {
+			t.Fatalf("expected %s to contain the pid but got %q", subsystem, data)
+		}
+	}
+}
@@ -0,0 +1,50 @@
+// +build linux freebsd
+
+package configs
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"text/template"
+)
+
+// CgroupPathData is what the PathTemplate of a cgroup config is expanded
+// with.
+type CgroupPathData struct {
+	// ID is the id of the container.
+	ID string
+
+	// Labels maps the keys of the container's labels onto their values.
+	Labels map[string]string
+}
+
+// ExpandPathTemplate returns the cgroup path that the PathTemplate of c
+// expands to for the container with the id and labels, such as
+// /machine.slice/{{.ID}} or /tenants/{{.Labels.tenant}}/{{.ID}}. A label
+// that the container does not have is an error, and so is a path that is
+// not clean, names the root cgroup or leads out of it.
+func (c *Cgroup) ExpandPathTemplate(id string, labels []string) (string, error) {
+	tmpl, err := template.New("cgroup path").Option("missingkey=error").Parse(c.PathTemplate)
+	if err != nil {
+		return "", fmt.Errorf("parsing cgroup path template: %v", err)
+	}
+	data := CgroupPathData{ID: id, Labels: make(map[string]string, len(labels))}
+	for _, l := range labels {
+		parts := strings.SplitN(l, "=", 2)
+		if len(parts) == 2 {
+			data.Labels[parts[0]] = parts[1]
+		}
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("expanding cgroup path template: %v", err)
+	}
+	path := buf.String()
+	if filepath.Clean(path) != path || path == "/" || path == "." || path == ".." ||
+		strings.HasPrefix(path, "../") || strings.ContainsAny(path, "\n\x00") {
+		return "", fmt.Errorf("cgroup path template %q expands to an invalid path %q", c.PathTemplate, path)
+	}
+	return path, nil
+}
@@ -0,0 +1,37 @@
+// +build linux freebsd
+
+package configs
+
+import "testing"
+
+func TestExpandPathTemplate(t *testing.T) {
+	labels := []string{"bundle=/bundle", "tenant=blue"}
+	for _, test := range []struct {
+		template string
+		path     string
+		valid    bool
+	}{
+		{"/machine.slice/{{.ID}}", "/machine.slice/ctr", true},
+		{"tenants/{{.Labels.tenant}}/{{.ID}}", "tenants/blue/ctr", true},
+		{"/tenants/{{.Labels.missing}}/{{.ID}}", "", false},
+		{"/machine.slice/{{.ID", "", false},
+		{"/machine.slice//{{.ID}}", "", false},
+		{"../{{.ID}}", "", false},
+		{"/{{.Labels.bundle}}/..", "", false},
+		{"/", "", false},
+	} {
+		c := &Cgroup{PathTemplate: test.template}
+		path, err := c.ExpandPathTemplate("ctr", labels)
+		if !test.valid {
+			if err == nil {
+				t.Errorf("expected %q to be rejected, got %q", test.template, path)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("expanding %q: %v", test.template, err)
+		} else if path != test.path {
+			t.Errorf("expected %q to expand to %q, got %q", test.template, test.path, path)
+		}
+	}
+}
@@ -22,6 +22,10 @@ type Cgroup struct {
 	// The path is assumed to be relative to the host system cgroup mountpoint.
 	Path string `json:"path"`
 
+	// PathTemplate is expanded into Path when the container is created, see
+	// ExpandPathTemplate. It cannot be combined with Path, Name or Parent.
+	PathTemplate string `json:"path_template,omitempty"`
+
 	// ScopePrefix describes prefix for the scope name
 	ScopePrefix string `json:"scope_prefix"`
 
@@ -29,6 +33,17 @@ type Cgroup struct {
 	// This takes precedence over Path.
 	Paths map[string]string
 
+	// Delegate hands the container's cgroups over to the container's root
+	// user, so that a runtime inside the container can create and manage
+	// child cgroups under them. The cgroup mount of the container has to be
+	// writable for this to be of use.
+	Delegate bool `json:"delegate,omitempty"`
+
+	// Transactional makes an update of the cgroups that fails partway write
+	// back the values it changed, so that the container keeps its prior
+	// limits.
+	Transactional bool `json:"transactional,omitempty"`
+
 	// Resources contains various cgroups settings to apply
 	*Resources
 }
@@ -47,6 +62,11 @@ type Resources struct {
 	// Memory limit (in bytes)
 	Memory uint64 `json:"memory"`
 
+	// MemoryPercent sets Memory to this percentage of the host's memory
+	// when the container is started, and is cleared then. It cannot be
+	// combined with Memory.
+	MemoryPercent float64 `json:"memory_percent,omitempty"`
+
 	// Memory reservation or soft_limit (in bytes)
 	MemoryReservation uint64 `json:"memory_reservation"`
 
@@ -68,6 +88,11 @@ type Resources struct {
 	// CPU period to be used for hardcapping (in usecs). 0 to use system default.
 	CpuPeriod uint64 `json:"cpu_period"`
 
+	// CpuPercent sets CpuQuota and CpuPeriod to allow this percentage of the
+	// time of the host's online CPUs when the container is started, and is
+	// cleared then. It cannot be combined with CpuQuota or CpuPeriod.
+	CpuPercent float64 `json:"cpu_percent,omitempty"`
+
 	// How many time CPU will use in realtime scheduling (in usecs).
 	CpuRtRuntime int64 `json:"cpu_rt_quota"`
 
@@ -110,7 +135,10 @@ type Resources struct {
 	// Hugetlb limit (in bytes)
 	HugetlbLimit []*HugepageLimit `json:"hugetlb_limit"`
 
-	// Whether to disable OOM Killer
+	// Whether to disable OOM Killer. When the memory limit is reached the
+	// tasks in the cgroup are paused instead of being killed, so the caller
+	// must watch for OOM notifications (see NotifyOOM) and react, otherwise
+	// the container hangs until memory is freed or the limit is raised.
 	OomKillDisable bool `json:"oom_kill_disable"`
 
 	// Tuning swappiness behaviour per cgroup
@@ -17,6 +17,93 @@ type Rlimit struct {
 	Soft uint64 `json:"soft"`
 }
 
+// RootfsOwnership is how the rootfs is made accessible to the root user of a
+// container with a user namespace.
+type RootfsOwnership string
+
+const (
+	// RootfsChown changes the owner of the rootfs directory to the root user
+	// of the container.
+	RootfsChown RootfsOwnership = "chown"
+
+	// RootfsChownRecursive shifts the owner of every file in the rootfs to
+	// the host ids that the ids it has map to. Files that are owned by host
+	// ids of the mappings already are left alone.
+	RootfsChownRecursive RootfsOwnership = "recursive"
+
+	// RootfsIdmap mounts the rootfs with the id mappings of the container,
+	// which leaves the files as they are. Mounts below the rootfs are not
+	// carried over. Kernels or filesystems without idmapped mounts get
+	// RootfsChownRecursive instead.
+	RootfsIdmap RootfsOwnership = "idmap"
+)
+
+// HostPidProc is how a container that shares the host's PID namespace gets
+// its /proc, which shows the host's processes in every case.
+type HostPidProc string
+
+const (
+	// HostPidProcMount mounts a new proc filesystem, which is what the empty
+	// value does as well. This fails in a user namespace that does not own
+	// the PID namespace.
+	HostPidProcMount HostPidProc = "mount"
+
+	// HostPidProcBind bind mounts the host's /proc instead.
+	HostPidProcBind HostPidProc = "bind"
+
+	// HostPidProcMasked bind mounts the host's /proc and masks the files in
+	// HostProcMaskedFiles below it.
+	HostPidProcMasked HostPidProc = "masked"
+)
+
+// HostProcMaskedFiles are the files below a proc mount that HostPidProcMasked
+// masks, relative to the mount. They expose the kernel and the host rather
+// than processes.
+var HostProcMaskedFiles = []string{
+	"kcore",
+	"keys",
+	"latency_stats",
+	"sched_debug",
+	"sysrq-trigger",
+	"timer_list",
+	"timer_stats",
+}
+
+// Securebits are the securebits flags of prctl(PR_SET_SECUREBITS), which
+// change how the kernel grants capabilities to uid 0. Each flag has a locked
+// flag that keeps it from being changed again.
+type Securebits uint
+
+const (
+	// SecbitNoroot keeps uid 0 from being granted capabilities on execve.
+	SecbitNoroot Securebits = 1 << iota
+	SecbitNorootLocked
+	// SecbitNoSetuidFixup keeps the capability sets from being changed
+	// when the uids switch between 0 and non-zero.
+	SecbitNoSetuidFixup
+	SecbitNoSetuidFixupLocked
+	// SecbitKeepCaps keeps the permitted capabilities over a switch from
+	// uid 0. The init manages it itself, so it cannot be configured.
+	SecbitKeepCaps
+	SecbitKeepCapsLocked
+	// SecbitNoCapAmbientRaise keeps capabilities from being raised in the
+	// ambient set.
+	SecbitNoCapAmbientRaise
+	SecbitNoCapAmbientRaiseLocked
+)
+
+// ParentDeathCleanup configures how the init cleans up after the death of
+// its parent during setup.
+type ParentDeathCleanup struct {
+	// Signal is sent to the other processes in the container's cgroups
+	// before the cgroups are removed. Zero means SIGKILL.
+	Signal int `json:"signal,omitempty"`
+
+	// Timeout limits how long the init waits for those processes to exit.
+	// Zero means one second.
+	Timeout time.Duration `json:"timeout,omitempty"`
+}
+
 // IDMap represents UID/GID Mappings for User Namespaces.
 type IDMap struct {
 	ContainerID int `json:"container_id"`
@@ -43,6 +130,10 @@ const (
 	Trap
 	Allow
 	Trace
+	// Log allows the syscall and records it in the kernel's audit log, to
+	// find out which syscalls a program needs before enforcing a profile.
+	// It cannot be the default action or have argument conditions.
+	Log
 )
 
 // Operator is a comparison operator to be used when matching syscall arguments in Seccomp
@@ -83,9 +174,14 @@ type Config struct {
 	NoPivotRoot bool `json:"no_pivot_root"`
 
 	// ParentDeathSignal specifies the signal that is sent to the container's process in the case
-	// that the parent process dies.
+	// that the parent process dies. No signal is sent if it is zero.
 	ParentDeathSignal int `json:"parent_death_signal"`
 
+	// BlockedSignals are the signals blocked by the container's processes
+	// when they start. Without them the processes start with no signals
+	// blocked, whatever the process creating the container blocks.
+	BlockedSignals []int `json:"blocked_signals,omitempty"`
+
 	// Path to a directory containing the container's root filesystem.
 	Rootfs string `json:"rootfs"`
 
@@ -93,6 +189,17 @@ type Config struct {
 	// bind mounts are writtable.
 	Readonlyfs bool `json:"readonlyfs"`
 
+	// RootfsMountFlags are MS_NOSUID, MS_NODEV and MS_NOEXEC flags that the
+	// rootfs is remounted with after pivot_root. They do not apply to the
+	// mounts below the rootfs.
+	RootfsMountFlags int `json:"rootfs_mount_flags,omitempty"`
+
+	// TmpfsDirs are directories, such as /var or /run, that are overlaid
+	// with a tmpfs holding a copy of their contents, so that services have
+	// scratch space even on a read-only rootfs. Writes do not outlive the
+	// container.
+	TmpfsDirs []string `json:"tmpfs_dirs,omitempty"`
+
 	// Specifies the mount propagation flags to be applied to /.
 	RootPropagation int `json:"rootPropagation"`
 
@@ -100,6 +207,36 @@ type Config struct {
 	// rootfs and mount namespace if specified
 	Mounts []*Mount `json:"mounts"`
 
+	// CgroupView mounts a read-only view of the container's own cgroups
+	// without a cgroup mount in Mounts. Nil means no view.
+	CgroupView *CgroupView `json:"cgroup_view,omitempty"`
+
+	// SetupTimeout limits how long the container's init may take to set up
+	// the container, which includes the mounts, before it is killed and the
+	// start fails. A mount of an unresponsive network filesystem can
+	// otherwise block the start forever. Zero means no limit.
+	SetupTimeout time.Duration `json:"setup_timeout,omitempty"`
+
+	// NetworkReadyTimeout makes the init wait before exec'ing the user's
+	// process until a prestart hook, or a process it started, signals that
+	// the network of the container is ready by writing to the fifo named by
+	// the NetworkReadyAnnotation of the hook state. The start fails if that
+	// does not happen within the timeout. Zero means the init does not wait.
+	NetworkReadyTimeout time.Duration `json:"network_ready_timeout,omitempty"`
+
+	// CgroupDrainTimeout makes Destroy wait for the processes in the
+	// container's cgroups to exit before it removes the cgroups, which
+	// fails while they are in use. The processes still running after the
+	// timeout are killed. Zero means the processes are killed at once.
+	CgroupDrainTimeout time.Duration `json:"cgroup_drain_timeout,omitempty"`
+
+	// ParentDeathCleanup makes the init remove the container's cgroups when
+	// the process starting the container dies while the init sets it up,
+	// which leaves them behind otherwise. The init stops watching before it
+	// switches to the container's user and drops its capabilities. Nil means
+	// the init only exits.
+	ParentDeathCleanup *ParentDeathCleanup `json:"parent_death_cleanup,omitempty"`
+
 	// The device nodes that should be automatically created within the container upon container start.  Note, make sure that the node is marked as allowed in the cgroup as well!
 	Devices []*Device `json:"devices"`
 
@@ -114,8 +251,15 @@ type Config struct {
 
 	// Capabilities specify the capabilities to keep when executing the process inside the container
 	// All capabilities not specified will be dropped from the processes capability mask
+	// The bounding set is dropped before the switch to the user of the process and the other
+	// sets after it, both after no_new_privs is set, so NoNewPrivileges also keeps an execve from
+	// gaining back what was dropped. In a user namespace they are the capabilities of the mapped
+	// root in that namespace.
 	Capabilities *Capabilities `json:"capabilities"`
 
+	// Securebits are set for the processes of the container before the switch to their user.
+	Securebits Securebits `json:"securebits,omitempty"`
+
 	// Networks specifies the container's network setup to be created
 	Networks []*Network `json:"networks"`
 
@@ -134,10 +278,35 @@ type Config struct {
 	// commonly used by selinux
 	ProcessLabel string `json:"process_label,omitempty"`
 
+	// EnvFile is the path on the host of a file with one KEY=VALUE pair per
+	// line that is added to the environment of every process started in the
+	// container. Blank lines and lines starting with # are ignored. Variables
+	// set in the environment of the process take precedence.
+	EnvFile string `json:"env_file,omitempty"`
+
+	// HostEnv names environment variables, such as TERM or http_proxy, that
+	// are passed from the environment of the caller to every process started
+	// in the container. Variables that are not set are left out, and the
+	// environment file and the environment of the process take precedence.
+	HostEnv []string `json:"host_env,omitempty"`
+
 	// Rlimits specifies the resource limits, such as max open files, to set in the container
 	// If Rlimits are not set, the container will inherit rlimits from the parent process
 	Rlimits []Rlimit `json:"rlimits,omitempty"`
 
+	// ResetRlimits starts the container's processes from the kernel's default
+	// rlimits instead of inheriting the rlimits of the process creating the
+	// container. Rlimits still override the defaults. RLIMIT_NPROC and
+	// RLIMIT_SIGPENDING are always inherited as the kernel sizes them at boot.
+	ResetRlimits bool `json:"reset_rlimits,omitempty"`
+
+	// MaxCoreSize sets RLIMIT_CORE, the largest core dump in bytes that the
+	// container's processes may write, for every process that does not set
+	// RLIMIT_CORE itself. Zero disables core dumps. kernel.core_pattern can
+	// not be set per container as it is not namespaced, so this limit is the
+	// only way to keep a container from writing core dumps.
+	MaxCoreSize *uint64 `json:"max_core_size,omitempty"`
+
 	// OomScoreAdj specifies the adjustment to be made by the kernel when calculating oom scores
 	// for a process. Valid values are between the range [-1000, '1000'], where processes with
 	// higher scores are preferred for being killed.
@@ -150,6 +319,20 @@ type Config struct {
 	// GidMappings is an array of Group ID mappings for User Namespaces
 	GidMappings []IDMap `json:"gid_mappings"`
 
+	// RootfsOwnership makes the rootfs of a container with a user namespace
+	// accessible to the root user of the container. Empty leaves the owner
+	// of the rootfs as is.
+	RootfsOwnership RootfsOwnership `json:"rootfs_ownership,omitempty"`
+
+	// RootfsOverlay, if set, mounts an overlay over the rootfs so that the
+	// container writes to the overlay's upper directory instead.
+	RootfsOverlay *Overlay `json:"rootfs_overlay,omitempty"`
+
+	// RootfsImage, if set, is mounted read-only at Rootfs through a loop
+	// device, as the lower layer of RootfsOverlay if that is set as well.
+	// Rootfs only serves as the mount point then.
+	RootfsImage *Image `json:"rootfs_image,omitempty"`
+
 	// MaskPaths specifies paths within the container's rootfs to mask over with a bind
 	// mount pointing to /dev/null as to prevent reads of the file.
 	MaskPaths []string `json:"mask_paths"`
@@ -158,10 +341,19 @@ type Config struct {
 	// so that these files prevent any writes.
 	ReadonlyPaths []string `json:"readonly_paths"`
 
+	// HostPidProc selects how the proc mounts of a container without a PID
+	// namespace of its own are set up. It has to be empty when the container
+	// has a PID namespace.
+	HostPidProc HostPidProc `json:"host_pid_proc,omitempty"`
+
 	// Sysctl is a map of properties and their values. It is the equivalent of using
 	// sysctl -w my.property.name value in Linux.
 	Sysctl map[string]string `json:"sysctl"`
 
+	// SocketBuffers sets the socket buffer sysctls of the container's network
+	// namespace. Entries in Sysctl take precedence over it.
+	SocketBuffers *SocketBuffers `json:"socket_buffers,omitempty"`
+
 	// Seccomp allows actions to be taken whenever a syscall is made within the container.
 	// A number of rules are given, each having an action to be taken if a syscall matches it.
 	// A default action to be taken if no rules match is also given.
@@ -170,6 +362,34 @@ type Config struct {
 	// NoNewPrivileges controls whether processes in the container can gain additional privileges.
 	NoNewPrivileges bool `json:"no_new_privileges,omitempty"`
 
+	// MinimalInit makes the init exec a small reaper as pid 1 instead of
+	// the user's process, which the reaper runs as a child in its own
+	// process group. The reaper forwards the signals it receives to that
+	// process group, reaps orphaned processes and exits with the exit code
+	// of the child. Images do not need to bundle an init such as tini. The
+	// reaper is exec'd from a sealed in-memory copy of the init binary, and
+	// needs /proc to be mounted in the container to read its arguments.
+	MinimalInit bool `json:"minimal_init,omitempty"`
+
+	// Daemon runs the container's init process detached, as a background
+	// service: it has no controlling terminal and its stdio is redirected to
+	// /dev/null, or to the files in the container that Daemon names, instead
+	// of the stdio of the Process. The files are opened before the rootfs is
+	// made read-only. It cannot be used with a console and needs a mount
+	// namespace.
+	Daemon *Daemon `json:"daemon,omitempty"`
+
+	// QuiescedFile is the path in the container that the init creates once it
+	// has quiesced after the signal sent by Quiesce.
+	QuiescedFile string `json:"quiesced_file,omitempty"`
+
+	// HardenedInit keeps the state of the manager out of the processes of
+	// the container. Right before the exec, the init builds the environment
+	// from the process's own variables only, sets the umask to 0027, closes
+	// the descriptors that were not passed on purpose and restores the
+	// default action of the signals that the manager ignored.
+	HardenedInit bool `json:"hardened_init,omitempty"`
+
 	// Hooks are a collection of actions to perform at various container lifecycle events.
 	// CommandHooks are serialized to JSON, but other hooks are not.
 	Hooks *Hooks
@@ -184,8 +404,107 @@ type Config struct {
 	// callers keyring in this case.
 	NoNewKeyring bool `json:"no_new_keyring"`
 
+	// LoginUID, if set, is written to the audit loginuid of the container's
+	// processes, which also starts a new audit session, so that the host's
+	// audit log attributes their actions to it. Where the host's audit
+	// policy does not allow that the processes keep the inherited loginuid.
+	LoginUID *uint32 `json:"login_uid,omitempty"`
+
 	// Rootless specifies whether the container is a rootless container.
 	Rootless bool `json:"rootless"`
+
+	// FileCapabilities are set on files inside the rootfs once the mounts are
+	// in place, e.g. to give a bind-mounted ping CAP_NET_RAW.
+	FileCapabilities []FileCapability `json:"file_capabilities,omitempty"`
+
+	// PidFile is the path of a file the pid of the container's init is written
+	// to once it has started. The file is removed when the container is destroyed.
+	PidFile string `json:"pid_file,omitempty"`
+
+	// NetnsPath is a file the container's network namespace is bind mounted
+	// at once it is created, like ip netns does below /var/run/netns, so that
+	// tools can enter it by path. The bind mount keeps the namespace alive
+	// until the container is destroyed, which removes the file.
+	NetnsPath string `json:"netns_path,omitempty"`
+
+	// InitFailure controls how the container's init terminates when it fails
+	// to set up the container. If nil the caller of StartInitialization decides.
+	InitFailure *InitFailurePolicy `json:"init_failure,omitempty"`
+
+	// SetupSyslog, if set, has the init send its setup steps and the error
+	// it fails with to the host's syslog, which journald reads as well.
+	SetupSyslog *SetupSyslog `json:"setup_syslog,omitempty"`
+
+	// CpuAffinity is the list of CPUs the container's processes are allowed to
+	// run on, set with sched_setaffinity(2) independently of the cpuset cgroup.
+	CpuAffinity []int `json:"cpu_affinity,omitempty"`
+}
+
+// FileCapability holds the capabilities set on a single file in the rootfs.
+type FileCapability struct {
+	// Path is the path of the file inside the container's rootfs.
+	Path string `json:"path"`
+
+	// Capabilities are added to both the permitted and effective file sets,
+	// so they are raised when an unprivileged user executes the file.
+	Capabilities []string `json:"capabilities"`
+}
+
+// InitFailurePolicy describes how the container's init terminates when it hits
+// an unrecoverable error before the user's process has been exec'd.
+//
+// The user's process owns exit codes 0-125, 126 and 127 are conventionally
+// used for a command that cannot be executed or found, and 128+n reports
+// death by signal n. A setup failure can only be told apart from the exit of
+// the user's process if ExitCode is taken from a range the process does not
+// use, such as 125 or a code documented by the caller.
+type InitFailurePolicy struct {
+	// ExitCode is the status the init exits with, 1 if unset.
+	ExitCode int `json:"exit_code,omitempty"`
+
+	// Signal, if set, is raised by the init against itself instead of exiting.
+	// The kernel discards signals that the init of a new PID namespace sends
+	// to itself, in which case the init falls back to ExitCode.
+	Signal int `json:"signal,omitempty"`
+}
+
+// Overlay is the writable layer of an overlay over the rootfs.
+type Overlay struct {
+	// UpperDir and WorkDir are the upper and work directories of the overlay,
+	// which are left in place on Destroy. If both are empty libcontainer
+	// creates them in the container's state directory and removes them on
+	// Destroy.
+	UpperDir string `json:"upper_dir,omitempty"`
+	WorkDir  string `json:"work_dir,omitempty"`
+
+	// Tmpfs puts the upper and work directories on a tmpfs, so that what
+	// the container writes is lost when it stops. UpperDir and WorkDir must
+	// be empty then.
+	Tmpfs bool `json:"tmpfs,omitempty"`
+}
+
+// Image is a read-only filesystem image file.
+type Image struct {
+	// Path is the absolute path of the image file on the host.
+	Path string `json:"path"`
+
+	// Type is the filesystem of the image, "squashfs" or "erofs".
+	Type string `json:"type"`
+}
+
+// SetupSyslog configures the messages the init sends to the host's syslog.
+type SetupSyslog struct {
+	// Tag is the tag of the messages, the container id if empty.
+	Tag string `json:"tag,omitempty"`
+}
+
+// Daemon names the files in the container that the output of a process in
+// daemon mode is appended to.
+type Daemon struct {
+	// Stdout and Stderr are created if they do not exist. If empty the
+	// stream is redirected to /dev/null.
+	Stdout string `json:"stdout,omitempty"`
+	Stderr string `json:"stderr,omitempty"`
 }
 
 type Hooks struct {
@@ -209,8 +528,18 @@ type Capabilities struct {
 	Inheritable []string
 	// Permitted is the limiting superset for effective capabilities.
 	Permitted []string
-	// Ambient is the ambient set of capabilities that are kept.
+	// Ambient is the ambient set of capabilities that are kept. It is
+	// applied after the switch to the user of the process, and unlike the
+	// other sets survives an execve by that user of a file without file
+	// capabilities. Each of them must be in both Permitted and Inheritable.
 	Ambient []string
+	// BoundingOnly applies only the Bounding and Ambient sets. The effective,
+	// inheritable and permitted sets are kept as they were inherited, so a
+	// capability dropped from the bounding set stays usable until it is
+	// dropped from them, but can not be gained again. The Ambient
+	// capabilities are added to the inheritable set, and must be in the
+	// inherited permitted set.
+	BoundingOnly bool
 }
 
 func (hooks *Hooks) UnmarshalJSON(b []byte) error {
@@ -262,6 +591,11 @@ func (hooks Hooks) MarshalJSON() ([]byte, error) {
 // HookState is the payload provided to a hook on execution.
 type HookState specs.State
 
+// NetworkReadyAnnotation is the annotation of the state of prestart hooks
+// that holds the path of the fifo to signal the network ready on, for a
+// container with a NetworkReadyTimeout.
+const NetworkReadyAnnotation = "org.opencontainers.runc.network-ready"
+
 type Hook interface {
 	// Run executes the hook with the provided state.
 	Run(HookState) error
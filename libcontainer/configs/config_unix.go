@@ -2,7 +2,10 @@
 
 package configs
 
-import "fmt"
+import (
+	"fmt"
+	"syscall"
+)
 
 // HostUID gets the translated uid for the process on host which could be
 // different when user namespaces are enabled.
@@ -61,3 +64,20 @@ func (c Config) hostIDFromMapping(containerID int, uMap []IDMap) (int, bool) {
 	}
 	return -1, false
 }
+
+// AllMounts returns the mounts of the container, which are the Mounts followed
+// by those of the TmpfsDirs.
+func (c Config) AllMounts() []*Mount {
+	mounts := append([]*Mount(nil), c.Mounts...)
+	for _, dir := range c.TmpfsDirs {
+		mounts = append(mounts, &Mount{
+			Source:      "tmpfs",
+			Destination: dir,
+			Device:      "tmpfs",
+			Flags:       syscall.MS_NOSUID | syscall.MS_NODEV,
+			Data:        "mode=755",
+			Extensions:  EXT_COPYUP,
+		})
+	}
+	return mounts
+}
@@ -130,3 +130,20 @@ func TestHostRootGIDWithUSERNS(t *testing.T) {
 		t.Fatalf("expected gid 1000 with no USERNS but received %d", uid)
 	}
 }
+
+func TestAllMounts(t *testing.T) {
+	config := &Config{
+		Mounts:    []*Mount{{Destination: "/data", Device: "bind"}},
+		TmpfsDirs: []string{"/run"},
+	}
+	mounts := config.AllMounts()
+	if len(mounts) != 2 || mounts[0] != config.Mounts[0] {
+		t.Fatalf("expected the mounts followed by the tmpfs directories, got %+v", mounts)
+	}
+	if m := mounts[1]; m.Destination != "/run" || m.Device != "tmpfs" || m.Extensions&EXT_COPYUP == 0 {
+		t.Fatalf("expected a tmpfs with the contents of /run, got %+v", m)
+	}
+	if len(config.Mounts) != 1 {
+		t.Fatalf("expected the mounts of the config to be left alone, got %+v", config.Mounts)
+	}
+}
@@ -0,0 +1,50 @@
+package configs
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// ReadEnvFile reads the KEY=VALUE pairs from the environment file at path.
+// Leading whitespace, blank lines and lines starting with # are ignored.
+func ReadEnvFile(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var (
+		env []string
+		n   int
+		s   = bufio.NewScanner(f)
+	)
+	for s.Scan() {
+		n++
+		line := strings.TrimLeft(s.Text(), " \t")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if strings.Index(line, "=") <= 0 {
+			return nil, fmt.Errorf("%s:%d: invalid environment %q", path, n, line)
+		}
+		env = append(env, line)
+	}
+	if err := s.Err(); err != nil {
+		return nil, fmt.Errorf("reading %s: %v", path, err)
+	}
+	return env, nil
+}
+
+// LookupHostEnv returns the KEY=VALUE pairs of the named variables that are
+// set in the current environment.
+func LookupHostEnv(names []string) []string {
+	var env []string
+	for _, name := range names {
+		if value, ok := os.LookupEnv(name); ok {
+			env = append(env, name+"="+value)
+		}
+	}
+	return env
+}
@@ -0,0 +1,63 @@
+package configs
+
+import (
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+)
+
+func writeEnvFile(t *testing.T, content string) string {
+	f, err := ioutil.TempFile("", "envfile")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if _, err := f.WriteString(content); err != nil {
+		t.Fatal(err)
+	}
+	return f.Name()
+}
+
+func TestReadEnvFile(t *testing.T) {
+	path := writeEnvFile(t, `# the environment
+PATH=/usr/bin:/bin
+
+  HOME=/root
+	# indented comment
+GREETING=hello = world
+EMPTY=
+`)
+	defer os.Remove(path)
+	env, err := ReadEnvFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"PATH=/usr/bin:/bin", "HOME=/root", "GREETING=hello = world", "EMPTY="}
+	if !reflect.DeepEqual(env, expected) {
+		t.Fatalf("expected %q, got %q", expected, env)
+	}
+}
+
+func TestReadEnvFileInvalid(t *testing.T) {
+	for _, content := range []string{"PATH=/bin\nNOVALUE\n", "=value\n"} {
+		path := writeEnvFile(t, content)
+		if _, err := ReadEnvFile(path); err == nil {
+			t.Errorf("expected an error reading %q", content)
+		}
+		os.Remove(path)
+	}
+}
+
+func TestLookupHostEnv(t *testing.T) {
+	os.Setenv("LIBCONTAINER_TEST_HOST_ENV", "a=b")
+	os.Setenv("LIBCONTAINER_TEST_HOST_EMPTY", "")
+	os.Unsetenv("LIBCONTAINER_TEST_HOST_UNSET")
+	defer os.Unsetenv("LIBCONTAINER_TEST_HOST_ENV")
+	defer os.Unsetenv("LIBCONTAINER_TEST_HOST_EMPTY")
+	env := LookupHostEnv([]string{"LIBCONTAINER_TEST_HOST_ENV", "LIBCONTAINER_TEST_HOST_UNSET", "LIBCONTAINER_TEST_HOST_EMPTY"})
+	expected := []string{"LIBCONTAINER_TEST_HOST_ENV=a=b", "LIBCONTAINER_TEST_HOST_EMPTY="}
+	if !reflect.DeepEqual(env, expected) {
+		t.Fatalf("expected environment %q, got %q", expected, env)
+	}
+}
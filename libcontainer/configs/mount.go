@@ -4,6 +4,10 @@ const (
 	// EXT_COPYUP is a directive to copy up the contents of a directory when
 	// a tmpfs is mounted over it.
 	EXT_COPYUP = 1 << iota
+
+	// EXT_IDMAP is a directive to show the files of a bind mount with the
+	// owners mapped by the container's user namespace.
+	EXT_IDMAP
 )
 
 type Mount struct {
@@ -37,3 +41,15 @@ type Mount struct {
 	// Optional Command to be run after Source is mounted.
 	PostmountCmds []Command `json:"postmount_cmds"`
 }
+
+// CgroupView is a read-only view of the container's own cgroups, for agents
+// in the container that monitor it without being able to change its limits.
+type CgroupView struct {
+	// Destination is the directory inside the container that holds a
+	// directory per cgroup hierarchy, named like the hierarchy's mount
+	// point on the host (e.g. memory or cpu,cpuacct).
+	Destination string `json:"destination"`
+
+	// Subsystems selects the hierarchies to show. All are shown if empty.
+	Subsystems []string `json:"subsystems,omitempty"`
+}
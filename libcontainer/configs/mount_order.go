@@ -0,0 +1,44 @@
+package configs
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// OrderMounts returns mounts ordered so that every mount comes before the
+// mounts nested below its destination, which would otherwise be hidden by
+// it. Mounts that are not nested keep the order they are given in. It
+// fails if a destination is mounted again after a mount nested below it, as
+// the order the mounts are meant to stack in is ambiguous then.
+func OrderMounts(mounts []*Mount) ([]*Mount, error) {
+	ordered := make([]*Mount, 0, len(mounts))
+	for _, m := range mounts {
+		dest := filepath.Clean(m.Destination)
+		i := 0
+		for ; i < len(ordered); i++ {
+			if isBelow(filepath.Clean(ordered[i].Destination), dest) {
+				break
+			}
+		}
+		if i < len(ordered) {
+			for _, o := range ordered {
+				if filepath.Clean(o.Destination) == dest {
+					return nil, fmt.Errorf("mount at %s conflicts with the mount at %s below it", m.Destination, ordered[i].Destination)
+				}
+			}
+		}
+		ordered = append(ordered, nil)
+		copy(ordered[i+1:], ordered[i:])
+		ordered[i] = m
+	}
+	return ordered, nil
+}
+
+// isBelow reports whether path is strictly below dir. Both must be clean.
+func isBelow(path, dir string) bool {
+	if !strings.HasSuffix(dir, "/") {
+		dir += "/"
+	}
+	return path != dir && strings.HasPrefix(path, dir)
+}
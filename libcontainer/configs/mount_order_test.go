@@ -0,0 +1,66 @@
+package configs
+
+import (
+	"reflect"
+	"testing"
+)
+
+func destinations(mounts []*Mount) []string {
+	var dests []string
+	for _, m := range mounts {
+		dests = append(dests, m.Destination)
+	}
+	return dests
+}
+
+func TestOrderMounts(t *testing.T) {
+	for _, test := range []struct {
+		mounts   []string
+		expected []string
+	}{
+		{
+			[]string{"/proc", "/dev", "/dev/pts", "/sys"},
+			[]string{"/proc", "/dev", "/dev/pts", "/sys"},
+		},
+		{
+			[]string{"/data/a/b", "/data/a", "/tmp", "/data"},
+			[]string{"/data", "/data/a", "/data/a/b", "/tmp"},
+		},
+		{
+			[]string{"/srv/x", "/srv/xy", "/srv/"},
+			[]string{"/srv/", "/srv/x", "/srv/xy"},
+		},
+		{
+			// A destination mounted twice keeps its order.
+			[]string{"/etc/hosts", "/etc/hosts", "/mnt/b", "/mnt"},
+			[]string{"/etc/hosts", "/etc/hosts", "/mnt", "/mnt/b"},
+		},
+		{
+			[]string{"/opt/data", "/"},
+			[]string{"/", "/opt/data"},
+		},
+	} {
+		var mounts []*Mount
+		for _, dest := range test.mounts {
+			mounts = append(mounts, &Mount{Destination: dest})
+		}
+		ordered, err := OrderMounts(mounts)
+		if err != nil {
+			t.Fatalf("ordering %q: %v", test.mounts, err)
+		}
+		if dests := destinations(ordered); !reflect.DeepEqual(dests, test.expected) {
+			t.Errorf("expected %q to be ordered as %q, got %q", test.mounts, test.expected, dests)
+		}
+	}
+}
+
+func TestOrderMountsConflict(t *testing.T) {
+	mounts := []*Mount{
+		{Destination: "/var"},
+		{Destination: "/var/lib"},
+		{Destination: "/var/"},
+	}
+	if _, err := OrderMounts(mounts); err == nil {
+		t.Fatal("expected a destination mounted again after a mount below it to be rejected")
+	}
+}
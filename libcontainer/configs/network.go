@@ -1,5 +1,10 @@
 package configs
 
+import (
+	"fmt"
+	"strconv"
+)
+
 // Network defines configuration for a container's networking stack
 //
 // The network configuration can be omitted from a container causing the
@@ -8,6 +13,13 @@ type Network struct {
 	// Type sets the networks type, commonly veth and loopback
 	Type string `json:"type"`
 
+	// Parent is the host interface that a macvlan interface is created on.
+	Parent string `json:"parent,omitempty"`
+
+	// MacvlanMode is the mode of a macvlan interface, one of bridge, private,
+	// vepa and passthru. Empty is bridge.
+	MacvlanMode string `json:"macvlan_mode,omitempty"`
+
 	// Name of the network interface
 	Name string `json:"name"`
 
@@ -43,11 +55,109 @@ type Network struct {
 	// container.
 	HostInterfaceName string `json:"host_interface_name"`
 
+	// PeerName is the name of the container's side of a veth pair while it is
+	// still in the host's network namespace, before it is renamed to Name.
+	// A random name is used if it is empty.
+	PeerName string `json:"peer_name,omitempty"`
+
+	// Offloads turns segmentation and receive offloads of the container's
+	// interface on or off.
+	// Note: This only applies to veth and macvlan interfaces.
+	Offloads *Offloads `json:"offloads,omitempty"`
+
 	// HairpinMode specifies if hairpin NAT should be enabled on the virtual interface
 	// bridge port in the case of type veth
 	// Note: This is unsupported on some systems.
 	// Note: This does not apply to loopback interfaces.
 	HairpinMode bool `json:"hairpin_mode"`
+
+	// ArpIgnore sets arp_ignore for the container's interface, which decides
+	// which ARP requests for local addresses are answered. Valid values are
+	// 0-3 and 8; 0 leaves the kernel default in place.
+	ArpIgnore int `json:"arp_ignore,omitempty"`
+
+	// ArpAnnounce sets arp_announce for the container's interface, which
+	// restricts the source addresses announced in ARP requests. Valid values
+	// are 0-2; 0 leaves the kernel default in place.
+	ArpAnnounce int `json:"arp_announce,omitempty"`
+
+	// ProxyArp enables proxy_arp on the container's interface.
+	ProxyArp bool `json:"proxy_arp,omitempty"`
+
+	// RouteTable is the id of the routing table that the gateways of the
+	// interface and the routes through it are added to instead of the main
+	// table. Rules direct the traffic from the addresses of the interface to
+	// the table. Zero uses the main table.
+	// Note: This does not apply to loopback interfaces.
+	RouteTable int `json:"route_table,omitempty"`
+}
+
+// Offloads holds the ethtool offload settings of an interface. A nil field
+// leaves the offload as the driver sets it up.
+type Offloads struct {
+	// TSO is TCP segmentation offload.
+	TSO *bool `json:"tso,omitempty"`
+
+	// GSO is generic segmentation offload.
+	GSO *bool `json:"gso,omitempty"`
+
+	// GRO is generic receive offload.
+	GRO *bool `json:"gro,omitempty"`
+}
+
+// SocketBuffers holds the socket buffer sizes of the container's network
+// namespace. Each field maps onto the sysctl named in its comment and is left
+// at the kernel default when zero or empty.
+type SocketBuffers struct {
+	// RmemDefault sets net.core.rmem_default.
+	RmemDefault uint64 `json:"rmem_default,omitempty"`
+
+	// RmemMax sets net.core.rmem_max.
+	RmemMax uint64 `json:"rmem_max,omitempty"`
+
+	// WmemDefault sets net.core.wmem_default.
+	WmemDefault uint64 `json:"wmem_default,omitempty"`
+
+	// WmemMax sets net.core.wmem_max.
+	WmemMax uint64 `json:"wmem_max,omitempty"`
+
+	// TCPRmem sets the min, default and max values of net.ipv4.tcp_rmem.
+	TCPRmem []uint64 `json:"tcp_rmem,omitempty"`
+
+	// TCPWmem sets the min, default and max values of net.ipv4.tcp_wmem.
+	TCPWmem []uint64 `json:"tcp_wmem,omitempty"`
+
+	// UDPRmemMin sets net.ipv4.udp_rmem_min.
+	UDPRmemMin uint64 `json:"udp_rmem_min,omitempty"`
+
+	// UDPWmemMin sets net.ipv4.udp_wmem_min.
+	UDPWmemMin uint64 `json:"udp_wmem_min,omitempty"`
+}
+
+// Sysctl returns the sysctls that apply the socket buffer sizes.
+func (s *SocketBuffers) Sysctl() map[string]string {
+	sysctl := map[string]string{}
+	for key, value := range map[string]uint64{
+		"net.core.rmem_default": s.RmemDefault,
+		"net.core.rmem_max":     s.RmemMax,
+		"net.core.wmem_default": s.WmemDefault,
+		"net.core.wmem_max":     s.WmemMax,
+		"net.ipv4.udp_rmem_min": s.UDPRmemMin,
+		"net.ipv4.udp_wmem_min": s.UDPWmemMin,
+	} {
+		if value != 0 {
+			sysctl[key] = strconv.FormatUint(value, 10)
+		}
+	}
+	for key, values := range map[string][]uint64{
+		"net.ipv4.tcp_rmem": s.TCPRmem,
+		"net.ipv4.tcp_wmem": s.TCPWmem,
+	} {
+		if len(values) == 3 {
+			sysctl[key] = fmt.Sprintf("%d %d %d", values[0], values[1], values[2])
+		}
+	}
+	return sysctl
 }
 
 // Routes can be specified to create entries in the route table as the container is started
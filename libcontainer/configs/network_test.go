@@ -0,0 +1,26 @@
+package configs_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/opencontainers/runc/libcontainer/configs"
+)
+
+func TestSocketBuffersSysctl(t *testing.T) {
+	buffers := &configs.SocketBuffers{
+		RmemMax:    16777216,
+		WmemMax:    16777216,
+		TCPRmem:    []uint64{4096, 87380, 16777216},
+		UDPRmemMin: 8192,
+	}
+	expected := map[string]string{
+		"net.core.rmem_max":     "16777216",
+		"net.core.wmem_max":     "16777216",
+		"net.ipv4.tcp_rmem":     "4096 87380 16777216",
+		"net.ipv4.udp_rmem_min": "8192",
+	}
+	if sysctl := buffers.Sysctl(); !reflect.DeepEqual(sysctl, expected) {
+		t.Fatalf("expected sysctls %v but received %v", expected, sysctl)
+	}
+}
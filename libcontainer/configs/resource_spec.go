@@ -0,0 +1,109 @@
+// +build linux freebsd
+
+package configs
+
+import (
+	"fmt"
+	"math"
+)
+
+const (
+	// DefaultCpuPeriod is the CFS period, in usecs, used to enforce the CPU
+	// limit of a ResourceSpec.
+	DefaultCpuPeriod uint64 = 100000
+
+	// cpuSharesPerCPU is the cpu.shares weight given to each whole CPU, which
+	// matches the default weight of a cgroup.
+	cpuSharesPerCPU = 1024
+
+	// minCpuShares is the smallest weight the kernel accepts.
+	minCpuShares = 2
+)
+
+// ResourceSpec describes the resources of a container in high-level terms
+// that are translated into cgroup settings by ToCgroups. A zero field leaves
+// the corresponding resource unlimited.
+type ResourceSpec struct {
+	// CPUs is the number of CPUs the container may use. Fractions are allowed,
+	// e.g. 1.5 allows one and a half CPUs worth of time in each period.
+	CPUs float64 `json:"cpus,omitempty"`
+
+	// MemoryBytes is the memory limit of the container in bytes.
+	MemoryBytes int64 `json:"memory_bytes,omitempty"`
+}
+
+// ToCgroups returns the cgroup resources that enforce the spec. The CPU limit
+// is expressed as a CFS quota over DefaultCpuPeriod and weighted with shares
+// proportional to the number of CPUs.
+func (s ResourceSpec) ToCgroups() (*Resources, error) {
+	if s.CPUs < 0 || math.IsNaN(s.CPUs) || math.IsInf(s.CPUs, 0) {
+		return nil, fmt.Errorf("invalid number of CPUs %v", s.CPUs)
+	}
+	if s.MemoryBytes < 0 {
+		return nil, fmt.Errorf("invalid memory limit %d", s.MemoryBytes)
+	}
+	r := &Resources{
+		Memory: uint64(s.MemoryBytes),
+	}
+	if s.CPUs > 0 {
+		quota := int64(math.Floor(s.CPUs*float64(DefaultCpuPeriod) + 0.5))
+		if quota < 1000 {
+			return nil, fmt.Errorf("%v CPUs is below the minimum CFS quota of 1ms", s.CPUs)
+		}
+		r.CpuPeriod = DefaultCpuPeriod
+		r.CpuQuota = quota
+		r.CpuShares = uint64(math.Floor(s.CPUs*cpuSharesPerCPU + 0.5))
+		if r.CpuShares < minCpuShares {
+			r.CpuShares = minCpuShares
+		}
+	}
+	return r, nil
+}
+
+// HostResources are the resources of the host that the percentage limits of
+// Resources are relative to.
+type HostResources struct {
+	// CPUs is the number of online CPUs.
+	CPUs int
+
+	// MemoryBytes is the total memory.
+	MemoryBytes int64
+}
+
+// ResolvePercentages sets the memory limit and the CFS quota of r from
+// MemoryPercent and CpuPercent of the host's resources, so that the same
+// config fits hosts of different sizes. The percentages are cleared once
+// resolved, so that the absolute limits can be updated later on.
+func (r *Resources) ResolvePercentages(host HostResources) error {
+	if r.MemoryPercent == 0 && r.CpuPercent == 0 {
+		return nil
+	}
+	if r.MemoryPercent != 0 && r.Memory != 0 {
+		return fmt.Errorf("memory percentage cannot be combined with a memory limit")
+	}
+	if r.CpuPercent != 0 && (r.CpuQuota != 0 || r.CpuPeriod != 0) {
+		return fmt.Errorf("cpu percentage cannot be combined with a cpu quota or period")
+	}
+	for _, pct := range []float64{r.MemoryPercent, r.CpuPercent} {
+		if pct < 0 || pct > 100 || math.IsNaN(pct) {
+			return fmt.Errorf("invalid percentage of host resources %v", pct)
+		}
+	}
+	spec := ResourceSpec{
+		CPUs:        r.CpuPercent / 100 * float64(host.CPUs),
+		MemoryBytes: int64(r.MemoryPercent / 100 * float64(host.MemoryBytes)),
+	}
+	resolved, err := spec.ToCgroups()
+	if err != nil {
+		return err
+	}
+	if r.MemoryPercent != 0 {
+		r.Memory = resolved.Memory
+	}
+	if r.CpuPercent != 0 {
+		r.CpuQuota = resolved.CpuQuota
+		r.CpuPeriod = resolved.CpuPeriod
+	}
+	r.MemoryPercent, r.CpuPercent = 0, 0
+	return nil
+}
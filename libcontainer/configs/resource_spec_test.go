@@ -0,0 +1,93 @@
+// +build linux freebsd
+
+package configs_test
+
+import (
+	"testing"
+
+	"github.com/opencontainers/runc/libcontainer/configs"
+)
+
+func TestResourceSpecToCgroups(t *testing.T) {
+	for _, test := range []struct {
+		spec   configs.ResourceSpec
+		quota  int64
+		period uint64
+		shares uint64
+		memory uint64
+	}{
+		{configs.ResourceSpec{CPUs: 1.5}, 150000, 100000, 1536, 0},
+		{configs.ResourceSpec{CPUs: 0.25, MemoryBytes: 64 << 20}, 25000, 100000, 256, 64 << 20},
+		{configs.ResourceSpec{CPUs: 0.01}, 1000, 100000, 10, 0},
+		{configs.ResourceSpec{MemoryBytes: 1 << 30}, 0, 0, 0, 1 << 30},
+	} {
+		r, err := test.spec.ToCgroups()
+		if err != nil {
+			t.Errorf("%+v: %v", test.spec, err)
+			continue
+		}
+		if r.CpuQuota != test.quota || r.CpuPeriod != test.period || r.CpuShares != test.shares || r.Memory != test.memory {
+			t.Errorf("%+v: expected quota %d period %d shares %d memory %d, got quota %d period %d shares %d memory %d",
+				test.spec, test.quota, test.period, test.shares, test.memory, r.CpuQuota, r.CpuPeriod, r.CpuShares, r.Memory)
+		}
+	}
+}
+
+func TestResourceSpecToCgroupsInvalid(t *testing.T) {
+	for _, spec := range []configs.ResourceSpec{
+		{CPUs: -1},
+		{CPUs: 0.001},
+		{MemoryBytes: -1},
+	} {
+		if _, err := spec.ToCgroups(); err == nil {
+			t.Errorf("expected %+v to be rejected", spec)
+		}
+	}
+}
+
+func TestResolvePercentages(t *testing.T) {
+	host := configs.HostResources{CPUs: 4, MemoryBytes: 8 << 30}
+	r := &configs.Resources{
+		MemoryPercent: 50,
+		CpuPercent:    25,
+		CpuShares:     512,
+	}
+	if err := r.ResolvePercentages(host); err != nil {
+		t.Fatal(err)
+	}
+	if r.Memory != 4<<30 {
+		t.Errorf("expected 50%% of %d bytes to be %d, got %d", host.MemoryBytes, 4<<30, r.Memory)
+	}
+	if r.CpuQuota != 100000 || r.CpuPeriod != 100000 {
+		t.Errorf("expected 25%% of %d CPUs to be one CPU, got quota %d period %d", host.CPUs, r.CpuQuota, r.CpuPeriod)
+	}
+	if r.CpuShares != 512 {
+		t.Errorf("expected the configured shares to be kept, got %d", r.CpuShares)
+	}
+	if r.MemoryPercent != 0 || r.CpuPercent != 0 {
+		t.Errorf("expected the percentages to be cleared once resolved, got %+v", r)
+	}
+
+	unset := &configs.Resources{Memory: 1 << 20, CpuQuota: 5000}
+	if err := unset.ResolvePercentages(host); err != nil {
+		t.Fatal(err)
+	}
+	if unset.Memory != 1<<20 || unset.CpuQuota != 5000 {
+		t.Errorf("expected limits without percentages to be kept, got %+v", unset)
+	}
+
+	for _, pct := range []float64{-1, 101} {
+		if err := (&configs.Resources{MemoryPercent: pct}).ResolvePercentages(host); err == nil {
+			t.Errorf("expected %v%% to be rejected", pct)
+		}
+	}
+	for _, r := range []*configs.Resources{
+		{MemoryPercent: 50, Memory: 1 << 20},
+		{CpuPercent: 50, CpuQuota: 5000},
+		{CpuPercent: 50, CpuPeriod: 100000},
+	} {
+		if err := r.ResolvePercentages(host); err == nil {
+			t.Errorf("expected percentages combined with absolute limits to be rejected: %+v", r)
+		}
+	}
+}
@@ -100,7 +100,7 @@ func rootlessMount(config *configs.Config) error {
 	//      convinced that's a good idea. The kernel is the best arbiter of
 	//      access control.
 
-	for _, mount := range config.Mounts {
+	for _, mount := range config.AllMounts() {
 		// Check that the options list doesn't contain any uid= or gid= entries
 		// that don't resolve to root.
 		for _, opt := range strings.Split(mount.Data, ",") {
@@ -2,12 +2,19 @@ package validate
 
 import (
 	"fmt"
+	"io/ioutil"
+	"math"
 	"os"
 	"path/filepath"
+	"regexp"
+	"strconv"
 	"strings"
+	"syscall"
 
 	"github.com/opencontainers/runc/libcontainer/configs"
+	"github.com/opencontainers/runc/libcontainer/seccomp"
 	selinux "github.com/opencontainers/selinux/go-selinux"
+	"github.com/syndtr/gocapability/capability"
 )
 
 type Validator interface {
@@ -40,6 +47,90 @@ func (v *ConfigValidator) Validate(config *configs.Config) error {
 	if err := v.sysctl(config); err != nil {
 		return err
 	}
+	if err := v.socketBuffers(config); err != nil {
+		return err
+	}
+	if err := v.maxCoreSize(config); err != nil {
+		return err
+	}
+	if err := v.cgroupDelegation(config); err != nil {
+		return err
+	}
+	if err := v.cgroupPathTemplate(config); err != nil {
+		return err
+	}
+	if err := v.cpuAffinity(config); err != nil {
+		return err
+	}
+	if err := v.cgroupView(config); err != nil {
+		return err
+	}
+	if err := v.percentLimits(config); err != nil {
+		return err
+	}
+	if config.ParentDeathSignal < 0 || config.ParentDeathSignal >= 65 {
+		return fmt.Errorf("parent death signal %d is invalid", config.ParentDeathSignal)
+	}
+	if err := v.blockedSignals(config); err != nil {
+		return err
+	}
+	if err := v.parentDeathCleanup(config); err != nil {
+		return err
+	}
+	if err := v.hostPidProc(config); err != nil {
+		return err
+	}
+	if err := v.rootfsOwnership(config); err != nil {
+		return err
+	}
+	if err := v.idmapMounts(config); err != nil {
+		return err
+	}
+	if err := v.rootfsOverlay(config); err != nil {
+		return err
+	}
+	if err := v.rootfsImage(config); err != nil {
+		return err
+	}
+	if err := v.rootfsMountFlags(config); err != nil {
+		return err
+	}
+	if err := v.initFailure(config); err != nil {
+		return err
+	}
+	if err := v.hostEnv(config); err != nil {
+		return err
+	}
+	if err := v.fileCapabilities(config); err != nil {
+		return err
+	}
+	if err := v.seccomp(config); err != nil {
+		return err
+	}
+	if err := v.capabilities(config); err != nil {
+		return err
+	}
+	if err := v.ambientCapabilities(config); err != nil {
+		return err
+	}
+	if err := v.securebits(config); err != nil {
+		return err
+	}
+	if err := v.daemon(config); err != nil {
+		return err
+	}
+	if err := v.tmpfsDirs(config); err != nil {
+		return err
+	}
+	if config.QuiescedFile != "" && !filepath.IsAbs(config.QuiescedFile) {
+		return fmt.Errorf("quiesced file %s is not an absolute path", config.QuiescedFile)
+	}
+	if err := v.mountOrder(config); err != nil {
+		return err
+	}
+	if config.CgroupDrainTimeout < 0 {
+		return fmt.Errorf("cgroup drain timeout %s is negative", config.CgroupDrainTimeout)
+	}
 	if config.Rootless {
 		if err := v.rootless(config); err != nil {
 			return err
@@ -76,6 +167,56 @@ func (v *ConfigValidator) network(config *configs.Config) error {
 			return fmt.Errorf("unable to apply network settings without a private NET namespace")
 		}
 	}
+	if config.NetnsPath != "" {
+		if !filepath.IsAbs(config.NetnsPath) {
+			return fmt.Errorf("netns path %q is not absolute", config.NetnsPath)
+		}
+		if !config.Namespaces.Contains(configs.NEWNET) || config.Namespaces.PathOf(configs.NEWNET) != "" {
+			return fmt.Errorf("netns path %q requires a new NET namespace", config.NetnsPath)
+		}
+		if config.Rootless {
+			return fmt.Errorf("netns path %q cannot be bound by a rootless container", config.NetnsPath)
+		}
+	}
+	for _, n := range config.Networks {
+		switch n.ArpIgnore {
+		case 0, 1, 2, 3, 8:
+		default:
+			return fmt.Errorf("invalid arp_ignore %d for network %q", n.ArpIgnore, n.Name)
+		}
+		if n.ArpAnnounce < 0 || n.ArpAnnounce > 2 {
+			return fmt.Errorf("invalid arp_announce %d for network %q", n.ArpAnnounce, n.Name)
+		}
+		if len(n.PeerName) >= syscall.IFNAMSIZ {
+			return fmt.Errorf("peer name %q of network %q is longer than %d characters", n.PeerName, n.Name, syscall.IFNAMSIZ-1)
+		}
+		if n.PeerName != "" && n.Type != "veth" {
+			return fmt.Errorf("peer name only applies to veth networks, not %q", n.Name)
+		}
+		switch {
+		case n.RouteTable < 0 || int64(n.RouteTable) > math.MaxUint32:
+			return fmt.Errorf("invalid route table %d for network %q", n.RouteTable, n.Name)
+		case n.RouteTable >= 253 && n.RouteTable <= 255:
+			return fmt.Errorf("route table %d of network %q is reserved", n.RouteTable, n.Name)
+		case n.RouteTable != 0 && n.Type == "loopback":
+			return fmt.Errorf("route table only applies to veth and macvlan networks, not %q", n.Name)
+		}
+		if n.Offloads != nil && n.Type != "veth" && n.Type != "macvlan" {
+			return fmt.Errorf("offloads only apply to veth and macvlan networks, not %q", n.Name)
+		}
+		if n.Type == "macvlan" {
+			if n.Parent == "" {
+				return fmt.Errorf("macvlan network %q requires a parent interface", n.Name)
+			}
+			switch n.MacvlanMode {
+			case "", "bridge", "private", "vepa", "passthru":
+			default:
+				return fmt.Errorf("invalid macvlan mode %q for network %q", n.MacvlanMode, n.Name)
+			}
+		} else if n.Parent != "" || n.MacvlanMode != "" {
+			return fmt.Errorf("parent and macvlan mode only apply to macvlan networks, not %q", n.Name)
+		}
+	}
 	return nil
 }
 
@@ -112,6 +253,531 @@ func (v *ConfigValidator) usernamespace(config *configs.Config) error {
 	return nil
 }
 
+// knownCapabilities returns the names of the capabilities known to the
+// kernel headers we were built with.
+func knownCapabilities() map[string]bool {
+	known := make(map[string]bool)
+	for _, c := range capability.List() {
+		known["CAP_"+strings.ToUpper(c.String())] = true
+	}
+	return known
+}
+
+// capabilities validates that the capability sets only use known names, so
+// a typo fails the container before it starts rather than in its init.
+func (v *ConfigValidator) capabilities(config *configs.Config) error {
+	caps := config.Capabilities
+	if caps == nil {
+		return nil
+	}
+	known := knownCapabilities()
+	for _, set := range []struct {
+		name string
+		caps []string
+	}{
+		{"bounding", caps.Bounding},
+		{"effective", caps.Effective},
+		{"inheritable", caps.Inheritable},
+		{"permitted", caps.Permitted},
+		{"ambient", caps.Ambient},
+	} {
+		for _, c := range set.caps {
+			if !known[c] {
+				return fmt.Errorf("unknown %s capability %q", set.name, c)
+			}
+		}
+	}
+	return nil
+}
+
+// fileCapabilities validates that file capabilities name a file and only
+// use capabilities known to the kernel headers we were built with.
+func (v *ConfigValidator) fileCapabilities(config *configs.Config) error {
+	if len(config.FileCapabilities) == 0 {
+		return nil
+	}
+	known := knownCapabilities()
+	for _, fc := range config.FileCapabilities {
+		if fc.Path == "" {
+			return fmt.Errorf("file capabilities %v are missing a path", fc.Capabilities)
+		}
+		for _, c := range fc.Capabilities {
+			if !known[c] {
+				return fmt.Errorf("unknown capability %q for %s", c, fc.Path)
+			}
+		}
+	}
+	return nil
+}
+
+// seccompSyscallName matches the names of syscalls.
+var seccompSyscallName = regexp.MustCompile(`^[a-z_][a-z0-9_]*$`)
+
+// seccomp validates that the rules of the seccomp filter name syscalls of
+// one of its architectures and use the actions and operators the filter
+// setup supports.
+func (v *ConfigValidator) seccomp(config *configs.Config) error {
+	if config.Seccomp == nil {
+		return nil
+	}
+	if config.Seccomp.DefaultAction < configs.Kill || config.Seccomp.DefaultAction > configs.Log {
+		return fmt.Errorf("invalid seccomp default action %d", config.Seccomp.DefaultAction)
+	}
+	if config.Seccomp.DefaultAction == configs.Log {
+		return fmt.Errorf("seccomp log action cannot be the default action")
+	}
+	for _, call := range config.Seccomp.Syscalls {
+		if call == nil {
+			return fmt.Errorf("seccomp syscall rule is empty")
+		}
+		if !seccompSyscallName.MatchString(call.Name) {
+			return fmt.Errorf("invalid seccomp syscall name %q", call.Name)
+		}
+		known, err := seccomp.SyscallKnown(call.Name, config.Seccomp.Architectures)
+		if err != nil {
+			return err
+		}
+		if !known {
+			return fmt.Errorf("unknown seccomp syscall %q", call.Name)
+		}
+		if call.Action < configs.Kill || call.Action > configs.Log {
+			return fmt.Errorf("invalid seccomp action %d for syscall %s", call.Action, call.Name)
+		}
+		if call.Action == configs.Log && len(call.Args) > 0 {
+			return fmt.Errorf("seccomp log action of syscall %s cannot have argument conditions", call.Name)
+		}
+		for _, arg := range call.Args {
+			if arg == nil || arg.Op < configs.EqualTo || arg.Op > configs.MaskEqualTo {
+				return fmt.Errorf("invalid seccomp argument condition for syscall %s", call.Name)
+			}
+		}
+	}
+	return nil
+}
+
+// ambientCapabilities validates that the ambient capabilities can be raised,
+// which the kernel only allows for capabilities both permitted and
+// inheritable.
+func (v *ConfigValidator) ambientCapabilities(config *configs.Config) error {
+	caps := config.Capabilities
+	if caps == nil || caps.BoundingOnly {
+		return nil
+	}
+	raisable := make(map[string]bool)
+	for _, c := range caps.Inheritable {
+		raisable[c] = true
+	}
+	permitted := make(map[string]bool)
+	for _, c := range caps.Permitted {
+		permitted[c] = true
+	}
+	for _, c := range caps.Ambient {
+		if !raisable[c] || !permitted[c] {
+			return fmt.Errorf("ambient capability %s is not both permitted and inheritable", c)
+		}
+	}
+	return nil
+}
+
+// securebits validates that only known securebits are set, and none that
+// would break the init: it needs to keep its capabilities over the switch to
+// the user of the process, and to raise the ambient capabilities.
+func (v *ConfigValidator) securebits(config *configs.Config) error {
+	bits := config.Securebits
+	if bits&^(configs.SecbitNoCapAmbientRaiseLocked<<1-1) != 0 {
+		return fmt.Errorf("unknown securebits %#x", uint(bits))
+	}
+	if bits&(configs.SecbitKeepCaps|configs.SecbitKeepCapsLocked) != 0 {
+		return fmt.Errorf("securebits cannot set or lock keep caps")
+	}
+	if bits&configs.SecbitNoCapAmbientRaise != 0 && config.Capabilities != nil && len(config.Capabilities.Ambient) > 0 {
+		return fmt.Errorf("securebits keep the ambient capabilities from being raised")
+	}
+	return nil
+}
+
+// socketBuffers validates that the socket buffer sizes are applied to a
+// network namespace of the container's own and that the TCP settings are
+// well formed.
+func (v *ConfigValidator) socketBuffers(config *configs.Config) error {
+	b := config.SocketBuffers
+	if b == nil {
+		return nil
+	}
+	if !config.Namespaces.Contains(configs.NEWNET) {
+		return fmt.Errorf("socket buffers are not allowed in the hosts network namespace")
+	}
+	if path := config.Namespaces.PathOf(configs.NEWNET); path != "" {
+		for key := range b.Sysctl() {
+			if err := checkHostNs(key, path); err != nil {
+				return err
+			}
+		}
+	}
+	for name, values := range map[string][]uint64{
+		"tcp_rmem": b.TCPRmem,
+		"tcp_wmem": b.TCPWmem,
+	} {
+		if len(values) == 0 {
+			continue
+		}
+		if len(values) != 3 {
+			return fmt.Errorf("%s must have min, default and max values", name)
+		}
+		if values[0] > values[1] || values[1] > values[2] {
+			return fmt.Errorf("%s values must be in ascending order", name)
+		}
+	}
+	return nil
+}
+
+// maxCoreSize validates that the core dump limit does not conflict with an
+// RLIMIT_CORE set in the rlimits.
+func (v *ConfigValidator) maxCoreSize(config *configs.Config) error {
+	if config.MaxCoreSize == nil {
+		return nil
+	}
+	for _, rl := range config.Rlimits {
+		if rl.Type == syscall.RLIMIT_CORE {
+			return fmt.Errorf("max core size conflicts with the RLIMIT_CORE rlimit")
+		}
+	}
+	return nil
+}
+
+// cgroupDelegation validates that delegated cgroups can be handed over to
+// the container and are mounted writable inside it.
+func (v *ConfigValidator) cgroupDelegation(config *configs.Config) error {
+	if config.Cgroups == nil || !config.Cgroups.Delegate {
+		return nil
+	}
+	if config.Rootless {
+		return fmt.Errorf("cgroups cannot be delegated in a rootless container")
+	}
+	for _, m := range config.AllMounts() {
+		if m.Device == "cgroup" && m.Flags&syscall.MS_RDONLY != 0 {
+			return fmt.Errorf("delegated cgroups cannot be mounted read-only at %s", m.Destination)
+		}
+	}
+	return nil
+}
+
+// cgroupPathTemplate validates that a cgroup path template is the only path
+// of the cgroups and expands to a valid path. The id of the container is not
+// known yet, so a stand-in is used.
+func (v *ConfigValidator) cgroupPathTemplate(config *configs.Config) error {
+	if config.Cgroups == nil || config.Cgroups.PathTemplate == "" {
+		return nil
+	}
+	cg := config.Cgroups
+	if cg.Path != "" || cg.Name != "" || cg.Parent != "" || cg.Paths != nil {
+		return fmt.Errorf("cgroup path template cannot be combined with a cgroup path")
+	}
+	_, err := cg.ExpandPathTemplate("id", config.Labels)
+	return err
+}
+
+// percentLimits validates that limits set as percentages of the host's
+// resources are not also set as absolute ones, as one would silently win.
+func (v *ConfigValidator) percentLimits(config *configs.Config) error {
+	if config.Cgroups == nil || config.Cgroups.Resources == nil {
+		return nil
+	}
+	r := config.Cgroups.Resources
+	if r.MemoryPercent != 0 && r.Memory != 0 {
+		return fmt.Errorf("memory percentage cannot be combined with a memory limit")
+	}
+	if r.CpuPercent != 0 && (r.CpuQuota != 0 || r.CpuPeriod != 0) {
+		return fmt.Errorf("cpu percentage cannot be combined with a cpu quota or period")
+	}
+	return nil
+}
+
+// cgroupView validates that the cgroup view is mounted below the root.
+func (v *ConfigValidator) cgroupView(config *configs.Config) error {
+	if config.CgroupView == nil {
+		return nil
+	}
+	dest := filepath.Clean(config.CgroupView.Destination)
+	if !filepath.IsAbs(dest) || dest == "/" {
+		return fmt.Errorf("cgroup view destination %q must be an absolute path below /", config.CgroupView.Destination)
+	}
+	return nil
+}
+
+// blockedSignals validates that the blocked signals can be blocked and that
+// the init does not need them to forward signals to the process.
+func (v *ConfigValidator) blockedSignals(config *configs.Config) error {
+	if len(config.BlockedSignals) == 0 {
+		return nil
+	}
+	if config.MinimalInit {
+		return fmt.Errorf("blocked signals cannot be used with a minimal init")
+	}
+	for _, sig := range config.BlockedSignals {
+		if sig <= 0 || sig >= 65 {
+			return fmt.Errorf("blocked signal %d is invalid", sig)
+		}
+		if sig == int(syscall.SIGKILL) || sig == int(syscall.SIGSTOP) {
+			return fmt.Errorf("signal %d cannot be blocked", sig)
+		}
+	}
+	return nil
+}
+
+// parentDeathCleanup validates that the init can clean up after the death of
+// its parent.
+func (v *ConfigValidator) parentDeathCleanup(config *configs.Config) error {
+	cleanup := config.ParentDeathCleanup
+	if cleanup == nil {
+		return nil
+	}
+	if config.ParentDeathSignal == int(syscall.SIGKILL) {
+		return fmt.Errorf("parent death cleanup requires a parent death signal other than SIGKILL")
+	}
+	if cleanup.Signal < 0 || cleanup.Signal >= 65 {
+		return fmt.Errorf("parent death cleanup signal %d is invalid", cleanup.Signal)
+	}
+	if cleanup.Timeout < 0 {
+		return fmt.Errorf("parent death cleanup timeout %s is negative", cleanup.Timeout)
+	}
+	return nil
+}
+
+// hostPidProc validates that the host PID proc mode is known and that the
+// container shares the host's PID namespace for it.
+func (v *ConfigValidator) hostPidProc(config *configs.Config) error {
+	switch config.HostPidProc {
+	case "":
+		return nil
+	case configs.HostPidProcMount, configs.HostPidProcBind, configs.HostPidProcMasked:
+	default:
+		return fmt.Errorf("unknown host pid proc mode %q", config.HostPidProc)
+	}
+	if config.Namespaces.Contains(configs.NEWPID) {
+		return fmt.Errorf("host pid proc mode %q requires the host's PID namespace", config.HostPidProc)
+	}
+	return nil
+}
+
+// rootfsOwnership validates that the rootfs ownership mode is known and that
+// the container has a user namespace and a privileged manager for it.
+func (v *ConfigValidator) rootfsOwnership(config *configs.Config) error {
+	switch config.RootfsOwnership {
+	case "":
+		return nil
+	case configs.RootfsChown, configs.RootfsChownRecursive, configs.RootfsIdmap:
+	default:
+		return fmt.Errorf("unknown rootfs ownership %q", config.RootfsOwnership)
+	}
+	if !config.Namespaces.Contains(configs.NEWUSER) {
+		return fmt.Errorf("rootfs ownership %q requires a user namespace", config.RootfsOwnership)
+	}
+	if config.Rootless {
+		return fmt.Errorf("rootfs ownership %q cannot be set up by a rootless container", config.RootfsOwnership)
+	}
+	return nil
+}
+
+// rootfsOverlay validates that the directories of the overlay are either
+// both given, as absolute paths, or both left to libcontainer.
+func (v *ConfigValidator) rootfsOverlay(config *configs.Config) error {
+	overlay := config.RootfsOverlay
+	if overlay == nil {
+		return nil
+	}
+	if overlay.Tmpfs && (overlay.UpperDir != "" || overlay.WorkDir != "") {
+		return fmt.Errorf("rootfs overlay on a tmpfs cannot have an upper or a work directory")
+	}
+	if (overlay.UpperDir == "") != (overlay.WorkDir == "") {
+		return fmt.Errorf("rootfs overlay requires both an upper and a work directory, or neither")
+	}
+	for _, dir := range []string{overlay.UpperDir, overlay.WorkDir} {
+		if dir != "" && !filepath.IsAbs(dir) {
+			return fmt.Errorf("rootfs overlay directory %s is not an absolute path", dir)
+		}
+	}
+	return nil
+}
+
+// rootfsImage validates that the rootfs image is a file of a supported
+// filesystem, mounted by an init that is privileged on the host.
+func (v *ConfigValidator) rootfsImage(config *configs.Config) error {
+	image := config.RootfsImage
+	if image == nil {
+		return nil
+	}
+	if !filepath.IsAbs(image.Path) {
+		return fmt.Errorf("rootfs image %s is not an absolute path", image.Path)
+	}
+	fi, err := os.Stat(image.Path)
+	if err != nil {
+		return err
+	}
+	if !fi.Mode().IsRegular() {
+		return fmt.Errorf("rootfs image %s is not a regular file", image.Path)
+	}
+	if image.Type != "squashfs" && image.Type != "erofs" {
+		return fmt.Errorf("rootfs image type %q is not squashfs or erofs", image.Type)
+	}
+	if !config.Namespaces.Contains(configs.NEWNS) {
+		return fmt.Errorf("rootfs image requires a private MNT namespace")
+	}
+	if config.Namespaces.Contains(configs.NEWUSER) || config.Rootless {
+		return fmt.Errorf("rootfs image cannot be mounted in a user namespace")
+	}
+	if config.RootfsOwnership != "" {
+		return fmt.Errorf("rootfs ownership cannot be set up for a rootfs image")
+	}
+	return nil
+}
+
+// rootfsMountFlags validates that the rootfs is only remounted with flags
+// that restrict what the files on it can do.
+func (v *ConfigValidator) rootfsMountFlags(config *configs.Config) error {
+	if config.RootfsMountFlags&^(syscall.MS_NOSUID|syscall.MS_NODEV|syscall.MS_NOEXEC) != 0 {
+		return fmt.Errorf("rootfs mount flags %#x are not a combination of nosuid, nodev and noexec", config.RootfsMountFlags)
+	}
+	return nil
+}
+
+// idmapMounts validates that idmapped mounts are bind mounts of a container
+// with a user namespace, which only a privileged manager can set up.
+func (v *ConfigValidator) idmapMounts(config *configs.Config) error {
+	for _, m := range config.AllMounts() {
+		if m.Extensions&configs.EXT_IDMAP == 0 {
+			continue
+		}
+		if m.Device != "bind" {
+			return fmt.Errorf("idmapped mount %s must be a bind mount", m.Destination)
+		}
+		if !config.Namespaces.Contains(configs.NEWUSER) {
+			return fmt.Errorf("idmapped mount %s requires a user namespace", m.Destination)
+		}
+		if config.Rootless {
+			return fmt.Errorf("idmapped mount %s cannot be set up by a rootless container", m.Destination)
+		}
+	}
+	return nil
+}
+
+// cpuAffinity validates that the CPUs of the affinity mask are online.
+func (v *ConfigValidator) cpuAffinity(config *configs.Config) error {
+	if len(config.CpuAffinity) == 0 {
+		return nil
+	}
+	data, err := ioutil.ReadFile("/sys/devices/system/cpu/online")
+	if err != nil {
+		return err
+	}
+	online, err := parseCPUList(strings.TrimSpace(string(data)))
+	if err != nil {
+		return err
+	}
+	for _, cpu := range config.CpuAffinity {
+		if !online[cpu] {
+			return fmt.Errorf("cpu %d in the affinity mask is not online", cpu)
+		}
+	}
+	return nil
+}
+
+// parseCPUList parses a CPU list such as "0-3,8" in the format used by
+// /sys/devices/system/cpu.
+func parseCPUList(list string) (map[int]bool, error) {
+	cpus := make(map[int]bool)
+	if list == "" {
+		return cpus, nil
+	}
+	for _, r := range strings.Split(list, ",") {
+		bounds := strings.SplitN(r, "-", 2)
+		first, err := strconv.Atoi(bounds[0])
+		if err != nil {
+			return nil, fmt.Errorf("invalid cpu list %q", list)
+		}
+		last := first
+		if len(bounds) == 2 {
+			if last, err = strconv.Atoi(bounds[1]); err != nil || last < first {
+				return nil, fmt.Errorf("invalid cpu list %q", list)
+			}
+		}
+		for cpu := first; cpu <= last; cpu++ {
+			cpus[cpu] = true
+		}
+	}
+	return cpus, nil
+}
+
+// hostEnv validates the names of the variables passed from the host.
+func (v *ConfigValidator) hostEnv(config *configs.Config) error {
+	for _, name := range config.HostEnv {
+		if name == "" || strings.Contains(name, "=") {
+			return fmt.Errorf("invalid host environment variable name %q", name)
+		}
+	}
+	return nil
+}
+
+// daemon validates that the files of daemon mode are absolute paths in the
+// container, which needs its own mount namespace for them not to be opened
+// on the host.
+func (v *ConfigValidator) daemon(config *configs.Config) error {
+	if config.Daemon == nil {
+		return nil
+	}
+	if !config.Namespaces.Contains(configs.NEWNS) {
+		return fmt.Errorf("daemon mode requires a mount namespace")
+	}
+	for _, path := range []string{config.Daemon.Stdout, config.Daemon.Stderr} {
+		if path != "" && !filepath.IsAbs(path) {
+			return fmt.Errorf("daemon stdio %q is not an absolute path", path)
+		}
+	}
+	return nil
+}
+
+// mountOrder validates that the mounts can be ordered parent before child.
+func (v *ConfigValidator) mountOrder(config *configs.Config) error {
+	_, err := configs.OrderMounts(config.AllMounts())
+	return err
+}
+
+// tmpfsDirs validates that the directories overlaid with a tmpfs are below
+// the rootfs and named once.
+func (v *ConfigValidator) tmpfsDirs(config *configs.Config) error {
+	seen := make(map[string]bool)
+	for _, dir := range config.TmpfsDirs {
+		if !filepath.IsAbs(dir) {
+			return fmt.Errorf("tmpfs directory %q is not an absolute path", dir)
+		}
+		clean := filepath.Clean(dir)
+		if clean == "/" {
+			return fmt.Errorf("tmpfs directory cannot be the rootfs")
+		}
+		if seen[clean] {
+			return fmt.Errorf("tmpfs directory %q is named more than once", dir)
+		}
+		seen[clean] = true
+	}
+	return nil
+}
+
+// initFailure validates that the init failure policy uses an exit code and
+// signal that the init is able to terminate with.
+func (v *ConfigValidator) initFailure(config *configs.Config) error {
+	p := config.InitFailure
+	if p == nil {
+		return nil
+	}
+	if p.ExitCode < 0 || p.ExitCode > 255 {
+		return fmt.Errorf("init failure exit code %d is out of range", p.ExitCode)
+	}
+	if p.Signal < 0 || p.Signal > 64 {
+		return fmt.Errorf("init failure signal %d is invalid", p.Signal)
+	}
+	return nil
+}
+
 // sysctl validates that the specified sysctl keys are valid or not.
 // /proc/sys isn't completely namespaced and depending on which namespaces
 // are specified, a subset of sysctls are permitted.
@@ -128,6 +794,9 @@ func (v *ConfigValidator) sysctl(config *configs.Config) error {
 	}
 
 	for s := range config.Sysctl {
+		if s == "kernel.core_pattern" {
+			return fmt.Errorf("sysctl %q is not namespaced, use max_core_size to limit the core dumps of the container", s)
+		}
 		if validSysctlMap[s] || strings.HasPrefix(s, "fs.mqueue.") {
 			if config.Namespaces.Contains(configs.NEWIPC) {
 				continue
@@ -0,0 +1,40 @@
+// +build linux,cgo,seccomp
+
+package validate_test
+
+import (
+	"testing"
+
+	"github.com/opencontainers/runc/libcontainer/configs"
+	"github.com/opencontainers/runc/libcontainer/configs/validate"
+)
+
+func TestValidateSeccompSyscallNames(t *testing.T) {
+	for _, test := range []struct {
+		name   string
+		arches []string
+		valid  bool
+	}{
+		{"getcwd", nil, true},
+		// Only the 32 bit x86 ABI has socketcall.
+		{"socketcall", []string{"x86"}, true},
+		{"not_a_syscall", nil, false},
+		{"not_a_syscall", []string{"x86"}, false},
+	} {
+		config := &configs.Config{
+			Rootfs: "/var",
+			Seccomp: &configs.Seccomp{
+				DefaultAction: configs.Allow,
+				Architectures: test.arches,
+				Syscalls:      []*configs.Syscall{{Name: test.name, Action: configs.Errno}},
+			},
+		}
+		err := validate.New().Validate(config)
+		if test.valid && err != nil {
+			t.Errorf("expected syscall %s on %v to be valid: %v", test.name, test.arches, err)
+		}
+		if !test.valid && err == nil {
+			t.Errorf("expected syscall %s on %v to be rejected", test.name, test.arches)
+		}
+	}
+}
@@ -1,8 +1,12 @@
 package validate_test
 
 import (
+	"io/ioutil"
 	"os"
+	"strings"
+	"syscall"
 	"testing"
+	"time"
 
 	"github.com/opencontainers/runc/libcontainer/configs"
 	"github.com/opencontainers/runc/libcontainer/configs/validate"
@@ -265,3 +269,887 @@ func TestValidateSysctlWithoutNETNamespace(t *testing.T) {
 		t.Error("Expected error to occur but it was nil")
 	}
 }
+
+func TestValidateSocketBuffers(t *testing.T) {
+	netns := configs.Namespaces{{Type: configs.NEWNET}}
+	hostns := configs.Namespaces{{Type: configs.NEWNET, Path: "/proc/self/ns/net"}}
+	for _, test := range []struct {
+		namespaces configs.Namespaces
+		buffers    configs.SocketBuffers
+		valid      bool
+	}{
+		{netns, configs.SocketBuffers{RmemMax: 1 << 24, TCPRmem: []uint64{4096, 87380, 1 << 24}}, true},
+		{netns, configs.SocketBuffers{TCPWmem: []uint64{4096, 16384}}, false},
+		{netns, configs.SocketBuffers{TCPRmem: []uint64{87380, 4096, 1 << 24}}, false},
+		{nil, configs.SocketBuffers{RmemMax: 1 << 24}, false},
+		{hostns, configs.SocketBuffers{WmemMax: 1 << 24}, false},
+	} {
+		buffers := test.buffers
+		config := &configs.Config{
+			Rootfs:        "/var",
+			Namespaces:    test.namespaces,
+			SocketBuffers: &buffers,
+		}
+		err := validate.New().Validate(config)
+		if test.valid && err != nil {
+			t.Errorf("expected socket buffers %+v to be valid: %v", buffers, err)
+		}
+		if !test.valid && err == nil {
+			t.Errorf("expected socket buffers %+v in namespaces %v to be invalid", buffers, test.namespaces)
+		}
+	}
+}
+
+func TestValidateMaxCoreSize(t *testing.T) {
+	var zero uint64
+	config := &configs.Config{
+		Rootfs:      "/var",
+		MaxCoreSize: &zero,
+	}
+	if err := validate.New().Validate(config); err != nil {
+		t.Fatal(err)
+	}
+	config.Rlimits = []configs.Rlimit{{Type: syscall.RLIMIT_CORE, Hard: 1024, Soft: 1024}}
+	if err := validate.New().Validate(config); err == nil {
+		t.Fatal("expected max core size and an RLIMIT_CORE rlimit to conflict")
+	}
+
+	config = &configs.Config{
+		Rootfs:     "/var",
+		Sysctl:     map[string]string{"kernel.core_pattern": "core"},
+		Namespaces: configs.Namespaces{{Type: configs.NEWIPC}, {Type: configs.NEWNET}},
+	}
+	if err := validate.New().Validate(config); err == nil || !strings.Contains(err.Error(), "max_core_size") {
+		t.Fatalf("expected kernel.core_pattern to be rejected in favour of max_core_size, got %v", err)
+	}
+}
+
+func TestValidateCgroupDelegation(t *testing.T) {
+	for _, test := range []struct {
+		flags    int
+		rootless bool
+		valid    bool
+	}{
+		{0, false, true},
+		{syscall.MS_RDONLY, false, false},
+		{0, true, false},
+	} {
+		config := &configs.Config{
+			Rootfs:   "/var",
+			Rootless: test.rootless,
+			Cgroups:  &configs.Cgroup{Delegate: true},
+			Mounts: []*configs.Mount{
+				{Device: "cgroup", Destination: "/sys/fs/cgroup", Flags: test.flags},
+			},
+		}
+		err := validate.New().Validate(config)
+		if test.valid && err != nil {
+			t.Errorf("expected delegation with mount flags %#x and rootless %v to be valid: %v", test.flags, test.rootless, err)
+		}
+		if !test.valid && err == nil {
+			t.Errorf("expected delegation with mount flags %#x and rootless %v to be invalid", test.flags, test.rootless)
+		}
+	}
+}
+
+func TestValidateCpuAffinity(t *testing.T) {
+	for _, test := range []struct {
+		cpus  []int
+		valid bool
+	}{
+		{[]int{0}, true},
+		{[]int{0, 1 << 20}, false},
+		{[]int{-1}, false},
+	} {
+		config := &configs.Config{
+			Rootfs:      "/var",
+			CpuAffinity: test.cpus,
+		}
+		err := validate.New().Validate(config)
+		if test.valid && err != nil {
+			t.Errorf("expected cpu affinity %v to be valid: %v", test.cpus, err)
+		}
+		if !test.valid && err == nil {
+			t.Errorf("expected cpu affinity %v to be invalid", test.cpus)
+		}
+	}
+}
+
+func TestValidateCgroupView(t *testing.T) {
+	for dest, valid := range map[string]bool{
+		"/run/cgroup": true,
+		"run/cgroup":  false,
+		"/":           false,
+		"/run/..":     false,
+	} {
+		config := &configs.Config{
+			Rootfs:     "/var",
+			CgroupView: &configs.CgroupView{Destination: dest},
+		}
+		err := validate.New().Validate(config)
+		if valid && err != nil {
+			t.Errorf("expected cgroup view at %q to be valid: %v", dest, err)
+		}
+		if !valid && err == nil {
+			t.Errorf("expected cgroup view at %q to be invalid", dest)
+		}
+	}
+}
+
+func TestValidatePercentLimits(t *testing.T) {
+	for _, test := range []struct {
+		resources configs.Resources
+		valid     bool
+	}{
+		{configs.Resources{MemoryPercent: 50, CpuPercent: 50}, true},
+		{configs.Resources{MemoryPercent: 50, CpuQuota: 5000, CpuPeriod: 100000}, true},
+		{configs.Resources{CpuPercent: 50, Memory: 1 << 20}, true},
+		{configs.Resources{MemoryPercent: 50, Memory: 1 << 20}, false},
+		{configs.Resources{CpuPercent: 50, CpuQuota: 5000}, false},
+		{configs.Resources{CpuPercent: 50, CpuPeriod: 100000}, false},
+	} {
+		resources := test.resources
+		config := &configs.Config{
+			Rootfs:  "/var",
+			Cgroups: &configs.Cgroup{Resources: &resources},
+		}
+		err := validate.New().Validate(config)
+		if test.valid && err != nil {
+			t.Errorf("expected resources %+v to be valid: %v", resources, err)
+		}
+		if !test.valid && err == nil {
+			t.Errorf("expected resources %+v to be invalid", resources)
+		}
+	}
+}
+
+func TestValidateInitFailure(t *testing.T) {
+	policies := map[*configs.InitFailurePolicy]bool{
+		{ExitCode: 125}:             true,
+		{Signal: 9}:                 true,
+		{ExitCode: 256}:             false,
+		{ExitCode: -1}:              false,
+		{ExitCode: 125, Signal: 65}: false,
+	}
+	for p, valid := range policies {
+		config := &configs.Config{
+			Rootfs:      "/var",
+			InitFailure: p,
+		}
+
+		validator := validate.New()
+		err := validator.Validate(config)
+		if valid && err != nil {
+			t.Errorf("Expected policy %+v to be valid: %v", p, err)
+		}
+		if !valid && err == nil {
+			t.Errorf("Expected policy %+v to be rejected", p)
+		}
+	}
+}
+
+func TestValidateParentDeathCleanup(t *testing.T) {
+	for _, test := range []struct {
+		pdeathsig int
+		cleanup   configs.ParentDeathCleanup
+		valid     bool
+	}{
+		{0, configs.ParentDeathCleanup{}, true},
+		{int(syscall.SIGTERM), configs.ParentDeathCleanup{Signal: int(syscall.SIGTERM), Timeout: time.Second}, true},
+		{int(syscall.SIGKILL), configs.ParentDeathCleanup{}, false},
+		{0, configs.ParentDeathCleanup{Signal: 65}, false},
+		{0, configs.ParentDeathCleanup{Timeout: -time.Second}, false},
+	} {
+		cleanup := test.cleanup
+		config := &configs.Config{
+			Rootfs:             "/var",
+			ParentDeathSignal:  test.pdeathsig,
+			ParentDeathCleanup: &cleanup,
+		}
+		err := validate.New().Validate(config)
+		if test.valid && err != nil {
+			t.Errorf("expected cleanup %+v with parent death signal %d to be valid: %v", cleanup, test.pdeathsig, err)
+		}
+		if !test.valid && err == nil {
+			t.Errorf("expected cleanup %+v with parent death signal %d to be rejected", cleanup, test.pdeathsig)
+		}
+	}
+}
+
+func TestValidateQuiescedFile(t *testing.T) {
+	for path, valid := range map[string]bool{
+		"":          true,
+		"/quiesced": true,
+		"quiesced":  false,
+	} {
+		config := &configs.Config{
+			Rootfs:       "/var",
+			QuiescedFile: path,
+		}
+		err := validate.New().Validate(config)
+		if valid && err != nil {
+			t.Errorf("expected quiesced file %q to be valid: %v", path, err)
+		}
+		if !valid && err == nil {
+			t.Errorf("expected quiesced file %q to be rejected", path)
+		}
+	}
+}
+
+func TestValidateParentDeathSignal(t *testing.T) {
+	for sig, valid := range map[int]bool{
+		0:                    true,
+		int(syscall.SIGTERM): true,
+		64:                   true,
+		65:                   false,
+		-1:                   false,
+	} {
+		config := &configs.Config{
+			Rootfs:            "/var",
+			ParentDeathSignal: sig,
+		}
+		err := validate.New().Validate(config)
+		if valid && err != nil {
+			t.Errorf("expected parent death signal %d to be valid: %v", sig, err)
+		}
+		if !valid && err == nil {
+			t.Errorf("expected parent death signal %d to be rejected", sig)
+		}
+	}
+}
+
+func TestValidateHostPidProc(t *testing.T) {
+	for _, test := range []struct {
+		mode  configs.HostPidProc
+		pidns bool
+		valid bool
+	}{
+		{"", true, true},
+		{configs.HostPidProcMount, false, true},
+		{configs.HostPidProcBind, false, true},
+		{configs.HostPidProcMasked, false, true},
+		{configs.HostPidProcMasked, true, false},
+		{"hide", false, false},
+	} {
+		config := &configs.Config{
+			Rootfs:      "/var",
+			HostPidProc: test.mode,
+		}
+		if test.pidns {
+			config.Namespaces = configs.Namespaces{{Type: configs.NEWPID}}
+		}
+		err := validate.New().Validate(config)
+		if test.valid && err != nil {
+			t.Errorf("expected mode %q with pid namespace %v to be valid: %v", test.mode, test.pidns, err)
+		}
+		if !test.valid && err == nil {
+			t.Errorf("expected mode %q with pid namespace %v to be rejected", test.mode, test.pidns)
+		}
+	}
+}
+
+func TestValidateRootfsOwnership(t *testing.T) {
+	if _, err := os.Stat("/proc/self/ns/user"); os.IsNotExist(err) {
+		t.Skip("userns is unsupported")
+	}
+	for _, test := range []struct {
+		mode     configs.RootfsOwnership
+		userns   bool
+		rootless bool
+		valid    bool
+	}{
+		{"", false, false, true},
+		{configs.RootfsChown, true, false, true},
+		{configs.RootfsChownRecursive, true, false, true},
+		{configs.RootfsIdmap, true, false, true},
+		{configs.RootfsIdmap, false, false, false},
+		{configs.RootfsChown, true, true, false},
+		{"shift", true, false, false},
+	} {
+		config := &configs.Config{
+			Rootfs:          "/var",
+			RootfsOwnership: test.mode,
+			Rootless:        test.rootless,
+		}
+		if test.userns {
+			config.Namespaces = configs.Namespaces{{Type: configs.NEWUSER}}
+		}
+		err := validate.New().Validate(config)
+		if test.valid && err != nil {
+			t.Errorf("expected %+v to be valid: %v", test, err)
+		}
+		if !test.valid && err == nil {
+			t.Errorf("expected %+v to be rejected", test)
+		}
+	}
+}
+
+func TestValidateIdmapMounts(t *testing.T) {
+	if _, err := os.Stat("/proc/self/ns/user"); os.IsNotExist(err) {
+		t.Skip("userns is unsupported")
+	}
+	for _, test := range []struct {
+		device   string
+		userns   bool
+		rootless bool
+		valid    bool
+	}{
+		{"bind", true, false, true},
+		{"bind", false, false, false},
+		{"bind", true, true, false},
+		{"tmpfs", true, false, false},
+	} {
+		config := &configs.Config{
+			Rootfs:   "/var",
+			Rootless: test.rootless,
+			Mounts: []*configs.Mount{{
+				Source:      "/var/lib",
+				Destination: "/data",
+				Device:      test.device,
+				Extensions:  configs.EXT_IDMAP,
+			}},
+		}
+		if test.userns {
+			config.Namespaces = configs.Namespaces{{Type: configs.NEWUSER}}
+		}
+		err := validate.New().Validate(config)
+		if test.valid && err != nil {
+			t.Errorf("expected %+v to be valid: %v", test, err)
+		}
+		if !test.valid && err == nil {
+			t.Errorf("expected %+v to be rejected", test)
+		}
+	}
+}
+
+func TestValidateCgroupPathTemplate(t *testing.T) {
+	for _, test := range []struct {
+		cgroup configs.Cgroup
+		valid  bool
+	}{
+		{configs.Cgroup{PathTemplate: "/machine.slice/{{.ID}}"}, true},
+		{configs.Cgroup{PathTemplate: "/tenants/{{.Labels.tenant}}/{{.ID}}"}, true},
+		{configs.Cgroup{PathTemplate: "/tenants/{{.Labels.zone}}/{{.ID}}"}, false},
+		{configs.Cgroup{PathTemplate: "/machine.slice/{{.ID}}", Path: "/machine.slice/ctr"}, false},
+		{configs.Cgroup{PathTemplate: "/machine.slice/{{.ID}}", Parent: "machine.slice"}, false},
+		{configs.Cgroup{PathTemplate: "../{{.ID}}"}, false},
+	} {
+		cgroup := test.cgroup
+		config := &configs.Config{
+			Rootfs:  "/var",
+			Cgroups: &cgroup,
+			Labels:  []string{"tenant=blue"},
+		}
+		err := validate.New().Validate(config)
+		if test.valid && err != nil {
+			t.Errorf("expected %+v to be valid: %v", test.cgroup, err)
+		}
+		if !test.valid && err == nil {
+			t.Errorf("expected %+v to be rejected", test.cgroup)
+		}
+	}
+}
+
+func TestValidateRootfsMountFlags(t *testing.T) {
+	for _, test := range []struct {
+		flags int
+		valid bool
+	}{
+		{0, true},
+		{syscall.MS_NOSUID | syscall.MS_NODEV | syscall.MS_NOEXEC, true},
+		{syscall.MS_RDONLY, false},
+		{syscall.MS_NOSUID | syscall.MS_BIND, false},
+	} {
+		config := &configs.Config{
+			Rootfs:           "/var",
+			RootfsMountFlags: test.flags,
+		}
+		err := validate.New().Validate(config)
+		if test.valid && err != nil {
+			t.Errorf("expected flags %#x to be valid: %v", test.flags, err)
+		}
+		if !test.valid && err == nil {
+			t.Errorf("expected flags %#x to be rejected", test.flags)
+		}
+	}
+}
+
+func TestValidateHostEnv(t *testing.T) {
+	for _, test := range []struct {
+		names []string
+		valid bool
+	}{
+		{[]string{"TERM", "http_proxy"}, true},
+		{[]string{""}, false},
+		{[]string{"TERM=xterm"}, false},
+	} {
+		config := &configs.Config{
+			Rootfs:  "/var",
+			HostEnv: test.names,
+		}
+		err := validate.New().Validate(config)
+		if test.valid && err != nil {
+			t.Errorf("expected %q to be valid: %v", test.names, err)
+		}
+		if !test.valid && err == nil {
+			t.Errorf("expected %q to be rejected", test.names)
+		}
+	}
+}
+
+func TestValidateTmpfsDirs(t *testing.T) {
+	for _, test := range []struct {
+		dirs  []string
+		valid bool
+	}{
+		{[]string{"/var", "/run"}, true},
+		{[]string{"run"}, false},
+		{[]string{"/"}, false},
+		{[]string{"/run", "/run/"}, false},
+	} {
+		config := &configs.Config{
+			Rootfs:    "/var",
+			TmpfsDirs: test.dirs,
+		}
+		err := validate.New().Validate(config)
+		if test.valid && err != nil {
+			t.Errorf("expected %q to be valid: %v", test.dirs, err)
+		}
+		if !test.valid && err == nil {
+			t.Errorf("expected %q to be rejected", test.dirs)
+		}
+	}
+}
+
+func TestValidateMountOrder(t *testing.T) {
+	config := &configs.Config{
+		Rootfs: "/var",
+		Mounts: []*configs.Mount{
+			{Destination: "/data/inner"},
+			{Destination: "/data"},
+		},
+	}
+	if err := validate.New().Validate(config); err != nil {
+		t.Errorf("expected mounts in reverse order to be valid: %v", err)
+	}
+	config.Mounts = append(config.Mounts, &configs.Mount{Destination: "/data"})
+	if err := validate.New().Validate(config); err == nil {
+		t.Error("expected a destination mounted again after a mount below it to be rejected")
+	}
+}
+
+func TestValidateDaemon(t *testing.T) {
+	mountns := configs.Namespaces{{Type: configs.NEWNS}}
+	for _, test := range []struct {
+		daemon     configs.Daemon
+		namespaces configs.Namespaces
+		valid      bool
+	}{
+		{configs.Daemon{}, mountns, true},
+		{configs.Daemon{Stdout: "/var/log/out.log", Stderr: "/var/log/err.log"}, mountns, true},
+		{configs.Daemon{Stdout: "out.log"}, mountns, false},
+		{configs.Daemon{Stderr: "log/err.log"}, mountns, false},
+		{configs.Daemon{Stdout: "/var/log/out.log"}, nil, false},
+	} {
+		daemon := test.daemon
+		config := &configs.Config{
+			Rootfs:     "/var",
+			Namespaces: test.namespaces,
+			Daemon:     &daemon,
+		}
+		err := validate.New().Validate(config)
+		if test.valid && err != nil {
+			t.Errorf("expected %+v to be valid: %v", test.daemon, err)
+		}
+		if !test.valid && err == nil {
+			t.Errorf("expected %+v to be rejected", test.daemon)
+		}
+	}
+}
+
+func TestValidateRootfsOverlay(t *testing.T) {
+	for _, test := range []struct {
+		overlay configs.Overlay
+		valid   bool
+	}{
+		{configs.Overlay{}, true},
+		{configs.Overlay{UpperDir: "/overlay/upper", WorkDir: "/overlay/work"}, true},
+		{configs.Overlay{UpperDir: "/overlay/upper"}, false},
+		{configs.Overlay{WorkDir: "/overlay/work"}, false},
+		{configs.Overlay{UpperDir: "overlay/upper", WorkDir: "/overlay/work"}, false},
+		{configs.Overlay{Tmpfs: true}, true},
+		{configs.Overlay{UpperDir: "/overlay/upper", WorkDir: "/overlay/work", Tmpfs: true}, false},
+	} {
+		overlay := test.overlay
+		config := &configs.Config{
+			Rootfs:        "/var",
+			RootfsOverlay: &overlay,
+		}
+		err := validate.New().Validate(config)
+		if test.valid && err != nil {
+			t.Errorf("expected %+v to be valid: %v", test.overlay, err)
+		}
+		if !test.valid && err == nil {
+			t.Errorf("expected %+v to be rejected", test.overlay)
+		}
+	}
+}
+
+func TestValidateRootfsImage(t *testing.T) {
+	image, err := ioutil.TempFile("", "rootfs-image")
+	if err != nil {
+		t.Fatal(err)
+	}
+	image.Close()
+	defer os.Remove(image.Name())
+
+	mnt := configs.Namespaces{{Type: configs.NEWNS}}
+	for _, test := range []struct {
+		image      configs.Image
+		namespaces configs.Namespaces
+		valid      bool
+	}{
+		{configs.Image{Path: image.Name(), Type: "squashfs"}, mnt, true},
+		{configs.Image{Path: image.Name(), Type: "erofs"}, mnt, true},
+		{configs.Image{Path: image.Name(), Type: "ext4"}, mnt, false},
+		{configs.Image{Path: "rootfs.img", Type: "squashfs"}, mnt, false},
+		{configs.Image{Path: "/var", Type: "squashfs"}, mnt, false},
+		{configs.Image{Path: image.Name() + ".missing", Type: "squashfs"}, mnt, false},
+		{configs.Image{Path: image.Name(), Type: "squashfs"}, nil, false},
+		{configs.Image{Path: image.Name(), Type: "squashfs"}, append(mnt, configs.Namespace{Type: configs.NEWUSER}), false},
+	} {
+		image := test.image
+		config := &configs.Config{
+			Rootfs:      "/var",
+			Namespaces:  test.namespaces,
+			RootfsImage: &image,
+		}
+		if test.namespaces.Contains(configs.NEWUSER) {
+			config.UidMappings = []configs.IDMap{{ContainerID: 0, HostID: 1000, Size: 1}}
+			config.GidMappings = []configs.IDMap{{ContainerID: 0, HostID: 1000, Size: 1}}
+		}
+		err := validate.New().Validate(config)
+		if test.valid && err != nil {
+			t.Errorf("expected %+v to be valid: %v", test.image, err)
+		}
+		if !test.valid && err == nil {
+			t.Errorf("expected %+v with namespaces %v to be rejected", test.image, test.namespaces)
+		}
+	}
+}
+
+func TestValidateVethOptions(t *testing.T) {
+	off := false
+	for _, test := range []struct {
+		network configs.Network
+		valid   bool
+	}{
+		{configs.Network{Type: "veth", PeerName: "vethpeer0", Offloads: &configs.Offloads{TSO: &off}}, true},
+		{configs.Network{Type: "veth", PeerName: "averyveryverylongname"}, false},
+		{configs.Network{Type: "loopback", PeerName: "lopeer"}, false},
+		{configs.Network{Type: "loopback", Offloads: &configs.Offloads{GRO: &off}}, false},
+		{configs.Network{Type: "macvlan", Parent: "eth0", MacvlanMode: "vepa", Offloads: &configs.Offloads{GRO: &off}}, true},
+		{configs.Network{Type: "macvlan"}, false},
+		{configs.Network{Type: "macvlan", Parent: "eth0", MacvlanMode: "source"}, false},
+		{configs.Network{Type: "macvlan", Parent: "eth0", PeerName: "mvpeer"}, false},
+		{configs.Network{Type: "veth", Parent: "eth0"}, false},
+	} {
+		n := test.network
+		config := &configs.Config{
+			Rootfs:     "/var",
+			Namespaces: configs.Namespaces{{Type: configs.NEWNET}},
+			Networks:   []*configs.Network{&n},
+		}
+		err := validate.New().Validate(config)
+		if test.valid && err != nil {
+			t.Errorf("expected %+v to be valid: %v", n, err)
+		}
+		if !test.valid && err == nil {
+			t.Errorf("expected %+v to be rejected", n)
+		}
+	}
+}
+
+func TestValidateNetnsPath(t *testing.T) {
+	for _, test := range []struct {
+		path  string
+		netns configs.Namespace
+		valid bool
+	}{
+		{"/var/run/netns/ct", configs.Namespace{Type: configs.NEWNET}, true},
+		{"netns/ct", configs.Namespace{Type: configs.NEWNET}, false},
+		{"/var/run/netns/ct", configs.Namespace{Type: configs.NEWNET, Path: "/proc/1/ns/net"}, false},
+		{"/var/run/netns/ct", configs.Namespace{Type: configs.NEWPID}, false},
+	} {
+		config := &configs.Config{
+			Rootfs:     "/var",
+			NetnsPath:  test.path,
+			Namespaces: configs.Namespaces{test.netns},
+		}
+		err := validate.New().Validate(config)
+		if test.valid && err != nil {
+			t.Errorf("expected %+v to be valid: %v", test, err)
+		}
+		if !test.valid && err == nil {
+			t.Errorf("expected %+v to be rejected", test)
+		}
+	}
+}
+
+func TestValidateAmbientCapabilities(t *testing.T) {
+	for _, test := range []struct {
+		caps  configs.Capabilities
+		valid bool
+	}{
+		{configs.Capabilities{}, true},
+		{configs.Capabilities{Permitted: []string{"CAP_KILL"}, Inheritable: []string{"CAP_KILL"}, Ambient: []string{"CAP_KILL"}}, true},
+		{configs.Capabilities{Permitted: []string{"CAP_KILL"}, Ambient: []string{"CAP_KILL"}}, false},
+		{configs.Capabilities{Inheritable: []string{"CAP_KILL"}, Ambient: []string{"CAP_KILL"}}, false},
+		{configs.Capabilities{Ambient: []string{"CAP_KILL"}, BoundingOnly: true}, true},
+	} {
+		caps := test.caps
+		config := &configs.Config{
+			Rootfs:       "/var",
+			Capabilities: &caps,
+		}
+		err := validate.New().Validate(config)
+		if test.valid && err != nil {
+			t.Errorf("expected capabilities %+v to be valid: %v", caps, err)
+		}
+		if !test.valid && err == nil {
+			t.Errorf("expected capabilities %+v to be rejected", caps)
+		}
+	}
+}
+
+func TestValidateFileCapabilities(t *testing.T) {
+	valid := &configs.Config{
+		Rootfs: "/var",
+		FileCapabilities: []configs.FileCapability{
+			{Path: "/bin/ping", Capabilities: []string{"CAP_NET_RAW"}},
+		},
+	}
+	validator := validate.New()
+	if err := validator.Validate(valid); err != nil {
+		t.Errorf("Expected error to not occur: %+v", err)
+	}
+
+	for _, fc := range []configs.FileCapability{
+		{Path: "/bin/ping", Capabilities: []string{"CAP_NOT_A_CAP"}},
+		{Capabilities: []string{"CAP_NET_RAW"}},
+	} {
+		config := &configs.Config{
+			Rootfs:           "/var",
+			FileCapabilities: []configs.FileCapability{fc},
+		}
+		if err := validator.Validate(config); err == nil {
+			t.Errorf("Expected error to occur for %+v but it was nil", fc)
+		}
+	}
+}
+
+func TestValidateCapabilities(t *testing.T) {
+	valid := &configs.Config{
+		Rootfs: "/var",
+		Capabilities: &configs.Capabilities{
+			Bounding:  []string{"CAP_CHOWN", "CAP_NET_RAW"},
+			Effective: []string{"CAP_CHOWN"},
+			Permitted: []string{"CAP_CHOWN"},
+		},
+	}
+	validator := validate.New()
+	if err := validator.Validate(valid); err != nil {
+		t.Errorf("Expected error to not occur: %+v", err)
+	}
+
+	for _, caps := range []*configs.Capabilities{
+		{Bounding: []string{"CAP_NOT_A_CAP"}},
+		{Effective: []string{"chown"}},
+		{Inheritable: []string{"CAP_CHOWN", ""}},
+		{Permitted: []string{"CAP_NOT_A_CAP"}},
+		{Ambient: []string{"CAP_NOT_A_CAP"}, BoundingOnly: true},
+	} {
+		config := &configs.Config{
+			Rootfs:       "/var",
+			Capabilities: caps,
+		}
+		if err := validator.Validate(config); err == nil {
+			t.Errorf("Expected error to occur for %+v but it was nil", caps)
+		}
+	}
+}
+
+func TestValidateSecurebits(t *testing.T) {
+	for _, test := range []struct {
+		bits    configs.Securebits
+		ambient []string
+		valid   bool
+	}{
+		{0, nil, true},
+		{configs.SecbitNoroot | configs.SecbitNorootLocked | configs.SecbitNoSetuidFixup, nil, true},
+		{configs.SecbitNoCapAmbientRaise, nil, true},
+		{configs.SecbitNoCapAmbientRaiseLocked, []string{"CAP_KILL"}, true},
+		{configs.SecbitNoCapAmbientRaise, []string{"CAP_KILL"}, false},
+		{configs.SecbitKeepCaps, nil, false},
+		{configs.SecbitKeepCapsLocked, nil, false},
+		{1 << 8, nil, false},
+	} {
+		config := &configs.Config{
+			Rootfs:     "/var",
+			Securebits: test.bits,
+			Capabilities: &configs.Capabilities{
+				Inheritable: []string{"CAP_KILL"},
+				Permitted:   []string{"CAP_KILL"},
+				Ambient:     test.ambient,
+			},
+		}
+		err := validate.New().Validate(config)
+		if test.valid && err != nil {
+			t.Errorf("expected securebits %#x to be valid: %v", uint(test.bits), err)
+		}
+		if !test.valid && err == nil {
+			t.Errorf("expected securebits %#x to be rejected", uint(test.bits))
+		}
+	}
+}
+
+func TestValidateNetworkArp(t *testing.T) {
+	networks := map[*configs.Network]bool{
+		{Type: "loopback", ArpIgnore: 1, ArpAnnounce: 2, ProxyArp: true}: true,
+		{Type: "loopback", ArpIgnore: 8}:                                 true,
+		{Type: "loopback", ArpIgnore: 5}:                                 false,
+		{Type: "loopback", ArpAnnounce: 3}:                               false,
+	}
+	for n, valid := range networks {
+		config := &configs.Config{
+			Rootfs: "/var",
+			Namespaces: configs.Namespaces(
+				[]configs.Namespace{
+					{Type: configs.NEWNET},
+				},
+			),
+			Networks: []*configs.Network{n},
+		}
+
+		validator := validate.New()
+		err := validator.Validate(config)
+		if valid && err != nil {
+			t.Errorf("Expected network %+v to be valid: %v", n, err)
+		}
+		if !valid && err == nil {
+			t.Errorf("Expected network %+v to be rejected", n)
+		}
+	}
+}
+
+func TestValidateCgroupDrainTimeout(t *testing.T) {
+	for timeout, valid := range map[time.Duration]bool{
+		0:            true,
+		time.Second:  true,
+		-time.Second: false,
+	} {
+		config := &configs.Config{
+			Rootfs:             "/var",
+			CgroupDrainTimeout: timeout,
+		}
+		err := validate.New().Validate(config)
+		if valid && err != nil {
+			t.Errorf("expected cgroup drain timeout %s to be valid: %v", timeout, err)
+		}
+		if !valid && err == nil {
+			t.Errorf("expected cgroup drain timeout %s to be rejected", timeout)
+		}
+	}
+}
+
+func TestValidateNetworkRouteTable(t *testing.T) {
+	networks := map[*configs.Network]bool{
+		{Type: "veth", Name: "eth0", RouteTable: 100}:                     true,
+		{Type: "veth", Name: "eth0", RouteTable: 254}:                     false,
+		{Type: "veth", Name: "eth0", RouteTable: -1}:                      false,
+		{Type: "loopback", Name: "lo", RouteTable: 100}:                   false,
+		{Type: "macvlan", Name: "eth0", Parent: "eth0", RouteTable: 1000}: true,
+	}
+	for n, valid := range networks {
+		config := &configs.Config{
+			Rootfs:     "/var",
+			Namespaces: configs.Namespaces([]configs.Namespace{{Type: configs.NEWNET}}),
+			Networks:   []*configs.Network{n},
+		}
+		err := validate.New().Validate(config)
+		if valid && err != nil {
+			t.Errorf("expected network %+v to be valid: %v", n, err)
+		}
+		if !valid && err == nil {
+			t.Errorf("expected network %+v to be rejected", n)
+		}
+	}
+}
+
+func TestValidateSeccomp(t *testing.T) {
+	for _, test := range []struct {
+		call  configs.Syscall
+		valid bool
+	}{
+		{configs.Syscall{Name: "getcwd", Action: configs.Errno}, true},
+		{configs.Syscall{Name: "getcwd", Action: configs.Log}, true},
+		{configs.Syscall{Name: "write", Action: configs.Errno, Args: []*configs.Arg{{Op: configs.MaskEqualTo}}}, true},
+		{configs.Syscall{Name: "", Action: configs.Errno}, false},
+		{configs.Syscall{Name: "get cwd", Action: configs.Errno}, false},
+		{configs.Syscall{Name: "Getcwd", Action: configs.Errno}, false},
+		{configs.Syscall{Name: "getcwd"}, false},
+		{configs.Syscall{Name: "write", Action: configs.Errno, Args: []*configs.Arg{{}}}, false},
+		{configs.Syscall{Name: "write", Action: configs.Log, Args: []*configs.Arg{{Op: configs.EqualTo}}}, false},
+	} {
+		call := test.call
+		config := &configs.Config{
+			Rootfs: "/var",
+			Seccomp: &configs.Seccomp{
+				DefaultAction: configs.Allow,
+				Syscalls:      []*configs.Syscall{&call},
+			},
+		}
+		err := validate.New().Validate(config)
+		if test.valid && err != nil {
+			t.Errorf("expected syscall rule %+v to be valid: %v", call, err)
+		}
+		if !test.valid && err == nil {
+			t.Errorf("expected syscall rule %+v to be rejected", call)
+		}
+	}
+	if err := validate.New().Validate(&configs.Config{Rootfs: "/var", Seccomp: &configs.Seccomp{}}); err == nil {
+		t.Error("expected a seccomp filter without a default action to be rejected")
+	}
+	if err := validate.New().Validate(&configs.Config{Rootfs: "/var", Seccomp: &configs.Seccomp{DefaultAction: configs.Log}}); err == nil {
+		t.Error("expected a seccomp filter with a log default action to be rejected")
+	}
+}
+
+func TestValidateBlockedSignals(t *testing.T) {
+	for _, test := range []struct {
+		signals []int
+		minimal bool
+		valid   bool
+	}{
+		{nil, true, true},
+		{[]int{int(syscall.SIGUSR1), int(syscall.SIGTERM)}, false, true},
+		{[]int{int(syscall.SIGUSR1)}, true, false},
+		{[]int{int(syscall.SIGKILL)}, false, false},
+		{[]int{int(syscall.SIGSTOP)}, false, false},
+		{[]int{0}, false, false},
+		{[]int{65}, false, false},
+	} {
+		config := &configs.Config{
+			Rootfs:         "/var",
+			BlockedSignals: test.signals,
+			MinimalInit:    test.minimal,
+		}
+		err := validate.New().Validate(config)
+		if test.valid && err != nil {
+			t.Errorf("expected blocked signals %v to be valid: %v", test.signals, err)
+		}
+		if !test.valid && err == nil {
+			t.Errorf("expected blocked signals %v to be rejected", test.signals)
+		}
+	}
+}
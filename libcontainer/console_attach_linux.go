@@ -0,0 +1,83 @@
+// +build linux
+
+package libcontainer
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"syscall"
+
+	"github.com/opencontainers/runc/libcontainer/utils"
+)
+
+// consoleSocketFilename is the socket in the container's state directory
+// that ServeConsole listens on.
+const consoleSocketFilename = "console.sock"
+
+func (c *linuxContainer) ServeConsole(console *os.File) (func(), error) {
+	c.m.Lock()
+	defer c.m.Unlock()
+	status, err := c.currentStatus()
+	if err != nil {
+		return nil, err
+	}
+	if status == Stopped {
+		return nil, newGenericError(fmt.Errorf("container not running"), ContainerNotRunning)
+	}
+	path := filepath.Join(c.root, consoleSocketFilename)
+	// A socket left behind by a server that is gone cannot be listened on.
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return nil, newSystemErrorWithCause(err, "removing stale console socket")
+	}
+	l, err := net.ListenUnix("unix", &net.UnixAddr{Name: path, Net: "unix"})
+	if err != nil {
+		return nil, newSystemErrorWithCause(err, "listening on console socket")
+	}
+	if err := os.Chmod(path, 0600); err != nil {
+		l.Close()
+		return nil, newSystemErrorWithCause(err, "restricting console socket")
+	}
+	go func() {
+		for {
+			conn, err := l.AcceptUnix()
+			if err != nil {
+				return
+			}
+			if socket, err := conn.File(); err == nil {
+				utils.SendFd(socket, console)
+				socket.Close()
+			}
+			conn.Close()
+		}
+	}()
+	return func() { l.Close() }, nil
+}
+
+func (c *linuxContainer) Attach() (*os.File, error) {
+	socket, err := dialConsoleSocket(filepath.Join(c.root, consoleSocketFilename))
+	if err != nil {
+		if isNoConsoleServed(err) {
+			return nil, newGenericError(fmt.Errorf("no console is served for the container"), ContainerNotRunning)
+		}
+		return nil, newSystemErrorWithCause(err, "connecting to console socket")
+	}
+	defer socket.Close()
+	console, err := utils.RecvFd(socket)
+	if err != nil {
+		return nil, newSystemErrorWithCause(err, "receiving console")
+	}
+	return console, nil
+}
+
+// isNoConsoleServed reports whether err dialing the console socket means
+// that nothing serves the console.
+func isNoConsoleServed(err error) bool {
+	if opErr, ok := err.(*net.OpError); ok {
+		if sysErr, ok := opErr.Err.(*os.SyscallError); ok {
+			return sysErr.Err == syscall.ENOENT || sysErr.Err == syscall.ECONNREFUSED
+		}
+	}
+	return false
+}
@@ -0,0 +1,74 @@
+// +build linux
+
+package libcontainer
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/opencontainers/runc/libcontainer/configs"
+	"github.com/opencontainers/runc/libcontainer/system"
+)
+
+func TestServeConsoleAttach(t *testing.T) {
+	root, err := ioutil.TempDir("", "libcontainer-attach")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	container := &linuxContainer{
+		id:            "myid",
+		root:          root,
+		config:        &configs.Config{},
+		cgroupManager: &mockCgroupManager{},
+	}
+	container.state = &stoppedState{c: container}
+	if _, err := container.ServeConsole(os.Stdin); err == nil {
+		t.Fatal("expected the console of a stopped container not to be served")
+	}
+	startTime, err := system.GetProcessStartTime(os.Getpid())
+	if err != nil {
+		t.Fatal(err)
+	}
+	container.initProcess = &mockProcess{_pid: os.Getpid(), started: startTime}
+	container.initProcessStartTime = startTime
+	container.state = &runningState{c: container}
+
+	if _, err := container.Attach(); err == nil {
+		t.Fatal("expected attaching without a served console to fail")
+	} else if lerr, ok := err.(Error); !ok || lerr.Code() != ContainerNotRunning {
+		t.Fatalf("expected a ContainerNotRunning error, got %v", err)
+	}
+
+	// A pipe stands in for the master of the console.
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	defer w.Close()
+	stop, err := container.ServeConsole(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		attached, err := container.Attach()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte("x")); err != nil {
+			t.Fatal(err)
+		}
+		buf := make([]byte, 1)
+		if _, err := attached.Read(buf); err != nil || buf[0] != 'x' {
+			t.Fatalf("expected to read from the attached console, got %q: %v", buf, err)
+		}
+		attached.Close()
+	}
+
+	stop()
+	if _, err := container.Attach(); err == nil {
+		t.Fatal("expected attaching to fail once the console is no longer served")
+	}
+}
@@ -2,6 +2,7 @@ package libcontainer
 
 import (
 	"fmt"
+	"net"
 	"os"
 	"unsafe"
 
@@ -14,6 +15,21 @@ func ConsoleFromFile(f *os.File) Console {
 	}
 }
 
+// dialConsoleSocket connects to the unix socket at path and returns the
+// connection as a file that can be passed to the init as its console socket.
+func dialConsoleSocket(path string) (*os.File, error) {
+	conn, err := net.Dial("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	uc, ok := conn.(*net.UnixConn)
+	if !ok {
+		return nil, fmt.Errorf("casting to UnixConn failed")
+	}
+	return uc.File()
+}
+
 // newConsole returns an initialized console that can be used within a container by copying bytes
 // from the master side to the slave that is attached as the tty for the container's init process.
 func newConsole() (Console, error) {
@@ -71,6 +87,13 @@ func (c *linuxConsole) Close() error {
 func (c *linuxConsole) mount() error {
 	oldMask := unix.Umask(0000)
 	defer unix.Umask(oldMask)
+	// A /dev/console symlink shipped by the image would make us bind the pty
+	// over whatever it points to, so replace it with a plain mountpoint.
+	if fi, err := os.Lstat("/dev/console"); err == nil && fi.Mode()&os.ModeSymlink != 0 {
+		if err := os.Remove("/dev/console"); err != nil {
+			return err
+		}
+	}
 	f, err := os.Create("/dev/console")
 	if err != nil && !os.IsExist(err) {
 		return err
@@ -0,0 +1,68 @@
+// +build linux
+
+package libcontainer
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/opencontainers/runc/libcontainer/utils"
+)
+
+func TestDialConsoleSocket(t *testing.T) {
+	dir, err := ioutil.TempDir("", "console-socket")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "console.sock")
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	received := make(chan string, 1)
+	go func() {
+		defer close(received)
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		socket, err := conn.(*net.UnixConn).File()
+		if err != nil {
+			return
+		}
+		defer socket.Close()
+		f, err := utils.RecvFd(socket)
+		if err != nil {
+			return
+		}
+		defer f.Close()
+		received <- f.Name()
+	}()
+
+	socket, err := dialConsoleSocket(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer socket.Close()
+	f, err := os.Open("/dev/null")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if err := utils.SendFd(socket, f); err != nil {
+		t.Fatal(err)
+	}
+	if name := <-received; name != "/dev/null" {
+		t.Fatalf("expected to receive /dev/null over the socket, got %q", name)
+	}
+
+	if _, err := dialConsoleSocket(filepath.Join(dir, "missing.sock")); err == nil {
+		t.Fatal("expected an error dialing a missing socket")
+	}
+}
@@ -98,7 +98,9 @@ type BaseContainer interface {
 	// the Container state is PAUSED in which case every PID in the slice is valid.
 	Processes() ([]int, error)
 
-	// Returns statistics for the container.
+	// Returns statistics for the container. When the statistics of some of
+	// the cgroup subsystems cannot be read, the statistics of the others are
+	// still returned along with the error.
 	//
 	// errors:
 	// ContainerNotExists - Container no longer exists,
@@ -155,10 +157,24 @@ type BaseContainer interface {
 	// including the initial process.
 	//
 	// errors:
+	// ContainerNotRunning - Container is not running,
 	// SystemError - System error.
 	Signal(s os.Signal, all bool) error
 
+	// Wait waits for the container's initial process to exit and returns its
+	// exit code, which is 128 plus the signal number if it was killed by a
+	// signal. The exit code is saved with the container's state, so Wait
+	// also returns it once the process has exited, and for a container
+	// loaded from that state.
+	//
+	// errors:
+	// ContainerNotRunning - Container was never started,
+	// SystemError - System error, or the exit code was not saved.
+	Wait() (int, error)
+
 	// Exec signals the container to exec the users process at the end of the init.
+	// It returns once the init has exec'd the users process, at which point the
+	// container transitions from Created to Running.
 	//
 	// errors:
 	// SystemError - System error.
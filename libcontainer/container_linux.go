@@ -24,6 +24,7 @@ import (
 	"github.com/opencontainers/runc/libcontainer/criurpc"
 	"github.com/opencontainers/runc/libcontainer/system"
 	"github.com/opencontainers/runc/libcontainer/utils"
+	"github.com/opencontainers/selinux/go-selinux"
 	"github.com/syndtr/gocapability/capability"
 	"github.com/vishvananda/netlink/nl"
 )
@@ -43,6 +44,10 @@ type linuxContainer struct {
 	criuVersion          int
 	state                containerState
 	created              time.Time
+	execSessions         map[string]*execSession
+	execSessionSeq       int
+	exitStatus           *int
+	rootfsImage          *rootfsImage
 }
 
 // State represents a running container's state
@@ -64,6 +69,14 @@ type State struct {
 
 	// Container's standard descriptors (std{in,out,err}), needed for checkpoint and restore
 	ExternalDescriptors []string `json:"external_descriptors,omitempty"`
+
+	// ExitStatus is the exit code of the init process once Wait has seen it
+	// exit.
+	ExitStatus *int `json:"exit_status,omitempty"`
+
+	// RootfsImage is the loop device that the image file of the rootfs is
+	// attached to.
+	RootfsImage *rootfsImage `json:"rootfs_image,omitempty"`
 }
 
 // Container is a libcontainer container object.
@@ -109,6 +122,84 @@ type Container interface {
 	// Systemerror - System error.
 	Resume() error
 
+	// PauseSubtree freezes the processes in the given child cgroup of the
+	// Container, such as a job run inside the Container, and in its
+	// descendants. The subtree is relative to the Container's freezer cgroup.
+	// The rest of the Container keeps running.
+	//
+	// errors:
+	// ContainerNotRunning - Container not running,
+	// ConfigInvalid - subtree is not a child cgroup of the Container,
+	// Systemerror - System error.
+	PauseSubtree(subtree string) error
+
+	// ResumeSubtree thaws a subtree frozen with PauseSubtree.
+	//
+	// errors:
+	// ContainerNotRunning - Container not running,
+	// ConfigInvalid - subtree is not a child cgroup of the Container,
+	// Systemerror - System error.
+	ResumeSubtree(subtree string) error
+
+	// SignalSubtree sends the signal to every process in the given child
+	// cgroup of the Container and in its descendants.
+	//
+	// errors:
+	// ContainerNotRunning - Container not running,
+	// ConfigInvalid - subtree is not a child cgroup of the Container,
+	// Systemerror - System error.
+	SignalSubtree(subtree string, s os.Signal) error
+
+	// ExecSessions returns the processes started in the running Container
+	// by this Container object that have not exited, oldest first.
+	ExecSessions() []ExecSession
+
+	// VerifyMounts compares the mount table of the Container's init process
+	// with the configured mounts. It reports mounts that are not part of the
+	// configuration, such as ones propagated in from the host, and configured
+	// mounts that are no longer mounted.
+	//
+	// errors:
+	// ContainerNotRunning - Container not running,
+	// Systemerror - System error.
+	VerifyMounts() ([]MountDrift, error)
+
+	// Capabilities returns the capability sets of the Container's init
+	// process as they are now, which the init may have changed since it was
+	// set up.
+	//
+	// errors:
+	// ContainerNotRunning - Container not running,
+	// Systemerror - System error.
+	Capabilities() (*CapabilitySets, error)
+
+	// Uptime returns how long the Container's init process has been running,
+	// from its start time in clock ticks since boot.
+	//
+	// errors:
+	// ContainerNotRunning - Container not running,
+	// Systemerror - System error.
+	Uptime() (time.Duration, error)
+
+	// AddDevice creates the device node in the running Container and allows
+	// access to it in the devices cgroup. In a user namespace the node is
+	// owned by the host ids that dev.Uid and dev.Gid map to.
+	//
+	// errors:
+	// ContainerNotRunning - Container not running,
+	// ConfigInvalid - the device exists or the Container is rootless,
+	// Systemerror - System error.
+	AddDevice(dev *configs.Device) error
+
+	// RemoveDevice denies access to the device at path in the devices
+	// cgroup and removes its node from the running Container.
+	//
+	// errors:
+	// ContainerNotRunning - Container not running,
+	// ConfigInvalid - the device does not exist or the Container is rootless,
+	// Systemerror - System error.
+	RemoveDevice(path string) error
+
 	// NotifyOOM returns a read-only channel signaling when the container receives an OOM notification.
 	//
 	// errors:
@@ -120,6 +211,132 @@ type Container interface {
 	// errors:
 	// Systemerror - System error.
 	NotifyMemoryPressure(level PressureLevel) (<-chan struct{}, error)
+
+	// Events returns a single stream of the OOM notifications, the memory
+	// pressure levels reached and the exit of the init of the Container. The
+	// stream is closed after the exit event or when the returned function is
+	// called, which releases all the resources used by the stream.
+	//
+	// errors:
+	// ContainerNotRunning - Container not running,
+	// Systemerror - System error.
+	Events() (<-chan Event, func(), error)
+
+	// ServeConsole serves console, the master of the console of the init of
+	// the Container received over the console socket, to callers of Attach
+	// in this or any other process. The console stays accessible while it
+	// is served, until the returned function is called.
+	//
+	// errors:
+	// ContainerNotRunning - Container not running,
+	// Systemerror - System error.
+	ServeConsole(console *os.File) (func(), error)
+
+	// Attach returns the master of the console of the init of the Container
+	// from the process serving it, so that the I/O of a Container started
+	// detached can be streamed again.
+	//
+	// errors:
+	// ContainerNotRunning - no console is served for the Container,
+	// Systemerror - System error.
+	Attach() (*os.File, error)
+
+	// Quiesce sends s to the init of the Container and waits for up to
+	// timeout for the init to create the configured quiesced file, or to
+	// exit. Unlike Pause it leaves quiescing to the application, which keeps
+	// running to finish the work it has.
+	//
+	// errors:
+	// ContainerNotRunning - Container not running,
+	// ConfigInvalid - no quiesced file is configured,
+	// Systemerror - System error.
+	Quiesce(s os.Signal, timeout time.Duration) error
+
+	// SignalProcessTree sends the provided signal to the process with the given
+	// host pid and to all of its descendants inside the container, leaving the
+	// rest of the container untouched.
+	//
+	// errors:
+	// ProcessNotExists - No process with that pid is inside the container,
+	// Systemerror - System error.
+	SignalProcessTree(pid int, s os.Signal) error
+
+	// SignalProcess sends the provided signal to the single process with the
+	// given host pid, which must be inside the container.
+	//
+	// errors:
+	// ProcessNotExists - No process with that pid is inside the container,
+	// Systemerror - System error.
+	SignalProcess(pid int, s os.Signal) error
+
+	// CgroupPaths returns a copy of the map from cgroup subsystem to the path
+	// of the container's cgroup for that subsystem.
+	CgroupPaths() map[string]string
+
+	// InitPidFd returns a pidfd referring to the container's init process. The
+	// caller owns the descriptor and must close it. Unlike the init's pid, the
+	// pidfd can be used to poll for the init's exit or to signal it without
+	// racing against the pid being reused.
+	//
+	// errors:
+	// ContainerNotRunning - Container is not running or created,
+	// Systemerror - System error, including ENOSYS on kernels without pidfds.
+	InitPidFd() (int, error)
+
+	// AddNetwork creates the network described by n and configures it inside
+	// the network namespace of the running container. The network is recorded
+	// in the container's configuration so that it is persisted with the state
+	// and reported by Stats.
+	//
+	// errors:
+	// ConfigInvalid - A network with the same name already exists or the
+	// container does not have its own network namespace,
+	// ContainerNotRunning - Container is not running or created,
+	// Systemerror - System error.
+	AddNetwork(n *configs.Network) error
+
+	// RemoveNetwork tears down the network with the given name that was
+	// previously configured for the container and removes it from the
+	// container's configuration.
+	//
+	// errors:
+	// ConfigInvalid - No network with that name exists or it cannot be removed,
+	// ContainerNotRunning - Container is not running or created,
+	// Systemerror - System error.
+	RemoveNetwork(name string) error
+
+	// UpdateNetwork replaces the configuration of the network with the given
+	// name by n. Changes to the addresses, gateways, MAC address, MTU and ARP
+	// settings are applied to the existing interface in place; any other
+	// change recreates the interface. Routes configured for the interface are
+	// applied again.
+	//
+	// errors:
+	// ConfigInvalid - No network with that name exists, n renames it to the
+	// name of another network or it cannot be recreated,
+	// ContainerNotRunning - Container is not running or created,
+	// Systemerror - System error.
+	UpdateNetwork(name string, n *configs.Network) error
+
+	// ProcessLabel returns the SELinux context of the container's processes.
+	// While the init is running on a host with SELinux enabled the context is
+	// read from the init itself, otherwise the configured context is returned.
+	//
+	// errors:
+	// Systemerror - System error.
+	ProcessLabel() (string, error)
+
+	// MountLabel returns the SELinux context applied to the container's mounts.
+	MountLabel() string
+
+	// ExportSpec returns the container's effective configuration together
+	// with its current state as JSON, in the same format as State. If redact
+	// is set, the values of the environment variables passed to hooks are
+	// masked so that the result can be shared in bug reports.
+	//
+	// errors:
+	// Systemerror - System error.
+	ExportSpec(redact bool) ([]byte, error)
 }
 
 // ID returns the container's unique ID
@@ -144,6 +361,83 @@ func (c *linuxContainer) State() (*State, error) {
 	return c.currentState()
 }
 
+func (c *linuxContainer) CgroupPaths() map[string]string {
+	paths := make(map[string]string)
+	for subsystem, path := range c.cgroupManager.GetPaths() {
+		paths[subsystem] = path
+	}
+	return paths
+}
+
+func (c *linuxContainer) ProcessLabel() (string, error) {
+	c.m.Lock()
+	defer c.m.Unlock()
+	if !selinux.GetEnabled() {
+		return c.config.ProcessLabel, nil
+	}
+	status, err := c.currentStatus()
+	if err != nil {
+		return "", err
+	}
+	if status == Stopped {
+		return c.config.ProcessLabel, nil
+	}
+	processLabel, err := selinux.PidLabel(c.initProcess.pid())
+	if err != nil {
+		return "", newSystemErrorWithCause(err, "reading init process label")
+	}
+	return processLabel, nil
+}
+
+func (c *linuxContainer) MountLabel() string {
+	return c.config.MountLabel
+}
+
+func (c *linuxContainer) ExportSpec(redact bool) ([]byte, error) {
+	c.m.Lock()
+	defer c.m.Unlock()
+	state, err := c.currentState()
+	if err != nil {
+		return nil, err
+	}
+	if redact && state.Config.Hooks != nil {
+		state.Config.Hooks = redactHooks(state.Config.Hooks)
+	}
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return nil, newSystemErrorWithCause(err, "marshaling state")
+	}
+	return data, nil
+}
+
+// redactedValue replaces the values of environment variables in exported specs.
+const redactedValue = "<redacted>"
+
+// redactHooks returns a copy of hooks with the values of the environment
+// variables of the command hooks masked.
+func redactHooks(hooks *configs.Hooks) *configs.Hooks {
+	redact := func(hooks []configs.Hook) []configs.Hook {
+		var redacted []configs.Hook
+		for _, hook := range hooks {
+			if chook, ok := hook.(configs.CommandHook); ok {
+				env := make([]string, len(chook.Env))
+				for i, e := range chook.Env {
+					env[i] = strings.SplitN(e, "=", 2)[0] + "=" + redactedValue
+				}
+				chook.Env = env
+				hook = chook
+			}
+			redacted = append(redacted, hook)
+		}
+		return redacted
+	}
+	return &configs.Hooks{
+		Prestart:  redact(hooks.Prestart),
+		Poststart: redact(hooks.Poststart),
+		Poststop:  redact(hooks.Poststop),
+	}
+}
+
 func (c *linuxContainer) Processes() ([]int, error) {
 	pids, err := c.cgroupManager.GetAllPids()
 	if err != nil {
@@ -157,7 +451,11 @@ func (c *linuxContainer) Stats() (*Stats, error) {
 		err   error
 		stats = &Stats{}
 	)
-	if stats.CgroupStats, err = c.cgroupManager.GetStats(); err != nil {
+	// Keep going when only some subsystems failed so that the caller gets
+	// all the stats that could be read.
+	stats.CgroupStats, err = c.cgroupManager.GetStats()
+	partial, isPartial := err.(*cgroups.StatsError)
+	if err != nil && !isPartial {
 		return stats, newSystemErrorWithCause(err, "getting container stats from cgroups")
 	}
 	for _, iface := range c.config.Networks {
@@ -170,6 +468,9 @@ func (c *linuxContainer) Stats() (*Stats, error) {
 			stats.Interfaces = append(stats.Interfaces, istats)
 		}
 	}
+	if isPartial {
+		return stats, newSystemErrorWithCause(partial, "getting container stats from cgroups")
+	}
 	return stats, nil
 }
 
@@ -183,8 +484,15 @@ func (c *linuxContainer) Set(config configs.Config) error {
 	if status == Stopped {
 		return newGenericError(fmt.Errorf("container not running"), ContainerNotRunning)
 	}
+	if err := resolvePercentLimits(&config); err != nil {
+		return newSystemErrorWithCause(err, "resolving percentage limits")
+	}
+	if err := c.cgroupManager.Set(&config); err != nil {
+		return err
+	}
 	c.config = &config
-	return c.cgroupManager.Set(c.config)
+	// A container that is loaded later starts from the limits in the state.
+	return c.persistState()
 }
 
 func (c *linuxContainer) Start(process *Process) error {
@@ -231,6 +539,10 @@ func (c *linuxContainer) Exec() error {
 	return c.exec()
 }
 
+// exec unblocks the init waiting on the exec fifo and returns once the init
+// has handed over to the user process. The init writes to the fifo before it
+// applies seccomp and execve(2)s, and holds the fifo open with O_CLOEXEC, so
+// reading until EOF marks the boundary between container setup and user code.
 func (c *linuxContainer) exec() error {
 	path := filepath.Join(c.root, execFifoFilename)
 	f, err := os.OpenFile(path, os.O_RDONLY, 0)
@@ -244,14 +556,46 @@ func (c *linuxContainer) exec() error {
 	}
 	if len(data) > 0 {
 		os.Remove(path)
-		return nil
+		return c.state.transition(&runningState{
+			c: c,
+		})
 	}
 	return fmt.Errorf("cannot start an already running container")
 }
 
 func (c *linuxContainer) start(process *Process, isInit bool) error {
+	if isInit && c.config.Daemon != nil && (process.ConsoleSocket != nil || process.ConsoleSocketPath != "") {
+		return newGenericError(fmt.Errorf("a container in daemon mode cannot have a console"), ConfigInvalid)
+	}
+	if process.ConsoleSocket == nil && process.ConsoleSocketPath != "" {
+		socket, err := dialConsoleSocket(process.ConsoleSocketPath)
+		if err != nil {
+			return newSystemErrorWithCausef(err, "connecting to console socket %q", process.ConsoleSocketPath)
+		}
+		// The init has its own copy of the socket once it is started.
+		defer func() {
+			socket.Close()
+			process.ConsoleSocket = nil
+		}()
+		process.ConsoleSocket = socket
+	}
+	var execID string
+	if isInit {
+		if err := resolvePercentLimits(c.config); err != nil {
+			return newSystemErrorWithCause(err, "resolving percentage limits")
+		}
+	} else {
+		id, err := c.newExecSessionID(process.ExecID)
+		if err != nil {
+			return err
+		}
+		execID = id
+	}
 	parent, err := c.newParentProcess(process, isInit)
 	if err != nil {
+		if lerr, ok := err.(Error); ok && lerr.Code() == ConfigInvalid {
+			return lerr
+		}
 		return newSystemErrorWithCause(err, "creating new parent process")
 	}
 	if err := parent.start(); err != nil {
@@ -259,20 +603,45 @@ func (c *linuxContainer) start(process *Process, isInit bool) error {
 		if err := parent.terminate(); err != nil {
 			logrus.Warn(err)
 		}
+		// Keep the code of errors the init reported, such as a missing
+		// executable, so that callers can tell them apart.
+		if lerr, ok := err.(Error); ok && lerr.Code() != SystemError {
+			return lerr
+		}
 		return newSystemErrorWithCause(err, "starting container process")
 	}
+	if process.PidWriter != nil {
+		if err := writeProcessPid(process.PidWriter, parent.pid()); err != nil {
+			if err := parent.terminate(); err != nil {
+				logrus.Warn(err)
+			}
+			return newSystemErrorWithCause(err, "writing pid to the process's pid writer")
+		}
+	}
 	// generate a timestamp indicating when the container was started
 	c.created = time.Now().UTC()
 	if isInit {
 		c.state = &createdState{
 			c: c,
 		}
+		// The exit code of a previous init must not be taken for the one
+		// of this init, also by containers loaded from the state.
+		c.exitStatus = nil
 		state, err := c.updateState(parent)
 		if err != nil {
 			return err
 		}
 		c.initProcessStartTime = state.InitProcessStartTime
 
+		if c.config.PidFile != "" {
+			if err := writePidFile(c.config.PidFile, parent.pid()); err != nil {
+				if err := parent.terminate(); err != nil {
+					logrus.Warn(err)
+				}
+				return newSystemErrorWithCause(err, "writing pid file")
+			}
+		}
+
 		if c.config.Hooks != nil {
 			s := configs.HookState{
 				Version: c.config.Version,
@@ -290,6 +659,13 @@ func (c *linuxContainer) start(process *Process, isInit bool) error {
 			}
 		}
 	} else {
+		if err := c.addExecSession(execID, process, parent); err != nil {
+			if err := parent.terminate(); err != nil {
+				logrus.Warn(err)
+			}
+			return newSystemErrorWithCause(err, "recording exec session")
+		}
+		process.ExecID = execID
 		c.state = &runningState{
 			c: c,
 		}
@@ -298,15 +674,336 @@ func (c *linuxContainer) start(process *Process, isInit bool) error {
 }
 
 func (c *linuxContainer) Signal(s os.Signal, all bool) error {
-	if all {
+	c.m.Lock()
+	defer c.m.Unlock()
+	// The status is checked against the start time of the init so that a
+	// process reusing its pid is not signaled.
+	status, err := c.currentStatus()
+	if err != nil {
+		return err
+	}
+	if all && (status != Stopped || !c.config.Namespaces.Contains(configs.NEWPID)) {
+		// Without a pid namespace the processes of the container outlive
+		// the init.
 		return signalAllProcesses(c.cgroupManager, s)
 	}
+	if status == Stopped {
+		return newGenericError(fmt.Errorf("container not running"), ContainerNotRunning)
+	}
 	if err := c.initProcess.signal(s); err != nil {
 		return newSystemErrorWithCause(err, "signaling init process")
 	}
 	return nil
 }
 
+func (c *linuxContainer) SignalProcessTree(pid int, s os.Signal) error {
+	// XXX: This requires cgroups to find the processes in the container.
+	if c.config.Rootless {
+		return fmt.Errorf("cannot signal a process tree in a rootless container")
+	}
+	return signalProcessTree(c.cgroupManager, pid, s)
+}
+
+func (c *linuxContainer) SignalProcess(pid int, s os.Signal) error {
+	// XXX: This requires cgroups to find the processes in the container.
+	if c.config.Rootless {
+		return fmt.Errorf("cannot signal a single process in a rootless container")
+	}
+	sig, ok := s.(syscall.Signal)
+	if !ok {
+		return newGenericError(fmt.Errorf("unsupported signal %v", s), SystemError)
+	}
+	pids, err := c.cgroupManager.GetAllPids()
+	if err != nil {
+		return newSystemErrorWithCause(err, "getting container pids")
+	}
+	found := false
+	for _, p := range pids {
+		if p == pid {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return newGenericError(fmt.Errorf("process %d is not in the container", pid), ProcessNotExists)
+	}
+	if err := syscall.Kill(pid, sig); err != nil {
+		if err == syscall.ESRCH {
+			return newGenericError(fmt.Errorf("process %d has exited", pid), ProcessNotExists)
+		}
+		return newSystemErrorWithCausef(err, "signaling process %d", pid)
+	}
+	return nil
+}
+
+// writePidFile atomically writes pid to path by writing it to a hidden
+// temporary file next to path and renaming it into place.
+func writePidFile(path string, pid int) error {
+	tmpName := filepath.Join(filepath.Dir(path), "."+filepath.Base(path))
+	f, err := os.OpenFile(tmpName, os.O_RDWR|os.O_CREATE|os.O_TRUNC|os.O_SYNC, 0644)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(f, "%d", pid)
+	f.Close()
+	if err != nil {
+		os.Remove(tmpName)
+		return err
+	}
+	return os.Rename(tmpName, path)
+}
+
+// writeProcessPid writes pid in decimal to the pid writer of a process.
+func writeProcessPid(w io.Writer, pid int) error {
+	_, err := fmt.Fprintf(w, "%d", pid)
+	return err
+}
+
+func (c *linuxContainer) InitPidFd() (int, error) {
+	c.m.Lock()
+	defer c.m.Unlock()
+	status, err := c.currentStatus()
+	if err != nil {
+		return -1, err
+	}
+	if status == Stopped {
+		return -1, newGenericError(fmt.Errorf("container not running"), ContainerNotRunning)
+	}
+	fd, err := openPidFd(c.initProcess.pid(), c.initProcessStartTime)
+	if err != nil {
+		return -1, newSystemErrorWithCause(err, "opening pidfd for init process")
+	}
+	return fd, nil
+}
+
+// openPidFd returns a pidfd for pid after checking that pid still belongs to
+// the process that was started at startTime. Checking after the pidfd has
+// been opened closes the window in which pid could be reused.
+func openPidFd(pid int, startTime string) (int, error) {
+	fd, err := system.PidfdOpen(pid)
+	if err != nil {
+		return -1, err
+	}
+	current, err := system.GetProcessStartTime(pid)
+	if os.IsNotExist(err) || (err == nil && current != startTime) {
+		err = syscall.ESRCH
+	}
+	if err != nil {
+		syscall.Close(fd)
+		return -1, err
+	}
+	return fd, nil
+}
+
+func (c *linuxContainer) AddNetwork(n *configs.Network) error {
+	c.m.Lock()
+	defer c.m.Unlock()
+	for _, existing := range c.config.Networks {
+		if existing.Name == n.Name {
+			return newGenericError(fmt.Errorf("network %q already exists", n.Name), ConfigInvalid)
+		}
+	}
+	if !c.config.Namespaces.Contains(configs.NEWNET) {
+		return newGenericError(fmt.Errorf("container does not have a network namespace"), ConfigInvalid)
+	}
+	strategy, err := getStrategy(n.Type)
+	if err != nil {
+		return newGenericError(err, ConfigInvalid)
+	}
+	state, err := c.runningState()
+	if err != nil {
+		return err
+	}
+	nw, err := createNetwork(strategy, n, state.InitProcessPid)
+	if err != nil {
+		return err
+	}
+	c.config.Networks = append(c.config.Networks, nw)
+	if err := c.persistState(); err != nil {
+		c.config.Networks = c.config.Networks[:len(c.config.Networks)-1]
+		return err
+	}
+	return nil
+}
+
+// createNetwork creates the network n for the container whose init is pid and
+// configures it inside the container's network namespace.
+func createNetwork(strategy networkStrategy, n *configs.Network, pid int) (*configs.Network, error) {
+	nw := &network{Network: *n}
+	if err := strategy.create(nw, pid); err != nil {
+		return nil, newSystemErrorWithCause(err, "creating network")
+	}
+	if err := inNetns(pid, func() error {
+		if err := strategy.initialize(nw); err != nil {
+			return err
+		}
+		return setupArp(nw)
+	}); err != nil {
+		if nw.HostInterfaceName != "" {
+			deleteHostInterface(nw.HostInterfaceName)
+		}
+		return nil, newSystemErrorWithCause(err, "initializing network")
+	}
+	return &nw.Network, nil
+}
+
+func (c *linuxContainer) UpdateNetwork(name string, n *configs.Network) error {
+	c.m.Lock()
+	defer c.m.Unlock()
+	index := -1
+	for i, existing := range c.config.Networks {
+		if existing.Name == name {
+			index = i
+		} else if existing.Name == n.Name {
+			return newGenericError(fmt.Errorf("network %q already exists", n.Name), ConfigInvalid)
+		}
+	}
+	if index < 0 {
+		return newGenericError(fmt.Errorf("network %q does not exist", name), ConfigInvalid)
+	}
+	strategy, err := getStrategy(n.Type)
+	if err != nil {
+		return newGenericError(err, ConfigInvalid)
+	}
+	old := c.config.Networks[index]
+	recreate := networkNeedsRecreate(old, n)
+	if recreate && (!networkRemovable(old) || !networkRemovable(n)) {
+		return newGenericError(fmt.Errorf("network %q cannot be recreated without a host interface", name), ConfigInvalid)
+	}
+	state, err := c.runningState()
+	if err != nil {
+		return err
+	}
+	updated := new(configs.Network)
+	*updated = *n
+	if recreate {
+		oldStrategy, err := getStrategy(old.Type)
+		if err != nil {
+			return newGenericError(err, ConfigInvalid)
+		}
+		if err := inNetns(state.InitProcessPid, func() error { return delRouteTableRules(old) }); err != nil {
+			return newSystemErrorWithCause(err, "removing route table rules")
+		}
+		if err := oldStrategy.remove(old, state.InitProcessPid); err != nil {
+			return newSystemErrorWithCause(err, "removing network")
+		}
+		if updated, err = createNetwork(strategy, n, state.InitProcessPid); err != nil {
+			// The old interface is gone, so stop reporting it.
+			c.config.Networks = append(c.config.Networks[:index:index], c.config.Networks[index+1:]...)
+			c.persistState()
+			return err
+		}
+	} else {
+		if n.HostInterfaceName != "" && n.Mtu != old.Mtu {
+			if err := setHostInterfaceMTU(n.HostInterfaceName, n.Mtu); err != nil {
+				return newSystemErrorWithCause(err, "setting host interface mtu")
+			}
+		}
+		routes := c.interfaceRoutes(n.Name)
+		if err := inNetns(state.InitProcessPid, func() error {
+			if err := delRouteTableRules(old); err != nil {
+				return err
+			}
+			if err := strategy.reconfigure(updated); err != nil {
+				return err
+			}
+			if err := setupArp(&network{Network: *updated}); err != nil {
+				return err
+			}
+			return setupRoute(&configs.Config{Routes: routes, Networks: []*configs.Network{updated}})
+		}); err != nil {
+			return newSystemErrorWithCause(err, "reconfiguring network")
+		}
+	}
+	c.config.Networks[index] = updated
+	if err := c.persistState(); err != nil {
+		c.config.Networks[index] = old
+		return err
+	}
+	return nil
+}
+
+// interfaceRoutes returns the configured routes that go through the named
+// interface.
+func (c *linuxContainer) interfaceRoutes(name string) []*configs.Route {
+	var routes []*configs.Route
+	for _, r := range c.config.Routes {
+		if r.InterfaceName == name {
+			routes = append(routes, r)
+		}
+	}
+	return routes
+}
+
+func (c *linuxContainer) RemoveNetwork(name string) error {
+	c.m.Lock()
+	defer c.m.Unlock()
+	index := -1
+	for i, n := range c.config.Networks {
+		if n.Name == name {
+			index = i
+			break
+		}
+	}
+	if index < 0 {
+		return newGenericError(fmt.Errorf("network %q does not exist", name), ConfigInvalid)
+	}
+	n := c.config.Networks[index]
+	if !networkRemovable(n) {
+		return newGenericError(fmt.Errorf("network %q has no host interface to remove", name), ConfigInvalid)
+	}
+	strategy, err := getStrategy(n.Type)
+	if err != nil {
+		return newGenericError(err, ConfigInvalid)
+	}
+	state, err := c.runningState()
+	if err != nil {
+		return err
+	}
+	if err := inNetns(state.InitProcessPid, func() error { return delRouteTableRules(n) }); err != nil {
+		return newSystemErrorWithCause(err, "removing route table rules")
+	}
+	if err := strategy.remove(n, state.InitProcessPid); err != nil {
+		return newSystemErrorWithCause(err, "removing network")
+	}
+	networks := make([]*configs.Network, 0, len(c.config.Networks)-1)
+	networks = append(networks, c.config.Networks[:index]...)
+	networks = append(networks, c.config.Networks[index+1:]...)
+	old := c.config.Networks
+	c.config.Networks = networks
+	if err := c.persistState(); err != nil {
+		c.config.Networks = old
+		return err
+	}
+	return nil
+}
+
+// runningState returns the current state of the container, failing if the
+// container has no live init process.
+func (c *linuxContainer) runningState() (*State, error) {
+	status, err := c.currentStatus()
+	if err != nil {
+		return nil, err
+	}
+	if status == Stopped {
+		return nil, newGenericError(fmt.Errorf("container not running"), ContainerNotRunning)
+	}
+	return c.currentState()
+}
+
+// persistState saves the state so that it reflects changes made to the
+// running container, such as its networks and devices.
+func (c *linuxContainer) persistState() error {
+	state, err := c.currentState()
+	if err != nil {
+		return err
+	}
+	if err := c.saveState(state); err != nil {
+		return newSystemErrorWithCause(err, "saving state")
+	}
+	return nil
+}
+
 func (c *linuxContainer) createExecFifo() error {
 	rootuid, err := c.Config().HostRootUID()
 	if err != nil {
@@ -407,17 +1104,30 @@ func (c *linuxContainer) newInitProcess(p *Process, cmd *exec.Cmd, parentPipe, c
 	if err != nil {
 		return nil, err
 	}
+	config, err := c.newInitConfig(p)
+	if err != nil {
+		return nil, err
+	}
+	var reaper *os.File
+	if c.config.MinimalInit {
+		if reaper, err = newReaperFile(cmd.Path); err != nil {
+			return nil, newSystemErrorWithCause(err, "copying init binary for the reaper")
+		}
+		cmd.ExtraFiles = append(cmd.ExtraFiles, reaper)
+		config.ReaperFd = stdioFdCount + len(cmd.ExtraFiles) - 1
+	}
 	return &initProcess{
 		cmd:           cmd,
 		childPipe:     childPipe,
 		parentPipe:    parentPipe,
 		manager:       c.cgroupManager,
-		config:        c.newInitConfig(p),
+		config:        config,
 		container:     c,
 		process:       p,
 		bootstrapData: data,
 		sharePidns:    sharePidns,
 		rootDir:       rootDir,
+		reaper:        reaper,
 	}, nil
 }
 
@@ -433,22 +1143,47 @@ func (c *linuxContainer) newSetnsProcess(p *Process, cmd *exec.Cmd, parentPipe,
 	if err != nil {
 		return nil, err
 	}
+	config, err := c.newInitConfig(p)
+	if err != nil {
+		return nil, err
+	}
+	cgroupPaths := c.cgroupManager.GetPaths()
+	if p.SubCgroup != "" {
+		if c.config.Rootless {
+			return nil, newGenericError(fmt.Errorf("cannot place a process in a sub-cgroup of a rootless container"), ConfigInvalid)
+		}
+		if cgroupPaths, err = subtreePaths(cgroupPaths, p.SubCgroup); err != nil {
+			return nil, err
+		}
+	}
 	return &setnsProcess{
 		cmd:           cmd,
-		cgroupPaths:   c.cgroupManager.GetPaths(),
+		cgroupPaths:   cgroupPaths,
 		childPipe:     childPipe,
 		parentPipe:    parentPipe,
-		config:        c.newInitConfig(p),
+		config:        config,
 		process:       p,
 		bootstrapData: data,
 	}, nil
 }
 
-func (c *linuxContainer) newInitConfig(process *Process) *initConfig {
+func (c *linuxContainer) newInitConfig(process *Process) (*initConfig, error) {
+	env := process.Env
+	if c.config.EnvFile != "" {
+		fileEnv, err := configs.ReadEnvFile(c.config.EnvFile)
+		if err != nil {
+			return nil, newSystemErrorWithCause(err, "reading environment file")
+		}
+		// The process environment comes last so that it takes precedence.
+		env = append(fileEnv, process.Env...)
+	}
+	if len(c.config.HostEnv) > 0 {
+		env = append(configs.LookupHostEnv(c.config.HostEnv), env...)
+	}
 	cfg := &initConfig{
 		Config:           c.config,
 		Args:             process.Args,
-		Env:              process.Env,
+		Env:              env,
 		User:             process.User,
 		AdditionalGroups: process.AdditionalGroups,
 		Cwd:              process.Cwd,
@@ -473,8 +1208,44 @@ func (c *linuxContainer) newInitConfig(process *Process) *initConfig {
 	if len(process.Rlimits) > 0 {
 		cfg.Rlimits = process.Rlimits
 	}
+	if c.config.MaxCoreSize != nil {
+		cfg.Rlimits = withCoreLimit(cfg.Rlimits, *c.config.MaxCoreSize)
+	}
+	if c.config.ResetRlimits {
+		cfg.Rlimits = withDefaultRlimits(cfg.Rlimits)
+	}
 	cfg.CreateConsole = process.ConsoleSocket != nil
-	return cfg
+	return cfg, nil
+}
+
+// withCoreLimit returns rlimits with RLIMIT_CORE set to max unless rlimits
+// already set it.
+func withCoreLimit(rlimits []configs.Rlimit, max uint64) []configs.Rlimit {
+	for _, rl := range rlimits {
+		if rl.Type == syscall.RLIMIT_CORE {
+			return rlimits
+		}
+	}
+	limits := make([]configs.Rlimit, len(rlimits), len(rlimits)+1)
+	copy(limits, rlimits)
+	return append(limits, configs.Rlimit{Type: syscall.RLIMIT_CORE, Hard: max, Soft: max})
+}
+
+// withDefaultRlimits returns rlimits with the kernel's default added for every
+// resource that rlimits does not set.
+func withDefaultRlimits(rlimits []configs.Rlimit) []configs.Rlimit {
+	set := make(map[int]bool, len(rlimits))
+	for _, rl := range rlimits {
+		set[rl.Type] = true
+	}
+	limits := make([]configs.Rlimit, len(rlimits), len(rlimits)+len(defaultRlimits))
+	copy(limits, rlimits)
+	for _, rl := range defaultRlimits {
+		if !set[rl.Type] {
+			limits = append(limits, rl)
+		}
+	}
+	return limits
 }
 
 func (c *linuxContainer) Destroy() error {
@@ -787,7 +1558,7 @@ func (c *linuxContainer) Checkpoint(criuOpts *CriuOpts) error {
 
 	//no need to dump these information in pre-dump
 	if !criuOpts.PreDump {
-		for _, m := range c.config.Mounts {
+		for _, m := range c.config.AllMounts() {
 			switch m.Device {
 			case "bind":
 				c.addCriuDumpMount(req, m)
@@ -940,7 +1711,7 @@ func (c *linuxContainer) Restore(process *Process, criuOpts *CriuOpts) error {
 		},
 	}
 
-	for _, m := range c.config.Mounts {
+	for _, m := range c.config.AllMounts() {
 		switch m.Device {
 		case "bind":
 			c.addCriuRestoreMount(req, m)
@@ -1422,6 +2193,8 @@ func (c *linuxContainer) currentState() (*State, error) {
 		CgroupPaths:         c.cgroupManager.GetPaths(),
 		NamespacePaths:      make(map[configs.NamespaceType]string),
 		ExternalDescriptors: externalDescriptors,
+		ExitStatus:          c.exitStatus,
+		RootfsImage:         c.rootfsImage,
 	}
 	if pid > 0 {
 		for _, ns := range c.config.Namespaces {
@@ -3,12 +3,25 @@
 package libcontainer
 
 import (
+	"bufio"
+	"encoding/json"
 	"fmt"
+	"io/ioutil"
 	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"syscall"
 	"testing"
+	"time"
 
 	"github.com/opencontainers/runc/libcontainer/cgroups"
+	"github.com/opencontainers/runc/libcontainer/cgroups/fs"
 	"github.com/opencontainers/runc/libcontainer/configs"
+	"github.com/opencontainers/runc/libcontainer/system"
+	"github.com/opencontainers/selinux/go-selinux"
 )
 
 type mockCgroupManager struct {
@@ -51,8 +64,9 @@ func (m *mockCgroupManager) Freeze(state configs.FreezerState) error {
 }
 
 type mockProcess struct {
-	_pid    int
-	started string
+	_pid     int
+	started  string
+	signaled []os.Signal
 }
 
 func (m *mockProcess) terminate() error {
@@ -75,7 +89,8 @@ func (m *mockProcess) wait() (*os.ProcessState, error) {
 	return nil, nil
 }
 
-func (m *mockProcess) signal(_ os.Signal) error {
+func (m *mockProcess) signal(s os.Signal) error {
+	m.signaled = append(m.signaled, s)
 	return nil
 }
 
@@ -86,6 +101,29 @@ func (m *mockProcess) externalDescriptors() []string {
 func (m *mockProcess) setExternalDescriptors(newFds []string) {
 }
 
+// newMockContainer returns a running container whose init is the process
+// with pid and whose cgroups are at paths.
+func newMockContainer(t *testing.T, pid int, paths map[string]string) *linuxContainer {
+	startTime, err := system.GetProcessStartTime(pid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	container := &linuxContainer{
+		id:                   "myid",
+		config:               &configs.Config{},
+		initProcessStartTime: startTime,
+		initProcess: &mockProcess{
+			_pid:    pid,
+			started: startTime,
+		},
+		cgroupManager: &mockCgroupManager{
+			paths: paths,
+		},
+	}
+	container.state = &runningState{c: container}
+	return container
+}
+
 func TestGetContainerPids(t *testing.T) {
 	container := &linuxContainer{
 		id:            "myid",
@@ -103,6 +141,208 @@ func TestGetContainerPids(t *testing.T) {
 	}
 }
 
+func TestGetContainerCgroupPaths(t *testing.T) {
+	expected := map[string]string{
+		"memory":  "/sys/fs/cgroup/memory/myid",
+		"cpu":     "/sys/fs/cgroup/cpu,cpuacct/myid",
+		"cpuacct": "/sys/fs/cgroup/cpu,cpuacct/myid",
+	}
+	m := &mockCgroupManager{paths: map[string]string{}}
+	for k, v := range expected {
+		m.paths[k] = v
+	}
+	container := &linuxContainer{
+		id:            "myid",
+		config:        &configs.Config{},
+		cgroupManager: m,
+	}
+	paths := container.CgroupPaths()
+	if !reflect.DeepEqual(paths, expected) {
+		t.Fatalf("expected cgroup paths %v but received %v", expected, paths)
+	}
+	paths["memory"] = "/elsewhere"
+	delete(paths, "cpu")
+	if !reflect.DeepEqual(m.paths, expected) {
+		t.Fatalf("modifying the returned paths changed the manager's paths to %v", m.paths)
+	}
+}
+
+func TestNetworkChangesRejectInvalidNames(t *testing.T) {
+	container := &linuxContainer{
+		id: "myid",
+		config: &configs.Config{
+			Namespaces: configs.Namespaces{{Type: configs.NEWNET}},
+			Networks: []*configs.Network{
+				{Type: "loopback", Name: "lo"},
+				{Type: "veth", Name: "eth0", HostInterfaceName: "veth0"},
+			},
+		},
+		cgroupManager: &mockCgroupManager{},
+	}
+	for _, test := range []struct {
+		desc string
+		err  error
+	}{
+		{"adding a duplicate name", container.AddNetwork(&configs.Network{Type: "veth", Name: "eth0"})},
+		{"removing an unknown name", container.RemoveNetwork("eth1")},
+		{"removing a network without a host interface", container.RemoveNetwork("lo")},
+		{"updating an unknown name", container.UpdateNetwork("eth1", &configs.Network{Type: "veth", Name: "eth1"})},
+		{"renaming to an existing name", container.UpdateNetwork("eth0", &configs.Network{Type: "veth", Name: "lo"})},
+		{"recreating a network without a host interface", container.UpdateNetwork("lo", &configs.Network{Type: "veth", Name: "lo"})},
+	} {
+		if err, ok := test.err.(Error); !ok || err.Code() != ConfigInvalid {
+			t.Errorf("%s: expected a ConfigInvalid error, got %v", test.desc, test.err)
+		}
+	}
+	if len(container.config.Networks) != 2 {
+		t.Fatalf("expected the networks to be unchanged, got %d", len(container.config.Networks))
+	}
+}
+
+func TestSetRestoresLimitsOnFailure(t *testing.T) {
+	root, err := ioutil.TempDir("", "libcontainer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	cpuPath := filepath.Join(root, "cpu")
+	if err := os.MkdirAll(cpuPath, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(cpuPath, "cpu.shares"), []byte("1024"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	config := &configs.Config{
+		Cgroups: &configs.Cgroup{
+			Resources: &configs.Resources{CpuShares: 1024},
+		},
+	}
+	container := &linuxContainer{
+		id:     "myid",
+		root:   root,
+		config: config,
+		cgroupManager: &fs.Manager{
+			Cgroups: config.Cgroups,
+			Paths: map[string]string{
+				"cpu": cpuPath,
+				// pids is applied after cpu and fails as the directory is missing.
+				"pids": filepath.Join(root, "pids"),
+			},
+		},
+	}
+	startTime, err := system.GetProcessStartTime(os.Getpid())
+	if err != nil {
+		t.Fatal(err)
+	}
+	container.initProcess = &mockProcess{_pid: os.Getpid(), started: startTime}
+	container.initProcessStartTime = startTime
+	container.state = &runningState{c: container}
+
+	update := *config
+	update.Cgroups = &configs.Cgroup{
+		Transactional: true,
+		Resources:     &configs.Resources{CpuShares: 512, PidsLimit: 10},
+	}
+	err = container.Set(update)
+	if err == nil || !strings.Contains(err.Error(), "pids.max") {
+		t.Fatalf("expected the update to fail writing pids.max but received %v", err)
+	}
+	data, err := ioutil.ReadFile(filepath.Join(cpuPath, "cpu.shares"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "1024" {
+		t.Fatalf("expected cpu.shares to be restored to 1024 but was %s", data)
+	}
+	if container.Config().Cgroups.Resources.CpuShares != 1024 {
+		t.Fatal("expected the config of the failed update to be discarded")
+	}
+}
+
+func TestSetPersistsLimitsForLoad(t *testing.T) {
+	factoryRoot, err := ioutil.TempDir("", "libcontainer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(factoryRoot)
+	root := filepath.Join(factoryRoot, "myid")
+	cpuPath := filepath.Join(factoryRoot, "cgroup", "cpu")
+	if err := os.MkdirAll(root, 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(cpuPath, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(cpuPath, "cpu.shares"), []byte("1024"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	config := &configs.Config{
+		Cgroups: &configs.Cgroup{
+			Resources: &configs.Resources{CpuShares: 1024},
+		},
+	}
+	container := &linuxContainer{
+		id:     "myid",
+		root:   root,
+		config: config,
+		cgroupManager: &fs.Manager{
+			Cgroups: config.Cgroups,
+			Paths:   map[string]string{"cpu": cpuPath},
+		},
+	}
+	startTime, err := system.GetProcessStartTime(os.Getpid())
+	if err != nil {
+		t.Fatal(err)
+	}
+	container.initProcess = &mockProcess{_pid: os.Getpid(), started: startTime}
+	container.initProcessStartTime = startTime
+	container.state = &runningState{c: container}
+
+	update := *config
+	update.Cgroups = &configs.Cgroup{
+		Resources: &configs.Resources{CpuShares: 512},
+	}
+	if err := container.Set(update); err != nil {
+		t.Fatal(err)
+	}
+
+	factory := &LinuxFactory{
+		Root: factoryRoot,
+		NewCgroupsManager: func(config *configs.Cgroup, paths map[string]string) cgroups.Manager {
+			return &fs.Manager{Cgroups: config, Paths: paths}
+		},
+	}
+	loaded, err := factory.Load("myid")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if shares := loaded.Config().Cgroups.Resources.CpuShares; shares != 512 {
+		t.Fatalf("expected the loaded container to have 512 cpu shares but had %d", shares)
+	}
+
+	// The reloaded container can update its limits again.
+	update = loaded.Config()
+	update.Cgroups.Resources.CpuShares = 256
+	if err := loaded.Set(update); err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadFile(filepath.Join(cpuPath, "cpu.shares"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "256" {
+		t.Fatalf("expected cpu.shares to be 256 but was %s", data)
+	}
+	if loaded, err = factory.Load("myid"); err != nil {
+		t.Fatal(err)
+	}
+	if shares := loaded.Config().Cgroups.Resources.CpuShares; shares != 256 {
+		t.Fatalf("expected the reloaded container to have 256 cpu shares but had %d", shares)
+	}
+}
+
 func TestGetContainerStats(t *testing.T) {
 	container := &linuxContainer{
 		id:     "myid",
@@ -130,6 +370,236 @@ func TestGetContainerStats(t *testing.T) {
 	}
 }
 
+func TestGetContainerLabels(t *testing.T) {
+	const (
+		processLabel = "system_u:system_r:svirt_lxc_net_t:s0:c1,c2"
+		mountLabel   = "system_u:object_r:svirt_sandbox_file_t:s0:c1,c2"
+	)
+	container := &linuxContainer{
+		id: "myid",
+		config: &configs.Config{
+			ProcessLabel: processLabel,
+			MountLabel:   mountLabel,
+		},
+		cgroupManager: &mockCgroupManager{},
+	}
+	container.state = &stoppedState{c: container}
+	if l := container.MountLabel(); l != mountLabel {
+		t.Fatalf("expected mount label %q but received %q", mountLabel, l)
+	}
+	l, err := container.ProcessLabel()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l != processLabel {
+		t.Fatalf("expected stopped container to report the configured label %q but received %q", processLabel, l)
+	}
+
+	startTime, err := system.GetProcessStartTime(os.Getpid())
+	if err != nil {
+		t.Fatal(err)
+	}
+	container.initProcess = &mockProcess{_pid: os.Getpid(), started: startTime}
+	container.state = &runningState{c: container}
+	expected := processLabel
+	if selinux.GetEnabled() {
+		if expected, err = selinux.PidLabel(os.Getpid()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if l, err = container.ProcessLabel(); err != nil {
+		t.Fatal(err)
+	}
+	if l != expected {
+		t.Fatalf("expected running container to report the applied label %q but received %q", expected, l)
+	}
+}
+
+func TestWriteProcessPid(t *testing.T) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	if err := writeProcessPid(w, 4242); err != nil {
+		t.Fatal(err)
+	}
+	w.Close()
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "4242" {
+		t.Fatalf("expected the pid file to hold 4242, got %q", data)
+	}
+}
+
+func TestInitConfigCoreLimit(t *testing.T) {
+	var maxCoreSize uint64
+	nofile := configs.Rlimit{Type: syscall.RLIMIT_NOFILE, Hard: 1024, Soft: 1024}
+	container := &linuxContainer{
+		id: "myid",
+		config: &configs.Config{
+			Rlimits:     []configs.Rlimit{nofile},
+			MaxCoreSize: &maxCoreSize,
+		},
+	}
+	cfg, err := container.newInitConfig(&Process{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []configs.Rlimit{nofile, {Type: syscall.RLIMIT_CORE}}
+	if !reflect.DeepEqual(cfg.Rlimits, expected) {
+		t.Fatalf("expected rlimits %v but received %v", expected, cfg.Rlimits)
+	}
+	if len(container.config.Rlimits) != 1 {
+		t.Fatalf("expected the container's rlimits to be left alone, got %v", container.config.Rlimits)
+	}
+
+	core := configs.Rlimit{Type: syscall.RLIMIT_CORE, Hard: 4096, Soft: 4096}
+	cfg, err = container.newInitConfig(&Process{Rlimits: []configs.Rlimit{core}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(cfg.Rlimits, []configs.Rlimit{core}) {
+		t.Fatalf("expected the process's RLIMIT_CORE to be kept, got %v", cfg.Rlimits)
+	}
+}
+
+func TestInitConfigResetRlimits(t *testing.T) {
+	nofile := configs.Rlimit{Type: syscall.RLIMIT_NOFILE, Hard: 65536, Soft: 65536}
+	container := &linuxContainer{
+		id:     "myid",
+		config: &configs.Config{},
+	}
+	cfg, err := container.newInitConfig(&Process{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cfg.Rlimits) != 0 {
+		t.Fatalf("expected no rlimits when inheriting, got %v", cfg.Rlimits)
+	}
+
+	container.config.ResetRlimits = true
+	cfg, err = container.newInitConfig(&Process{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(cfg.Rlimits, defaultRlimits) {
+		t.Fatalf("expected the default rlimits %v but received %v", defaultRlimits, cfg.Rlimits)
+	}
+
+	cfg, err = container.newInitConfig(&Process{Rlimits: []configs.Rlimit{nofile}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cfg.Rlimits) != len(defaultRlimits) {
+		t.Fatalf("expected %d rlimits, got %v", len(defaultRlimits), cfg.Rlimits)
+	}
+	for _, rl := range cfg.Rlimits {
+		if rl.Type == syscall.RLIMIT_NOFILE && rl != nofile {
+			t.Fatalf("expected the process's RLIMIT_NOFILE to override the default, got %v", rl)
+		}
+	}
+}
+
+func TestInitConfigEnvFile(t *testing.T) {
+	f, err := ioutil.TempFile("", "envfile")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.WriteString("# defaults\nPATH=/bin\n\nTERM=dumb\n"); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	container := &linuxContainer{
+		id: "myid",
+		config: &configs.Config{
+			EnvFile: f.Name(),
+		},
+	}
+	cfg, err := container.newInitConfig(&Process{Env: []string{"TERM=xterm", "HOME=/root"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"PATH=/bin", "TERM=dumb", "TERM=xterm", "HOME=/root"}
+	if !reflect.DeepEqual(cfg.Env, expected) {
+		t.Fatalf("expected environment %q but received %q", expected, cfg.Env)
+	}
+
+	os.Setenv("LIBCONTAINER_TEST_TERM", "screen")
+	defer os.Unsetenv("LIBCONTAINER_TEST_TERM")
+	container.config.HostEnv = []string{"LIBCONTAINER_TEST_TERM", "LIBCONTAINER_TEST_UNSET"}
+	cfg, err = container.newInitConfig(&Process{Env: []string{"TERM=xterm"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected = []string{"LIBCONTAINER_TEST_TERM=screen", "PATH=/bin", "TERM=dumb", "TERM=xterm"}
+	if !reflect.DeepEqual(cfg.Env, expected) {
+		t.Fatalf("expected the host environment first in %q but received %q", expected, cfg.Env)
+	}
+
+	container.config.EnvFile = f.Name() + ".missing"
+	if _, err := container.newInitConfig(&Process{}); err == nil {
+		t.Fatal("expected an error for a missing environment file")
+	}
+}
+
+func TestExportSpec(t *testing.T) {
+	startTime, err := system.GetProcessStartTime(os.Getpid())
+	if err != nil {
+		t.Fatal(err)
+	}
+	hook := configs.NewCommandHook(configs.Command{
+		Path: "/bin/hook",
+		Env:  []string{"TOKEN=secret", "EMPTY"},
+	})
+	container := &linuxContainer{
+		id: "myid",
+		config: &configs.Config{
+			Rootfs:   "/var/lib/rootfs",
+			Hostname: "exported",
+			Hooks:    &configs.Hooks{Prestart: []configs.Hook{hook}},
+		},
+		initProcess:   &mockProcess{_pid: os.Getpid(), started: startTime},
+		cgroupManager: &mockCgroupManager{},
+	}
+	container.state = &runningState{c: container}
+
+	data, err := container.ExportSpec(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var state State
+	if err := json.Unmarshal(data, &state); err != nil {
+		t.Fatal(err)
+	}
+	if state.InitProcessPid != os.Getpid() {
+		t.Fatalf("expected the exported state to have init pid %d but had %d", os.Getpid(), state.InitProcessPid)
+	}
+	if !reflect.DeepEqual(state.Config, *container.config) {
+		t.Fatalf("expected the exported config to round-trip to %+v but got %+v", *container.config, state.Config)
+	}
+
+	if data, err = container.ExportSpec(true); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "secret") {
+		t.Fatalf("expected the hook environment to be redacted: %s", data)
+	}
+	if err := json.Unmarshal(data, &state); err != nil {
+		t.Fatal(err)
+	}
+	env := state.Config.Hooks.Prestart[0].(configs.CommandHook).Env
+	if expected := []string{"TOKEN=<redacted>", "EMPTY=<redacted>"}; !reflect.DeepEqual(env, expected) {
+		t.Fatalf("expected redacted hook environment %v but got %v", expected, env)
+	}
+	if container.config.Hooks.Prestart[0].(configs.CommandHook).Env[0] != "TOKEN=secret" {
+		t.Fatal("expected redacting the export to leave the container's config alone")
+	}
+}
+
 func TestGetContainerState(t *testing.T) {
 	var (
 		pid                 = os.Getpid()
@@ -216,3 +686,223 @@ func TestGetContainerState(t *testing.T) {
 		}
 	}
 }
+
+func TestExecFifoSignalsExecOnce(t *testing.T) {
+	root, err := ioutil.TempDir("", "libcontainer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	fifo := filepath.Join(root, execFifoFilename)
+	if err := syscall.Mkfifo(fifo, 0622); err != nil {
+		t.Fatal(err)
+	}
+	container := &linuxContainer{
+		id:     "myid",
+		root:   root,
+		config: &configs.Config{},
+	}
+	container.state = &createdState{c: container}
+
+	// Emulate the init: block until the fifo is opened for reading, write the
+	// marker and close the fifo as execve(2) would.
+	writes := make(chan error, 1)
+	go func() {
+		f, err := os.OpenFile(fifo, os.O_WRONLY, 0)
+		if err != nil {
+			writes <- err
+			return
+		}
+		_, err = f.Write([]byte("0"))
+		f.Close()
+		writes <- err
+	}()
+	if err := container.exec(); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-writes; err != nil {
+		t.Fatal(err)
+	}
+	if status := container.state.status(); status != Running {
+		t.Fatalf("expected status %s but received %s", Running, status)
+	}
+	if _, err := os.Stat(fifo); !os.IsNotExist(err) {
+		t.Fatalf("expected exec fifo to be removed, got %v", err)
+	}
+	if err := container.exec(); err == nil {
+		t.Fatal("expected second exec to fail")
+	}
+}
+
+// isGone reports whether pid has exited, treating zombies as exited since
+// they may be reaped by someone other than the test.
+func isGone(pid int) bool {
+	data, err := ioutil.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+	if err != nil {
+		return true
+	}
+	fields := strings.Fields(string(data[strings.LastIndex(string(data), ")")+1:]))
+	return fields[0] == "Z" || fields[0] == "X"
+}
+
+func TestSignalChecksInitStartTime(t *testing.T) {
+	startTime, err := system.GetProcessStartTime(os.Getpid())
+	if err != nil {
+		t.Fatal(err)
+	}
+	init := &mockProcess{_pid: os.Getpid(), started: startTime}
+	container := &linuxContainer{
+		id:            "myid",
+		config:        &configs.Config{},
+		initProcess:   init,
+		cgroupManager: &mockCgroupManager{},
+	}
+	container.initProcessStartTime = startTime
+	container.state = &runningState{c: container}
+	if err := container.Signal(syscall.SIGUSR1, false); err != nil {
+		t.Fatal(err)
+	}
+	if len(init.signaled) != 1 || init.signaled[0] != syscall.SIGUSR1 {
+		t.Fatalf("expected the init to be signaled with SIGUSR1, got %v", init.signaled)
+	}
+
+	// A different start time means the pid of the init has been reused.
+	container.initProcessStartTime = "0"
+	err = container.Signal(syscall.SIGUSR1, false)
+	if e, ok := err.(Error); !ok || e.Code() != ContainerNotRunning {
+		t.Fatalf("expected a ContainerNotRunning error, got %v", err)
+	}
+	if len(init.signaled) != 1 {
+		t.Fatalf("expected a recycled pid not to be signaled, got %v", init.signaled)
+	}
+}
+
+func TestSignalProcessTree(t *testing.T) {
+	parent := exec.Command("sh", "-c", "sleep 100 & echo $!; wait")
+	stdout, err := parent.StdoutPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := parent.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer parent.Process.Kill()
+	line, err := bufio.NewReader(stdout).ReadString('\n')
+	if err != nil {
+		t.Fatal(err)
+	}
+	child, err := strconv.Atoi(strings.TrimSpace(line))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer syscall.Kill(child, syscall.SIGKILL)
+
+	sibling := exec.Command("sleep", "100")
+	if err := sibling.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer sibling.Process.Kill()
+
+	container := &linuxContainer{
+		id:     "myid",
+		config: &configs.Config{},
+		cgroupManager: &mockCgroupManager{
+			allPids: []int{parent.Process.Pid, child, sibling.Process.Pid},
+		},
+	}
+	if err := container.SignalProcessTree(parent.Process.Pid, syscall.SIGKILL); err != nil {
+		t.Fatal(err)
+	}
+	if err := parent.Wait(); err == nil {
+		t.Fatal("expected the parent of the tree to be killed")
+	}
+	for i := 0; !isGone(child); i++ {
+		if i == 100 {
+			t.Fatalf("expected descendant %d to be killed", child)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if isGone(sibling.Process.Pid) {
+		t.Fatal("expected process outside of the tree to keep running")
+	}
+
+	err = container.SignalProcessTree(os.Getpid(), syscall.SIGKILL)
+	if err == nil {
+		t.Fatal("expected an error signaling a pid outside of the container")
+	}
+	if lerr, ok := err.(Error); !ok || lerr.Code() != ProcessNotExists {
+		t.Fatalf("expected ProcessNotExists but received %v", err)
+	}
+}
+
+func TestSignalProcess(t *testing.T) {
+	target := exec.Command("sleep", "100")
+	if err := target.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer target.Process.Kill()
+	other := exec.Command("sleep", "100")
+	if err := other.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer other.Process.Kill()
+
+	container := &linuxContainer{
+		id:     "myid",
+		config: &configs.Config{},
+		cgroupManager: &mockCgroupManager{
+			allPids: []int{target.Process.Pid, other.Process.Pid},
+		},
+	}
+	if err := container.SignalProcess(target.Process.Pid, syscall.SIGTERM); err != nil {
+		t.Fatal(err)
+	}
+	if err := target.Wait(); err == nil {
+		t.Fatal("expected the signaled process to be terminated")
+	}
+	if isGone(other.Process.Pid) {
+		t.Fatal("expected the other process in the container to keep running")
+	}
+
+	err := container.SignalProcess(os.Getpid(), syscall.SIGTERM)
+	if lerr, ok := err.(Error); !ok || lerr.Code() != ProcessNotExists {
+		t.Fatalf("expected ProcessNotExists for a pid outside of the container but received %v", err)
+	}
+}
+
+func TestNonChildProcessSignalsThroughPidfd(t *testing.T) {
+	fd, err := system.PidfdOpen(os.Getpid())
+	if err == syscall.ENOSYS {
+		t.Skip("pidfds are not supported by the kernel")
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	syscall.Close(fd)
+
+	cmd := exec.Command("sleep", "100")
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer cmd.Process.Kill()
+	startTime, err := system.GetProcessStartTime(cmd.Process.Pid)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A stale start time means the pid now belongs to another process.
+	stale := &nonChildProcess{processPid: cmd.Process.Pid, processStartTime: "0"}
+	if err := stale.signal(syscall.SIGKILL); err != syscall.ESRCH {
+		t.Fatalf("expected ESRCH signaling a reused pid but received %v", err)
+	}
+
+	p := &nonChildProcess{processPid: cmd.Process.Pid, processStartTime: startTime}
+	if err := p.signal(syscall.SIGKILL); err != nil {
+		t.Fatal(err)
+	}
+	err = cmd.Wait()
+	status, ok := err.(*exec.ExitError)
+	if !ok || status.Sys().(syscall.WaitStatus).Signal() != syscall.SIGKILL {
+		t.Fatalf("expected process to be killed by SIGKILL but received %v", err)
+	}
+}
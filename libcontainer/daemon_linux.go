@@ -0,0 +1,42 @@
+// +build linux
+
+package libcontainer
+
+import (
+	"os"
+	"syscall"
+
+	"github.com/opencontainers/runc/libcontainer/configs"
+)
+
+// setupDaemon redirects the stdio of the init to /dev/null and the files
+// daemon names, so the process does not hold on to the stdio, which may be a
+// terminal, of whoever started it. The init is already the leader of a new
+// session without a controlling terminal, and in daemon mode it never takes
+// one.
+func setupDaemon(daemon *configs.Daemon) error {
+	stdio := []struct {
+		path string
+		flag int
+	}{
+		{"", os.O_RDONLY},
+		{daemon.Stdout, os.O_WRONLY | os.O_CREATE | os.O_APPEND},
+		{daemon.Stderr, os.O_WRONLY | os.O_CREATE | os.O_APPEND},
+	}
+	for fd, s := range stdio {
+		path, flag := s.path, s.flag
+		if path == "" {
+			path, flag = "/dev/null", os.O_RDWR
+		}
+		f, err := os.OpenFile(path, flag, 0644)
+		if err != nil {
+			return newSystemErrorWithCausef(err, "opening %s for daemon stdio", path)
+		}
+		err = syscall.Dup3(int(f.Fd()), fd, 0)
+		f.Close()
+		if err != nil {
+			return newSystemErrorWithCausef(err, "redirecting fd %d to %s", fd, path)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+// +build linux
+
+package libcontainer
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/opencontainers/runc/libcontainer/configs"
+)
+
+// TestSetupDaemon runs TestSetupDaemonHelper, which redirects its stdio in
+// daemon mode and writes to stdout and stderr, and expects the output in the
+// daemon's stdout file only.
+func TestSetupDaemon(t *testing.T) {
+	dir, err := ioutil.TempDir("", "libcontainer-daemon")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	log := filepath.Join(dir, "stdout.log")
+	if err := ioutil.WriteFile(log, []byte("earlier\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cmd := exec.Command(os.Args[0], "-test.run=^TestSetupDaemonHelper$")
+	cmd.Env = append(os.Environ(), "LIBCONTAINER_TEST_DAEMON="+log)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("daemon helper failed: %v\n%s", err, out)
+	}
+	if len(out) != 0 {
+		t.Fatalf("expected no output on the original stdio, got %q", out)
+	}
+	data, err := ioutil.ReadFile(log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The test binary itself reports PASS on stdout after the helper.
+	if !strings.HasPrefix(string(data), "earlier\nstdout\n") || strings.Contains(string(data), "stderr") {
+		t.Fatalf("expected stdout to be appended to %s, got %q", log, data)
+	}
+}
+
+func TestSetupDaemonHelper(t *testing.T) {
+	log := os.Getenv("LIBCONTAINER_TEST_DAEMON")
+	if log == "" {
+		return
+	}
+	if err := setupDaemon(&configs.Daemon{Stdout: log}); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := os.Stdin.Read(make([]byte, 1)); n != 0 || err == nil {
+		t.Fatalf("expected stdin to be /dev/null, read %d bytes: %v", n, err)
+	}
+	fmt.Fprintln(os.Stdout, "stdout")
+	fmt.Fprintln(os.Stderr, "stderr")
+}
@@ -0,0 +1,248 @@
+// +build linux
+
+package libcontainer
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	"github.com/opencontainers/runc/libcontainer/cgroups/fs"
+	"github.com/opencontainers/runc/libcontainer/configs"
+	"golang.org/x/sys/unix"
+)
+
+func (c *linuxContainer) AddDevice(dev *configs.Device) error {
+	c.m.Lock()
+	defer c.m.Unlock()
+	if err := c.checkDeviceChange(); err != nil {
+		return err
+	}
+	dev, err := hotplugDevice(dev)
+	if err != nil {
+		return newGenericError(err, ConfigInvalid)
+	}
+	resources, err := c.deviceResources()
+	if err != nil {
+		return err
+	}
+	if findDevice(c.config.Devices, dev.Path) >= 0 {
+		return newGenericError(fmt.Errorf("device %s already exists", dev.Path), ConfigInvalid)
+	}
+	node := *dev
+	if c.config.Namespaces.Contains(configs.NEWUSER) {
+		uid, err := c.config.HostUID(int(dev.Uid))
+		if err != nil {
+			return newGenericError(err, ConfigInvalid)
+		}
+		gid, err := c.config.HostGID(int(dev.Gid))
+		if err != nil {
+			return newGenericError(err, ConfigInvalid)
+		}
+		node.Uid, node.Gid = uint32(uid), uint32(gid)
+	}
+	if err := c.setDeviceRule(dev, true); err != nil {
+		return newSystemErrorWithCausef(err, "allowing device %s", dev.Path)
+	}
+	if err := createHotplugNode(c.initRoot(), &node); err != nil {
+		c.setDeviceRule(dev, false)
+		return newSystemErrorWithCausef(err, "creating device node %s", dev.Path)
+	}
+	added := *dev
+	c.config.Devices = append(c.config.Devices, &added)
+	allowDeviceRule(resources, &added)
+	return c.persistState()
+}
+
+func (c *linuxContainer) RemoveDevice(path string) error {
+	c.m.Lock()
+	defer c.m.Unlock()
+	if err := c.checkDeviceChange(); err != nil {
+		return err
+	}
+	i := findDevice(c.config.Devices, path)
+	if i < 0 {
+		return newGenericError(fmt.Errorf("device %s does not exist", path), ConfigInvalid)
+	}
+	resources, err := c.deviceResources()
+	if err != nil {
+		return err
+	}
+	dev := c.config.Devices[i]
+	devices := dropDeviceRule(resources.Devices, dev, false)
+	allowed := dropDeviceRule(resources.AllowedDevices, dev, true)
+	// Another rule, such as a default one, may still allow the device.
+	if !deviceAllowed(devices, dev, false) && !deviceAllowed(allowed, dev, true) {
+		if err := c.setDeviceRule(dev, false); err != nil {
+			return newSystemErrorWithCausef(err, "denying device %s", path)
+		}
+	}
+	if err := removeHotplugNode(c.initRoot(), path); err != nil {
+		return newSystemErrorWithCausef(err, "removing device node %s", path)
+	}
+	c.config.Devices = append(c.config.Devices[:i], c.config.Devices[i+1:]...)
+	resources.Devices, resources.AllowedDevices = devices, allowed
+	return c.persistState()
+}
+
+// checkDeviceChange returns an error if the devices of the container cannot
+// be changed.
+func (c *linuxContainer) checkDeviceChange() error {
+	if c.config.Rootless {
+		return newGenericError(fmt.Errorf("cannot change the devices of a rootless container"), ConfigInvalid)
+	}
+	_, err := c.runningState()
+	return err
+}
+
+// deviceResources returns the resources of the container, which hold its
+// device rules, and sets up empty ones for a config without any.
+func (c *linuxContainer) deviceResources() (*configs.Resources, error) {
+	if c.config.Cgroups == nil {
+		return nil, newGenericError(fmt.Errorf("container has no cgroup config"), ConfigInvalid)
+	}
+	if c.config.Cgroups.Resources == nil {
+		c.config.Cgroups.Resources = &configs.Resources{}
+	}
+	return c.config.Cgroups.Resources, nil
+}
+
+// hotplugDevice returns a copy of the device to be added, whose permissions
+// default to "rwm" as the devices cgroup takes no rule without any.
+func hotplugDevice(dev *configs.Device) (*configs.Device, error) {
+	if dev == nil {
+		return nil, fmt.Errorf("no device given")
+	}
+	if dev.Path == "" || !filepath.IsAbs(dev.Path) {
+		return nil, fmt.Errorf("device path %q is not absolute", dev.Path)
+	}
+	d := *dev
+	if d.Permissions == "" {
+		d.Permissions = "rwm"
+	}
+	if strings.Trim(d.Permissions, "rwm") != "" {
+		return nil, fmt.Errorf("device %s has invalid permissions %q", d.Path, d.Permissions)
+	}
+	return &d, nil
+}
+
+// initRoot returns the root directory of the init process as seen from the
+// host.
+func (c *linuxContainer) initRoot() string {
+	return fmt.Sprintf("/proc/%d/root", c.initProcess.pid())
+}
+
+// setDeviceRule allows or denies access to the device in the devices cgroup
+// of the container.
+func (c *linuxContainer) setDeviceRule(dev *configs.Device, allow bool) error {
+	path, ok := c.cgroupManager.GetPaths()["devices"]
+	if !ok {
+		return fmt.Errorf("devices cgroup is not mounted")
+	}
+	rule := *dev
+	rule.Allow = allow
+	devices := &fs.DevicesGroup{}
+	return devices.Set(path, &configs.Cgroup{
+		Resources: &configs.Resources{
+			Devices: []*configs.Device{&rule},
+		},
+	})
+}
+
+// createHotplugNode creates the device node below root, which is the root
+// of the container. The path is walked one component at a time relative to
+// root, so that the running container cannot point it at the host by
+// swapping a component for a symlink meanwhile.
+func createHotplugNode(root string, node *configs.Device) error {
+	fileMode, err := deviceFileMode(node)
+	if err != nil {
+		return err
+	}
+	rootDir, err := openRoot(root)
+	if err != nil {
+		return err
+	}
+	defer rootDir.Close()
+	dir, err := mkdirAllInRoot(rootDir, filepath.Dir(node.Path))
+	if err != nil {
+		return err
+	}
+	defer dir.Close()
+	name := filepath.Base(node.Path)
+	if err := unix.Mknodat(int(dir.Fd()), name, fileMode, node.Mkdev()); err != nil {
+		return &os.PathError{Op: "mknodat", Path: node.Path, Err: err}
+	}
+	if err := unix.Fchownat(int(dir.Fd()), name, int(node.Uid), int(node.Gid), unix.AT_SYMLINK_NOFOLLOW); err != nil {
+		return &os.PathError{Op: "fchownat", Path: node.Path, Err: err}
+	}
+	return nil
+}
+
+// removeHotplugNode removes the device node at path below root, walking the
+// path like createHotplugNode.
+func removeHotplugNode(root, path string) error {
+	rootDir, err := openRoot(root)
+	if err != nil {
+		return err
+	}
+	defer rootDir.Close()
+	if err := removeInRoot(rootDir, path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// openRoot opens the directory for openInRoot to resolve paths below.
+func openRoot(root string) (*os.File, error) {
+	return os.OpenFile(root, unix.O_PATH|syscall.O_DIRECTORY|syscall.O_CLOEXEC, 0)
+}
+
+// findDevice returns the index of the device with the path, or -1.
+func findDevice(devices []*configs.Device, path string) int {
+	for i, dev := range devices {
+		if dev.Path == path {
+			return i
+		}
+	}
+	return -1
+}
+
+// allowDeviceRule adds an allow rule for the device to r, so that applying r
+// again keeps the device accessible.
+func allowDeviceRule(r *configs.Resources, dev *configs.Device) {
+	rule := *dev
+	rule.Allow = true
+	if len(r.Devices) > 0 {
+		r.Devices = append(r.Devices, &rule)
+		return
+	}
+	r.AllowedDevices = append(r.AllowedDevices, &rule)
+}
+
+// dropDeviceRule returns the rules without the last rule that allows the
+// device, which is the one allowDeviceRule added. Every rule of an allow
+// list, such as Resources.AllowedDevices, allows its device.
+func dropDeviceRule(rules []*configs.Device, dev *configs.Device, allowList bool) []*configs.Device {
+	for i := len(rules) - 1; i >= 0; i-- {
+		if (allowList || rules[i].Allow) && sameDevice(rules[i], dev) {
+			return append(rules[:i:i], rules[i+1:]...)
+		}
+	}
+	return rules
+}
+
+// deviceAllowed returns whether one of the rules allows the device.
+func deviceAllowed(rules []*configs.Device, dev *configs.Device, allowList bool) bool {
+	for _, rule := range rules {
+		if (allowList || rule.Allow) && sameDevice(rule, dev) {
+			return true
+		}
+	}
+	return false
+}
+
+func sameDevice(a, b *configs.Device) bool {
+	return a.Type == b.Type && a.Major == b.Major && a.Minor == b.Minor
+}
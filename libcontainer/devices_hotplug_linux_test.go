@@ -0,0 +1,142 @@
+// +build linux
+
+package libcontainer
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+
+	"github.com/opencontainers/runc/libcontainer/configs"
+)
+
+func TestHotplugNodeInScope(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("creating device nodes requires root")
+	}
+	root, err := ioutil.TempDir("", "hotplug")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	// A symlink in the container cannot point the node outside its root.
+	outside, err := ioutil.TempDir("", "hotplug-outside")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(outside)
+	if err := os.Symlink(outside, filepath.Join(root, "dev")); err != nil {
+		t.Fatal(err)
+	}
+
+	node := &configs.Device{Type: 'c', Path: "/dev/hotplug", Major: 1, Minor: 3, FileMode: 0666, Uid: 1000, Gid: 1000}
+	if err := createHotplugNode(root, node); err != nil {
+		t.Fatal(err)
+	}
+	var st syscall.Stat_t
+	if err := syscall.Stat(filepath.Join(root, outside, "hotplug"), &st); err != nil {
+		t.Fatalf("expected the node to be created inside the root: %v", err)
+	}
+	if st.Mode&syscall.S_IFMT != syscall.S_IFCHR || st.Rdev != uint64(node.Mkdev()) || st.Uid != 1000 || st.Gid != 1000 {
+		t.Fatalf("unexpected device node %+v", st)
+	}
+	if entries, _ := ioutil.ReadDir(outside); len(entries) != 0 {
+		t.Fatalf("expected nothing to be created outside the root, got %v", entries)
+	}
+
+	if err := removeHotplugNode(root, node.Path); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Lstat(filepath.Join(root, outside, "hotplug")); !os.IsNotExist(err) {
+		t.Fatalf("expected the node to be removed, got %v", err)
+	}
+	if err := removeHotplugNode(root, node.Path); err != nil {
+		t.Fatalf("expected removing a missing node to succeed: %v", err)
+	}
+
+	// Neither can ".." in a relative one.
+	if err := os.Symlink("../../..", filepath.Join(root, "up")); err != nil {
+		t.Fatal(err)
+	}
+	node.Path = "/up/hotplug"
+	if err := createHotplugNode(root, node); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Lstat(filepath.Join(root, "hotplug")); err != nil {
+		t.Fatalf("expected the node to be created at the root: %v", err)
+	}
+}
+
+func TestDeviceRules(t *testing.T) {
+	null := &configs.Device{Type: 'c', Path: "/dev/null", Major: 1, Minor: 3, Permissions: "rwm"}
+	kmsg := &configs.Device{Type: 'c', Path: "/dev/kmsg", Major: 1, Minor: 11, Permissions: "rwm"}
+
+	r := &configs.Resources{AllowedDevices: []*configs.Device{null}}
+	allowDeviceRule(r, kmsg)
+	if len(r.Devices) != 0 || len(r.AllowedDevices) != 2 || !r.AllowedDevices[1].Allow {
+		t.Fatalf("expected an allowed device to be added, got %+v", r)
+	}
+	allowed := dropDeviceRule(r.AllowedDevices, kmsg, true)
+	if len(allowed) != 1 || allowed[0] != null || deviceAllowed(allowed, kmsg, true) {
+		t.Fatalf("expected only the null device to be left, got %+v", allowed)
+	}
+	if len(r.AllowedDevices) != 2 {
+		t.Fatal("expected dropping a rule to leave the original rules alone")
+	}
+
+	// A second node for the null device keeps the default rule.
+	allowDeviceRule(r, &configs.Device{Type: 'c', Path: "/dev/null2", Major: 1, Minor: 3})
+	allowed = dropDeviceRule(r.AllowedDevices, null, true)
+	if len(allowed) != 2 || allowed[0] != null || !deviceAllowed(allowed, null, true) {
+		t.Fatalf("expected the default null rule to be kept, got %+v", allowed)
+	}
+
+	// Rule lists hold deny rules as well.
+	deny := &configs.Device{Type: 'c', Major: 1, Minor: 11, Permissions: "rwm"}
+	r = &configs.Resources{Devices: []*configs.Device{deny}}
+	allowDeviceRule(r, kmsg)
+	if len(r.AllowedDevices) != 0 || len(r.Devices) != 2 || !deviceAllowed(r.Devices, kmsg, false) {
+		t.Fatalf("expected an allow rule to be added, got %+v", r)
+	}
+	devices := dropDeviceRule(r.Devices, kmsg, false)
+	if len(devices) != 1 || devices[0] != deny || deviceAllowed(devices, kmsg, false) {
+		t.Fatalf("expected only the deny rule to be left, got %+v", devices)
+	}
+}
+
+func TestHotplugDevice(t *testing.T) {
+	dev := &configs.Device{Type: 'c', Path: "/dev/kmsg", Major: 1, Minor: 11}
+	d, err := hotplugDevice(dev)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.Permissions != "rwm" || dev.Permissions != "" {
+		t.Fatalf("expected the permissions of a copy to default to rwm, got %q", d.Permissions)
+	}
+	for _, dev := range []*configs.Device{
+		nil,
+		{Type: 'c', Path: "dev/kmsg", Major: 1, Minor: 11},
+		{Type: 'c', Path: "/dev/kmsg", Major: 1, Minor: 11, Permissions: "rx"},
+	} {
+		if _, err := hotplugDevice(dev); err == nil {
+			t.Errorf("expected an error for %+v", dev)
+		}
+	}
+}
+
+func TestDeviceResources(t *testing.T) {
+	c := &linuxContainer{config: &configs.Config{}}
+	if _, err := c.deviceResources(); err == nil {
+		t.Fatal("expected an error for a config without cgroups")
+	}
+	c.config.Cgroups = &configs.Cgroup{}
+	r, err := c.deviceResources()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r == nil || c.config.Cgroups.Resources != r {
+		t.Fatalf("expected empty resources to be set up, got %+v", c.config.Cgroups)
+	}
+}
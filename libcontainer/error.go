@@ -20,6 +20,10 @@ const (
 
 	// Process errors
 	NoProcessOps
+	ProcessNotExists
+	ExecNotFound
+	ExecPermissionDenied
+	ExecFormatInvalid
 
 	// Common errors
 	ConfigInvalid
@@ -51,6 +55,14 @@ func (c ErrorCode) String() string {
 		return "Container is not paused"
 	case NoProcessOps:
 		return "No process operations"
+	case ProcessNotExists:
+		return "Process does not exist"
+	case ExecNotFound:
+		return "Executable not found"
+	case ExecPermissionDenied:
+		return "Executable permission denied"
+	case ExecFormatInvalid:
+		return "Executable format invalid"
 	default:
 		return "Unknown error"
 	}
@@ -0,0 +1,169 @@
+// +build linux
+
+package libcontainer
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// eventsPollInterval is how often Events checks whether the init of the
+// container has exited.
+const eventsPollInterval = 100 * time.Millisecond
+
+// EventType is the type of an Event.
+type EventType int
+
+const (
+	// OOMEvent is sent when a process of the container is killed by the
+	// OOM killer.
+	OOMEvent EventType = iota
+	// MemoryPressureEvent is sent when the memory cgroup of the container
+	// reaches a pressure level.
+	MemoryPressureEvent
+	// ExitEvent is sent when the init of the container exits. It is always
+	// the last event of the stream.
+	ExitEvent
+)
+
+func (t EventType) String() string {
+	switch t {
+	case OOMEvent:
+		return "oom"
+	case MemoryPressureEvent:
+		return "memory-pressure"
+	case ExitEvent:
+		return "exit"
+	}
+	return fmt.Sprintf("EventType(%d)", int(t))
+}
+
+// Event is a notification about the container received from Events.
+type Event struct {
+	Type EventType
+
+	// Level is the pressure level that was reached, set for
+	// MemoryPressureEvent only.
+	Level PressureLevel
+}
+
+// memoryWatchers are the memory cgroup events Events listens to.
+var memoryWatchers = []struct {
+	evName string
+	arg    string
+	event  Event
+}{
+	{"memory.oom_control", "", Event{Type: OOMEvent}},
+	{"memory.pressure_level", LowPressure.String(), Event{Type: MemoryPressureEvent, Level: LowPressure}},
+	{"memory.pressure_level", MediumPressure.String(), Event{Type: MemoryPressureEvent, Level: MediumPressure}},
+	{"memory.pressure_level", CriticalPressure.String(), Event{Type: MemoryPressureEvent, Level: CriticalPressure}},
+}
+
+func (c *linuxContainer) Events() (<-chan Event, func(), error) {
+	// XXX(cyphar): This requires cgroups.
+	if c.config.Rootless {
+		return nil, nil, fmt.Errorf("cannot get events from rootless container")
+	}
+	c.m.Lock()
+	_, err := c.runningState()
+	c.m.Unlock()
+	if err != nil {
+		return nil, nil, err
+	}
+	dir := c.cgroupManager.GetPaths()[oomCgroupName]
+	if dir == "" {
+		return nil, nil, fmt.Errorf("path %q missing", oomCgroupName)
+	}
+	var (
+		chans []<-chan struct{}
+		stops []func()
+	)
+	for _, w := range memoryWatchers {
+		ch, stop, err := watchMemoryEvent(dir, w.evName, w.arg)
+		if err != nil {
+			for _, stop := range stops {
+				stop()
+			}
+			return nil, nil, newSystemErrorWithCausef(err, "watching %s", w.evName)
+		}
+		chans = append(chans, ch)
+		stops = append(stops, stop)
+	}
+	events, stop := c.multiplexEvents(chans, stops, eventsPollInterval)
+	return events, stop, nil
+}
+
+// multiplexEvents forwards the notifications of the memory watchers, chans
+// being in the same order as memoryWatchers, and polls the container for the
+// exit of its init. The stream is closed after the exit event or once it has
+// been stopped, and the watchers are stopped along with it.
+func (c *linuxContainer) multiplexEvents(chans []<-chan struct{}, stops []func(), interval time.Duration) (<-chan Event, func()) {
+	var (
+		wg       sync.WaitGroup
+		once     sync.Once
+		out      = make(chan Event)
+		done     = make(chan struct{})
+		finished = make(chan struct{})
+	)
+	closeDone := func() {
+		once.Do(func() { close(done) })
+	}
+	send := func(ev Event) bool {
+		select {
+		case out <- ev:
+			return true
+		case <-done:
+			return false
+		}
+	}
+	for i, ch := range chans {
+		wg.Add(1)
+		go func(ch <-chan struct{}, ev Event) {
+			defer wg.Done()
+			for {
+				select {
+				case _, ok := <-ch:
+					if !ok || !send(ev) {
+						return
+					}
+				case <-done:
+					return
+				}
+			}
+		}(ch, memoryWatchers[i].event)
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if status, err := c.Status(); err != nil || status != Stopped {
+					continue
+				}
+				send(Event{Type: ExitEvent})
+				closeDone()
+				return
+			case <-done:
+				return
+			}
+		}
+	}()
+	go func() {
+		<-done
+		for _, stop := range stops {
+			stop()
+		}
+		wg.Wait()
+		close(out)
+		close(finished)
+	}()
+	stop := func() {
+		closeDone()
+		<-finished
+	}
+	return out, stop
+}
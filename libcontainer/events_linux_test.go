@@ -0,0 +1,132 @@
+// +build linux
+
+package libcontainer
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// newEventsContainer returns a running container whose init is a sleep and
+// whose memory cgroup is a fake directory.
+func newEventsContainer(t *testing.T) (*linuxContainer, *exec.Cmd, func()) {
+	dir, err := ioutil.TempDir("", "testevents")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"memory.oom_control", "memory.pressure_level", "cgroup.event_control"} {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte{}, 0700); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cmd := exec.Command("sleep", "100")
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	container := newMockContainer(t, cmd.Process.Pid, map[string]string{"memory": dir})
+	container.root = dir
+	return container, cmd, func() {
+		cmd.Process.Kill()
+		cmd.Wait()
+		os.RemoveAll(dir)
+	}
+}
+
+func countFds(t *testing.T) int {
+	fds, err := ioutil.ReadDir("/proc/self/fd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return len(fds)
+}
+
+func TestEventsMultiplex(t *testing.T) {
+	container, cmd, cleanup := newEventsContainer(t)
+	defer cleanup()
+
+	chans := make([]chan struct{}, len(memoryWatchers))
+	watched := make([]<-chan struct{}, len(memoryWatchers))
+	stopped := 0
+	stops := make([]func(), len(memoryWatchers))
+	for i := range chans {
+		chans[i] = make(chan struct{})
+		watched[i] = chans[i]
+		stops[i] = func() { stopped++ }
+	}
+	events, stop := container.multiplexEvents(watched, stops, 10*time.Millisecond)
+	defer stop()
+
+	for i, w := range memoryWatchers {
+		chans[i] <- struct{}{}
+		select {
+		case ev := <-events:
+			if ev != w.event {
+				t.Fatalf("expected %+v, got %+v", w.event, ev)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("no %s event after 1s", w.event.Type)
+		}
+	}
+
+	cmd.Process.Kill()
+	cmd.Wait()
+	select {
+	case ev := <-events:
+		if ev.Type != ExitEvent {
+			t.Fatalf("expected an exit event, got %+v", ev)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("no exit event after 1s")
+	}
+	select {
+	case ev, ok := <-events:
+		if ok {
+			t.Fatalf("expected the stream to be closed after the exit event, got %+v", ev)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("stream not closed 1s after the exit event")
+	}
+	stop()
+	if stopped != len(memoryWatchers) {
+		t.Fatalf("expected all %d watchers to be stopped, %d were", len(memoryWatchers), stopped)
+	}
+}
+
+func TestEventsStop(t *testing.T) {
+	container, _, cleanup := newEventsContainer(t)
+	defer cleanup()
+
+	fds := countFds(t)
+	events, stop, err := container.Events()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := countFds(t); n != fds+2*len(memoryWatchers) {
+		t.Fatalf("expected an eventfd and an event file per watcher, %d fds were opened", n-fds)
+	}
+	stop()
+	if _, ok := <-events; ok {
+		t.Fatal("expected the stream to be closed after stop")
+	}
+	if n := countFds(t); n != fds {
+		t.Fatalf("expected all fds to be closed after stop, %d are left", n-fds)
+	}
+	// Stopping again must not block.
+	stop()
+}
+
+func TestEventsNotRunning(t *testing.T) {
+	container, cmd, cleanup := newEventsContainer(t)
+	defer cleanup()
+
+	cmd.Process.Kill()
+	cmd.Wait()
+	_, _, err := container.Events()
+	if lerr, ok := err.(Error); !ok || lerr.Code() != ContainerNotRunning {
+		t.Fatalf("expected ContainerNotRunning but received %v", err)
+	}
+}
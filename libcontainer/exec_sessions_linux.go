@@ -0,0 +1,114 @@
+// +build linux
+
+package libcontainer
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/opencontainers/runc/libcontainer/system"
+)
+
+// ExecSession is a process that was started in the running container.
+type ExecSession struct {
+	// ID identifies the session, see Process.ExecID.
+	ID string
+
+	// Pid is the pid of the process.
+	Pid int
+
+	// Args are the command and arguments of the process.
+	Args []string
+
+	// Started is when the process was started.
+	Started time.Time
+}
+
+type execSession struct {
+	ExecSession
+
+	// startTime tells the process apart from a later one with the same pid.
+	startTime string
+}
+
+func (c *linuxContainer) ExecSessions() []ExecSession {
+	c.m.Lock()
+	defer c.m.Unlock()
+	c.pruneExecSessions()
+	sessions := make([]ExecSession, 0, len(c.execSessions))
+	for _, s := range c.execSessions {
+		sessions = append(sessions, s.ExecSession)
+	}
+	sort.Sort(sessionsByStart(sessions))
+	return sessions
+}
+
+// sessionsByStart sorts exec sessions by the time they started, and by id
+// for those that started at the same time.
+type sessionsByStart []ExecSession
+
+func (s sessionsByStart) Len() int      { return len(s) }
+func (s sessionsByStart) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+func (s sessionsByStart) Less(i, j int) bool {
+	if !s[i].Started.Equal(s[j].Started) {
+		return s[i].Started.Before(s[j].Started)
+	}
+	return s[i].ID < s[j].ID
+}
+
+// newExecSessionID returns id if no running exec session uses it, or a new
+// id if it is empty.
+func (c *linuxContainer) newExecSessionID(id string) (string, error) {
+	c.pruneExecSessions()
+	if id != "" {
+		if _, ok := c.execSessions[id]; ok {
+			return "", newGenericError(fmt.Errorf("exec session %q already exists", id), ConfigInvalid)
+		}
+		return id, nil
+	}
+	for {
+		c.execSessionSeq++
+		id = strconv.Itoa(c.execSessionSeq)
+		if _, ok := c.execSessions[id]; !ok {
+			return id, nil
+		}
+	}
+}
+
+// addExecSession records the started process as the exec session id.
+func (c *linuxContainer) addExecSession(id string, process *Process, parent parentProcess) error {
+	startTime, err := parent.startTime()
+	if err != nil {
+		return err
+	}
+	if c.execSessions == nil {
+		c.execSessions = make(map[string]*execSession)
+	}
+	c.execSessions[id] = &execSession{
+		ExecSession: ExecSession{
+			ID:      id,
+			Pid:     parent.pid(),
+			Args:    append([]string(nil), process.Args...),
+			Started: time.Now().UTC(),
+		},
+		startTime: startTime,
+	}
+	return nil
+}
+
+// pruneExecSessions forgets the exec sessions whose processes have exited,
+// whether or not they were waited for.
+func (c *linuxContainer) pruneExecSessions() {
+	for id, s := range c.execSessions {
+		startTime, err := system.GetProcessStartTime(s.Pid)
+		if err != nil || startTime != s.startTime {
+			delete(c.execSessions, id)
+			continue
+		}
+		if zombie, err := system.IsZombie(s.Pid); err != nil || zombie {
+			delete(c.execSessions, id)
+		}
+	}
+}
@@ -0,0 +1,76 @@
+// +build linux
+
+package libcontainer
+
+import (
+	"os/exec"
+	"testing"
+	"time"
+
+	"github.com/opencontainers/runc/libcontainer/system"
+)
+
+func startExecSession(t *testing.T, container *linuxContainer, id string) *exec.Cmd {
+	cmd := exec.Command("sleep", "100")
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	startTime, err := system.GetProcessStartTime(cmd.Process.Pid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err = container.newExecSessionID(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parent := &mockProcess{_pid: cmd.Process.Pid, started: startTime}
+	if err := container.addExecSession(id, &Process{Args: cmd.Args}, parent); err != nil {
+		t.Fatal(err)
+	}
+	return cmd
+}
+
+func TestExecSessions(t *testing.T) {
+	container := &linuxContainer{id: "myid"}
+	first := startExecSession(t, container, "")
+	defer first.Process.Kill()
+	named := startExecSession(t, container, "named")
+	defer named.Process.Kill()
+
+	sessions := container.ExecSessions()
+	if len(sessions) != 2 {
+		t.Fatalf("expected 2 exec sessions, got %+v", sessions)
+	}
+	if sessions[0].ID != "1" || sessions[0].Pid != first.Process.Pid {
+		t.Fatalf("expected the first session to have a generated id and pid %d, got %+v", first.Process.Pid, sessions[0])
+	}
+	if sessions[1].ID != "named" || sessions[1].Pid != named.Process.Pid {
+		t.Fatalf("expected the second session to be named with pid %d, got %+v", named.Process.Pid, sessions[1])
+	}
+	if _, err := container.newExecSessionID("named"); err == nil {
+		t.Fatal("expected the id of a running exec session to be rejected")
+	}
+
+	// An exited process is dropped before and after it was waited for.
+	named.Process.Kill()
+	for deadline := time.Now().Add(time.Second); len(container.ExecSessions()) != 1; {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected the killed session to be dropped, got %+v", container.ExecSessions())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	named.Wait()
+	sessions = container.ExecSessions()
+	if len(sessions) != 1 || sessions[0].ID != "1" {
+		t.Fatalf("expected only the first session to be left, got %+v", sessions)
+	}
+	if _, err := container.newExecSessionID("named"); err != nil {
+		t.Fatalf("expected the id of an exited exec session to be reusable: %v", err)
+	}
+
+	first.Process.Kill()
+	first.Wait()
+	if sessions := container.ExecSessions(); len(sessions) != 0 {
+		t.Fatalf("expected no exec sessions after all exited, got %+v", sessions)
+	}
+}
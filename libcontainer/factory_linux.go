@@ -19,6 +19,7 @@ import (
 	"github.com/opencontainers/runc/libcontainer/cgroups/systemd"
 	"github.com/opencontainers/runc/libcontainer/configs"
 	"github.com/opencontainers/runc/libcontainer/configs/validate"
+	"github.com/opencontainers/runc/libcontainer/system"
 	"github.com/opencontainers/runc/libcontainer/utils"
 )
 
@@ -102,6 +103,20 @@ func TmpfsRoot(l *LinuxFactory) error {
 	return nil
 }
 
+// Subreaper is an option func to make the manager the subreaper of its
+// descendants, so that the orphaned processes of containers that share its
+// PID namespace are reparented to it instead of the host's init, and to reap
+// them. Only children that were seen running in the cgroups of a container
+// the manager started are reaped; the processes that libcontainer starts are
+// left for Process.Wait and other children for whoever started them.
+func Subreaper(l *LinuxFactory) error {
+	if err := system.SetSubreaper(1); err != nil {
+		return newSystemErrorWithCause(err, "setting the manager as subreaper")
+	}
+	startReaper()
+	return nil
+}
+
 // CriuPath returns an option func to configure a LinuxFactory with the
 // provided criupath
 func CriuPath(criupath string) func(*LinuxFactory) error {
@@ -112,7 +127,9 @@ func CriuPath(criupath string) func(*LinuxFactory) error {
 }
 
 // New returns a linux based container factory based in the root directory and
-// configures the factory with the provided option funcs.
+// configures the factory with the provided option funcs. The root directory
+// holds the state of the containers and is independent of where their root
+// filesystems are, so it can be put on a tmpfs with TmpfsRoot.
 func New(root string, options ...func(*LinuxFactory) error) (Factory, error) {
 	if root != "" {
 		if err := os.MkdirAll(root, 0700); err != nil {
@@ -136,7 +153,9 @@ func New(root string, options ...func(*LinuxFactory) error) (Factory, error) {
 
 // LinuxFactory implements the default factory interface for linux based systems.
 type LinuxFactory struct {
-	// Root directory for the factory to store state.
+	// Root directory for the factory to store state. Every container keeps
+	// its state file in Root/<id>, which is used by Load and removed by
+	// Destroy.
 	Root string
 
 	// InitArgs are arguments for calling the init responsibilities for spawning
@@ -164,6 +183,18 @@ func (l *LinuxFactory) Create(id string, config *configs.Config) (Container, err
 	if err := l.Validator.Validate(config); err != nil {
 		return nil, newGenericError(err, ConfigInvalid)
 	}
+	if config.Cgroups != nil && config.Cgroups.PathTemplate != "" {
+		path, err := config.Cgroups.ExpandPathTemplate(id, config.Labels)
+		if err != nil {
+			return nil, newGenericError(err, ConfigInvalid)
+		}
+		// The caller may create more containers from the same config.
+		cgroups := *config.Cgroups
+		cgroups.Path, cgroups.PathTemplate = path, ""
+		expanded := *config
+		expanded.Cgroups = &cgroups
+		config = &expanded
+	}
 	uid, err := config.HostRootUID()
 	if err != nil {
 		return nil, newGenericError(err, SystemError)
@@ -227,6 +258,8 @@ func (l *LinuxFactory) Load(id string) (Container, error) {
 		cgroupManager:        l.NewCgroupsManager(state.Config.Cgroups, state.CgroupPaths),
 		root:                 containerRoot,
 		created:              state.Created,
+		exitStatus:           state.ExitStatus,
+		rootfsImage:          state.RootfsImage,
 	}
 	c.state = &loadedState{c: c}
 	if err := c.refreshState(); err != nil {
@@ -245,6 +278,7 @@ func (l *LinuxFactory) StartInitialization() (err error) {
 	var (
 		pipefd, rootfd int
 		consoleSocket  *os.File
+		failure        *configs.InitFailurePolicy
 		envInitPipe    = os.Getenv("_LIBCONTAINER_INITPIPE")
 		envStateDir    = os.Getenv("_LIBCONTAINER_STATEDIR")
 		envConsole     = os.Getenv("_LIBCONTAINER_CONSOLE")
@@ -284,16 +318,24 @@ func (l *LinuxFactory) StartInitialization() (err error) {
 	os.Clearenv()
 
 	defer func() {
+		logSetupError(err)
 		// We have an error during the initialization of the container's init,
 		// send it back to the parent process in the form of an initError.
 		if werr := utils.WriteJSON(pipe, syncT{procError}); werr != nil {
 			fmt.Fprintln(os.Stderr, err)
 			return
 		}
-		if werr := utils.WriteJSON(pipe, newSystemError(err)); werr != nil {
+		ierr := newGenericError(err, SystemError)
+		if gerr, ok := ierr.(*genericError); ok {
+			gerr.Trail = finishTrail(err)
+		}
+		if werr := utils.WriteJSON(pipe, ierr); werr != nil {
 			fmt.Fprintln(os.Stderr, err)
 			return
 		}
+		if failure != nil {
+			exitInit(failure)
+		}
 	}()
 	defer func() {
 		if e := recover(); e != nil {
@@ -301,7 +343,20 @@ func (l *LinuxFactory) StartInitialization() (err error) {
 		}
 	}()
 
-	i, err := newContainerInit(it, pipe, consoleSocket, rootfd)
+	config, err := decodeInitConfig(pipe)
+	if err != nil {
+		return err
+	}
+	failure = config.Config.InitFailure
+	if it == initStandard {
+		// A syslog that cannot be reached is no reason for the setup to
+		// fail, but is reported along with the error it fails with.
+		if err := openSetupLog(config); err != nil {
+			traceStep("connecting to syslog: %v", err)
+		}
+	}
+
+	i, err := newContainerInit(it, pipe, consoleSocket, rootfd, config)
 	if err != nil {
 		return err
 	}
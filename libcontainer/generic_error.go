@@ -13,6 +13,9 @@ var errorTemplate = template.Must(template.New("error").Parse(`Timestamp: {{.Tim
 Code: {{.ECode}}
 {{if .Message }}
 Message: {{.Message}}
+{{end}}{{if .Trail }}
+Setup trail:{{range .Trail}}
+  {{.}}{{end}}
 {{end}}
 Frames:{{range $i, $frame := .Stack.Frames}}
 ---
@@ -63,9 +66,27 @@ func createSystemError(err error, cause string) Error {
 	if err != nil {
 		gerr.Message = err.Error()
 	}
+	gerr.Trail = SetupTrail(err)
 	return gerr
 }
 
+// SetupTrail returns the last steps that the container's init took to set up
+// the container, with the errno of the step that failed, if err is the init
+// failing to set up the container.
+func SetupTrail(err error) []string {
+	for err != nil {
+		gerr, ok := err.(*genericError)
+		if !ok {
+			return nil
+		}
+		if len(gerr.Trail) > 0 {
+			return gerr.Trail
+		}
+		err = gerr.Err
+	}
+	return nil
+}
+
 type genericError struct {
 	Timestamp time.Time
 	ECode     ErrorCode
@@ -73,6 +94,7 @@ type genericError struct {
 	Cause     string
 	Message   string
 	Stack     stacktrace.Stacktrace
+	Trail     []string
 }
 
 func (e *genericError) Error() string {
@@ -0,0 +1,50 @@
+// +build linux
+
+package libcontainer
+
+import (
+	"os"
+	"strings"
+	"syscall"
+
+	"github.com/opencontainers/runc/libcontainer/system"
+)
+
+// hardenedUmask keeps the files created by the container's processes from
+// being readable by other users by default.
+const hardenedUmask = 0027
+
+// execEnv returns the environment that the user's process is exec'd with.
+// With a hardened init that is the process's environment and the HOME that
+// setupUser filled in, whatever else the init set along the way.
+func execEnv(config *initConfig) ([]string, error) {
+	if !config.Config.HardenedInit {
+		return os.Environ(), nil
+	}
+	env, err := dedupEnv(config.Env)
+	if err != nil {
+		return nil, err
+	}
+	for _, pair := range env {
+		if strings.HasPrefix(pair, "HOME=") {
+			return env, nil
+		}
+	}
+	if home := os.Getenv("HOME"); home != "" {
+		env = append(env, "HOME="+home)
+	}
+	return env, nil
+}
+
+// hardenExec is the last step of a hardened init before the exec. It is a
+// no-op otherwise.
+func hardenExec(config *initConfig) error {
+	if !config.Config.HardenedInit {
+		return nil
+	}
+	syscall.Umask(hardenedUmask)
+	if err := system.ResetIgnoredSignals(); err != nil {
+		return newSystemErrorWithCause(err, "resetting ignored signals")
+	}
+	return nil
+}
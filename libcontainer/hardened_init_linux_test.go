@@ -0,0 +1,89 @@
+// +build linux
+
+package libcontainer
+
+import (
+	"os"
+	"os/exec"
+	"os/signal"
+	"reflect"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+
+	"github.com/opencontainers/runc/libcontainer/configs"
+	"github.com/opencontainers/runc/libcontainer/system"
+)
+
+func TestExecEnv(t *testing.T) {
+	os.Setenv("LIBCONTAINER_TEST_LEAKED", "1")
+	defer os.Unsetenv("LIBCONTAINER_TEST_LEAKED")
+	config := &initConfig{
+		Env:    []string{"PATH=/bin", "TERM=xterm", "PATH=/usr/bin"},
+		Config: &configs.Config{HardenedInit: true},
+	}
+	os.Setenv("HOME", "/root")
+	env, err := execEnv(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"TERM=xterm", "PATH=/usr/bin", "HOME=/root"}
+	if !reflect.DeepEqual(env, expected) {
+		t.Fatalf("expected env %q, got %q", expected, env)
+	}
+
+	config.Env = append(config.Env, "HOME=/home/user")
+	if env, err = execEnv(config); err != nil {
+		t.Fatal(err)
+	}
+	if env[len(env)-1] != "HOME=/home/user" || len(env) != 3 {
+		t.Fatalf("expected the process's HOME to be kept, got %q", env)
+	}
+
+	config.Config.HardenedInit = false
+	if env, err = execEnv(config); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(env, os.Environ()) {
+		t.Fatalf("expected the init's environment without a hardened init, got %q", env)
+	}
+}
+
+// TestResetIgnoredSignals runs TestResetIgnoredSignalsHelper, which ignores
+// SIGHUP, resets the ignored signals and execs grep, and expects SIGHUP to
+// not be ignored by grep.
+func TestResetIgnoredSignals(t *testing.T) {
+	cmd := exec.Command(os.Args[0], "-test.run=^TestResetIgnoredSignalsHelper$")
+	cmd.Env = append(os.Environ(), "LIBCONTAINER_TEST_RESET_SIGNALS=1")
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("reset signals helper failed: %v\n%s", err, out)
+	}
+	fields := strings.Fields(string(out))
+	if len(fields) != 2 || fields[0] != "SigIgn:" {
+		t.Fatalf("unexpected helper output %q", out)
+	}
+	ignored, err := strconv.ParseUint(fields[1], 16, 64)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ignored&(1<<(uint(syscall.SIGHUP)-1)) != 0 {
+		t.Fatalf("expected SIGHUP to be reset, ignored signals are %s", fields[1])
+	}
+}
+
+func TestResetIgnoredSignalsHelper(t *testing.T) {
+	if os.Getenv("LIBCONTAINER_TEST_RESET_SIGNALS") != "1" {
+		return
+	}
+	signal.Ignore(syscall.SIGHUP)
+	if err := system.ResetIgnoredSignals(); err != nil {
+		t.Fatal(err)
+	}
+	grep, err := exec.LookPath("grep")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Fatal(syscall.Exec(grep, []string{"grep", "SigIgn", "/proc/self/status"}, nil))
+}
@@ -0,0 +1,109 @@
+// +build linux
+
+package libcontainer
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/opencontainers/runc/libcontainer/configs"
+)
+
+// resolvePercentLimits resolves the limits that config sets as percentages of
+// the host's resources to absolute ones.
+func resolvePercentLimits(config *configs.Config) error {
+	if config.Cgroups == nil || config.Cgroups.Resources == nil {
+		return nil
+	}
+	r := config.Cgroups.Resources
+	if r.MemoryPercent == 0 && r.CpuPercent == 0 {
+		return nil
+	}
+	host, err := readHostResources()
+	if err != nil {
+		return err
+	}
+	return r.ResolvePercentages(host)
+}
+
+// readHostResources returns the number of online CPUs and the total memory of
+// the host.
+func readHostResources() (configs.HostResources, error) {
+	var host configs.HostResources
+	online, err := ioutil.ReadFile("/sys/devices/system/cpu/online")
+	if err != nil {
+		return host, err
+	}
+	if host.CPUs, err = countCPUs(strings.TrimSpace(string(online))); err != nil {
+		return host, err
+	}
+	f, err := os.Open("/proc/meminfo")
+	if err != nil {
+		return host, err
+	}
+	defer f.Close()
+	host.MemoryBytes, err = parseMemTotal(f)
+	return host, err
+}
+
+// onlineCPUs returns the number of online CPUs of the host, or the number of
+// CPUs the process may run on if that cannot be read.
+func onlineCPUs() int {
+	online, err := ioutil.ReadFile("/sys/devices/system/cpu/online")
+	if err != nil {
+		return runtime.NumCPU()
+	}
+	cpus, err := countCPUs(strings.TrimSpace(string(online)))
+	if err != nil || cpus == 0 {
+		return runtime.NumCPU()
+	}
+	return cpus
+}
+
+// countCPUs returns the number of CPUs in a list such as "0-3,8" in the
+// format used by /sys/devices/system/cpu.
+func countCPUs(list string) (int, error) {
+	count := 0
+	for _, r := range strings.Split(list, ",") {
+		bounds := strings.SplitN(r, "-", 2)
+		first, err := strconv.Atoi(bounds[0])
+		if err != nil {
+			return 0, fmt.Errorf("invalid cpu list %q", list)
+		}
+		last := first
+		if len(bounds) == 2 {
+			if last, err = strconv.Atoi(bounds[1]); err != nil || last < first {
+				return 0, fmt.Errorf("invalid cpu list %q", list)
+			}
+		}
+		count += last - first + 1
+	}
+	return count, nil
+}
+
+// parseMemTotal returns the total memory in bytes from the contents of
+// /proc/meminfo.
+func parseMemTotal(r io.Reader) (int64, error) {
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		fields := strings.Fields(s.Text())
+		if len(fields) != 3 || fields[0] != "MemTotal:" || fields[2] != "kB" {
+			continue
+		}
+		kb, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid MemTotal %q", fields[1])
+		}
+		return kb * 1024, nil
+	}
+	if err := s.Err(); err != nil {
+		return 0, err
+	}
+	return 0, fmt.Errorf("no MemTotal in meminfo")
+}
@@ -0,0 +1,66 @@
+// +build linux
+
+package libcontainer
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/opencontainers/runc/libcontainer/configs"
+)
+
+const testMeminfo = `MemTotal:        8388608 kB
+MemFree:         1048576 kB
+MemAvailable:    4194304 kB
+`
+
+func TestParseMemTotal(t *testing.T) {
+	total, err := parseMemTotal(strings.NewReader(testMeminfo))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 8<<30 {
+		t.Fatalf("expected 8GiB of memory, got %d bytes", total)
+	}
+	if _, err := parseMemTotal(strings.NewReader("MemFree: 1 kB\n")); err == nil {
+		t.Fatal("expected an error without MemTotal")
+	}
+}
+
+func TestCountCPUs(t *testing.T) {
+	for list, expected := range map[string]int{
+		"0":       1,
+		"0-3":     4,
+		"0-3,8":   5,
+		"0,2,4-5": 4,
+	} {
+		n, err := countCPUs(list)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n != expected {
+			t.Errorf("expected %d cpus in %q, got %d", expected, list, n)
+		}
+	}
+	if _, err := countCPUs("3-1"); err == nil {
+		t.Fatal("expected an invalid range to be rejected")
+	}
+}
+
+func TestResolvePercentLimits(t *testing.T) {
+	total, err := readHostResources()
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := &configs.Config{
+		Cgroups: &configs.Cgroup{
+			Resources: &configs.Resources{MemoryPercent: 50},
+		},
+	}
+	if err := resolvePercentLimits(config); err != nil {
+		t.Fatal(err)
+	}
+	if expected := uint64(total.MemoryBytes / 2); config.Cgroups.Resources.Memory != expected {
+		t.Fatalf("expected 50%% memory to be %d bytes, got %d", expected, config.Cgroups.Resources.Memory)
+	}
+}
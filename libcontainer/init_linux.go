@@ -8,6 +8,8 @@ import (
 	"io"
 	"net"
 	"os"
+	"os/exec"
+	"os/signal"
 	"strings"
 	"syscall"
 	"unsafe"
@@ -36,8 +38,9 @@ type pid struct {
 type network struct {
 	configs.Network
 
-	// TempVethPeerName is a unique temporary veth peer name that was placed into
-	// the container's namespace.
+	// TempVethPeerName is a unique temporary name of the interface, a veth
+	// peer or a macvlan interface, that was placed into the container's
+	// namespace.
 	TempVethPeerName string `json:"temp_veth_peer_name"`
 }
 
@@ -59,17 +62,29 @@ type initConfig struct {
 	Rlimits          []configs.Rlimit      `json:"rlimits"`
 	CreateConsole    bool                  `json:"create_console"`
 	Rootless         bool                  `json:"rootless"`
+	CgroupPaths      map[string]string     `json:"cgroup_paths,omitempty"`
+	RootfsImage      *rootfsImage          `json:"rootfs_image,omitempty"`
+	ReaperFd         int                   `json:"reaper_fd,omitempty"`
+	Overlay          *rootfsOverlay        `json:"overlay,omitempty"`
 }
 
 type initer interface {
 	Init() error
 }
 
-func newContainerInit(t initType, pipe *os.File, consoleSocket *os.File, stateDirFD int) (initer, error) {
+// decodeInitConfig reads the config that the parent sends the init.
+func decodeInitConfig(r io.Reader) (*initConfig, error) {
 	var config *initConfig
-	if err := json.NewDecoder(pipe).Decode(&config); err != nil {
+	if err := json.NewDecoder(r).Decode(&config); err != nil {
 		return nil, err
 	}
+	if config == nil || config.Config == nil {
+		return nil, newGenericError(fmt.Errorf("init config is missing the container config"), ConfigInvalid)
+	}
+	return config, nil
+}
+
+func newContainerInit(t initType, pipe *os.File, consoleSocket *os.File, stateDirFD int, config *initConfig) (initer, error) {
 	if err := populateProcessEnvironment(config.Env); err != nil {
 		return nil, err
 	}
@@ -92,14 +107,67 @@ func newContainerInit(t initType, pipe *os.File, consoleSocket *os.File, stateDi
 	return nil, fmt.Errorf("unknown init type %q", t)
 }
 
+// newExecError converts the error from looking up or exec'ing the user's
+// process name into an error whose code tells a missing executable apart
+// from one that may not be executed or is not in a format the kernel runs.
+func newExecError(name string, err error) Error {
+	code := SystemError
+	switch execErrorCause(err) {
+	case exec.ErrNotFound, syscall.ENOENT:
+		code = ExecNotFound
+	case syscall.EACCES, syscall.EPERM:
+		code = ExecPermissionDenied
+	case syscall.ENOEXEC:
+		code = ExecFormatInvalid
+	}
+	if errno, ok := err.(syscall.Errno); ok {
+		err = &os.PathError{Op: "exec", Path: name, Err: errno}
+	}
+	return newGenericError(err, code)
+}
+
+// execErrorCause returns the error that the *exec.Error and *os.PathError
+// wrappers of err were made for.
+func execErrorCause(err error) error {
+	for {
+		switch e := err.(type) {
+		case *exec.Error:
+			err = e.Err
+		case *os.PathError:
+			err = e.Err
+		default:
+			return err
+		}
+	}
+}
+
+// exitInit terminates the init as described by the failure policy p after
+// a setup error has been reported to the parent. It does not return.
+func exitInit(p *configs.InitFailurePolicy) {
+	if p.Signal != 0 {
+		sig := syscall.Signal(p.Signal)
+		signal.Reset(sig)
+		syscall.Kill(syscall.Getpid(), sig)
+	}
+	code := p.ExitCode
+	if code == 0 {
+		code = 1
+	}
+	os.Exit(code)
+}
+
 // populateProcessEnvironment loads the provided environment variables into the
-// current processes's environment.
+// current processes's environment. When a variable is set more than once the
+// last value wins, and the variables are set in the order of their last
+// occurrence so that the resulting environment does not depend on how the
+// duplicates were interleaved.
 func populateProcessEnvironment(env []string) error {
+	env, err := dedupEnv(env)
+	if err != nil {
+		return err
+	}
 	for _, pair := range env {
 		p := strings.SplitN(pair, "=", 2)
-		if len(p) < 2 {
-			return fmt.Errorf("invalid environment '%v'", pair)
-		}
 		if err := os.Setenv(p[0], p[1]); err != nil {
 			return err
 		}
@@ -107,6 +175,32 @@ func populateProcessEnvironment(env []string) error {
 	return nil
 }
 
+// dedupEnv returns env without the entries that are overridden by a later
+// entry for the same variable, keeping the order of the remaining entries.
+func dedupEnv(env []string) ([]string, error) {
+	last := make(map[string]int, len(env))
+	for i, pair := range env {
+		p := strings.SplitN(pair, "=", 2)
+		if len(p) < 2 {
+			return nil, fmt.Errorf("invalid environment '%v'", pair)
+		}
+		last[p[0]] = i
+	}
+	deduped := make([]string, 0, len(last))
+	for i, pair := range env {
+		if last[strings.SplitN(pair, "=", 2)[0]] == i {
+			deduped = append(deduped, pair)
+		}
+	}
+	return deduped, nil
+}
+
+// closeInheritedFds marks every descriptor but stdio and the passed files
+// close-on-exec.
+func closeInheritedFds(config *initConfig) error {
+	return utils.CloseExecFrom(config.PassedFilesCount + 3)
+}
+
 // finalizeNamespace drops the caps, sets the correct user
 // and working dir, and closes any leaked file descriptors
 // before executing the command inside the namespace
@@ -114,10 +208,16 @@ func finalizeNamespace(config *initConfig) error {
 	// Ensure that all unwanted fds we may have accidentally
 	// inherited are marked close-on-exec so they stay out of the
 	// container
-	if err := utils.CloseExecFrom(config.PassedFilesCount + 3); err != nil {
+	if err := closeInheritedFds(config); err != nil {
 		return err
 	}
 
+	if len(config.Config.CpuAffinity) > 0 {
+		if err := system.SchedSetaffinity(0, config.Config.CpuAffinity); err != nil {
+			return newSystemErrorWithCause(err, "setting cpu affinity")
+		}
+	}
+
 	capabilities := &configs.Capabilities{}
 	if config.Capabilities != nil {
 		capabilities = config.Capabilities
@@ -132,6 +232,12 @@ func finalizeNamespace(config *initConfig) error {
 	if err := w.ApplyBoundingSet(); err != nil {
 		return err
 	}
+	// securebits replace the keep caps flag, so they go first
+	if config.Config.Securebits != 0 {
+		if err := system.SetSecurebits(uint(config.Config.Securebits)); err != nil {
+			return newSystemErrorWithCause(err, "setting securebits")
+		}
+	}
 	// preserve existing capabilities while we change users
 	if err := system.SetKeepCaps(); err != nil {
 		return err
@@ -363,11 +469,31 @@ func setupNetwork(config *initConfig) error {
 		if err := strategy.initialize(config); err != nil {
 			return err
 		}
+		if err := setupArp(config); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// setSignalMask blocks the configured signals, and only them, for the
+// process about to be exec'd.
+func setSignalMask(config *configs.Config) error {
+	var mask uint64
+	for _, sig := range config.BlockedSignals {
+		mask |= 1 << uint(sig-1)
+	}
+	if err := system.SetSignalMask(mask); err != nil {
+		return newSystemErrorWithCause(err, "setting signal mask")
 	}
 	return nil
 }
 
 func setupRoute(config *configs.Config) error {
+	tables := make(map[string]int)
+	for _, n := range config.Networks {
+		tables[n.Name] = n.RouteTable
+	}
 	for _, config := range config.Routes {
 		_, dst, err := net.ParseCIDR(config.Destination)
 		if err != nil {
@@ -392,13 +518,46 @@ func setupRoute(config *configs.Config) error {
 			Gw:        gw,
 			LinkIndex: l.Attrs().Index,
 		}
-		if err := netlink.RouteAdd(route); err != nil {
+		if err := addRoute(route, tables[config.InterfaceName]); err != nil {
 			return err
 		}
 	}
 	return nil
 }
 
+// The resources that have no constant in the syscall package.
+const (
+	rlimitRSS      = 5
+	rlimitMemlock  = 8
+	rlimitLocks    = 10
+	rlimitMsgqueue = 12
+	rlimitNice     = 13
+	rlimitRtprio   = 14
+	rlimitRttime   = 15
+)
+
+const rlimInfinity = ^uint64(0)
+
+// defaultRlimits are the rlimits the kernel gives to init, see INIT_RLIMIT in
+// include/asm-generic/resource.h. RLIMIT_NPROC and RLIMIT_SIGPENDING are left
+// out as they are derived from the amount of memory at boot.
+var defaultRlimits = []configs.Rlimit{
+	{Type: syscall.RLIMIT_CPU, Hard: rlimInfinity, Soft: rlimInfinity},
+	{Type: syscall.RLIMIT_FSIZE, Hard: rlimInfinity, Soft: rlimInfinity},
+	{Type: syscall.RLIMIT_DATA, Hard: rlimInfinity, Soft: rlimInfinity},
+	{Type: syscall.RLIMIT_STACK, Hard: rlimInfinity, Soft: 8 << 20},
+	{Type: syscall.RLIMIT_CORE, Hard: rlimInfinity, Soft: 0},
+	{Type: rlimitRSS, Hard: rlimInfinity, Soft: rlimInfinity},
+	{Type: syscall.RLIMIT_NOFILE, Hard: 4096, Soft: 1024},
+	{Type: rlimitMemlock, Hard: 8 << 20, Soft: 8 << 20},
+	{Type: syscall.RLIMIT_AS, Hard: rlimInfinity, Soft: rlimInfinity},
+	{Type: rlimitLocks, Hard: rlimInfinity, Soft: rlimInfinity},
+	{Type: rlimitMsgqueue, Hard: 819200, Soft: 819200},
+	{Type: rlimitNice, Hard: 0, Soft: 0},
+	{Type: rlimitRtprio, Hard: 0, Soft: 0},
+	{Type: rlimitRttime, Hard: rlimInfinity, Soft: rlimInfinity},
+}
+
 func setupRlimits(limits []configs.Rlimit, pid int) error {
 	for _, rlimit := range limits {
 		if err := system.Prlimit(pid, rlimit.Type, syscall.Rlimit{Max: rlimit.Hard, Cur: rlimit.Soft}); err != nil {
@@ -447,6 +606,58 @@ func isNoChildren(err error) bool {
 	return false
 }
 
+// signalProcessTree freezes the manager's cgroups, works out which of the
+// processes inside them descend from pid and sends the signal s to pid and
+// those descendants. Freezing first stops the tree from forking new children
+// behind our back while it is being walked.
+func signalProcessTree(m cgroups.Manager, pid int, s os.Signal) error {
+	sig, ok := s.(syscall.Signal)
+	if !ok {
+		return newGenericError(fmt.Errorf("unsupported signal %v", s), SystemError)
+	}
+	if err := m.Freeze(configs.Frozen); err != nil {
+		logrus.Warn(err)
+	}
+	defer func() {
+		if err := m.Freeze(configs.Thawed); err != nil {
+			logrus.Warn(err)
+		}
+	}()
+	pids, err := m.GetAllPids()
+	if err != nil {
+		return newSystemErrorWithCause(err, "getting container pids")
+	}
+	var (
+		found    bool
+		children = make(map[int][]int)
+	)
+	for _, p := range pids {
+		if p == pid {
+			found = true
+			continue
+		}
+		ppid, err := system.GetParentPid(p)
+		if err != nil {
+			// the process has already gone away.
+			continue
+		}
+		children[ppid] = append(children[ppid], p)
+	}
+	if !found {
+		return newGenericError(fmt.Errorf("process %d is not in the container", pid), ProcessNotExists)
+	}
+	tree := []int{pid}
+	for i := 0; i < len(tree); i++ {
+		tree = append(tree, children[tree[i]]...)
+	}
+	for _, p := range tree {
+		if err := syscall.Kill(p, sig); err != nil && err != syscall.ESRCH {
+			return newSystemErrorWithCausef(err, "signaling process %d", p)
+		}
+	}
+	return nil
+}
+
 // signalAllProcesses freezes then iterates over all the processes inside the
 // manager's cgroups sending the signal s to them.
 // If s is SIGKILL then it will wait for each process to exit.
@@ -0,0 +1,95 @@
+// +build linux
+
+package libcontainer
+
+import (
+	"os"
+	"os/exec"
+	"reflect"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+func TestDedupEnv(t *testing.T) {
+	env, err := dedupEnv([]string{
+		"PATH=/bin",
+		"TERM=dumb",
+		"HOME=/",
+		"TERM=xterm",
+		"EMPTY=",
+		"PATH=/usr/bin:/bin",
+		"VALUE=a=b",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"HOME=/", "TERM=xterm", "EMPTY=", "PATH=/usr/bin:/bin", "VALUE=a=b"}
+	if !reflect.DeepEqual(env, expected) {
+		t.Fatalf("expected %q, got %q", expected, env)
+	}
+
+	if _, err := dedupEnv([]string{"PATH=/bin", "INVALID"}); err == nil {
+		t.Fatal("expected an error for an entry without a value")
+	}
+}
+
+func TestPopulateProcessEnvironment(t *testing.T) {
+	saved := os.Environ()
+	defer func() {
+		os.Clearenv()
+		for _, pair := range saved {
+			p := strings.SplitN(pair, "=", 2)
+			os.Setenv(p[0], p[1])
+		}
+	}()
+
+	os.Clearenv()
+	if err := populateProcessEnvironment([]string{"A=1", "B=1", "A=2", "C=1", "B=2"}); err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"A=2", "C=1", "B=2"}
+	if env := os.Environ(); !reflect.DeepEqual(env, expected) {
+		t.Fatalf("expected environment %q, got %q", expected, env)
+	}
+}
+
+func TestNewExecError(t *testing.T) {
+	_, missingPath := exec.LookPath("/does-not-exist")
+	_, missingName := exec.LookPath("does-not-exist")
+	for _, test := range []struct {
+		err  error
+		code ErrorCode
+	}{
+		{missingPath, ExecNotFound},
+		{missingName, ExecNotFound},
+		{syscall.ENOENT, ExecNotFound},
+		{syscall.EACCES, ExecPermissionDenied},
+		{&os.PathError{Op: "stat", Path: "/bin/sh", Err: syscall.EACCES}, ExecPermissionDenied},
+		{syscall.ENOEXEC, ExecFormatInvalid},
+		{syscall.EIO, SystemError},
+	} {
+		if test.err == nil {
+			t.Fatal("expected the lookup to fail")
+		}
+		if code := newExecError("/does-not-exist", test.err).Code(); code != test.code {
+			t.Errorf("expected code %s for %v, got %s", test.code, test.err, code)
+		}
+	}
+}
+
+func TestDecodeInitConfig(t *testing.T) {
+	for _, data := range []string{"null", "{}"} {
+		_, err := decodeInitConfig(strings.NewReader(data))
+		if e, ok := err.(Error); !ok || e.Code() != ConfigInvalid {
+			t.Errorf("expected a ConfigInvalid error for %s, got %v", data, err)
+		}
+	}
+	config, err := decodeInitConfig(strings.NewReader(`{"config": {"rootfs": "/rootfs"}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.Config.Rootfs != "/rootfs" {
+		t.Fatalf("expected the container config to be decoded, got %+v", config.Config)
+	}
+}
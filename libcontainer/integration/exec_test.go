@@ -1,22 +1,30 @@
 package integration
 
 import (
+	"bufio"
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"io"
 	"io/ioutil"
+	"net"
 	"os"
 	"os/exec"
+	"os/signal"
 	"path/filepath"
 	"reflect"
 	"strconv"
 	"strings"
 	"syscall"
 	"testing"
+	"time"
 
 	"github.com/opencontainers/runc/libcontainer"
+	"github.com/opencontainers/runc/libcontainer/cgroups"
 	"github.com/opencontainers/runc/libcontainer/cgroups/systemd"
 	"github.com/opencontainers/runc/libcontainer/configs"
+	"github.com/opencontainers/runc/libcontainer/system"
+	"github.com/opencontainers/runc/libcontainer/utils"
 )
 
 func TestExecPS(t *testing.T) {
@@ -339,6 +347,419 @@ func TestProcessEnv(t *testing.T) {
 	}
 }
 
+func TestEnvFile(t *testing.T) {
+	if testing.Short() {
+		return
+	}
+	rootfs, err := newRootfs()
+	ok(t, err)
+	defer remove(rootfs)
+
+	envFile, err := ioutil.TempFile("", "envfile")
+	ok(t, err)
+	defer os.Remove(envFile.Name())
+	_, err = envFile.WriteString("# from the env file\nFOO=file\n\nBAR=file\n")
+	ok(t, err)
+	ok(t, envFile.Close())
+
+	config := newTemplateConfig(rootfs)
+	config.EnvFile = envFile.Name()
+
+	container, err := newContainer(config)
+	ok(t, err)
+	defer container.Destroy()
+
+	var stdout bytes.Buffer
+	pconfig := libcontainer.Process{
+		Cwd:    "/",
+		Args:   []string{"sh", "-c", "env"},
+		Env:    append(standardEnvironment, "BAR=process"),
+		Stdout: &stdout,
+	}
+	ok(t, container.Run(&pconfig))
+	waitProcess(&pconfig, t)
+
+	outputEnv := stdout.String()
+	if !strings.Contains(outputEnv, "FOO=file") {
+		t.Fatalf("expected FOO from the env file in the environment: %s", outputEnv)
+	}
+	if !strings.Contains(outputEnv, "BAR=process") || strings.Contains(outputEnv, "BAR=file") {
+		t.Fatalf("expected the process environment to override BAR: %s", outputEnv)
+	}
+}
+
+func TestProcessPidWriter(t *testing.T) {
+	if testing.Short() {
+		return
+	}
+	rootfs, err := newRootfs()
+	ok(t, err)
+	defer remove(rootfs)
+
+	container, err := newContainer(newTemplateConfig(rootfs))
+	ok(t, err)
+	defer container.Destroy()
+
+	stdinR, stdinW, err := os.Pipe()
+	ok(t, err)
+	defer stdinW.Close()
+	initPids, err := ioutil.TempFile("", "pidfile")
+	ok(t, err)
+	defer os.Remove(initPids.Name())
+	defer initPids.Close()
+	initProcess := &libcontainer.Process{
+		Cwd:       "/",
+		Args:      []string{"cat"},
+		Env:       standardEnvironment,
+		Stdin:     stdinR,
+		PidWriter: initPids,
+	}
+	err = container.Run(initProcess)
+	stdinR.Close()
+	ok(t, err)
+
+	// The pid of an exec'd process goes to its own pid file.
+	pidR, pidW, err := os.Pipe()
+	ok(t, err)
+	defer pidR.Close()
+	execProcess := &libcontainer.Process{
+		Cwd:       "/",
+		Args:      []string{"true"},
+		Env:       standardEnvironment,
+		PidWriter: pidW,
+	}
+	err = container.Run(execProcess)
+	pidW.Close()
+	ok(t, err)
+	waitProcess(execProcess, t)
+
+	for _, c := range []struct {
+		process *libcontainer.Process
+		read    func() ([]byte, error)
+	}{
+		{initProcess, func() ([]byte, error) { return ioutil.ReadFile(initPids.Name()) }},
+		{execProcess, func() ([]byte, error) { return ioutil.ReadAll(pidR) }},
+	} {
+		data, err := c.read()
+		ok(t, err)
+		pid, err := c.process.Pid()
+		ok(t, err)
+		if string(data) != strconv.Itoa(pid) {
+			t.Fatalf("expected the pid writer to get %d, got %q", pid, data)
+		}
+	}
+
+	stdinW.Close()
+	waitProcess(initProcess, t)
+}
+
+func TestHostEnv(t *testing.T) {
+	if testing.Short() {
+		return
+	}
+	rootfs, err := newRootfs()
+	ok(t, err)
+	defer remove(rootfs)
+	defer os.Setenv("TERM", os.Getenv("TERM"))
+	ok(t, os.Setenv("TERM", "screen-256color"))
+
+	config := newTemplateConfig(rootfs)
+	config.HostEnv = []string{"TERM", "LIBCONTAINER_TEST_UNSET"}
+	buffers, exitCode, err := runContainer(config, "", "env")
+	ok(t, err)
+	if exitCode != 0 {
+		t.Fatalf("exit code not 0. code %d stderr %q", exitCode, buffers.Stderr)
+	}
+	// standardEnvironment sets TERM=xterm, which takes precedence.
+	if out := buffers.Stdout.String(); !strings.Contains(out, "TERM=xterm\n") || strings.Contains(out, "LIBCONTAINER_TEST_UNSET") {
+		t.Fatalf("expected the process's TERM to take precedence: %s", out)
+	}
+
+	// Without TERM in the process's environment the host's is passed in.
+	container, err := newContainer(config)
+	ok(t, err)
+	defer container.Destroy()
+	var stdout bytes.Buffer
+	process := &libcontainer.Process{
+		Cwd:    "/",
+		Args:   []string{"env"},
+		Env:    []string{"PATH=/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin"},
+		Stdout: &stdout,
+	}
+	ok(t, container.Run(process))
+	waitProcess(process, t)
+	if out := stdout.String(); !strings.Contains(out, "TERM=screen-256color\n") {
+		t.Fatalf("expected TERM from the host in the environment: %s", out)
+	}
+}
+
+func TestDaemon(t *testing.T) {
+	if testing.Short() {
+		return
+	}
+	rootfs, err := newRootfs()
+	ok(t, err)
+	defer remove(rootfs)
+
+	config := newTemplateConfig(rootfs)
+	config.Daemon = &configs.Daemon{Stdout: "/daemon.log"}
+	container, err := newContainer(config)
+	ok(t, err)
+	defer container.Destroy()
+
+	var stdout, stderr bytes.Buffer
+	process := &libcontainer.Process{
+		Cwd:    "/",
+		Args:   []string{"sh", "-c", "cat /proc/self/stat; echo oops >&2"},
+		Env:    standardEnvironment,
+		Stdout: &stdout,
+		Stderr: &stderr,
+	}
+	ok(t, container.Run(process))
+	waitProcess(process, t)
+	if stdout.Len() != 0 || stderr.Len() != 0 {
+		t.Fatalf("expected the stdio of the process to be redirected, got %q and %q", stdout.String(), stderr.String())
+	}
+	out, err := ioutil.ReadFile(filepath.Join(rootfs, "daemon.log"))
+	ok(t, err)
+	// The seventh field of stat is the controlling terminal of the process.
+	fields := strings.Fields(string(out))
+	if len(fields) < 7 || fields[6] != "0" {
+		t.Fatalf("expected no controlling terminal in daemon mode: %s", out)
+	}
+
+	console := &libcontainer.Process{
+		Cwd:               "/",
+		Args:              []string{"true"},
+		Env:               standardEnvironment,
+		ConsoleSocketPath: "/nonexistent",
+	}
+	config.Cgroups.Path = "integration/test-daemon-console"
+	consoleContainer, err := newContainerWithName("test-daemon-console", config)
+	ok(t, err)
+	defer consoleContainer.Destroy()
+	if err := consoleContainer.Run(console); err == nil {
+		t.Fatal("expected a console to be rejected in daemon mode")
+	}
+}
+
+// TestDaemonReadonlyRootfs expects the daemon files to be created before the
+// rootfs is made read-only.
+func TestDaemonReadonlyRootfs(t *testing.T) {
+	if testing.Short() {
+		return
+	}
+	rootfs, err := newRootfs()
+	ok(t, err)
+	defer remove(rootfs)
+
+	config := newTemplateConfig(rootfs)
+	config.Readonlyfs = true
+	config.Daemon = &configs.Daemon{Stdout: "/daemon.log"}
+	container, err := newContainer(config)
+	ok(t, err)
+	defer container.Destroy()
+
+	process := &libcontainer.Process{
+		Cwd:  "/",
+		Args: []string{"echo", "daemon"},
+		Env:  standardEnvironment,
+	}
+	ok(t, container.Run(process))
+	waitProcess(process, t)
+	out, err := ioutil.ReadFile(filepath.Join(rootfs, "daemon.log"))
+	ok(t, err)
+	if string(out) != "daemon\n" {
+		t.Fatalf("expected the output in the daemon file, got %q", out)
+	}
+}
+
+func TestQuiesce(t *testing.T) {
+	if testing.Short() {
+		return
+	}
+	rootfs, err := newRootfs()
+	ok(t, err)
+	defer remove(rootfs)
+
+	config := newTemplateConfig(rootfs)
+	config.QuiescedFile = "/quiesced"
+	container, err := newContainer(config)
+	ok(t, err)
+	defer container.Destroy()
+
+	stdoutR, stdoutW, err := os.Pipe()
+	ok(t, err)
+	defer stdoutR.Close()
+	process := &libcontainer.Process{
+		Cwd:    "/",
+		Args:   []string{"sh", "-c", "trap 'touch /quiesced' USR1; echo ready; while :; do sleep 0.1; done"},
+		Env:    standardEnvironment,
+		Stdout: stdoutW,
+	}
+	err = container.Run(process)
+	stdoutW.Close()
+	ok(t, err)
+	defer process.Signal(syscall.SIGKILL)
+
+	// Wait for the trap to be set before signaling.
+	_, err = bufio.NewReader(stdoutR).ReadString('\n')
+	ok(t, err)
+	ok(t, container.Quiesce(syscall.SIGUSR1, 10*time.Second))
+	if _, err := os.Stat(filepath.Join(rootfs, "quiesced")); err != nil {
+		t.Fatalf("expected the init to have quiesced: %v", err)
+	}
+}
+
+func TestContainerWait(t *testing.T) {
+	if testing.Short() {
+		return
+	}
+	rootfs, err := newRootfs()
+	ok(t, err)
+	defer remove(rootfs)
+
+	config := newTemplateConfig(rootfs)
+	container, err := newContainer(config)
+	ok(t, err)
+	defer container.Destroy()
+
+	process := &libcontainer.Process{
+		Cwd:  "/",
+		Args: []string{"sh", "-c", "exit 7"},
+		Env:  standardEnvironment,
+	}
+	ok(t, container.Run(process))
+	code, err := container.Wait()
+	ok(t, err)
+	if code != 7 {
+		t.Fatalf("expected exit code 7, got %d", code)
+	}
+	// The process is waited for once, so it reports the same exit.
+	state, _ := process.Wait()
+	if state == nil || utils.ExitCode(state) != 7 {
+		t.Fatalf("expected the process to report exit code 7, got %v", state)
+	}
+	loaded, err := factory.Load(container.ID())
+	ok(t, err)
+	if code, err := loaded.Wait(); err != nil || code != 7 {
+		t.Fatalf("expected the loaded container to report exit code 7, got %d: %v", code, err)
+	}
+
+	// Starting the init again drops the exit code of the previous one.
+	process = &libcontainer.Process{
+		Cwd:  "/",
+		Args: []string{"sh", "-c", "exit 8"},
+		Env:  standardEnvironment,
+	}
+	ok(t, container.Run(process))
+	s, err := container.State()
+	ok(t, err)
+	if s.ExitStatus != nil {
+		t.Fatalf("expected the saved exit code to be cleared, got %d", *s.ExitStatus)
+	}
+	if code, err := container.Wait(); err != nil || code != 8 {
+		t.Fatalf("expected exit code 8, got %d: %v", code, err)
+	}
+}
+
+func TestUptime(t *testing.T) {
+	if testing.Short() {
+		return
+	}
+	rootfs, err := newRootfs()
+	ok(t, err)
+	defer remove(rootfs)
+
+	config := newTemplateConfig(rootfs)
+	container, err := newContainer(config)
+	ok(t, err)
+	defer container.Destroy()
+
+	if _, err := container.Uptime(); err == nil {
+		t.Fatal("expected the uptime of a stopped container to fail")
+	}
+	stdinR, stdinW, err := os.Pipe()
+	ok(t, err)
+	process := &libcontainer.Process{
+		Cwd:   "/",
+		Args:  []string{"cat"},
+		Env:   standardEnvironment,
+		Stdin: stdinR,
+	}
+	err = container.Run(process)
+	stdinR.Close()
+	defer stdinW.Close()
+	ok(t, err)
+
+	time.Sleep(500 * time.Millisecond)
+	uptime, err := container.Uptime()
+	ok(t, err)
+	if uptime < 450*time.Millisecond || uptime > 10*time.Second {
+		t.Fatalf("expected an uptime of about 500ms, got %s", uptime)
+	}
+	stdinW.Close()
+	waitProcess(process, t)
+}
+
+func TestBlockedSignals(t *testing.T) {
+	if testing.Short() {
+		return
+	}
+	rootfs, err := newRootfs()
+	ok(t, err)
+	defer remove(rootfs)
+
+	config := newTemplateConfig(rootfs)
+	buffers, exitCode, err := runContainer(config, "", "grep", "SigBlk", "/proc/self/status")
+	ok(t, err)
+	if exitCode != 0 {
+		t.Fatalf("exit code not 0. code %d stderr %q", exitCode, buffers.Stderr)
+	}
+	if out := strings.TrimSpace(buffers.Stdout.String()); out != "SigBlk:\t0000000000000000" {
+		t.Fatalf("expected no blocked signals, got %q", out)
+	}
+
+	// SIGHUP is bit 0 and SIGUSR1 bit 9.
+	config.BlockedSignals = []int{int(syscall.SIGHUP), int(syscall.SIGUSR1)}
+	container, err := newContainer(config)
+	ok(t, err)
+	defer container.Destroy()
+	stdinR, stdinW, err := os.Pipe()
+	ok(t, err)
+	init := &libcontainer.Process{
+		Cwd:   "/",
+		Args:  []string{"cat"},
+		Env:   standardEnvironment,
+		Stdin: stdinR,
+	}
+	err = container.Run(init)
+	stdinR.Close()
+	defer stdinW.Close()
+	ok(t, err)
+	for _, args := range [][]string{
+		{"grep", "SigBlk", "/proc/1/status"},
+		{"grep", "SigBlk", "/proc/self/status"},
+	} {
+		buffers := newStdBuffers()
+		process := &libcontainer.Process{
+			Cwd:    "/",
+			Args:   args,
+			Env:    standardEnvironment,
+			Stdin:  buffers.Stdin,
+			Stdout: buffers.Stdout,
+			Stderr: buffers.Stderr,
+		}
+		ok(t, container.Run(process))
+		waitProcess(process, t)
+		if out := strings.TrimSpace(buffers.Stdout.String()); out != "SigBlk:\t0000000000000201" {
+			t.Fatalf("expected SIGHUP and SIGUSR1 to be blocked by %v, got %q", args, out)
+		}
+	}
+	stdinW.Close()
+	waitProcess(init, t)
+}
+
 func TestProcessEmptyCaps(t *testing.T) {
 	if testing.Short() {
 		return
@@ -957,6 +1378,42 @@ func TestSysctl(t *testing.T) {
 	}
 }
 
+func TestMountOrder(t *testing.T) {
+	if testing.Short() {
+		return
+	}
+	rootfs, err := newRootfs()
+	ok(t, err)
+	defer remove(rootfs)
+	dir, err := ioutil.TempDir("", "mount-order")
+	ok(t, err)
+	defer os.RemoveAll(dir)
+	ok(t, ioutil.WriteFile(filepath.Join(dir, "file"), []byte("inner"), 0644))
+
+	// The tmpfs would hide the bind mount below it if they were mounted in
+	// the order they are given in.
+	config := newTemplateConfig(rootfs)
+	config.Mounts = append(config.Mounts, &configs.Mount{
+		Source:      dir,
+		Destination: "/data/inner",
+		Device:      "bind",
+		Flags:       syscall.MS_BIND | syscall.MS_REC,
+	}, &configs.Mount{
+		Source:      "tmpfs",
+		Destination: "/data",
+		Device:      "tmpfs",
+		Flags:       defaultMountFlags,
+	})
+	buffers, exitCode, err := runContainer(config, "", "cat", "/data/inner/file")
+	ok(t, err)
+	if exitCode != 0 {
+		t.Fatalf("exit code not 0. code %d stderr %q", exitCode, buffers.Stderr)
+	}
+	if out := buffers.Stdout.String(); out != "inner" {
+		t.Fatalf("expected the bind mount to be mounted on top of the tmpfs, got %q", out)
+	}
+}
+
 func TestMountCgroupRO(t *testing.T) {
 	if testing.Short() {
 		return
@@ -1098,202 +1555,171 @@ func TestOomScoreAdj(t *testing.T) {
 	}
 }
 
-func TestHook(t *testing.T) {
+func TestSuperviseRestartOnOOM(t *testing.T) {
 	if testing.Short() {
 		return
 	}
-
-	bundle, err := newTestBundle()
-	ok(t, err)
-	defer remove(bundle)
-
 	rootfs, err := newRootfs()
 	ok(t, err)
 	defer remove(rootfs)
 
 	config := newTemplateConfig(rootfs)
-	expectedBundle := bundle
-	config.Labels = append(config.Labels, fmt.Sprintf("bundle=%s", expectedBundle))
+	config.Cgroups.Resources.Memory = 32 * 1024 * 1024
+	config.Cgroups.Resources.MemorySwap = 32 * 1024 * 1024
+	container, err := newContainer(config)
+	ok(t, err)
+	defer container.Destroy()
 
-	getRootfsFromBundle := func(bundle string) (string, error) {
-		f, err := os.Open(filepath.Join(bundle, "config.json"))
-		if err != nil {
-			return "", err
+	// dd touches all of its 64M buffer, so the OOM killer kills it.
+	process := &libcontainer.Process{
+		Cwd:  "/",
+		Args: []string{"dd", "if=/dev/zero", "of=/dev/null", "bs=64M", "count=1"},
+		Env:  standardEnvironment,
+	}
+	ok(t, container.Run(process))
+	events := make(chan libcontainer.RestartEvent, 16)
+	stop, err := libcontainer.Supervise(container, process, libcontainer.RestartOnOOM, func(ev libcontainer.RestartEvent) {
+		events <- ev
+	})
+	ok(t, err)
+	defer stop()
+
+	var intents int
+	for {
+		var ev libcontainer.RestartEvent
+		select {
+		case ev = <-events:
+		case <-time.After(10 * time.Second):
+			t.Fatal("timed out waiting for the init to be restarted")
 		}
-
-		var config configs.Config
-		if err = json.NewDecoder(f).Decode(&config); err != nil {
-			return "", err
+		if ev.Reason != libcontainer.OOMReason || !ev.Restart {
+			t.Fatalf("expected an OOM kill and restart intent, got %+v", ev)
 		}
-		return config.Rootfs, nil
+		if !ev.Exited {
+			intents++
+			continue
+		}
+		if intents == 0 {
+			t.Fatal("expected the OOM kill to be reported before the exit")
+		}
+		ok(t, ev.Err)
+		if ev.Process == nil {
+			t.Fatal("expected the restarted init")
+		}
+		stop()
+		ev.Process.Signal(syscall.SIGKILL)
+		break
 	}
+}
 
-	config.Hooks = &configs.Hooks{
-		Prestart: []configs.Hook{
-			configs.NewFunctionHook(func(s configs.HookState) error {
-				if s.Bundle != expectedBundle {
-					t.Fatalf("Expected prestart hook bundlePath '%s'; got '%s'", expectedBundle, s.Bundle)
-				}
-
-				root, err := getRootfsFromBundle(s.Bundle)
-				if err != nil {
-					return err
-				}
-				f, err := os.Create(filepath.Join(root, "test"))
-				if err != nil {
-					return err
-				}
-				return f.Close()
-			}),
-		},
-		Poststart: []configs.Hook{
-			configs.NewFunctionHook(func(s configs.HookState) error {
-				if s.Bundle != expectedBundle {
-					t.Fatalf("Expected poststart hook bundlePath '%s'; got '%s'", expectedBundle, s.Bundle)
-				}
+func TestOomKillDisable(t *testing.T) {
+	if testing.Short() {
+		return
+	}
+	root, err := newTestRoot()
+	ok(t, err)
+	defer os.RemoveAll(root)
 
-				root, err := getRootfsFromBundle(s.Bundle)
-				if err != nil {
-					return err
-				}
-				return ioutil.WriteFile(filepath.Join(root, "test"), []byte("hello world"), 0755)
-			}),
-		},
-		Poststop: []configs.Hook{
-			configs.NewFunctionHook(func(s configs.HookState) error {
-				if s.Bundle != expectedBundle {
-					t.Fatalf("Expected poststop hook bundlePath '%s'; got '%s'", expectedBundle, s.Bundle)
-				}
+	rootfs, err := newRootfs()
+	ok(t, err)
+	defer remove(rootfs)
 
-				root, err := getRootfsFromBundle(s.Bundle)
-				if err != nil {
-					return err
-				}
-				return os.RemoveAll(filepath.Join(root, "test"))
-			}),
-		},
-	}
+	config := newTemplateConfig(rootfs)
+	config.Cgroups.Resources.Memory = 32 * 1024 * 1024
+	config.Cgroups.Resources.OomKillDisable = true
 
-	// write config of json format into config.json under bundle
-	f, err := os.OpenFile(filepath.Join(bundle, "config.json"), os.O_CREATE|os.O_RDWR, 0644)
+	factory, err := libcontainer.New(root, libcontainer.Cgroupfs)
 	ok(t, err)
-	ok(t, json.NewEncoder(f).Encode(config))
 
 	container, err := factory.Create("test", config)
 	ok(t, err)
+	defer container.Destroy()
 
-	var stdout bytes.Buffer
-	pconfig := libcontainer.Process{
-		Cwd:    "/",
-		Args:   []string{"sh", "-c", "ls /test"},
-		Env:    standardEnvironment,
-		Stdin:  nil,
-		Stdout: &stdout,
+	// Filling /dev/shm (tmpfs) is charged to the memory cgroup and cannot be
+	// reclaimed, so dd hits the limit and is paused by the kernel.
+	pconfig := &libcontainer.Process{
+		Cwd:  "/",
+		Args: []string{"dd", "if=/dev/zero", "of=/dev/shm/fill", "bs=1M", "count=64"},
+		Env:  standardEnvironment,
 	}
-	err = container.Run(&pconfig)
+	err = container.Run(pconfig)
 	ok(t, err)
 
-	// Wait for process
-	waitProcess(&pconfig, t)
-
-	outputLs := string(stdout.Bytes())
-
-	// Check that the ls output has the expected file touched by the prestart hook
-	if !strings.Contains(outputLs, "/test") {
-		container.Destroy()
-		t.Fatalf("ls output doesn't have the expected file: %s", outputLs)
-	}
-
-	// Check that the file is written by the poststart hook
-	testFilePath := filepath.Join(rootfs, "test")
-	contents, err := ioutil.ReadFile(testFilePath)
-	if err != nil {
-		t.Fatalf("cannot read file '%s': %s", testFilePath, err)
+	state, err := container.State()
+	ok(t, err)
+	oomControl := filepath.Join(state.CgroupPaths["memory"], "memory.oom_control")
+	underOom := false
+	for i := 0; i < 50 && !underOom; i++ {
+		data, err := ioutil.ReadFile(oomControl)
+		ok(t, err)
+		underOom = strings.Contains(string(data), "under_oom 1")
+		if !underOom {
+			time.Sleep(100 * time.Millisecond)
+		}
 	}
-	if string(contents) != "hello world" {
-		t.Fatalf("Expected test file to contain 'hello world'; got '%s'", string(contents))
+	if !underOom {
+		t.Fatal("expected the container to be under oom")
 	}
 
-	if err := container.Destroy(); err != nil {
-		t.Fatalf("container destroy %s", err)
+	// The process must have been paused rather than killed.
+	pid, err := pconfig.Pid()
+	ok(t, err)
+	if err := syscall.Kill(pid, 0); err != nil {
+		t.Fatalf("expected process %d to be alive: %v", pid, err)
 	}
-	fi, err := os.Stat(filepath.Join(rootfs, "test"))
-	if err == nil || !os.IsNotExist(err) {
-		t.Fatalf("expected file to not exist, got %s", fi.Name())
+
+	ok(t, pconfig.Signal(syscall.SIGKILL))
+	if _, err := pconfig.Wait(); err == nil {
+		t.Fatal("expected process to be killed by SIGKILL")
 	}
 }
 
-func TestSTDIOPermissions(t *testing.T) {
+func TestInitFailureExitCode(t *testing.T) {
 	if testing.Short() {
 		return
 	}
-
 	rootfs, err := newRootfs()
 	ok(t, err)
 	defer remove(rootfs)
+
 	config := newTemplateConfig(rootfs)
-	buffers, exitCode, err := runContainer(config, "", "sh", "-c", "echo hi > /dev/stderr")
+	config.InitFailure = &configs.InitFailurePolicy{ExitCode: 125}
+	container, err := newContainer(config)
 	ok(t, err)
-	if exitCode != 0 {
-		t.Fatalf("exit code not 0. code %d stderr %q", exitCode, buffers.Stderr)
-	}
+	defer container.Destroy()
 
-	if actual := strings.Trim(buffers.Stderr.String(), "\n"); actual != "hi" {
-		t.Fatalf("stderr should equal be equal %q %q", actual, "hi")
+	pconfig := &libcontainer.Process{
+		Cwd:  "/",
+		Args: []string{"/does-not-exist"},
+		Env:  standardEnvironment,
+	}
+	if err := container.Run(pconfig); err == nil {
+		t.Fatal("expected the init to fail to set up the container")
 	}
-}
 
-func unmountOp(path string) error {
-	if err := syscall.Unmount(path, syscall.MNT_DETACH); err != nil {
-		return err
+	// The init has already been reaped while reporting the error, Wait only
+	// hands back its exit status.
+	state, _ := pconfig.Wait()
+	if state == nil {
+		t.Fatal("expected the exit status of the failed init")
+	}
+	if status := state.Sys().(syscall.WaitStatus); status.ExitStatus() != 125 {
+		t.Fatalf("expected init to exit with 125 but got %v", status)
 	}
-	return nil
 }
 
-// Launch container with rootfsPropagation in rslave mode. Also
-// bind mount a volume /mnt1host at /mnt1cont at the time of launch. Now do
-// another mount on host (/mnt1host/mnt2host) and this new mount should
-// propagate to container (/mnt1cont/mnt2host)
-func TestRootfsPropagationSlaveMount(t *testing.T) {
-	var mountPropagated bool
-	var dir1cont string
-	var dir2cont string
-
-	dir1cont = "/root/mnt1cont"
-
+func TestPidFile(t *testing.T) {
 	if testing.Short() {
 		return
 	}
 	rootfs, err := newRootfs()
 	ok(t, err)
 	defer remove(rootfs)
-	config := newTemplateConfig(rootfs)
-
-	config.RootPropagation = syscall.MS_SLAVE | syscall.MS_REC
-
-	// Bind mount a volume
-	dir1host, err := ioutil.TempDir("", "mnt1host")
-	ok(t, err)
-	defer os.RemoveAll(dir1host)
-
-	// Make this dir a "shared" mount point. This will make sure a
-	// slave relationship can be established in container.
-	err = syscall.Mount(dir1host, dir1host, "bind", syscall.MS_BIND|syscall.MS_REC, "")
-	ok(t, err)
-	err = syscall.Mount("", dir1host, "", syscall.MS_SHARED|syscall.MS_REC, "")
-	ok(t, err)
-	defer unmountOp(dir1host)
-
-	config.Mounts = append(config.Mounts, &configs.Mount{
-		Source:      dir1host,
-		Destination: dir1cont,
-		Device:      "bind",
-		Flags:       syscall.MS_BIND | syscall.MS_REC})
-
-	// TODO: systemd specific processing
-	f := factory
 
-	container, err := f.Create("testSlaveMount", config)
+	pidFile := filepath.Join(rootfs, "init.pid")
+	config := newTemplateConfig(rootfs)
+	config.PidFile = pidFile
+	container, err := newContainer(config)
 	ok(t, err)
 	defer container.Destroy()
 
@@ -1306,211 +1732,240 @@ func TestRootfsPropagationSlaveMount(t *testing.T) {
 		Env:   standardEnvironment,
 		Stdin: stdinR,
 	}
-
 	err = container.Run(pconfig)
 	stdinR.Close()
 	defer stdinW.Close()
 	ok(t, err)
 
-	// Create mnt1host/mnt2host and bind mount itself on top of it. This
-	// should be visible in container.
-	dir2host, err := ioutil.TempDir(dir1host, "mnt2host")
-	ok(t, err)
-	defer os.RemoveAll(dir2host)
-
-	err = syscall.Mount(dir2host, dir2host, "bind", syscall.MS_BIND, "")
-	defer unmountOp(dir2host)
+	pid, err := pconfig.Pid()
 	ok(t, err)
-
-	// Run "cat /proc/self/mountinfo" in container and look at mount points.
-	var stdout2 bytes.Buffer
-
-	stdinR2, stdinW2, err := os.Pipe()
+	data, err := ioutil.ReadFile(pidFile)
 	ok(t, err)
-
-	pconfig2 := &libcontainer.Process{
-		Cwd:    "/",
-		Args:   []string{"cat", "/proc/self/mountinfo"},
-		Env:    standardEnvironment,
-		Stdin:  stdinR2,
-		Stdout: &stdout2,
+	if string(data) != strconv.Itoa(pid) {
+		t.Fatalf("expected pid file to contain %d but found %q", pid, data)
 	}
 
-	err = container.Run(pconfig2)
-	stdinR2.Close()
-	defer stdinW2.Close()
-	ok(t, err)
-
-	stdinW2.Close()
-	waitProcess(pconfig2, t)
 	stdinW.Close()
 	waitProcess(pconfig, t)
-
-	mountPropagated = false
-	dir2cont = filepath.Join(dir1cont, filepath.Base(dir2host))
-
-	propagationInfo := string(stdout2.Bytes())
-	lines := strings.Split(propagationInfo, "\n")
-	for _, l := range lines {
-		linefields := strings.Split(l, " ")
-		if len(linefields) < 5 {
-			continue
-		}
-
-		if linefields[4] == dir2cont {
-			mountPropagated = true
-			break
-		}
-	}
-
-	if mountPropagated != true {
-		t.Fatalf("Mount on host %s did not propagate in container at %s\n", dir2host, dir2cont)
+	ok(t, container.Destroy())
+	if _, err := os.Stat(pidFile); !os.IsNotExist(err) {
+		t.Fatalf("expected pid file to be removed on destroy: %v", err)
 	}
 }
 
-// Launch container with rootfsPropagation 0 so no propagation flags are
-// applied. Also bind mount a volume /mnt1host at /mnt1cont at the time of
-// launch. Now do a mount in container (/mnt1cont/mnt2cont) and this new
-// mount should propagate to host (/mnt1host/mnt2cont)
-
-func TestRootfsPropagationSharedMount(t *testing.T) {
-	var dir1cont string
-	var dir2cont string
-
-	dir1cont = "/root/mnt1cont"
-
+func TestInitPidFd(t *testing.T) {
 	if testing.Short() {
 		return
 	}
 	rootfs, err := newRootfs()
 	ok(t, err)
 	defer remove(rootfs)
-	config := newTemplateConfig(rootfs)
-	config.RootPropagation = syscall.MS_PRIVATE
 
-	// Bind mount a volume
-	dir1host, err := ioutil.TempDir("", "mnt1host")
+	config := newTemplateConfig(rootfs)
+	container, err := newContainer(config)
 	ok(t, err)
-	defer os.RemoveAll(dir1host)
+	defer container.Destroy()
 
-	// Make this dir a "shared" mount point. This will make sure a
-	// shared relationship can be established in container.
-	err = syscall.Mount(dir1host, dir1host, "bind", syscall.MS_BIND|syscall.MS_REC, "")
-	ok(t, err)
-	err = syscall.Mount("", dir1host, "", syscall.MS_SHARED|syscall.MS_REC, "")
+	stdinR, stdinW, err := os.Pipe()
 	ok(t, err)
-	defer unmountOp(dir1host)
 
-	config.Mounts = append(config.Mounts, &configs.Mount{
-		Source:      dir1host,
-		Destination: dir1cont,
-		Device:      "bind",
-		Flags:       syscall.MS_BIND | syscall.MS_REC})
+	pconfig := &libcontainer.Process{
+		Cwd:   "/",
+		Args:  []string{"cat"},
+		Env:   standardEnvironment,
+		Stdin: stdinR,
+	}
+	err = container.Run(pconfig)
+	stdinR.Close()
+	defer stdinW.Close()
+	ok(t, err)
 
-	// TODO: systemd specific processing
-	f := factory
+	fd, err := container.InitPidFd()
+	if err != nil {
+		if lerr, ok := err.(libcontainer.Error); ok && lerr.Code() == libcontainer.SystemError {
+			t.Skipf("pidfds are unsupported: %v", err)
+		}
+		t.Fatal(err)
+	}
+	defer syscall.Close(fd)
 
-	container, err := f.Create("testSharedMount", config)
+	ok(t, system.PidfdSendSignal(fd, syscall.SIGKILL))
+	if _, err := pconfig.Wait(); err == nil {
+		t.Fatal("expected init to be killed through its pidfd")
+	}
+}
+
+func TestContainerCapabilities(t *testing.T) {
+	if testing.Short() {
+		return
+	}
+	rootfs, err := newRootfs()
+	ok(t, err)
+	defer remove(rootfs)
+	config := newTemplateConfig(rootfs)
+	caps := []string{"CAP_CHOWN", "CAP_KILL", "CAP_NET_BIND_SERVICE"}
+	config.Capabilities = &configs.Capabilities{
+		Bounding:    caps,
+		Effective:   caps,
+		Permitted:   caps,
+		Inheritable: caps,
+	}
+	container, err := newContainer(config)
 	ok(t, err)
 	defer container.Destroy()
 
+	if _, err := container.Capabilities(); err == nil {
+		t.Fatal("expected reading the capabilities of a stopped container to fail")
+	}
 	stdinR, stdinW, err := os.Pipe()
 	ok(t, err)
-
-	pconfig := &libcontainer.Process{
+	process := &libcontainer.Process{
 		Cwd:   "/",
 		Args:  []string{"cat"},
 		Env:   standardEnvironment,
 		Stdin: stdinR,
 	}
-
-	err = container.Run(pconfig)
+	err = container.Run(process)
 	stdinR.Close()
 	defer stdinW.Close()
 	ok(t, err)
 
-	// Create mnt1host/mnt2cont.  This will become visible inside container
-	// at mnt1cont/mnt2cont. Bind mount itself on top of it. This
-	// should be visible on host now.
-	dir2host, err := ioutil.TempDir(dir1host, "mnt2cont")
+	sets, err := container.Capabilities()
 	ok(t, err)
-	defer os.RemoveAll(dir2host)
-
-	dir2cont = filepath.Join(dir1cont, filepath.Base(dir2host))
+	for name, set := range map[string][]string{
+		"effective":   sets.Effective,
+		"permitted":   sets.Permitted,
+		"inheritable": sets.Inheritable,
+		"bounding":    sets.Bounding,
+	} {
+		if !reflect.DeepEqual(set, caps) {
+			t.Errorf("expected the %s set %v, got %v", name, caps, set)
+		}
+	}
+	if len(sets.Ambient) != 0 {
+		t.Errorf("expected an empty ambient set, got %v", sets.Ambient)
+	}
 
-	// Mount something in container and see if it is visible on host.
-	var stdout2 bytes.Buffer
+	stdinW.Close()
+	waitProcess(process, t)
+}
 
-	stdinR2, stdinW2, err := os.Pipe()
+func TestLoginUID(t *testing.T) {
+	if testing.Short() {
+		return
+	}
+	host, err := ioutil.ReadFile("/proc/self/loginuid")
+	if err != nil {
+		t.Skipf("auditing is unsupported: %v", err)
+	}
+	rootfs, err := newRootfs()
 	ok(t, err)
+	defer remove(rootfs)
+	config := newTemplateConfig(rootfs)
+	uid := uint32(1234)
+	config.LoginUID = &uid
 
-	pconfig2 := &libcontainer.Process{
-		Cwd:          "/",
-		Args:         []string{"mount", "--bind", dir2cont, dir2cont},
-		Env:          standardEnvironment,
-		Stdin:        stdinR2,
-		Stdout:       &stdout2,
-		Capabilities: &configs.Capabilities{},
+	buffers, exitCode, err := runContainer(config, "", "cat", "/proc/self/loginuid")
+	ok(t, err)
+	if exitCode != 0 {
+		t.Fatalf("exit code not 0. code %d stderr %q", exitCode, buffers.Stderr)
 	}
+	loginUID := strings.TrimSpace(buffers.Stdout.String())
+	if loginUID == strings.TrimSpace(string(host)) {
+		t.Skip("the audit policy does not allow setting the loginuid")
+	}
+	if loginUID != "1234" {
+		t.Fatalf("expected loginuid 1234, got %s", loginUID)
+	}
+}
 
-	// Provide CAP_SYS_ADMIN
-	pconfig2.Capabilities.Bounding = append(config.Capabilities.Bounding, "CAP_SYS_ADMIN")
-	pconfig2.Capabilities.Permitted = append(config.Capabilities.Permitted, "CAP_SYS_ADMIN")
-	pconfig2.Capabilities.Effective = append(config.Capabilities.Effective, "CAP_SYS_ADMIN")
-	pconfig2.Capabilities.Inheritable = append(config.Capabilities.Inheritable, "CAP_SYS_ADMIN")
-
-	err = container.Run(pconfig2)
-	stdinR2.Close()
-	defer stdinW2.Close()
+func TestFileCapabilities(t *testing.T) {
+	if testing.Short() {
+		return
+	}
+	rootfs, err := newRootfs()
 	ok(t, err)
+	defer remove(rootfs)
 
-	// Wait for process
-	stdinW2.Close()
-	waitProcess(pconfig2, t)
-	stdinW.Close()
-	waitProcess(pconfig, t)
-
-	defer unmountOp(dir2host)
+	// busybox runs the applet named by its first argument when invoked under
+	// a name starting with "busybox", so a copy keeps working as cat.
+	data, err := ioutil.ReadFile(filepath.Join(rootfs, "bin/busybox"))
+	ok(t, err)
+	ok(t, ioutil.WriteFile(filepath.Join(rootfs, "bin/busybox-netraw"), data, 0755))
 
-	// Check if mount is visible on host or not.
-	out, err := exec.Command("findmnt", "-n", "-f", "-oTARGET", dir2host).CombinedOutput()
-	outtrim := strings.TrimSpace(string(out))
-	if err != nil {
-		t.Logf("findmnt error %q: %q", err, outtrim)
+	config := newTemplateConfig(rootfs)
+	config.Capabilities.Ambient = nil
+	config.FileCapabilities = []configs.FileCapability{
+		{Path: "/bin/busybox-netraw", Capabilities: []string{"CAP_NET_RAW"}},
 	}
 
-	if string(outtrim) != dir2host {
-		t.Fatalf("Mount in container on %s did not propagate to host on %s. finmnt output=%s", dir2cont, dir2host, outtrim)
+	// CAP_NET_RAW is bit 13 of the effective set.
+	for args, expected := range map[string]string{
+		"cat /proc/self/status":                "CapEff:\t0000000000000000",
+		"busybox-netraw cat /proc/self/status": "CapEff:\t0000000000002000",
+	} {
+		container, err := newContainer(config)
+		ok(t, err)
+		defer container.Destroy()
+
+		buffers := newStdBuffers()
+		pconfig := &libcontainer.Process{
+			Cwd:    "/",
+			Args:   strings.Fields(args),
+			Env:    standardEnvironment,
+			User:   "1000:1000",
+			Stdin:  buffers.Stdin,
+			Stdout: buffers.Stdout,
+			Stderr: buffers.Stderr,
+		}
+		err = container.Run(pconfig)
+		ok(t, err)
+		waitProcess(pconfig, t)
+		if out := buffers.Stdout.String(); !strings.Contains(out, expected) {
+			t.Fatalf("expected %q to report %q, got %q", args, expected, out)
+		}
 	}
 }
 
-func TestPIDHost(t *testing.T) {
+func TestAmbientCapabilities(t *testing.T) {
 	if testing.Short() {
 		return
 	}
-
 	rootfs, err := newRootfs()
 	ok(t, err)
 	defer remove(rootfs)
 
-	l, err := os.Readlink("/proc/1/ns/pid")
-	ok(t, err)
-
 	config := newTemplateConfig(rootfs)
-	config.Namespaces.Remove(configs.NEWPID)
-	buffers, exitCode, err := runContainer(config, "", "readlink", "/proc/self/ns/pid")
+	config.Capabilities.Ambient = []string{"CAP_KILL"}
+	container, err := newContainer(config)
 	ok(t, err)
+	defer container.Destroy()
 
-	if exitCode != 0 {
-		t.Fatalf("exit code not 0. code %d stderr %q", exitCode, buffers.Stderr)
+	buffers := newStdBuffers()
+	pconfig := &libcontainer.Process{
+		Cwd:    "/",
+		Args:   []string{"cat", "/proc/self/status"},
+		Env:    standardEnvironment,
+		User:   "1000:1000",
+		Stdin:  buffers.Stdin,
+		Stdout: buffers.Stdout,
+		Stderr: buffers.Stderr,
 	}
+	err = container.Run(pconfig)
+	ok(t, err)
+	waitProcess(pconfig, t)
 
-	if actual := strings.Trim(buffers.Stdout.String(), "\n"); actual != l {
-		t.Fatalf("ipc link not equal to host link %q %q", actual, l)
+	// Only CAP_KILL, bit 5, is left to the user after the execve; the other
+	// capabilities kept without being ambient are lost.
+	out := buffers.Stdout.String()
+	for _, expected := range []string{"CapEff:\t0000000000000020", "CapAmb:\t0000000000000020"} {
+		if !strings.Contains(out, expected) {
+			t.Fatalf("expected the status to report %q, got %q", expected, out)
+		}
 	}
 }
 
-func TestInitJoinPID(t *testing.T) {
+func TestNoNewPrivileges(t *testing.T) {
 	if testing.Short() {
 		return
 	}
@@ -1518,221 +1973,508 @@ func TestInitJoinPID(t *testing.T) {
 	ok(t, err)
 	defer remove(rootfs)
 
-	// Execute a long-running container
-	container1, err := newContainer(newTemplateConfig(rootfs))
+	config := newTemplateConfig(rootfs)
+	for _, nnp := range []bool{false, true} {
+		config.NoNewPrivileges = nnp
+		buffers, exitCode, err := runContainer(config, "", "grep", "NoNewPrivs", "/proc/self/status")
+		ok(t, err)
+		if exitCode != 0 {
+			t.Fatalf("exit code not 0. code %d stderr %q", exitCode, buffers.Stderr)
+		}
+		expected := "NoNewPrivs:\t0"
+		if nnp {
+			expected = "NoNewPrivs:\t1"
+		}
+		if out := strings.TrimSpace(buffers.Stdout.String()); out != expected {
+			t.Fatalf("expected %q with NoNewPrivileges %v, got %q", expected, nnp, out)
+		}
+	}
+}
+
+func TestSecurebitsNoroot(t *testing.T) {
+	if testing.Short() {
+		return
+	}
+	rootfs, err := newRootfs()
 	ok(t, err)
-	defer container1.Destroy()
+	defer remove(rootfs)
 
-	stdinR1, stdinW1, err := os.Pipe()
+	// Without file capabilities uid 0 only keeps its ambient capabilities
+	// over the execve, which are none.
+	config := newTemplateConfig(rootfs)
+	config.Securebits = configs.SecbitNoroot
+	buffers, exitCode, err := runContainer(config, "", "grep", "-E", "^(Uid|CapEff)", "/proc/self/status")
 	ok(t, err)
-	init1 := &libcontainer.Process{
-		Cwd:   "/",
-		Args:  []string{"cat"},
-		Env:   standardEnvironment,
-		Stdin: stdinR1,
+	if exitCode != 0 {
+		t.Fatalf("exit code not 0. code %d stderr %q", exitCode, buffers.Stderr)
 	}
-	err = container1.Run(init1)
-	stdinR1.Close()
-	defer stdinW1.Close()
+	out := buffers.Stdout.String()
+	for _, expected := range []string{"Uid:\t0\t0\t0\t0", "CapEff:\t0000000000000000"} {
+		if !strings.Contains(out, expected) {
+			t.Fatalf("expected the status to report %q, got %q", expected, out)
+		}
+	}
+}
+
+func TestConsoleWritesReachTerminal(t *testing.T) {
+	if testing.Short() {
+		return
+	}
+	rootfs, err := newRootfs()
 	ok(t, err)
+	defer remove(rootfs)
 
-	// get the state of the first container
-	state1, err := container1.State()
+	config := newTemplateConfig(rootfs)
+	container, err := newContainer(config)
 	ok(t, err)
-	pidns1 := state1.NamespacePaths[configs.NEWPID]
+	defer container.Destroy()
 
-	// Run a container inside the existing pidns but with different cgroups
-	config2 := newTemplateConfig(rootfs)
-	config2.Namespaces.Add(configs.NEWPID, pidns1)
-	config2.Cgroups.Path = "integration/test2"
-	container2, err := newContainerWithName("testCT2", config2)
+	parent, child, err := utils.NewSockPair("console")
 	ok(t, err)
-	defer container2.Destroy()
+	defer parent.Close()
+	defer child.Close()
 
-	stdinR2, stdinW2, err := os.Pipe()
+	pconfig := &libcontainer.Process{
+		Cwd:           "/",
+		Args:          []string{"sh", "-c", "echo hello-console > /dev/console"},
+		Env:           standardEnvironment,
+		ConsoleSocket: child,
+	}
+	consoles := make(chan *os.File, 1)
+	go func() {
+		f, err := utils.RecvFd(parent)
+		if err != nil {
+			t.Log(err)
+		}
+		consoles <- f
+	}()
+	err = container.Run(pconfig)
 	ok(t, err)
-	init2 := &libcontainer.Process{
-		Cwd:   "/",
-		Args:  []string{"cat"},
-		Env:   standardEnvironment,
-		Stdin: stdinR2,
+	f := <-consoles
+	if f == nil {
+		t.Fatal("did not receive the console")
+	}
+	console := libcontainer.ConsoleFromFile(f)
+	defer console.Close()
+
+	var out bytes.Buffer
+	copied := make(chan struct{})
+	go func() {
+		io.Copy(&out, console)
+		close(copied)
+	}()
+	waitProcess(pconfig, t)
+	select {
+	case <-time.After(5 * time.Second):
+		t.Fatal("waiting for console output timed out")
+	case <-copied:
 	}
-	err = container2.Run(init2)
-	stdinR2.Close()
-	defer stdinW2.Close()
-	ok(t, err)
-	// get the state of the second container
-	state2, err := container2.State()
+	if !strings.Contains(out.String(), "hello-console") {
+		t.Fatalf("expected write to /dev/console to reach the terminal, got %q", out.String())
+	}
+}
+
+func TestConsoleSocketPath(t *testing.T) {
+	if testing.Short() {
+		return
+	}
+	rootfs, err := newRootfs()
 	ok(t, err)
+	defer remove(rootfs)
 
-	ns1, err := os.Readlink(fmt.Sprintf("/proc/%d/ns/pid", state1.InitProcessPid))
+	dir, err := ioutil.TempDir("", "console-socket")
 	ok(t, err)
-	ns2, err := os.Readlink(fmt.Sprintf("/proc/%d/ns/pid", state2.InitProcessPid))
+	defer os.RemoveAll(dir)
+	socketPath := filepath.Join(dir, "console.sock")
+	l, err := net.Listen("unix", socketPath)
 	ok(t, err)
-	if ns1 != ns2 {
-		t.Errorf("pidns(%s), wanted %s", ns2, ns1)
-	}
+	defer l.Close()
+	consoles := make(chan *os.File, 1)
+	go func() {
+		defer close(consoles)
+		conn, err := l.Accept()
+		if err != nil {
+			t.Log(err)
+			return
+		}
+		defer conn.Close()
+		socket, err := conn.(*net.UnixConn).File()
+		if err != nil {
+			t.Log(err)
+			return
+		}
+		defer socket.Close()
+		f, err := utils.RecvFd(socket)
+		if err != nil {
+			t.Log(err)
+			return
+		}
+		consoles <- f
+	}()
 
-	// check that namespaces are not the same
-	if reflect.DeepEqual(state2.NamespacePaths, state1.NamespacePaths) {
-		t.Errorf("Namespaces(%v), original %v", state2.NamespacePaths,
-			state1.NamespacePaths)
-	}
-	// check that pidns is joined correctly. The initial container process list
-	// should contain the second container's init process
-	buffers := newStdBuffers()
-	ps := &libcontainer.Process{
-		Cwd:    "/",
-		Args:   []string{"ps"},
-		Env:    standardEnvironment,
-		Stdout: buffers.Stdout,
-	}
-	err = container1.Run(ps)
+	container, err := newContainer(newTemplateConfig(rootfs))
 	ok(t, err)
-	waitProcess(ps, t)
-
-	// Stop init processes one by one. Stop the second container should
-	// not stop the first.
-	stdinW2.Close()
-	waitProcess(init2, t)
-	stdinW1.Close()
-	waitProcess(init1, t)
+	defer container.Destroy()
 
-	out := strings.TrimSpace(buffers.Stdout.String())
-	// output of ps inside the initial PID namespace should have
-	// 1 line of header,
-	// 2 lines of init processes,
-	// 1 line of ps process
-	if len(strings.Split(out, "\n")) != 4 {
-		t.Errorf("unexpected running process, output %q", out)
+	pconfig := &libcontainer.Process{
+		Cwd:               "/",
+		Args:              []string{"echo", "hello-socket"},
+		Env:               standardEnvironment,
+		ConsoleSocketPath: socketPath,
+	}
+	ok(t, container.Run(pconfig))
+	f := <-consoles
+	if f == nil {
+		t.Fatal("did not receive the console over the socket")
+	}
+	console := libcontainer.ConsoleFromFile(f)
+	defer console.Close()
+
+	var out bytes.Buffer
+	copied := make(chan struct{})
+	go func() {
+		io.Copy(&out, console)
+		close(copied)
+	}()
+	waitProcess(pconfig, t)
+	select {
+	case <-time.After(5 * time.Second):
+		t.Fatal("waiting for console output timed out")
+	case <-copied:
+	}
+	if !strings.Contains(out.String(), "hello-socket") {
+		t.Fatalf("expected the output on the received console, got %q", out.String())
 	}
 }
 
-func TestInitJoinNetworkAndUser(t *testing.T) {
-	if _, err := os.Stat("/proc/self/ns/user"); os.IsNotExist(err) {
-		t.Skip("userns is unsupported")
-	}
+func TestConsoleAttach(t *testing.T) {
 	if testing.Short() {
 		return
 	}
+	root, err := newTestRoot()
+	ok(t, err)
+	defer os.RemoveAll(root)
 	rootfs, err := newRootfs()
 	ok(t, err)
 	defer remove(rootfs)
 
-	// Execute a long-running container
-	config1 := newTemplateConfig(rootfs)
-	config1.UidMappings = []configs.IDMap{{HostID: 0, ContainerID: 0, Size: 1000}}
-	config1.GidMappings = []configs.IDMap{{HostID: 0, ContainerID: 0, Size: 1000}}
-	config1.Namespaces = append(config1.Namespaces, configs.Namespace{Type: configs.NEWUSER})
-	container1, err := newContainer(config1)
+	factory, err := libcontainer.New(root, libcontainer.Cgroupfs)
 	ok(t, err)
-	defer container1.Destroy()
+	container, err := factory.Create("test", newTemplateConfig(rootfs))
+	ok(t, err)
+	defer container.Destroy()
 
-	stdinR1, stdinW1, err := os.Pipe()
+	parent, child, err := utils.NewSockPair("console")
 	ok(t, err)
-	init1 := &libcontainer.Process{
-		Cwd:   "/",
-		Args:  []string{"cat"},
-		Env:   standardEnvironment,
-		Stdin: stdinR1,
+	defer parent.Close()
+	defer child.Close()
+	pconfig := &libcontainer.Process{
+		Cwd:           "/",
+		Args:          []string{"cat"},
+		Env:           standardEnvironment,
+		ConsoleSocket: child,
+	}
+	consoles := make(chan *os.File, 1)
+	go func() {
+		f, err := utils.RecvFd(parent)
+		if err != nil {
+			t.Log(err)
+		}
+		consoles <- f
+	}()
+	ok(t, container.Run(pconfig))
+	defer pconfig.Signal(syscall.SIGKILL)
+	master := <-consoles
+	if master == nil {
+		t.Fatal("did not receive the console")
+	}
+	defer master.Close()
+	stop, err := container.ServeConsole(master)
+	ok(t, err)
+	defer stop()
+
+	// A later caller loads the container and attaches to its console.
+	loaded, err := factory.Load("test")
+	ok(t, err)
+	attached, err := loaded.Attach()
+	ok(t, err)
+	console := libcontainer.ConsoleFromFile(attached)
+	defer console.Close()
+	_, err = console.Write([]byte("hello-attach\n"))
+	ok(t, err)
+
+	lines := make(chan string)
+	go func() {
+		s := bufio.NewScanner(console)
+		for s.Scan() {
+			lines <- s.Text()
+		}
+		close(lines)
+	}()
+	// The terminal echoes the input before cat copies it.
+	for seen := 0; seen < 2; seen++ {
+		select {
+		case line := <-lines:
+			if strings.TrimSpace(line) != "hello-attach" {
+				t.Fatalf("expected hello-attach on the attached console, got %q", line)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("waiting for console output timed out")
+		}
 	}
-	err = container1.Run(init1)
-	stdinR1.Close()
-	defer stdinW1.Close()
+}
+
+func TestPrivateDevpts(t *testing.T) {
+	if testing.Short() {
+		return
+	}
+	rootfs, err := newRootfs()
 	ok(t, err)
+	defer remove(rootfs)
 
-	// get the state of the first container
-	state1, err := container1.State()
+	config := newTemplateConfig(rootfs)
+	var mounts []*configs.Mount
+	for _, m := range config.Mounts {
+		if m.Destination != "/dev/pts" {
+			mounts = append(mounts, m)
+		}
+	}
+	config.Mounts = mounts
+
+	// A fresh devpts instance holds nothing but its ptmx, whatever ptys the
+	// host has open, and /dev/ptmx allocates from that instance.
+	buffers, exitCode, err := runContainer(config, "", "sh", "-c", "ls /dev/pts; readlink /dev/ptmx")
 	ok(t, err)
-	netns1 := state1.NamespacePaths[configs.NEWNET]
-	userns1 := state1.NamespacePaths[configs.NEWUSER]
+	if exitCode != 0 {
+		t.Fatalf("exit code not 0. code %d stderr %q", exitCode, buffers.Stderr)
+	}
+	if out := strings.Fields(buffers.Stdout.String()); !reflect.DeepEqual(out, []string{"ptmx", "pts/ptmx"}) {
+		t.Fatalf("expected a private devpts instance, got %q", buffers.Stdout.String())
+	}
+}
 
-	// Run a container inside the existing pidns but with different cgroups
-	rootfs2, err := newRootfs()
+func TestBindMountNoexec(t *testing.T) {
+	if testing.Short() {
+		return
+	}
+	rootfs, err := newRootfs()
 	ok(t, err)
-	defer remove(rootfs2)
+	defer remove(rootfs)
 
-	config2 := newTemplateConfig(rootfs2)
-	config2.UidMappings = []configs.IDMap{{HostID: 0, ContainerID: 0, Size: 1000}}
-	config2.GidMappings = []configs.IDMap{{HostID: 0, ContainerID: 0, Size: 1000}}
-	config2.Namespaces.Add(configs.NEWNET, netns1)
-	config2.Namespaces.Add(configs.NEWUSER, userns1)
-	config2.Cgroups.Path = "integration/test2"
-	container2, err := newContainerWithName("testCT2", config2)
+	dir, err := ioutil.TempDir("", "noexec")
 	ok(t, err)
-	defer container2.Destroy()
+	defer os.RemoveAll(dir)
+	data, err := ioutil.ReadFile(filepath.Join(rootfs, "bin/busybox"))
+	ok(t, err)
+	ok(t, ioutil.WriteFile(filepath.Join(dir, "busybox"), data, 0755))
 
-	stdinR2, stdinW2, err := os.Pipe()
+	config := newTemplateConfig(rootfs)
+	config.Mounts = append(config.Mounts, &configs.Mount{
+		Source:      dir,
+		Destination: "/noexec",
+		Device:      "bind",
+		Flags:       syscall.MS_BIND | syscall.MS_NOEXEC | syscall.MS_NOSUID | syscall.MS_NODEV,
+	})
+
+	// The shell reports EACCES from execve(2) as 126.
+	buffers, exitCode, err := runContainer(config, "", "sh", "-c", "/noexec/busybox true")
 	ok(t, err)
-	init2 := &libcontainer.Process{
-		Cwd:   "/",
-		Args:  []string{"cat"},
-		Env:   standardEnvironment,
-		Stdin: stdinR2,
+	if exitCode != 126 || !strings.Contains(buffers.Stderr.String(), "Permission denied") {
+		t.Fatalf("expected exec on a noexec bind mount to be denied, got code %d stderr %q", exitCode, buffers.Stderr)
 	}
-	err = container2.Run(init2)
-	stdinR2.Close()
-	defer stdinW2.Close()
+}
+
+func TestProxyArp(t *testing.T) {
+	if testing.Short() {
+		return
+	}
+	rootfs, err := newRootfs()
 	ok(t, err)
+	defer remove(rootfs)
 
-	// get the state of the second container
-	state2, err := container2.State()
+	config := newTemplateConfig(rootfs)
+	config.Networks[0].ProxyArp = true
+	config.Networks[0].ArpIgnore = 1
+
+	buffers, exitCode, err := runContainer(config, "", "cat", "/proc/sys/net/ipv4/conf/lo/proxy_arp", "/proc/sys/net/ipv4/conf/lo/arp_ignore")
 	ok(t, err)
+	if exitCode != 0 {
+		t.Fatalf("exit code not 0. code %d stderr %q", exitCode, buffers.Stderr)
+	}
+	if out := strings.Fields(buffers.Stdout.String()); !reflect.DeepEqual(out, []string{"1", "1"}) {
+		t.Fatalf("expected proxy_arp and arp_ignore to be 1, got %q", buffers.Stdout.String())
+	}
+}
 
-	for _, ns := range []string{"net", "user"} {
-		ns1, err := os.Readlink(fmt.Sprintf("/proc/%d/ns/%s", state1.InitProcessPid, ns))
-		ok(t, err)
-		ns2, err := os.Readlink(fmt.Sprintf("/proc/%d/ns/%s", state2.InitProcessPid, ns))
-		ok(t, err)
-		if ns1 != ns2 {
-			t.Errorf("%s(%s), wanted %s", ns, ns2, ns1)
-		}
+func TestNetworkReady(t *testing.T) {
+	if testing.Short() {
+		return
 	}
+	rootfs, err := newRootfs()
+	ok(t, err)
+	defer remove(rootfs)
 
-	// check that namespaces are not the same
-	if reflect.DeepEqual(state2.NamespacePaths, state1.NamespacePaths) {
-		t.Errorf("Namespaces(%v), original %v", state2.NamespacePaths,
-			state1.NamespacePaths)
+	// The hook returns at once and signals the network ready later, after
+	// it "configured" the network by creating a file in the rootfs.
+	config := newTemplateConfig(rootfs)
+	config.NetworkReadyTimeout = 10 * time.Second
+	config.Hooks = &configs.Hooks{
+		Prestart: []configs.Hook{
+			configs.NewFunctionHook(func(s configs.HookState) error {
+				fifo := s.Annotations[configs.NetworkReadyAnnotation]
+				if fifo == "" {
+					return fmt.Errorf("no network ready fifo in the hook state")
+				}
+				go func() {
+					time.Sleep(500 * time.Millisecond)
+					if err := ioutil.WriteFile(filepath.Join(rootfs, "network-ready"), []byte("ready"), 0644); err != nil {
+						t.Error(err)
+					}
+					f, err := os.OpenFile(fifo, os.O_WRONLY, 0)
+					if err != nil {
+						t.Error(err)
+						return
+					}
+					f.Write([]byte("ready"))
+					f.Close()
+				}()
+				return nil
+			}),
+		},
+	}
+	buffers, exitCode, err := runContainer(config, "", "cat", "/network-ready")
+	ok(t, err)
+	if exitCode != 0 {
+		t.Fatalf("expected the process to start after the network was ready, exit code %d stderr %q", exitCode, buffers.Stderr)
+	}
+	if out := buffers.Stdout.String(); out != "ready" {
+		t.Fatalf("expected the file of the hook, got %q", out)
+	}
+
+	// A hook may also signal the network ready before it returns.
+	config.Hooks = &configs.Hooks{
+		Prestart: []configs.Hook{
+			configs.NewFunctionHook(func(s configs.HookState) error {
+				if err := ioutil.WriteFile(filepath.Join(rootfs, "network-ready"), []byte("synchronous"), 0644); err != nil {
+					return err
+				}
+				return ioutil.WriteFile(s.Annotations[configs.NetworkReadyAnnotation], []byte("ready"), 0)
+			}),
+		},
+	}
+	buffers, exitCode, err = runContainer(config, "", "cat", "/network-ready")
+	ok(t, err)
+	if exitCode != 0 {
+		t.Fatalf("expected the process to start after a synchronous signal, exit code %d stderr %q", exitCode, buffers.Stderr)
+	}
+	if out := buffers.Stdout.String(); out != "synchronous" {
+		t.Fatalf("expected the file of the synchronous hook, got %q", out)
+	}
+
+	// Without the signal the start fails.
+	config.Hooks = nil
+	config.NetworkReadyTimeout = 500 * time.Millisecond
+	container, err := newContainerWithName("test-network-ready", config)
+	ok(t, err)
+	defer container.Destroy()
+	process := &libcontainer.Process{
+		Cwd:  "/",
+		Args: []string{"true"},
+		Env:  standardEnvironment,
+	}
+	if err := container.Run(process); err == nil {
+		t.Fatal("expected the start to fail without the network signalled ready")
 	}
-	// Stop init processes one by one. Stop the second container should
-	// not stop the first.
-	stdinW2.Close()
-	waitProcess(init2, t)
-	stdinW1.Close()
-	waitProcess(init1, t)
 }
 
-func TestTmpfsCopyUp(t *testing.T) {
+func TestHook(t *testing.T) {
 	if testing.Short() {
 		return
 	}
-	root, err := newTestRoot()
+
+	bundle, err := newTestBundle()
 	ok(t, err)
-	defer os.RemoveAll(root)
+	defer remove(bundle)
 
 	rootfs, err := newRootfs()
 	ok(t, err)
 	defer remove(rootfs)
 
 	config := newTemplateConfig(rootfs)
+	expectedBundle := bundle
+	config.Labels = append(config.Labels, fmt.Sprintf("bundle=%s", expectedBundle))
 
-	config.Mounts = append(config.Mounts, &configs.Mount{
-		Source:      "tmpfs",
-		Destination: "/etc",
-		Device:      "tmpfs",
-		Extensions:  configs.EXT_COPYUP,
-	})
+	getRootfsFromBundle := func(bundle string) (string, error) {
+		f, err := os.Open(filepath.Join(bundle, "config.json"))
+		if err != nil {
+			return "", err
+		}
 
-	factory, err := libcontainer.New(root, libcontainer.Cgroupfs)
+		var config configs.Config
+		if err = json.NewDecoder(f).Decode(&config); err != nil {
+			return "", err
+		}
+		return config.Rootfs, nil
+	}
+
+	config.Hooks = &configs.Hooks{
+		Prestart: []configs.Hook{
+			configs.NewFunctionHook(func(s configs.HookState) error {
+				if s.Bundle != expectedBundle {
+					t.Fatalf("Expected prestart hook bundlePath '%s'; got '%s'", expectedBundle, s.Bundle)
+				}
+
+				root, err := getRootfsFromBundle(s.Bundle)
+				if err != nil {
+					return err
+				}
+				f, err := os.Create(filepath.Join(root, "test"))
+				if err != nil {
+					return err
+				}
+				return f.Close()
+			}),
+		},
+		Poststart: []configs.Hook{
+			configs.NewFunctionHook(func(s configs.HookState) error {
+				if s.Bundle != expectedBundle {
+					t.Fatalf("Expected poststart hook bundlePath '%s'; got '%s'", expectedBundle, s.Bundle)
+				}
+
+				root, err := getRootfsFromBundle(s.Bundle)
+				if err != nil {
+					return err
+				}
+				return ioutil.WriteFile(filepath.Join(root, "test"), []byte("hello world"), 0755)
+			}),
+		},
+		Poststop: []configs.Hook{
+			configs.NewFunctionHook(func(s configs.HookState) error {
+				if s.Bundle != expectedBundle {
+					t.Fatalf("Expected poststop hook bundlePath '%s'; got '%s'", expectedBundle, s.Bundle)
+				}
+
+				root, err := getRootfsFromBundle(s.Bundle)
+				if err != nil {
+					return err
+				}
+				return os.RemoveAll(filepath.Join(root, "test"))
+			}),
+		},
+	}
+
+	// write config of json format into config.json under bundle
+	f, err := os.OpenFile(filepath.Join(bundle, "config.json"), os.O_CREATE|os.O_RDWR, 0644)
 	ok(t, err)
+	ok(t, json.NewEncoder(f).Encode(config))
 
 	container, err := factory.Create("test", config)
 	ok(t, err)
-	defer container.Destroy()
 
 	var stdout bytes.Buffer
 	pconfig := libcontainer.Process{
-		Args:   []string{"ls", "/etc/passwd"},
+		Cwd:    "/",
+		Args:   []string{"sh", "-c", "ls /test"},
 		Env:    standardEnvironment,
 		Stdin:  nil,
 		Stdout: &stdout,
@@ -1745,8 +2487,2027 @@ func TestTmpfsCopyUp(t *testing.T) {
 
 	outputLs := string(stdout.Bytes())
 
-	// Check that the ls output has /etc/passwd
-	if !strings.Contains(outputLs, "/etc/passwd") {
-		t.Fatalf("/etc/passwd not copied up as expected: %v", outputLs)
+	// Check that the ls output has the expected file touched by the prestart hook
+	if !strings.Contains(outputLs, "/test") {
+		container.Destroy()
+		t.Fatalf("ls output doesn't have the expected file: %s", outputLs)
+	}
+
+	// Check that the file is written by the poststart hook
+	testFilePath := filepath.Join(rootfs, "test")
+	contents, err := ioutil.ReadFile(testFilePath)
+	if err != nil {
+		t.Fatalf("cannot read file '%s': %s", testFilePath, err)
+	}
+	if string(contents) != "hello world" {
+		t.Fatalf("Expected test file to contain 'hello world'; got '%s'", string(contents))
+	}
+
+	if err := container.Destroy(); err != nil {
+		t.Fatalf("container destroy %s", err)
+	}
+	fi, err := os.Stat(filepath.Join(rootfs, "test"))
+	if err == nil || !os.IsNotExist(err) {
+		t.Fatalf("expected file to not exist, got %s", fi.Name())
+	}
+}
+
+func TestSTDIOPermissions(t *testing.T) {
+	if testing.Short() {
+		return
+	}
+
+	rootfs, err := newRootfs()
+	ok(t, err)
+	defer remove(rootfs)
+	config := newTemplateConfig(rootfs)
+	buffers, exitCode, err := runContainer(config, "", "sh", "-c", "echo hi > /dev/stderr")
+	ok(t, err)
+	if exitCode != 0 {
+		t.Fatalf("exit code not 0. code %d stderr %q", exitCode, buffers.Stderr)
+	}
+
+	if actual := strings.Trim(buffers.Stderr.String(), "\n"); actual != "hi" {
+		t.Fatalf("stderr should equal be equal %q %q", actual, "hi")
+	}
+}
+
+func unmountOp(path string) error {
+	if err := syscall.Unmount(path, syscall.MNT_DETACH); err != nil {
+		return err
+	}
+	return nil
+}
+
+// Launch container with rootfsPropagation in rslave mode. Also
+// bind mount a volume /mnt1host at /mnt1cont at the time of launch. Now do
+// another mount on host (/mnt1host/mnt2host) and this new mount should
+// propagate to container (/mnt1cont/mnt2host)
+func TestRootfsPropagationSlaveMount(t *testing.T) {
+	var mountPropagated bool
+	var dir1cont string
+	var dir2cont string
+
+	dir1cont = "/root/mnt1cont"
+
+	if testing.Short() {
+		return
+	}
+	rootfs, err := newRootfs()
+	ok(t, err)
+	defer remove(rootfs)
+	config := newTemplateConfig(rootfs)
+
+	config.RootPropagation = syscall.MS_SLAVE | syscall.MS_REC
+
+	// Bind mount a volume
+	dir1host, err := ioutil.TempDir("", "mnt1host")
+	ok(t, err)
+	defer os.RemoveAll(dir1host)
+
+	// Make this dir a "shared" mount point. This will make sure a
+	// slave relationship can be established in container.
+	err = syscall.Mount(dir1host, dir1host, "bind", syscall.MS_BIND|syscall.MS_REC, "")
+	ok(t, err)
+	err = syscall.Mount("", dir1host, "", syscall.MS_SHARED|syscall.MS_REC, "")
+	ok(t, err)
+	defer unmountOp(dir1host)
+
+	config.Mounts = append(config.Mounts, &configs.Mount{
+		Source:      dir1host,
+		Destination: dir1cont,
+		Device:      "bind",
+		Flags:       syscall.MS_BIND | syscall.MS_REC})
+
+	// TODO: systemd specific processing
+	f := factory
+
+	container, err := f.Create("testSlaveMount", config)
+	ok(t, err)
+	defer container.Destroy()
+
+	stdinR, stdinW, err := os.Pipe()
+	ok(t, err)
+
+	pconfig := &libcontainer.Process{
+		Cwd:   "/",
+		Args:  []string{"cat"},
+		Env:   standardEnvironment,
+		Stdin: stdinR,
+	}
+
+	err = container.Run(pconfig)
+	stdinR.Close()
+	defer stdinW.Close()
+	ok(t, err)
+
+	// Create mnt1host/mnt2host and bind mount itself on top of it. This
+	// should be visible in container.
+	dir2host, err := ioutil.TempDir(dir1host, "mnt2host")
+	ok(t, err)
+	defer os.RemoveAll(dir2host)
+
+	err = syscall.Mount(dir2host, dir2host, "bind", syscall.MS_BIND, "")
+	defer unmountOp(dir2host)
+	ok(t, err)
+
+	// Run "cat /proc/self/mountinfo" in container and look at mount points.
+	var stdout2 bytes.Buffer
+
+	stdinR2, stdinW2, err := os.Pipe()
+	ok(t, err)
+
+	pconfig2 := &libcontainer.Process{
+		Cwd:    "/",
+		Args:   []string{"cat", "/proc/self/mountinfo"},
+		Env:    standardEnvironment,
+		Stdin:  stdinR2,
+		Stdout: &stdout2,
+	}
+
+	err = container.Run(pconfig2)
+	stdinR2.Close()
+	defer stdinW2.Close()
+	ok(t, err)
+
+	stdinW2.Close()
+	waitProcess(pconfig2, t)
+	stdinW.Close()
+	waitProcess(pconfig, t)
+
+	mountPropagated = false
+	dir2cont = filepath.Join(dir1cont, filepath.Base(dir2host))
+
+	propagationInfo := string(stdout2.Bytes())
+	lines := strings.Split(propagationInfo, "\n")
+	for _, l := range lines {
+		linefields := strings.Split(l, " ")
+		if len(linefields) < 5 {
+			continue
+		}
+
+		if linefields[4] == dir2cont {
+			mountPropagated = true
+			break
+		}
+	}
+
+	if mountPropagated != true {
+		t.Fatalf("Mount on host %s did not propagate in container at %s\n", dir2host, dir2cont)
+	}
+}
+
+// Launch container with rootfsPropagation 0 so no propagation flags are
+// applied. Also bind mount a volume /mnt1host at /mnt1cont at the time of
+// launch. Now do a mount in container (/mnt1cont/mnt2cont) and this new
+// mount should propagate to host (/mnt1host/mnt2cont)
+
+func TestRootfsPropagationSharedMount(t *testing.T) {
+	var dir1cont string
+	var dir2cont string
+
+	dir1cont = "/root/mnt1cont"
+
+	if testing.Short() {
+		return
+	}
+	rootfs, err := newRootfs()
+	ok(t, err)
+	defer remove(rootfs)
+	config := newTemplateConfig(rootfs)
+	config.RootPropagation = syscall.MS_PRIVATE
+
+	// Bind mount a volume
+	dir1host, err := ioutil.TempDir("", "mnt1host")
+	ok(t, err)
+	defer os.RemoveAll(dir1host)
+
+	// Make this dir a "shared" mount point. This will make sure a
+	// shared relationship can be established in container.
+	err = syscall.Mount(dir1host, dir1host, "bind", syscall.MS_BIND|syscall.MS_REC, "")
+	ok(t, err)
+	err = syscall.Mount("", dir1host, "", syscall.MS_SHARED|syscall.MS_REC, "")
+	ok(t, err)
+	defer unmountOp(dir1host)
+
+	config.Mounts = append(config.Mounts, &configs.Mount{
+		Source:      dir1host,
+		Destination: dir1cont,
+		Device:      "bind",
+		Flags:       syscall.MS_BIND | syscall.MS_REC})
+
+	// TODO: systemd specific processing
+	f := factory
+
+	container, err := f.Create("testSharedMount", config)
+	ok(t, err)
+	defer container.Destroy()
+
+	stdinR, stdinW, err := os.Pipe()
+	ok(t, err)
+
+	pconfig := &libcontainer.Process{
+		Cwd:   "/",
+		Args:  []string{"cat"},
+		Env:   standardEnvironment,
+		Stdin: stdinR,
+	}
+
+	err = container.Run(pconfig)
+	stdinR.Close()
+	defer stdinW.Close()
+	ok(t, err)
+
+	// Create mnt1host/mnt2cont.  This will become visible inside container
+	// at mnt1cont/mnt2cont. Bind mount itself on top of it. This
+	// should be visible on host now.
+	dir2host, err := ioutil.TempDir(dir1host, "mnt2cont")
+	ok(t, err)
+	defer os.RemoveAll(dir2host)
+
+	dir2cont = filepath.Join(dir1cont, filepath.Base(dir2host))
+
+	// Mount something in container and see if it is visible on host.
+	var stdout2 bytes.Buffer
+
+	stdinR2, stdinW2, err := os.Pipe()
+	ok(t, err)
+
+	pconfig2 := &libcontainer.Process{
+		Cwd:          "/",
+		Args:         []string{"mount", "--bind", dir2cont, dir2cont},
+		Env:          standardEnvironment,
+		Stdin:        stdinR2,
+		Stdout:       &stdout2,
+		Capabilities: &configs.Capabilities{},
+	}
+
+	// Provide CAP_SYS_ADMIN
+	pconfig2.Capabilities.Bounding = append(config.Capabilities.Bounding, "CAP_SYS_ADMIN")
+	pconfig2.Capabilities.Permitted = append(config.Capabilities.Permitted, "CAP_SYS_ADMIN")
+	pconfig2.Capabilities.Effective = append(config.Capabilities.Effective, "CAP_SYS_ADMIN")
+	pconfig2.Capabilities.Inheritable = append(config.Capabilities.Inheritable, "CAP_SYS_ADMIN")
+
+	err = container.Run(pconfig2)
+	stdinR2.Close()
+	defer stdinW2.Close()
+	ok(t, err)
+
+	// Wait for process
+	stdinW2.Close()
+	waitProcess(pconfig2, t)
+	stdinW.Close()
+	waitProcess(pconfig, t)
+
+	defer unmountOp(dir2host)
+
+	// Check if mount is visible on host or not.
+	out, err := exec.Command("findmnt", "-n", "-f", "-oTARGET", dir2host).CombinedOutput()
+	outtrim := strings.TrimSpace(string(out))
+	if err != nil {
+		t.Logf("findmnt error %q: %q", err, outtrim)
+	}
+
+	if string(outtrim) != dir2host {
+		t.Fatalf("Mount in container on %s did not propagate to host on %s. finmnt output=%s", dir2cont, dir2host, outtrim)
+	}
+}
+
+func TestPIDHost(t *testing.T) {
+	if testing.Short() {
+		return
+	}
+
+	rootfs, err := newRootfs()
+	ok(t, err)
+	defer remove(rootfs)
+
+	l, err := os.Readlink("/proc/1/ns/pid")
+	ok(t, err)
+
+	config := newTemplateConfig(rootfs)
+	config.Namespaces.Remove(configs.NEWPID)
+	buffers, exitCode, err := runContainer(config, "", "readlink", "/proc/self/ns/pid")
+	ok(t, err)
+
+	if exitCode != 0 {
+		t.Fatalf("exit code not 0. code %d stderr %q", exitCode, buffers.Stderr)
+	}
+
+	if actual := strings.Trim(buffers.Stdout.String(), "\n"); actual != l {
+		t.Fatalf("ipc link not equal to host link %q %q", actual, l)
+	}
+}
+
+func TestInitJoinPID(t *testing.T) {
+	if testing.Short() {
+		return
+	}
+	rootfs, err := newRootfs()
+	ok(t, err)
+	defer remove(rootfs)
+
+	// Execute a long-running container
+	container1, err := newContainer(newTemplateConfig(rootfs))
+	ok(t, err)
+	defer container1.Destroy()
+
+	stdinR1, stdinW1, err := os.Pipe()
+	ok(t, err)
+	init1 := &libcontainer.Process{
+		Cwd:   "/",
+		Args:  []string{"cat"},
+		Env:   standardEnvironment,
+		Stdin: stdinR1,
+	}
+	err = container1.Run(init1)
+	stdinR1.Close()
+	defer stdinW1.Close()
+	ok(t, err)
+
+	// get the state of the first container
+	state1, err := container1.State()
+	ok(t, err)
+	pidns1 := state1.NamespacePaths[configs.NEWPID]
+
+	// Run a container inside the existing pidns but with different cgroups
+	config2 := newTemplateConfig(rootfs)
+	config2.Namespaces.Add(configs.NEWPID, pidns1)
+	config2.Cgroups.Path = "integration/test2"
+	container2, err := newContainerWithName("testCT2", config2)
+	ok(t, err)
+	defer container2.Destroy()
+
+	stdinR2, stdinW2, err := os.Pipe()
+	ok(t, err)
+	init2 := &libcontainer.Process{
+		Cwd:   "/",
+		Args:  []string{"cat"},
+		Env:   standardEnvironment,
+		Stdin: stdinR2,
+	}
+	err = container2.Run(init2)
+	stdinR2.Close()
+	defer stdinW2.Close()
+	ok(t, err)
+	// get the state of the second container
+	state2, err := container2.State()
+	ok(t, err)
+
+	ns1, err := os.Readlink(fmt.Sprintf("/proc/%d/ns/pid", state1.InitProcessPid))
+	ok(t, err)
+	ns2, err := os.Readlink(fmt.Sprintf("/proc/%d/ns/pid", state2.InitProcessPid))
+	ok(t, err)
+	if ns1 != ns2 {
+		t.Errorf("pidns(%s), wanted %s", ns2, ns1)
+	}
+
+	// check that namespaces are not the same
+	if reflect.DeepEqual(state2.NamespacePaths, state1.NamespacePaths) {
+		t.Errorf("Namespaces(%v), original %v", state2.NamespacePaths,
+			state1.NamespacePaths)
+	}
+	// check that pidns is joined correctly. The initial container process list
+	// should contain the second container's init process
+	buffers := newStdBuffers()
+	ps := &libcontainer.Process{
+		Cwd:    "/",
+		Args:   []string{"ps"},
+		Env:    standardEnvironment,
+		Stdout: buffers.Stdout,
+	}
+	err = container1.Run(ps)
+	ok(t, err)
+	waitProcess(ps, t)
+
+	// Stop init processes one by one. Stop the second container should
+	// not stop the first.
+	stdinW2.Close()
+	waitProcess(init2, t)
+	stdinW1.Close()
+	waitProcess(init1, t)
+
+	out := strings.TrimSpace(buffers.Stdout.String())
+	// output of ps inside the initial PID namespace should have
+	// 1 line of header,
+	// 2 lines of init processes,
+	// 1 line of ps process
+	if len(strings.Split(out, "\n")) != 4 {
+		t.Errorf("unexpected running process, output %q", out)
+	}
+}
+
+func TestInitJoinNetworkAndUser(t *testing.T) {
+	if _, err := os.Stat("/proc/self/ns/user"); os.IsNotExist(err) {
+		t.Skip("userns is unsupported")
+	}
+	if testing.Short() {
+		return
+	}
+	rootfs, err := newRootfs()
+	ok(t, err)
+	defer remove(rootfs)
+
+	// Execute a long-running container
+	config1 := newTemplateConfig(rootfs)
+	config1.UidMappings = []configs.IDMap{{HostID: 0, ContainerID: 0, Size: 1000}}
+	config1.GidMappings = []configs.IDMap{{HostID: 0, ContainerID: 0, Size: 1000}}
+	config1.Namespaces = append(config1.Namespaces, configs.Namespace{Type: configs.NEWUSER})
+	container1, err := newContainer(config1)
+	ok(t, err)
+	defer container1.Destroy()
+
+	stdinR1, stdinW1, err := os.Pipe()
+	ok(t, err)
+	init1 := &libcontainer.Process{
+		Cwd:   "/",
+		Args:  []string{"cat"},
+		Env:   standardEnvironment,
+		Stdin: stdinR1,
+	}
+	err = container1.Run(init1)
+	stdinR1.Close()
+	defer stdinW1.Close()
+	ok(t, err)
+
+	// get the state of the first container
+	state1, err := container1.State()
+	ok(t, err)
+	netns1 := state1.NamespacePaths[configs.NEWNET]
+	userns1 := state1.NamespacePaths[configs.NEWUSER]
+
+	// Run a container inside the existing pidns but with different cgroups
+	rootfs2, err := newRootfs()
+	ok(t, err)
+	defer remove(rootfs2)
+
+	config2 := newTemplateConfig(rootfs2)
+	config2.UidMappings = []configs.IDMap{{HostID: 0, ContainerID: 0, Size: 1000}}
+	config2.GidMappings = []configs.IDMap{{HostID: 0, ContainerID: 0, Size: 1000}}
+	config2.Namespaces.Add(configs.NEWNET, netns1)
+	config2.Namespaces.Add(configs.NEWUSER, userns1)
+	config2.Cgroups.Path = "integration/test2"
+	container2, err := newContainerWithName("testCT2", config2)
+	ok(t, err)
+	defer container2.Destroy()
+
+	stdinR2, stdinW2, err := os.Pipe()
+	ok(t, err)
+	init2 := &libcontainer.Process{
+		Cwd:   "/",
+		Args:  []string{"cat"},
+		Env:   standardEnvironment,
+		Stdin: stdinR2,
+	}
+	err = container2.Run(init2)
+	stdinR2.Close()
+	defer stdinW2.Close()
+	ok(t, err)
+
+	// get the state of the second container
+	state2, err := container2.State()
+	ok(t, err)
+
+	for _, ns := range []string{"net", "user"} {
+		ns1, err := os.Readlink(fmt.Sprintf("/proc/%d/ns/%s", state1.InitProcessPid, ns))
+		ok(t, err)
+		ns2, err := os.Readlink(fmt.Sprintf("/proc/%d/ns/%s", state2.InitProcessPid, ns))
+		ok(t, err)
+		if ns1 != ns2 {
+			t.Errorf("%s(%s), wanted %s", ns, ns2, ns1)
+		}
+	}
+
+	// check that namespaces are not the same
+	if reflect.DeepEqual(state2.NamespacePaths, state1.NamespacePaths) {
+		t.Errorf("Namespaces(%v), original %v", state2.NamespacePaths,
+			state1.NamespacePaths)
+	}
+	// Stop init processes one by one. Stop the second container should
+	// not stop the first.
+	stdinW2.Close()
+	waitProcess(init2, t)
+	stdinW1.Close()
+	waitProcess(init1, t)
+}
+
+func TestTmpfsCopyUp(t *testing.T) {
+	if testing.Short() {
+		return
+	}
+	root, err := newTestRoot()
+	ok(t, err)
+	defer os.RemoveAll(root)
+
+	rootfs, err := newRootfs()
+	ok(t, err)
+	defer remove(rootfs)
+
+	config := newTemplateConfig(rootfs)
+
+	config.Mounts = append(config.Mounts, &configs.Mount{
+		Source:      "tmpfs",
+		Destination: "/etc",
+		Device:      "tmpfs",
+		Extensions:  configs.EXT_COPYUP,
+	})
+
+	factory, err := libcontainer.New(root, libcontainer.Cgroupfs)
+	ok(t, err)
+
+	container, err := factory.Create("test", config)
+	ok(t, err)
+	defer container.Destroy()
+
+	var stdout bytes.Buffer
+	pconfig := libcontainer.Process{
+		Args:   []string{"ls", "/etc/passwd"},
+		Env:    standardEnvironment,
+		Stdin:  nil,
+		Stdout: &stdout,
+	}
+	err = container.Run(&pconfig)
+	ok(t, err)
+
+	// Wait for process
+	waitProcess(&pconfig, t)
+
+	outputLs := string(stdout.Bytes())
+
+	// Check that the ls output has /etc/passwd
+	if !strings.Contains(outputLs, "/etc/passwd") {
+		t.Fatalf("/etc/passwd not copied up as expected: %v", outputLs)
+	}
+}
+
+func TestSocketBuffers(t *testing.T) {
+	if testing.Short() {
+		return
+	}
+	rootfs, err := newRootfs()
+	ok(t, err)
+	defer remove(rootfs)
+
+	config := newTemplateConfig(rootfs)
+	config.SocketBuffers = &configs.SocketBuffers{
+		RmemMax: 4194304,
+		TCPWmem: []uint64{8192, 32768, 4194304},
+	}
+
+	buffers, exitCode, err := runContainer(config, "", "cat", "/proc/sys/net/core/rmem_max", "/proc/sys/net/ipv4/tcp_wmem")
+	ok(t, err)
+	if exitCode != 0 {
+		t.Fatalf("exit code not 0. code %d stderr %q", exitCode, buffers.Stderr)
+	}
+	if out := strings.Fields(buffers.Stdout.String()); !reflect.DeepEqual(out, []string{"4194304", "8192", "32768", "4194304"}) {
+		t.Fatalf("expected socket buffer sysctls to be applied, got %q", buffers.Stdout.String())
+	}
+}
+
+func TestMountCustomFilesystem(t *testing.T) {
+	if testing.Short() {
+		return
+	}
+	filesystems, err := ioutil.ReadFile("/proc/filesystems")
+	ok(t, err)
+	if !strings.Contains(string(filesystems), "\tfusectl\n") {
+		t.Skip("fusectl is not supported")
+	}
+	rootfs, err := newRootfs()
+	ok(t, err)
+	defer remove(rootfs)
+
+	config := newTemplateConfig(rootfs)
+	config.Mounts = append(config.Mounts, &configs.Mount{
+		Source:      "fusectl",
+		Destination: "/fusectl",
+		Device:      "fusectl",
+		Flags:       defaultMountFlags,
+	})
+	buffers, exitCode, err := runContainer(config, "", "grep", "/fusectl", "/proc/mounts")
+	ok(t, err)
+	if exitCode != 0 {
+		t.Fatalf("exit code not 0. code %d stderr %q", exitCode, buffers.Stderr)
+	}
+	if !strings.Contains(buffers.Stdout.String(), "fusectl /fusectl fusectl") {
+		t.Fatalf("expected fusectl to be mounted at /fusectl, got %q", buffers.Stdout)
+	}
+
+	config.Mounts[len(config.Mounts)-1].Device = "runc-no-such-fs"
+	_, _, err = runContainer(config, "", "true")
+	if err == nil || !strings.Contains(err.Error(), "not supported by the kernel") {
+		t.Fatalf("expected an unsupported filesystem to be reported, got %v", err)
+	}
+}
+
+func TestCpuAffinity(t *testing.T) {
+	if testing.Short() {
+		return
+	}
+	rootfs, err := newRootfs()
+	ok(t, err)
+	defer remove(rootfs)
+
+	config := newTemplateConfig(rootfs)
+	config.CpuAffinity = []int{0}
+
+	buffers, exitCode, err := runContainer(config, "", "grep", "Cpus_allowed_list", "/proc/self/status")
+	ok(t, err)
+	if exitCode != 0 {
+		t.Fatalf("exit code not 0. code %d stderr %q", exitCode, buffers.Stderr)
+	}
+	if out := strings.Fields(buffers.Stdout.String()); !reflect.DeepEqual(out, []string{"Cpus_allowed_list:", "0"}) {
+		t.Fatalf("expected the container to be restricted to cpu 0, got %q", buffers.Stdout.String())
+	}
+}
+
+func TestDelegateCgroup(t *testing.T) {
+	if testing.Short() {
+		return
+	}
+	rootfs, err := newRootfs()
+	ok(t, err)
+	defer remove(rootfs)
+
+	config := newTemplateConfig(rootfs)
+	config.Cgroups.Delegate = true
+	config.Mounts = append(config.Mounts, &configs.Mount{
+		Destination: "/sys/fs/cgroup",
+		Device:      "cgroup",
+		Flags:       defaultMountFlags,
+	})
+
+	buffers, exitCode, err := runContainer(config, "", "sh", "-c",
+		"mkdir /sys/fs/cgroup/memory/inner && echo $$ > /sys/fs/cgroup/memory/inner/cgroup.procs && cat /proc/self/cgroup")
+	ok(t, err)
+	if exitCode != 0 {
+		t.Fatalf("exit code not 0. code %d stderr %q", exitCode, buffers.Stderr)
+	}
+	var memory string
+	for _, l := range strings.Split(buffers.Stdout.String(), "\n") {
+		if parts := strings.SplitN(l, ":", 3); len(parts) == 3 && parts[1] == "memory" {
+			memory = parts[2]
+		}
+	}
+	if !strings.HasSuffix(memory, "/inner") {
+		t.Fatalf("expected the process to have moved into the inner cgroup, got %q", buffers.Stdout)
+	}
+}
+
+func TestDelegateCgroupUserns(t *testing.T) {
+	if testing.Short() {
+		return
+	}
+	if _, err := os.Stat("/proc/self/ns/user"); os.IsNotExist(err) {
+		t.Skip("userns is unsupported")
+	}
+	rootfs, err := newRootfs()
+	ok(t, err)
+	defer remove(rootfs)
+
+	config := newTemplateConfig(rootfs)
+	config.UidMappings = []configs.IDMap{{HostID: 100000, ContainerID: 0, Size: 65536}}
+	config.GidMappings = []configs.IDMap{{HostID: 100000, ContainerID: 0, Size: 65536}}
+	config.Namespaces = append(config.Namespaces, configs.Namespace{Type: configs.NEWUSER})
+	config.RootfsOwnership = configs.RootfsChown
+	config.Cgroups.Delegate = true
+	config.Mounts = append(config.Mounts, &configs.Mount{
+		Destination: "/sys/fs/cgroup",
+		Device:      "cgroup",
+		Flags:       defaultMountFlags,
+	})
+
+	buffers, exitCode, err := runContainer(config, "", "stat", "-c", "%n %u",
+		"/sys/fs/cgroup/memory", "/sys/fs/cgroup/memory/cgroup.procs", "/sys/fs/cgroup/memory/memory.limit_in_bytes")
+	ok(t, err)
+	if exitCode != 0 {
+		t.Fatalf("exit code not 0. code %d stderr %q", exitCode, buffers.Stderr)
+	}
+	// The limits stay with the host's root, which the container cannot map.
+	expected := map[string]string{
+		"/sys/fs/cgroup/memory":                       "0",
+		"/sys/fs/cgroup/memory/cgroup.procs":          "0",
+		"/sys/fs/cgroup/memory/memory.limit_in_bytes": "65534",
+	}
+	for _, l := range strings.Split(strings.TrimSpace(buffers.Stdout.String()), "\n") {
+		fields := strings.Fields(l)
+		if len(fields) != 2 || expected[fields[0]] != fields[1] {
+			t.Fatalf("unexpected owner in %q", buffers.Stdout)
+		}
+	}
+}
+
+func TestShmSharedWithIpcNamespace(t *testing.T) {
+	if testing.Short() {
+		return
+	}
+	rootfs, err := newRootfs()
+	ok(t, err)
+	defer remove(rootfs)
+
+	config1 := newTemplateConfig(rootfs)
+	container1, err := newContainer(config1)
+	ok(t, err)
+	defer container1.Destroy()
+
+	stdinR1, stdinW1, err := os.Pipe()
+	ok(t, err)
+	init1 := &libcontainer.Process{
+		Cwd:   "/",
+		Args:  []string{"cat"},
+		Env:   standardEnvironment,
+		Stdin: stdinR1,
+	}
+	err = container1.Run(init1)
+	stdinR1.Close()
+	defer stdinW1.Close()
+	ok(t, err)
+
+	buffers := newStdBuffers()
+	touch := &libcontainer.Process{
+		Cwd:    "/",
+		Args:   []string{"touch", "/dev/shm/segment"},
+		Env:    standardEnvironment,
+		Stdin:  buffers.Stdin,
+		Stdout: buffers.Stdout,
+		Stderr: buffers.Stderr,
+	}
+	ok(t, container1.Run(touch))
+	waitProcess(touch, t)
+
+	state1, err := container1.State()
+	ok(t, err)
+
+	rootfs2, err := newRootfs()
+	ok(t, err)
+	defer remove(rootfs2)
+
+	shared := newTemplateConfig(rootfs2)
+	shared.Namespaces.Add(configs.NEWIPC, state1.NamespacePaths[configs.NEWIPC])
+	shared.Cgroups.Path = "integration/test2"
+	buffers, exitCode, err := runContainer(shared, "", "ls", "/dev/shm/segment")
+	ok(t, err)
+	if exitCode != 0 {
+		t.Fatalf("expected a container sharing the ipc namespace to see the segment: %s", buffers)
+	}
+
+	private := newTemplateConfig(rootfs2)
+	private.Cgroups.Path = "integration/test2"
+	buffers, exitCode, err = runContainer(private, "", "ls", "/dev/shm/segment")
+	ok(t, err)
+	if exitCode == 0 {
+		t.Fatalf("expected a container with a private ipc namespace not to see the segment: %s", buffers)
+	}
+
+	stdinW1.Close()
+	waitProcess(init1, t)
+}
+
+func TestMaxCoreSizeDisablesCoreDumps(t *testing.T) {
+	if testing.Short() {
+		return
+	}
+	rootfs, err := newRootfs()
+	ok(t, err)
+	defer remove(rootfs)
+
+	config := newTemplateConfig(rootfs)
+	var disabled uint64
+	config.MaxCoreSize = &disabled
+
+	buffers, exitCode, err := runContainer(config, "", "grep", "Max core file size", "/proc/self/limits")
+	ok(t, err)
+	if exitCode != 0 {
+		t.Fatalf("exit code not 0. code %d stderr %q", exitCode, buffers.Stderr)
+	}
+	if fields := strings.Fields(buffers.Stdout.String()); len(fields) < 6 || fields[4] != "0" || fields[5] != "0" {
+		t.Fatalf("expected the core size limit to be 0, got %q", buffers.Stdout.String())
+	}
+
+	// Whether a crash leaves a core file in the rootfs depends on the host's
+	// core_pattern, which has to name a file relative to the cwd.
+	pattern, err := ioutil.ReadFile("/proc/sys/kernel/core_pattern")
+	ok(t, err)
+	if p := strings.TrimSpace(string(pattern)); strings.HasPrefix(p, "|") || strings.Contains(p, "/") {
+		t.Skipf("core_pattern %q does not write core files to the cwd", p)
+	}
+	buffers, exitCode, err = runContainer(config, "", "sh", "-c", "cd /tmp && sh -c 'kill -SEGV $$'; ls /tmp")
+	ok(t, err)
+	if exitCode != 0 {
+		t.Fatalf("exit code not 0. code %d stderr %q", exitCode, buffers.Stderr)
+	}
+	if out := strings.TrimSpace(buffers.Stdout.String()); out != "" {
+		t.Fatalf("expected no core file to be written, found %q", out)
+	}
+}
+
+func TestResetRlimits(t *testing.T) {
+	if testing.Short() {
+		return
+	}
+	rootfs, err := newRootfs()
+	ok(t, err)
+	defer remove(rootfs)
+
+	var host syscall.Rlimit
+	ok(t, syscall.Getrlimit(syscall.RLIMIT_NOFILE, &host))
+	if host.Cur == 1024 && host.Max == 4096 {
+		t.Skip("the open files limit is already the kernel default")
+	}
+
+	openFiles := func(config *configs.Config) []string {
+		buffers, exitCode, err := runContainer(config, "", "grep", "Max open files", "/proc/self/limits")
+		ok(t, err)
+		if exitCode != 0 {
+			t.Fatalf("exit code not 0. code %d stderr %q", exitCode, buffers.Stderr)
+		}
+		fields := strings.Fields(buffers.Stdout.String())
+		if len(fields) < 5 {
+			t.Fatalf("unexpected limits %q", buffers.Stdout.String())
+		}
+		return fields[3:5]
+	}
+
+	config := newTemplateConfig(rootfs)
+	// The template sets RLIMIT_NOFILE, which would win in both modes.
+	config.Rlimits = nil
+	inherited := []string{strconv.FormatUint(host.Cur, 10), strconv.FormatUint(host.Max, 10)}
+	if limits := openFiles(config); !reflect.DeepEqual(limits, inherited) {
+		t.Fatalf("expected the container to inherit the open files limit %v, got %v", inherited, limits)
+	}
+
+	config.ResetRlimits = true
+	if limits := openFiles(config); !reflect.DeepEqual(limits, []string{"1024", "4096"}) {
+		t.Fatalf("expected the kernel's default open files limit, got %v", limits)
+	}
+}
+
+func TestStateRootOnTmpfs(t *testing.T) {
+	if testing.Short() {
+		return
+	}
+	root, err := newTestRoot()
+	ok(t, err)
+	defer os.RemoveAll(root)
+	f, err := libcontainer.New(root, libcontainer.Cgroupfs, libcontainer.TmpfsRoot)
+	ok(t, err)
+	defer syscall.Unmount(root, syscall.MNT_DETACH)
+
+	rootfs, err := newRootfs()
+	ok(t, err)
+	defer remove(rootfs)
+
+	container, err := f.Create("test", newTemplateConfig(rootfs))
+	ok(t, err)
+	defer container.Destroy()
+
+	stdinR, stdinW, err := os.Pipe()
+	ok(t, err)
+	process := &libcontainer.Process{
+		Cwd:   "/",
+		Args:  []string{"cat"},
+		Env:   standardEnvironment,
+		Stdin: stdinR,
+	}
+	err = container.Run(process)
+	stdinR.Close()
+	defer stdinW.Close()
+	ok(t, err)
+
+	if _, err := os.Stat(filepath.Join(root, "test", "state.json")); err != nil {
+		t.Fatalf("expected the state file in the factory root: %v", err)
+	}
+
+	// A factory on the same root sees the running container.
+	f2, err := libcontainer.New(root, libcontainer.Cgroupfs)
+	ok(t, err)
+	loaded, err := f2.Load("test")
+	ok(t, err)
+	status, err := loaded.Status()
+	ok(t, err)
+	if status != libcontainer.Running {
+		t.Fatalf("expected the loaded container to be running, got %s", status)
+	}
+	_, err = execInContainer(loaded, "true")
+	ok(t, err)
+
+	stdinW.Close()
+	waitProcess(process, t)
+	ok(t, loaded.Destroy())
+	if _, err := os.Stat(filepath.Join(root, "test")); !os.IsNotExist(err) {
+		t.Fatalf("expected the container's state to be removed on destroy, got %v", err)
+	}
+	if _, err := f2.Load("test"); err == nil {
+		t.Fatal("expected loading a destroyed container to fail")
+	}
+}
+
+func TestSetupTimeout(t *testing.T) {
+	if testing.Short() {
+		return
+	}
+	rootfs, err := newRootfs()
+	ok(t, err)
+	defer remove(rootfs)
+
+	sleep, err := exec.LookPath("sleep")
+	ok(t, err)
+	config := newTemplateConfig(rootfs)
+	config.SetupTimeout = time.Second
+	// A premount command that never returns stands in for a stuck mount.
+	config.Mounts = append(config.Mounts, &configs.Mount{
+		Source:       "tmpfs",
+		Destination:  "/slow",
+		Device:       "tmpfs",
+		PremountCmds: []configs.Command{{Path: sleep, Args: []string{"100"}}},
+	})
+
+	container, err := newContainer(config)
+	ok(t, err)
+	defer container.Destroy()
+
+	start := time.Now()
+	_, err = execInContainer(container, "true")
+	if err == nil {
+		t.Fatal("expected the start to fail once the setup timeout passed")
+	}
+	if !strings.Contains(err.Error(), "did not finish setting up") {
+		t.Fatalf("expected a setup timeout error, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 30*time.Second {
+		t.Fatalf("expected the start to be aborted after the timeout, took %s", elapsed)
+	}
+}
+
+func TestPauseSubtree(t *testing.T) {
+	if testing.Short() {
+		return
+	}
+	rootfs, err := newRootfs()
+	ok(t, err)
+	defer remove(rootfs)
+
+	container, err := newContainer(newTemplateConfig(rootfs))
+	ok(t, err)
+	defer container.Destroy()
+
+	stdinR, stdinW, err := os.Pipe()
+	ok(t, err)
+	initial := &libcontainer.Process{
+		Cwd:   "/",
+		Args:  []string{"cat"},
+		Env:   standardEnvironment,
+		Stdin: stdinR,
+	}
+	err = container.Run(initial)
+	stdinR.Close()
+	defer stdinW.Close()
+	ok(t, err)
+
+	// Move a job into its own child cgroup of the container.
+	job := &libcontainer.Process{
+		Cwd:  "/",
+		Args: []string{"sleep", "100"},
+		Env:  standardEnvironment,
+	}
+	ok(t, container.Run(job))
+	pid, err := job.Pid()
+	ok(t, err)
+	state, err := container.State()
+	ok(t, err)
+	jobCgroup := filepath.Join(state.CgroupPaths["freezer"], "job")
+	ok(t, os.Mkdir(jobCgroup, 0755))
+	defer os.Remove(jobCgroup)
+	ok(t, cgroups.WriteCgroupProc(jobCgroup, pid))
+
+	ok(t, container.PauseSubtree("job"))
+	data, err := ioutil.ReadFile(filepath.Join(jobCgroup, "freezer.state"))
+	ok(t, err)
+	if s := strings.TrimSpace(string(data)); s != string(configs.Frozen) {
+		t.Fatalf("expected the job to be frozen, got %q", s)
+	}
+	if status, err := container.Status(); err != nil || status != libcontainer.Running {
+		t.Fatalf("expected the container to keep running, got %s (%v)", status, err)
+	}
+	_, err = execInContainer(container, "true")
+	ok(t, err)
+
+	ok(t, container.SignalSubtree("job", syscall.SIGKILL))
+	if _, err := job.Wait(); err == nil {
+		t.Fatal("expected the job to be killed")
+	}
+	if status, err := container.Status(); err != nil || status != libcontainer.Running {
+		t.Fatalf("expected the container to keep running after the job was killed, got %s (%v)", status, err)
+	}
+
+	stdinW.Close()
+	waitProcess(initial, t)
+}
+
+func TestExportSpec(t *testing.T) {
+	if testing.Short() {
+		return
+	}
+	rootfs, err := newRootfs()
+	ok(t, err)
+	defer remove(rootfs)
+
+	config := newTemplateConfig(rootfs)
+	container, err := newContainer(config)
+	ok(t, err)
+	defer container.Destroy()
+
+	stdinR, stdinW, err := os.Pipe()
+	ok(t, err)
+	process := &libcontainer.Process{
+		Cwd:   "/",
+		Args:  []string{"cat"},
+		Env:   standardEnvironment,
+		Stdin: stdinR,
+	}
+	err = container.Run(process)
+	stdinR.Close()
+	defer stdinW.Close()
+	ok(t, err)
+
+	data, err := container.ExportSpec(true)
+	ok(t, err)
+	var exported libcontainer.State
+	ok(t, json.Unmarshal(data, &exported))
+	pid, err := process.Pid()
+	ok(t, err)
+	if exported.InitProcessPid != pid {
+		t.Fatalf("expected the exported init pid to be %d but was %d", pid, exported.InitProcessPid)
+	}
+
+	// Compare against the config after the same encoding, which normalizes
+	// fields that JSON can not tell apart such as nil and empty slices.
+	original, err := json.Marshal(container.Config())
+	ok(t, err)
+	var expected configs.Config
+	ok(t, json.Unmarshal(original, &expected))
+	if !reflect.DeepEqual(exported.Config, expected) {
+		t.Fatalf("expected the exported config to be equivalent to the container's config")
+	}
+
+	stdinW.Close()
+	waitProcess(process, t)
+}
+
+func TestVerifyMountsInjected(t *testing.T) {
+	if testing.Short() {
+		return
+	}
+	rootfs, err := newRootfs()
+	ok(t, err)
+	defer remove(rootfs)
+	config := newTemplateConfig(rootfs)
+	config.RootPropagation = syscall.MS_SLAVE | syscall.MS_REC
+
+	// Share a host directory with the container so that mounts made below
+	// it on the host propagate in.
+	dirhost, err := ioutil.TempDir("", "verifymounts")
+	ok(t, err)
+	defer os.RemoveAll(dirhost)
+	ok(t, syscall.Mount(dirhost, dirhost, "bind", syscall.MS_BIND|syscall.MS_REC, ""))
+	defer unmountOp(dirhost)
+	ok(t, syscall.Mount("", dirhost, "", syscall.MS_SHARED|syscall.MS_REC, ""))
+	config.Mounts = append(config.Mounts, &configs.Mount{
+		Source:      dirhost,
+		Destination: "/shared",
+		Device:      "bind",
+		Flags:       syscall.MS_BIND,
+	})
+
+	container, err := newContainer(config)
+	ok(t, err)
+	defer container.Destroy()
+
+	stdinR, stdinW, err := os.Pipe()
+	ok(t, err)
+	process := &libcontainer.Process{
+		Cwd:   "/",
+		Args:  []string{"cat"},
+		Env:   standardEnvironment,
+		Stdin: stdinR,
+	}
+	err = container.Run(process)
+	stdinR.Close()
+	defer stdinW.Close()
+	ok(t, err)
+
+	hasDrift := func(drift []libcontainer.MountDrift, dest string) bool {
+		for _, d := range drift {
+			if d.Destination == dest && !d.Missing {
+				return true
+			}
+		}
+		return false
+	}
+	drift, err := container.VerifyMounts()
+	ok(t, err)
+	if hasDrift(drift, "/shared/injected") {
+		t.Fatalf("unexpected drift before the host injected a mount: %+v", drift)
+	}
+
+	injected := filepath.Join(dirhost, "injected")
+	ok(t, os.Mkdir(injected, 0755))
+	ok(t, syscall.Mount("tmpfs", injected, "tmpfs", 0, ""))
+	defer unmountOp(injected)
+
+	drift, err = container.VerifyMounts()
+	ok(t, err)
+	if !hasDrift(drift, "/shared/injected") {
+		t.Fatalf("expected the host-injected mount to be reported, got %+v", drift)
+	}
+
+	stdinW.Close()
+	waitProcess(process, t)
+}
+
+func TestMinimalInit(t *testing.T) {
+	if testing.Short() {
+		return
+	}
+	rootfs, err := newRootfs()
+	ok(t, err)
+	defer remove(rootfs)
+	config := newTemplateConfig(rootfs)
+	config.MinimalInit = true
+
+	container, err := newContainer(config)
+	ok(t, err)
+	defer container.Destroy()
+
+	// The orphaned sleep is reparented to the init, which has to reap it.
+	process := &libcontainer.Process{
+		Cwd:  "/",
+		Args: []string{"sh", "-c", `sh -c 'sleep 0.1 &'; trap 'exit 42' TERM; while :; do sleep 0.05; done`},
+		Env:  standardEnvironment,
+	}
+	ok(t, container.Run(process))
+
+	time.Sleep(500 * time.Millisecond)
+	buffers, err := execInContainer(container, "sh", "-c", "cat /proc/[0-9]*/stat")
+	ok(t, err)
+	for _, line := range strings.Split(buffers.Stdout.String(), "\n") {
+		if fields := strings.Fields(line[strings.LastIndex(line, ")")+1:]); len(fields) > 1 && fields[0] == "Z" {
+			t.Fatalf("expected orphans to be reaped, found zombie %q", line)
+		}
+	}
+	cmdline, err := execInContainer(container, "cat", "/proc/1/cmdline")
+	ok(t, err)
+	if !strings.HasPrefix(cmdline.Stdout.String(), "runc:[reaper]\x00sh\x00") {
+		t.Fatalf("expected pid 1 to be the reaper of the user's process, got %q", cmdline.Stdout)
+	}
+	// The reaper is a single thread exec'd from a copy of the init binary.
+	exe, err := execInContainer(container, "readlink", "/proc/1/exe")
+	ok(t, err)
+	if !strings.HasPrefix(exe.Stdout.String(), "/memfd:runc:[reaper]") {
+		t.Fatalf("expected pid 1 to run from a memfd, got %q", exe.Stdout)
+	}
+	status, err := execInContainer(container, "grep", "Threads", "/proc/1/status")
+	ok(t, err)
+	if strings.TrimSpace(status.Stdout.String()) != "Threads:\t1" {
+		t.Fatalf("expected a single threaded pid 1, got %q", status.Stdout)
+	}
+
+	ok(t, container.Signal(syscall.SIGTERM, false))
+	state, err := process.Wait()
+	if err == nil {
+		t.Fatal("expected the process to exit with the child's exit code")
+	}
+	if status := state.Sys().(syscall.WaitStatus).ExitStatus(); status != 42 {
+		t.Fatalf("expected the forwarded SIGTERM to make the child exit with 42, got %d", status)
+	}
+}
+
+func TestCgroupView(t *testing.T) {
+	if testing.Short() {
+		return
+	}
+	rootfs, err := newRootfs()
+	ok(t, err)
+	defer remove(rootfs)
+	config := newTemplateConfig(rootfs)
+	config.CgroupView = &configs.CgroupView{
+		Destination: "/run/cgroup",
+		Subsystems:  []string{"memory"},
+	}
+
+	buffers, exitCode, err := runContainer(config, "", "ls", "/run/cgroup")
+	ok(t, err)
+	if exitCode != 0 {
+		t.Fatalf("exit code not 0. code %d stderr %q", exitCode, buffers.Stderr)
+	}
+	if out := strings.TrimSpace(buffers.Stdout.String()); out != "memory" {
+		t.Fatalf("expected only the memory hierarchy in the view, got %q", out)
+	}
+
+	buffers, exitCode, err = runContainer(config, "", "cat", "/run/cgroup/memory/memory.usage_in_bytes")
+	ok(t, err)
+	if exitCode != 0 {
+		t.Fatalf("expected the memory stats to be readable. code %d stderr %q", exitCode, buffers.Stderr)
+	}
+	if _, err := strconv.ParseUint(strings.TrimSpace(buffers.Stdout.String()), 10, 64); err != nil {
+		t.Fatalf("expected the memory usage, got %q", buffers.Stdout)
+	}
+
+	buffers, exitCode, err = runContainer(config, "", "sh", "-c", "echo 1048576 > /run/cgroup/memory/memory.limit_in_bytes")
+	ok(t, err)
+	if exitCode == 0 {
+		t.Fatal("expected the memory limit not to be writable")
+	}
+	if !strings.Contains(buffers.Stderr.String(), "Read-only file system") {
+		t.Fatalf("expected the view to be read-only, got stderr %q", buffers.Stderr)
+	}
+}
+
+func TestVerifyMountsCgroupView(t *testing.T) {
+	if testing.Short() {
+		return
+	}
+	rootfs, err := newRootfs()
+	ok(t, err)
+	defer remove(rootfs)
+	config := newTemplateConfig(rootfs)
+	config.CgroupView = &configs.CgroupView{
+		Destination: "/run/cgroup",
+	}
+
+	container, err := newContainer(config)
+	ok(t, err)
+	defer container.Destroy()
+
+	stdinR, stdinW, err := os.Pipe()
+	ok(t, err)
+	process := &libcontainer.Process{
+		Cwd:   "/",
+		Args:  []string{"cat"},
+		Env:   standardEnvironment,
+		Stdin: stdinR,
+	}
+	err = container.Run(process)
+	stdinR.Close()
+	defer stdinW.Close()
+	ok(t, err)
+
+	drift, err := container.VerifyMounts()
+	ok(t, err)
+	for _, d := range drift {
+		if strings.HasPrefix(d.Destination, "/run/cgroup/") {
+			t.Fatalf("expected the hierarchies of the cgroup view not to be reported, got %+v", drift)
+		}
+	}
+
+	stdinW.Close()
+	waitProcess(process, t)
+}
+
+func TestSetupTrail(t *testing.T) {
+	if testing.Short() {
+		return
+	}
+	rootfs, err := newRootfs()
+	ok(t, err)
+	defer remove(rootfs)
+	config := newTemplateConfig(rootfs)
+	config.Mounts = append(config.Mounts, &configs.Mount{
+		Source:      "/runc-no-such-source",
+		Destination: "/data",
+		Device:      "bind",
+		Flags:       syscall.MS_BIND,
+	})
+
+	container, err := newContainer(config)
+	ok(t, err)
+	defer container.Destroy()
+
+	err = container.Run(&libcontainer.Process{
+		Cwd:  "/",
+		Args: []string{"true"},
+		Env:  standardEnvironment,
+	})
+	if err == nil {
+		t.Fatal("expected the container to fail mounting a missing source")
+	}
+	trail := libcontainer.SetupTrail(err)
+	if len(trail) < 2 {
+		t.Fatalf("expected the trail to hold the steps up to the failure, got %q", trail)
+	}
+	last := trail[len(trail)-1]
+	if !strings.Contains(last, "/runc-no-such-source at /data") || !strings.Contains(last, fmt.Sprintf("(errno %d)", syscall.ENOENT)) {
+		t.Fatalf("expected the failed mount with its errno to be the last step, got %q", last)
+	}
+}
+
+func TestMemoryPercent(t *testing.T) {
+	if testing.Short() {
+		return
+	}
+	rootfs, err := newRootfs()
+	ok(t, err)
+	defer remove(rootfs)
+	config := newTemplateConfig(rootfs)
+	config.Cgroups.Resources.MemoryPercent = 50
+
+	container, err := newContainer(config)
+	ok(t, err)
+	defer container.Destroy()
+
+	stdinR, stdinW, err := os.Pipe()
+	ok(t, err)
+	process := &libcontainer.Process{
+		Cwd:   "/",
+		Args:  []string{"cat"},
+		Env:   standardEnvironment,
+		Stdin: stdinR,
+	}
+	err = container.Run(process)
+	stdinR.Close()
+	defer stdinW.Close()
+	ok(t, err)
+
+	var info syscall.Sysinfo_t
+	ok(t, syscall.Sysinfo(&info))
+	// The kernel rounds the limit down to whole pages.
+	pageSize := uint64(os.Getpagesize())
+	expected := uint64(info.Totalram) * uint64(info.Unit) / 2 / pageSize * pageSize
+	state, err := container.State()
+	ok(t, err)
+	data, err := ioutil.ReadFile(filepath.Join(state.CgroupPaths["memory"], "memory.limit_in_bytes"))
+	ok(t, err)
+	limit, err := strconv.ParseUint(strings.TrimSpace(string(data)), 10, 64)
+	ok(t, err)
+	if limit != expected {
+		t.Fatalf("expected 50%% of the host's memory to be a limit of %d bytes, got %d", expected, limit)
+	}
+
+	stdinW.Close()
+	waitProcess(process, t)
+}
+
+func TestAddRemoveDevice(t *testing.T) {
+	if testing.Short() {
+		return
+	}
+	rootfs, err := newRootfs()
+	ok(t, err)
+	defer remove(rootfs)
+
+	container, err := newContainer(newTemplateConfig(rootfs))
+	ok(t, err)
+	defer container.Destroy()
+
+	stdinR, stdinW, err := os.Pipe()
+	ok(t, err)
+	process := &libcontainer.Process{
+		Cwd:   "/",
+		Args:  []string{"cat"},
+		Env:   standardEnvironment,
+		Stdin: stdinR,
+	}
+	err = container.Run(process)
+	stdinR.Close()
+	defer stdinW.Close()
+	ok(t, err)
+
+	// /dev/kmsg is not one of the devices that the template allows.
+	kmsg := &configs.Device{
+		Type:        'c',
+		Path:        "/dev/hotplug",
+		Major:       1,
+		Minor:       11,
+		Permissions: "rwm",
+		FileMode:    0666,
+	}
+	if _, err := execInContainer(container, "sh", "-c", "echo hotplug > /dev/hotplug"); err == nil {
+		t.Fatal("expected writing to the device to fail before it was added")
+	}
+	ok(t, container.AddDevice(kmsg))
+	buffers, err := execInContainer(container, "sh", "-c", "echo hotplug > /dev/hotplug")
+	if err != nil {
+		t.Fatalf("expected writing to the added device to succeed: %v %s", err, buffers)
+	}
+	state, err := container.State()
+	ok(t, err)
+	if len(state.Config.Devices) == 0 || state.Config.Devices[len(state.Config.Devices)-1].Path != kmsg.Path {
+		t.Fatalf("expected the device to be in the state, got %+v", state.Config.Devices)
+	}
+	if err := container.AddDevice(kmsg); err == nil {
+		t.Fatal("expected adding the device twice to fail")
+	}
+
+	ok(t, container.RemoveDevice(kmsg.Path))
+	if _, err := execInContainer(container, "test", "-e", "/dev/hotplug"); err == nil {
+		t.Fatal("expected the device node to be removed")
+	}
+	list, err := ioutil.ReadFile(filepath.Join(state.CgroupPaths["devices"], "devices.list"))
+	ok(t, err)
+	if strings.Contains(string(list), "c 1:11 ") {
+		t.Fatalf("expected the device to be denied, got devices.list:\n%s", list)
+	}
+	if err := container.RemoveDevice(kmsg.Path); err == nil {
+		t.Fatal("expected removing a missing device to fail")
+	}
+
+	stdinW.Close()
+	waitProcess(process, t)
+}
+
+func TestSetAfterLoad(t *testing.T) {
+	if testing.Short() {
+		return
+	}
+	rootfs, err := newRootfs()
+	ok(t, err)
+	defer remove(rootfs)
+
+	config := newTemplateConfig(rootfs)
+	config.Cgroups.Resources.Memory = 64 * 1024 * 1024
+	container, err := newContainer(config)
+	ok(t, err)
+	defer container.Destroy()
+
+	stdinR, stdinW, err := os.Pipe()
+	ok(t, err)
+	process := &libcontainer.Process{
+		Cwd:   "/",
+		Args:  []string{"cat"},
+		Env:   standardEnvironment,
+		Stdin: stdinR,
+	}
+	err = container.Run(process)
+	stdinR.Close()
+	defer stdinW.Close()
+	ok(t, err)
+
+	loaded, err := factory.Load(container.ID())
+	ok(t, err)
+	update := loaded.Config()
+	if update.Cgroups.Resources.Memory != config.Cgroups.Resources.Memory {
+		t.Fatalf("expected the loaded container to have the memory limit %d, got %d", config.Cgroups.Resources.Memory, update.Cgroups.Resources.Memory)
+	}
+	update.Cgroups.Resources.Memory = 128 * 1024 * 1024
+	ok(t, loaded.Set(update))
+
+	state, err := loaded.State()
+	ok(t, err)
+	data, err := ioutil.ReadFile(filepath.Join(state.CgroupPaths["memory"], "memory.limit_in_bytes"))
+	ok(t, err)
+	if limit := strings.TrimSpace(string(data)); limit != "134217728" {
+		t.Fatalf("expected the memory limit to be updated to 134217728, got %s", limit)
+	}
+	reloaded, err := factory.Load(container.ID())
+	ok(t, err)
+	if memory := reloaded.Config().Cgroups.Resources.Memory; memory != update.Cgroups.Resources.Memory {
+		t.Fatalf("expected the updated memory limit to be persisted, got %d", memory)
+	}
+
+	stdinW.Close()
+	waitProcess(process, t)
+}
+
+// TestParentDeathCleanup kills the process starting a container while a
+// prestart hook runs and expects the init to remove the container's cgroup.
+func TestParentDeathCleanup(t *testing.T) {
+	if testing.Short() {
+		return
+	}
+	rootfs, err := newRootfs()
+	ok(t, err)
+	defer remove(rootfs)
+	memory, err := cgroups.FindCgroupMountpoint("memory")
+	ok(t, err)
+	cgroupPath := fmt.Sprintf("integration/parent-death-%d", os.Getpid())
+	id := fmt.Sprintf("parent-death-%d", os.Getpid())
+	defer os.RemoveAll(filepath.Join("/run/libctTests", id))
+
+	cmd := exec.Command(os.Args[0], "-test.run=^TestParentDeathCleanupManager$")
+	cmd.Env = append(os.Environ(),
+		"LIBCONTAINER_TEST_ROOTFS="+rootfs,
+		"LIBCONTAINER_TEST_CGROUP="+cgroupPath,
+		"LIBCONTAINER_TEST_ID="+id,
+	)
+	stdout, err := cmd.StdoutPipe()
+	ok(t, err)
+	ok(t, cmd.Start())
+	defer cmd.Process.Kill()
+
+	hooked := make(chan bool)
+	go func() {
+		s := bufio.NewScanner(stdout)
+		for s.Scan() {
+			if s.Text() == "prestart" {
+				hooked <- true
+			}
+		}
+		close(hooked)
+	}()
+	select {
+	case running := <-hooked:
+		if !running {
+			cmd.Wait()
+			t.Fatal("manager exited before the prestart hook ran")
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("prestart hook did not run after 10s")
+	}
+	dir := filepath.Join(memory, cgroupPath)
+	if _, err := os.Stat(dir); err != nil {
+		t.Fatalf("expected the cgroup of the container to exist during setup: %v", err)
+	}
+
+	ok(t, cmd.Process.Kill())
+	cmd.Wait()
+	for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(50 * time.Millisecond) {
+		if _, err := os.Stat(dir); os.IsNotExist(err) {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("expected the init to remove the cgroup after the manager died")
+		}
+	}
+}
+
+func TestParentDeathCleanupManager(t *testing.T) {
+	rootfs := os.Getenv("LIBCONTAINER_TEST_ROOTFS")
+	if rootfs == "" {
+		return
+	}
+	config := newTemplateConfig(rootfs)
+	config.Cgroups.Path = os.Getenv("LIBCONTAINER_TEST_CGROUP")
+	config.ParentDeathCleanup = &configs.ParentDeathCleanup{}
+	config.Hooks = &configs.Hooks{
+		Prestart: []configs.Hook{
+			configs.NewFunctionHook(func(configs.HookState) error {
+				fmt.Println("prestart")
+				time.Sleep(time.Minute)
+				return nil
+			}),
+		},
+	}
+	container, err := newContainerWithName(os.Getenv("LIBCONTAINER_TEST_ID"), config)
+	ok(t, err)
+	// The init removes the cgroup before it switches to the user.
+	process := &libcontainer.Process{
+		Cwd:  "/",
+		Args: []string{"true"},
+		Env:  standardEnvironment,
+		User: "65534:65534",
+	}
+	ok(t, container.Run(process))
+	t.Fatal("expected the manager to be killed during the prestart hook")
+}
+
+func TestHostPidProc(t *testing.T) {
+	if testing.Short() {
+		return
+	}
+	rootfs, err := newRootfs()
+	ok(t, err)
+	defer remove(rootfs)
+	hostPid := fmt.Sprintf("/proc/%d", os.Getpid())
+
+	// With a PID namespace /proc only shows the container's processes.
+	config := newTemplateConfig(rootfs)
+	buffers, exitCode, err := runContainer(config, "", "sh", "-c", "test -e "+hostPid+" && echo host; readlink /proc/self")
+	ok(t, err)
+	if exitCode != 0 {
+		t.Fatalf("exit code not 0. code %d stderr %q", exitCode, buffers.Stderr)
+	}
+	if out := strings.TrimSpace(buffers.Stdout.String()); strings.Contains(out, "host") || out == strconv.Itoa(os.Getpid()) {
+		t.Fatalf("expected /proc to show the container's processes only, got %q", out)
+	}
+
+	for _, mode := range []configs.HostPidProc{configs.HostPidProcMount, configs.HostPidProcBind, configs.HostPidProcMasked} {
+		config := newTemplateConfig(rootfs)
+		config.Namespaces.Remove(configs.NEWPID)
+		config.HostPidProc = mode
+		buffers, exitCode, err := runContainer(config, "", "sh", "-c", "test -e "+hostPid+" && echo host; head -c 16 /proc/timer_list | wc -c")
+		ok(t, err)
+		if exitCode != 0 {
+			t.Fatalf("%s: exit code not 0. code %d stderr %q", mode, exitCode, buffers.Stderr)
+		}
+		lines := strings.Fields(buffers.Stdout.String())
+		if len(lines) != 2 || lines[0] != "host" {
+			t.Fatalf("%s: expected /proc to show the host's processes, got %q", mode, buffers.Stdout)
+		}
+		if masked := lines[1] == "0"; masked != (mode == configs.HostPidProcMasked) {
+			t.Fatalf("%s: expected /proc/timer_list to be masked %v, read %s bytes", mode, mode == configs.HostPidProcMasked, lines[1])
+		}
+	}
+}
+
+func TestRootfsOwnership(t *testing.T) {
+	if testing.Short() {
+		return
+	}
+	if _, err := os.Stat("/proc/self/ns/user"); os.IsNotExist(err) {
+		t.Skip("userns is unsupported")
+	}
+	for _, mode := range []configs.RootfsOwnership{configs.RootfsChown, configs.RootfsChownRecursive, configs.RootfsIdmap} {
+		rootfs, err := newRootfs()
+		ok(t, err)
+		defer remove(rootfs)
+		config := newTemplateConfig(rootfs)
+		config.UidMappings = []configs.IDMap{{HostID: 100000, ContainerID: 0, Size: 65536}}
+		config.GidMappings = []configs.IDMap{{HostID: 100000, ContainerID: 0, Size: 65536}}
+		config.Namespaces = append(config.Namespaces, configs.Namespace{Type: configs.NEWUSER})
+		config.RootfsOwnership = mode
+
+		buffers, exitCode, err := runContainer(config, "", "sh", "-c", "touch /written && stat -c %u /bin")
+		ok(t, err)
+		if exitCode != 0 {
+			t.Fatalf("%s: exit code not 0. code %d stderr %q", mode, exitCode, buffers.Stderr)
+		}
+		// Only the rootfs directory itself is chowned without shifting.
+		owner := strings.TrimSpace(buffers.Stdout.String())
+		if shifted := owner == "0"; shifted != (mode != configs.RootfsChown) {
+			t.Fatalf("%s: unexpected owner %s of /bin", mode, owner)
+		}
+	}
+}
+
+func TestIdmapBindMount(t *testing.T) {
+	if testing.Short() {
+		return
+	}
+	if _, err := os.Stat("/proc/self/ns/user"); os.IsNotExist(err) {
+		t.Skip("userns is unsupported")
+	}
+	rootfs, err := newRootfs()
+	ok(t, err)
+	defer remove(rootfs)
+	data, err := ioutil.TempDir("", "idmap")
+	ok(t, err)
+	defer remove(data)
+	ok(t, ioutil.WriteFile(filepath.Join(data, "file"), nil, 0644))
+
+	config := newTemplateConfig(rootfs)
+	config.UidMappings = []configs.IDMap{{HostID: 100000, ContainerID: 0, Size: 65536}}
+	config.GidMappings = []configs.IDMap{{HostID: 100000, ContainerID: 0, Size: 65536}}
+	config.Namespaces = append(config.Namespaces, configs.Namespace{Type: configs.NEWUSER})
+	config.RootfsOwnership = configs.RootfsIdmap
+	config.Mounts = append(config.Mounts, &configs.Mount{
+		Source:      data,
+		Destination: "/data",
+		Device:      "bind",
+		Flags:       syscall.MS_BIND | syscall.MS_REC,
+		Extensions:  configs.EXT_IDMAP,
+	})
+
+	buffers, exitCode, err := runContainer(config, "", "stat", "-c", "%u", "/data/file")
+	ok(t, err)
+	if exitCode != 0 {
+		t.Fatalf("exit code not 0. code %d stderr %q", exitCode, buffers.Stderr)
+	}
+	owner := strings.TrimSpace(buffers.Stdout.String())
+	if owner == "65534" {
+		t.Skip("idmapped mounts are unsupported")
+	}
+	if owner != "0" {
+		t.Fatalf("expected /data/file to be owned by the container's root, got %s", owner)
+	}
+
+	buffers, exitCode, err = runContainer(config, "", "touch", "/data/written")
+	ok(t, err)
+	if exitCode != 0 {
+		t.Fatalf("exit code not 0. code %d stderr %q", exitCode, buffers.Stderr)
+	}
+	// The files keep their owners on disk.
+	var st syscall.Stat_t
+	ok(t, syscall.Stat(filepath.Join(data, "written"), &st))
+	if st.Uid != 0 {
+		t.Fatalf("expected /data/written to be owned by root on disk, got %d", st.Uid)
+	}
+}
+
+func TestCgroupPathTemplate(t *testing.T) {
+	if testing.Short() {
+		return
+	}
+	rootfs, err := newRootfs()
+	ok(t, err)
+	defer remove(rootfs)
+	config := newTemplateConfig(rootfs)
+	config.Cgroups.Path = ""
+	config.Cgroups.PathTemplate = "integration/{{.Labels.group}}/{{.ID}}"
+	config.Labels = append(config.Labels, "group=templated")
+
+	var paths []string
+	for _, id := range []string{"templated-a", "templated-b"} {
+		container, err := newContainerWithName(id, config)
+		ok(t, err)
+		defer container.Destroy()
+		process := &libcontainer.Process{
+			Cwd:  "/",
+			Args: []string{"cat"},
+			Env:  standardEnvironment,
+		}
+		stdinR, stdinW, err := os.Pipe()
+		ok(t, err)
+		process.Stdin = stdinR
+		err = container.Run(process)
+		stdinR.Close()
+		defer stdinW.Close()
+		ok(t, err)
+		state, err := container.State()
+		ok(t, err)
+		path := state.CgroupPaths["memory"]
+		if !strings.HasSuffix(path, "/integration/templated/"+id) {
+			t.Fatalf("expected the memory cgroup of %s to end in integration/templated/%s, got %s", id, id, path)
+		}
+		paths = append(paths, path)
+	}
+	if filepath.Dir(paths[0]) != filepath.Dir(paths[1]) {
+		t.Fatalf("expected the cgroups %s and %s to be grouped", paths[0], paths[1])
+	}
+	if config.Cgroups.Path != "" {
+		t.Fatalf("expected the config to be left alone, got path %q", config.Cgroups.Path)
+	}
+}
+
+func TestRootfsMountFlags(t *testing.T) {
+	if testing.Short() {
+		return
+	}
+	rootfs, err := newRootfs()
+	ok(t, err)
+	defer remove(rootfs)
+	// A setuid root copy of busybox reports the effective uid it runs with.
+	busybox, err := ioutil.ReadFile(filepath.Join(rootfs, "bin", "busybox"))
+	ok(t, err)
+	suidbox := filepath.Join(rootfs, "bin", "suidbox")
+	ok(t, ioutil.WriteFile(suidbox, busybox, 0755))
+	ok(t, os.Chown(suidbox, 0, 0))
+	ok(t, os.Chmod(suidbox, 04755))
+
+	effectiveUID := func(flags int) string {
+		config := newTemplateConfig(rootfs)
+		config.RootfsMountFlags = flags
+		container, err := newContainer(config)
+		ok(t, err)
+		defer container.Destroy()
+		var stdout, stderr bytes.Buffer
+		process := &libcontainer.Process{
+			Cwd:    "/",
+			Args:   []string{"/bin/suidbox", "cat", "/proc/self/status"},
+			Env:    standardEnvironment,
+			User:   "1000:1000",
+			Stdout: &stdout,
+			Stderr: &stderr,
+		}
+		ok(t, container.Run(process))
+		waitProcess(process, t)
+		for _, line := range strings.Split(stdout.String(), "\n") {
+			if fields := strings.Fields(line); len(fields) == 5 && fields[0] == "Uid:" {
+				return fields[2]
+			}
+		}
+		t.Fatalf("no Uid line in the status %q, stderr %q", stdout.String(), stderr.String())
+		return ""
+	}
+	if uid := effectiveUID(0); uid != "0" {
+		t.Skipf("busybox drops the setuid privileges, effective uid %s", uid)
+	}
+	if uid := effectiveUID(syscall.MS_NOSUID | syscall.MS_NODEV); uid != "1000" {
+		t.Fatalf("expected the setuid binary to run with effective uid 1000 on a nosuid rootfs, got %s", uid)
+	}
+}
+
+func TestTmpfsDirs(t *testing.T) {
+	if testing.Short() {
+		return
+	}
+	rootfs, err := newRootfs()
+	ok(t, err)
+	defer remove(rootfs)
+	ok(t, os.MkdirAll(filepath.Join(rootfs, "run", "kept"), 0755))
+
+	config := newTemplateConfig(rootfs)
+	config.Readonlyfs = true
+	config.TmpfsDirs = []string{"/run"}
+	buffers, exitCode, err := runContainer(config, "", "sh", "-c", "ls /run && echo scratch > /run/f && cat /run/f && touch /f 2>&1")
+	ok(t, err)
+	out := buffers.Stdout.String()
+	if !strings.Contains(out, "kept") || !strings.Contains(out, "scratch") {
+		t.Fatalf("expected /run to keep its contents and be writable: %s %s", out, buffers.Stderr)
+	}
+	if exitCode == 0 || !strings.Contains(out, "Read-only file system") {
+		t.Fatalf("expected / to stay read-only, exit code %d: %s", exitCode, out)
+	}
+	if _, err := os.Stat(filepath.Join(rootfs, "run", "f")); !os.IsNotExist(err) {
+		t.Fatalf("expected the write to /run to stay in the tmpfs: %v", err)
+	}
+}
+
+func TestRootfsOverlay(t *testing.T) {
+	if testing.Short() {
+		return
+	}
+	dir, err := ioutil.TempDir("", "overlay")
+	ok(t, err)
+	defer remove(dir)
+	upper, work := filepath.Join(dir, "upper"), filepath.Join(dir, "work")
+	ok(t, os.Mkdir(upper, 0755))
+	ok(t, os.Mkdir(work, 0755))
+
+	for _, overlay := range []*configs.Overlay{
+		{},
+		{UpperDir: upper, WorkDir: work},
+	} {
+		rootfs, err := newRootfs()
+		ok(t, err)
+		defer remove(rootfs)
+		config := newTemplateConfig(rootfs)
+		config.RootfsOverlay = overlay
+
+		buffers, exitCode, err := runContainer(config, "", "sh", "-c", "echo overlay > /written && cat /written")
+		ok(t, err)
+		if exitCode != 0 {
+			t.Fatalf("exit code not 0. code %d stderr %q", exitCode, buffers.Stderr)
+		}
+		if out := strings.TrimSpace(buffers.Stdout.String()); out != "overlay" {
+			t.Fatalf("expected to read back the write through the overlay, got %q", out)
+		}
+		if _, err := os.Stat(filepath.Join(rootfs, "written")); !os.IsNotExist(err) {
+			t.Fatalf("expected the write to leave the rootfs alone, got %v", err)
+		}
+	}
+	// The external upper directory outlives the container.
+	data, err := ioutil.ReadFile(filepath.Join(upper, "written"))
+	ok(t, err)
+	if strings.TrimSpace(string(data)) != "overlay" {
+		t.Fatalf("expected the write in the external upper directory, got %q", data)
+	}
+}
+
+func TestRootfsImage(t *testing.T) {
+	if testing.Short() {
+		return
+	}
+	mksquashfs, err := exec.LookPath("mksquashfs")
+	if err != nil {
+		t.Skip("mksquashfs is not installed")
+	}
+	if _, err := os.Stat("/dev/loop-control"); err != nil {
+		t.Skipf("no loop devices: %v", err)
+	}
+	busybox, err := newRootfs()
+	ok(t, err)
+	defer remove(busybox)
+	ok(t, ioutil.WriteFile(filepath.Join(busybox, "image"), []byte("squashfs\n"), 0644))
+	dir, err := ioutil.TempDir("", "image")
+	ok(t, err)
+	defer remove(dir)
+	image := filepath.Join(dir, "rootfs.squashfs")
+	if out, err := exec.Command(mksquashfs, busybox, image, "-noappend").CombinedOutput(); err != nil {
+		t.Fatalf("creating squashfs image: %v: %s", err, out)
+	}
+
+	for _, overlay := range []*configs.Overlay{nil, {Tmpfs: true}} {
+		rootfs := filepath.Join(dir, "rootfs")
+		ok(t, os.MkdirAll(rootfs, 0755))
+		config := newTemplateConfig(rootfs)
+		config.RootfsImage = &configs.Image{Path: image, Type: "squashfs"}
+		config.RootfsOverlay = overlay
+
+		container, err := newContainer(config)
+		ok(t, err)
+		buffers := newStdBuffers()
+		process := &libcontainer.Process{
+			Cwd:    "/",
+			Args:   []string{"sh", "-c", "cat /image && (echo written > /written) 2>/dev/null; cat /written"},
+			Env:    standardEnvironment,
+			Stdin:  buffers.Stdin,
+			Stdout: buffers.Stdout,
+			Stderr: buffers.Stderr,
+		}
+		err = container.Run(process)
+		if err != nil {
+			container.Destroy()
+			ok(t, err)
+		}
+		waitProcess(process, t)
+		state, err := container.State()
+		ok(t, err)
+		device := state.RootfsImage.Device
+		ok(t, container.Destroy())
+
+		// The image itself is read-only.
+		expected := "squashfs"
+		if overlay != nil {
+			expected += "\nwritten"
+		}
+		if out := strings.TrimSpace(buffers.Stdout.String()); out != expected {
+			t.Fatalf("expected %q from the image with overlay %+v, got %q (stderr %q)", expected, overlay, out, buffers.Stderr)
+		}
+		if backing, err := ioutil.ReadFile(filepath.Join("/sys/block", filepath.Base(device), "loop/backing_file")); err == nil {
+			t.Fatalf("expected %s to be detached on Destroy, got %s", device, backing)
+		}
+	}
+}
+
+func TestHardenedInit(t *testing.T) {
+	if testing.Short() {
+		return
+	}
+	rootfs, err := newRootfs()
+	ok(t, err)
+	defer remove(rootfs)
+
+	// Leak a descriptor, a variable and an ignored signal from the manager.
+	leaked, err := syscall.Open("/dev/null", syscall.O_RDONLY, 0)
+	ok(t, err)
+	defer syscall.Close(leaked)
+	os.Setenv("LIBCONTAINER_TEST_LEAKED", "1")
+	defer os.Unsetenv("LIBCONTAINER_TEST_LEAKED")
+	signal.Ignore(syscall.SIGHUP)
+	defer signal.Reset(syscall.SIGHUP)
+
+	config := newTemplateConfig(rootfs)
+	config.HardenedInit = true
+	buffers, exitCode, err := runContainer(config, "", "sh", "-c",
+		"ls /proc/self/fd | tr '\\n' ' '; echo; env | sort | tr '\\n' ' '; echo; umask; grep SigIgn /proc/self/status")
+	ok(t, err)
+	if exitCode != 0 {
+		t.Fatalf("exit code not 0. code %d stderr %q", exitCode, buffers.Stderr)
+	}
+	lines := strings.Split(strings.TrimSpace(buffers.Stdout.String()), "\n")
+	if len(lines) != 4 {
+		t.Fatalf("unexpected output %q", buffers.Stdout)
+	}
+	// ls has the directory it lists open as well.
+	if fds := strings.Fields(lines[0]); len(fds) > 4 {
+		t.Errorf("expected only stdio to be open, got %q", lines[0])
+	}
+	if strings.Contains(lines[1], "LEAKED") || !strings.Contains(lines[1], "HOME=/root") {
+		t.Errorf("expected only the process's environment and HOME, got %q", lines[1])
+	}
+	if lines[2] != "0027" {
+		t.Errorf("expected umask 0027, got %q", lines[2])
+	}
+	ignored, err := strconv.ParseUint(strings.TrimSpace(strings.TrimPrefix(lines[3], "SigIgn:")), 16, 64)
+	ok(t, err)
+	if ignored&(1<<(uint(syscall.SIGHUP)-1)) != 0 {
+		t.Errorf("expected SIGHUP to not be ignored, got %q", lines[3])
+	}
+}
+
+// TestSubreaper runs TestSubreaperManager, which orphans a process of a
+// container that shares its PID namespace, and expects the manager to reap
+// the orphan.
+func TestSubreaper(t *testing.T) {
+	if testing.Short() {
+		return
+	}
+	rootfs, err := newRootfs()
+	ok(t, err)
+	defer remove(rootfs)
+
+	cmd := exec.Command(os.Args[0], "-test.run=^TestSubreaperManager$", "-test.v")
+	cmd.Env = append(os.Environ(), "LIBCONTAINER_TEST_SUBREAPER_ROOTFS="+rootfs)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("manager failed: %v\n%s", err, out)
+	}
+	if !strings.Contains(string(out), "--- PASS: TestSubreaperManager") {
+		t.Fatalf("manager did not run:\n%s", out)
+	}
+}
+
+func TestSubreaperManager(t *testing.T) {
+	rootfs := os.Getenv("LIBCONTAINER_TEST_SUBREAPER_ROOTFS")
+	if rootfs == "" {
+		return
+	}
+	f, err := libcontainer.New("/run/libctTests", libcontainer.Cgroupfs, libcontainer.Subreaper)
+	ok(t, err)
+	config := newTemplateConfig(rootfs)
+	config.Namespaces.Remove(configs.NEWPID)
+	container, err := f.Create(fmt.Sprintf("subreaper-%d", os.Getpid()), config)
+	ok(t, err)
+	defer container.Destroy()
+
+	var stdout bytes.Buffer
+	process := &libcontainer.Process{
+		Cwd:    "/",
+		Args:   []string{"sh", "-c", "sleep 1 >/dev/null & echo $!"},
+		Env:    standardEnvironment,
+		Stdout: &stdout,
+	}
+	ok(t, container.Run(process))
+	waitProcess(process, t)
+	orphan, err := strconv.Atoi(strings.TrimSpace(stdout.String()))
+	ok(t, err)
+	parent, err := system.GetParentPid(orphan)
+	if err == nil && parent != os.Getpid() {
+		t.Fatalf("expected the orphan to be reparented to the manager, got parent %d", parent)
+	}
+	for deadline := time.Now().Add(5 * time.Second); syscall.Kill(orphan, 0) == nil; time.Sleep(50 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected the manager to reap the orphan %d", orphan)
+		}
 	}
 }
@@ -4,7 +4,10 @@ import (
 	"bytes"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"os"
+	"os/exec"
+	"path/filepath"
 	"strconv"
 	"strings"
 	"syscall"
@@ -12,8 +15,10 @@ import (
 	"time"
 
 	"github.com/opencontainers/runc/libcontainer"
+	"github.com/opencontainers/runc/libcontainer/cgroups"
 	"github.com/opencontainers/runc/libcontainer/configs"
 	"github.com/opencontainers/runc/libcontainer/utils"
+	"github.com/vishvananda/netlink"
 )
 
 func TestExecIn(t *testing.T) {
@@ -248,6 +253,59 @@ func TestExecInError(t *testing.T) {
 	}
 }
 
+func TestExecInExecErrors(t *testing.T) {
+	if testing.Short() {
+		return
+	}
+	rootfs, err := newRootfs()
+	ok(t, err)
+	defer remove(rootfs)
+	ok(t, ioutil.WriteFile(filepath.Join(rootfs, "not-executable"), []byte("#!/bin/sh\n"), 0644))
+	ok(t, ioutil.WriteFile(filepath.Join(rootfs, "not-a-binary"), []byte("hello"), 0755))
+	config := newTemplateConfig(rootfs)
+	container, err := newContainer(config)
+	ok(t, err)
+	defer container.Destroy()
+
+	// Execute a first process in the container
+	stdinR, stdinW, err := os.Pipe()
+	ok(t, err)
+	process := &libcontainer.Process{
+		Cwd:   "/",
+		Args:  []string{"cat"},
+		Env:   standardEnvironment,
+		Stdin: stdinR,
+	}
+	err = container.Run(process)
+	stdinR.Close()
+	defer func() {
+		stdinW.Close()
+		if _, err := process.Wait(); err != nil {
+			t.Log(err)
+		}
+	}()
+	ok(t, err)
+
+	for path, code := range map[string]libcontainer.ErrorCode{
+		"/does-not-exist": libcontainer.ExecNotFound,
+		"/not-executable": libcontainer.ExecPermissionDenied,
+		"/not-a-binary":   libcontainer.ExecFormatInvalid,
+	} {
+		err = container.Run(&libcontainer.Process{
+			Cwd:  "/",
+			Args: []string{path},
+			Env:  standardEnvironment,
+		})
+		if err == nil {
+			t.Fatalf("expected exec of %s to fail", path)
+		}
+		lerr, ok := err.(libcontainer.Error)
+		if !ok || lerr.Code() != code {
+			t.Fatalf("expected exec of %s to fail with %q but got %v", path, code, err)
+		}
+	}
+}
+
 func TestExecInTTY(t *testing.T) {
 	if testing.Short() {
 		return
@@ -578,3 +636,567 @@ func TestExecInUserns(t *testing.T) {
 		t.Errorf("execin userns(%s), wanted %s", out, initUserns)
 	}
 }
+
+func TestAddRemoveNetwork(t *testing.T) {
+	if testing.Short() {
+		return
+	}
+	bridge := newTestBridge(t, "rctestbr0", "10.231.0.1/24")
+	defer netlink.LinkDel(bridge)
+
+	rootfs, err := newRootfs()
+	ok(t, err)
+	defer remove(rootfs)
+	config := newTemplateConfig(rootfs)
+	container, err := newContainer(config)
+	ok(t, err)
+	defer container.Destroy()
+
+	stdinR, stdinW, err := os.Pipe()
+	ok(t, err)
+	process := &libcontainer.Process{
+		Cwd:   "/",
+		Args:  []string{"cat"},
+		Env:   standardEnvironment,
+		Stdin: stdinR,
+	}
+	err = container.Run(process)
+	stdinR.Close()
+	defer stdinW.Close()
+	ok(t, err)
+
+	network := &configs.Network{
+		Type:              "veth",
+		Name:              "eth1",
+		Bridge:            bridge.Name,
+		HostInterfaceName: "rctestveth0",
+		Address:           "10.231.0.2/24",
+		Mtu:               1500,
+	}
+	ok(t, container.AddNetwork(network))
+	if err := container.AddNetwork(network); err == nil {
+		t.Fatal("expected adding a network with a duplicate name to fail")
+	}
+
+	buffers, err := execInContainer(container, "ping", "-c", "1", "-W", "2", "10.231.0.1")
+	if err != nil {
+		t.Fatalf("ping over added network failed: %v: %s", err, buffers)
+	}
+	state, err := container.State()
+	ok(t, err)
+	if n := state.Config.Networks[len(state.Config.Networks)-1]; n.Name != "eth1" {
+		t.Fatalf("expected eth1 to be recorded in the state, got %q", n.Name)
+	}
+
+	ok(t, container.RemoveNetwork("eth1"))
+	if _, err := netlink.LinkByName(network.HostInterfaceName); err == nil {
+		t.Fatalf("expected %s to be deleted", network.HostInterfaceName)
+	}
+	buffers, err = execInContainer(container, "cat", "/proc/net/dev")
+	ok(t, err)
+	if strings.Contains(buffers.Stdout.String(), "eth1") {
+		t.Fatalf("expected eth1 to be gone from the container, got %q", buffers.Stdout)
+	}
+	if err := container.RemoveNetwork("eth1"); err == nil {
+		t.Fatal("expected removing an unknown network to fail")
+	}
+
+	stdinW.Close()
+	waitProcess(process, t)
+}
+
+func TestMixedNetworks(t *testing.T) {
+	if testing.Short() {
+		return
+	}
+	bridge := newTestBridge(t, "rctestbr0", "10.232.0.1/24")
+	defer netlink.LinkDel(bridge)
+	// The veth pair only serves as a parent for the macvlan interfaces.
+	ok(t, netlink.LinkAdd(&netlink.Veth{
+		LinkAttrs: netlink.LinkAttrs{Name: "rctestmvpar0"},
+		PeerName:  "rctestmvpar1",
+	}))
+	parent, err := netlink.LinkByName("rctestmvpar0")
+	ok(t, err)
+	defer netlink.LinkDel(parent)
+	ok(t, netlink.LinkSetUp(parent))
+
+	rootfs, err := newRootfs()
+	ok(t, err)
+	defer remove(rootfs)
+	config := newTemplateConfig(rootfs)
+	config.Networks = append(config.Networks, &configs.Network{
+		Type:              "veth",
+		Name:              "eth1",
+		Bridge:            bridge.Name,
+		HostInterfaceName: "rctestveth0",
+		Address:           "10.232.0.2/24",
+		Mtu:               1500,
+	}, &configs.Network{
+		Type:    "macvlan",
+		Name:    "eth2",
+		Parent:  parent.Attrs().Name,
+		Address: "10.233.0.2/24",
+		Mtu:     1500,
+	})
+	container, err := newContainer(config)
+	ok(t, err)
+	defer container.Destroy()
+
+	stdinR, stdinW, err := os.Pipe()
+	ok(t, err)
+	process := &libcontainer.Process{
+		Cwd:   "/",
+		Args:  []string{"cat"},
+		Env:   standardEnvironment,
+		Stdin: stdinR,
+	}
+	err = container.Run(process)
+	stdinR.Close()
+	defer stdinW.Close()
+	ok(t, err)
+
+	buffers, err := execInContainer(container, "ping", "-c", "1", "-W", "2", "10.232.0.1")
+	if err != nil {
+		t.Fatalf("ping over the veth network failed: %v: %s", err, buffers)
+	}
+	buffers, err = execInContainer(container, "ip", "addr", "show", "eth2")
+	if err != nil {
+		t.Fatalf("showing the macvlan network failed: %v: %s", err, buffers)
+	}
+	if !strings.Contains(buffers.Stdout.String(), "10.233.0.2/24") {
+		t.Fatalf("expected eth2 to have its address, got %q", buffers.Stdout)
+	}
+
+	ok(t, container.RemoveNetwork("eth2"))
+	buffers, err = execInContainer(container, "cat", "/proc/net/dev")
+	ok(t, err)
+	if strings.Contains(buffers.Stdout.String(), "eth2") {
+		t.Fatalf("expected eth2 to be gone from the container, got %q", buffers.Stdout)
+	}
+	network := &configs.Network{
+		Type:    "macvlan",
+		Name:    "eth2",
+		Parent:  parent.Attrs().Name,
+		Address: "10.233.0.3/24",
+		Mtu:     1500,
+	}
+	ok(t, container.AddNetwork(network))
+
+	stdinW.Close()
+	waitProcess(process, t)
+	ok(t, container.Destroy())
+	if _, err := netlink.LinkByName("rctestveth0"); err == nil {
+		t.Fatal("expected the host side of the veth network to be deleted")
+	}
+	links, err := netlink.LinkList()
+	ok(t, err)
+	for _, link := range links {
+		if link.Attrs().ParentIndex == parent.Attrs().Index && link.Type() == "macvlan" {
+			t.Fatalf("expected the macvlan interfaces to be deleted, found %s", link.Attrs().Name)
+		}
+	}
+}
+
+func TestUpdateNetwork(t *testing.T) {
+	if testing.Short() {
+		return
+	}
+	bridge := newTestBridge(t, "rctestbr1", "10.232.0.1/24")
+	defer netlink.LinkDel(bridge)
+
+	rootfs, err := newRootfs()
+	ok(t, err)
+	defer remove(rootfs)
+	config := newTemplateConfig(rootfs)
+	container, err := newContainer(config)
+	ok(t, err)
+	defer container.Destroy()
+
+	stdinR, stdinW, err := os.Pipe()
+	ok(t, err)
+	process := &libcontainer.Process{
+		Cwd:   "/",
+		Args:  []string{"cat"},
+		Env:   standardEnvironment,
+		Stdin: stdinR,
+	}
+	err = container.Run(process)
+	stdinR.Close()
+	defer stdinW.Close()
+	ok(t, err)
+
+	network := &configs.Network{
+		Type:              "veth",
+		Name:              "eth1",
+		Bridge:            bridge.Name,
+		HostInterfaceName: "rctestveth1",
+		Address:           "10.232.0.2/24",
+		Mtu:               1500,
+	}
+	ok(t, container.AddNetwork(network))
+
+	// Re-addressing keeps the interface.
+	hostLink, err := netlink.LinkByName(network.HostInterfaceName)
+	ok(t, err)
+	readdressed := *network
+	readdressed.Address = "10.232.0.3/24"
+	ok(t, container.UpdateNetwork("eth1", &readdressed))
+	link, err := netlink.LinkByName(network.HostInterfaceName)
+	ok(t, err)
+	if link.Attrs().Index != hostLink.Attrs().Index {
+		t.Fatal("expected the interface to be re-addressed in place")
+	}
+	buffers, err := execInContainer(container, "ip", "-4", "addr", "show", "eth1")
+	ok(t, err)
+	if out := buffers.Stdout.String(); !strings.Contains(out, "10.232.0.3/24") || strings.Contains(out, "10.232.0.2/24") {
+		t.Fatalf("expected eth1 to only have the new address, got %q", out)
+	}
+	buffers, err = execInContainer(container, "ping", "-c", "1", "-W", "2", "10.232.0.1")
+	if err != nil {
+		t.Fatalf("ping from the new address failed: %v: %s", err, buffers)
+	}
+
+	// Moving the interface to another host interface name recreates it.
+	recreated := readdressed
+	recreated.HostInterfaceName = "rctestveth2"
+	recreated.Address = "10.232.0.4/24"
+	ok(t, container.UpdateNetwork("eth1", &recreated))
+	if _, err := netlink.LinkByName(network.HostInterfaceName); err == nil {
+		t.Fatalf("expected %s to be deleted", network.HostInterfaceName)
+	}
+	buffers, err = execInContainer(container, "ping", "-c", "1", "-W", "2", "10.232.0.1")
+	if err != nil {
+		t.Fatalf("ping over the recreated interface failed: %v: %s", err, buffers)
+	}
+	state, err := container.State()
+	ok(t, err)
+	if n := state.Config.Networks[len(state.Config.Networks)-1]; n.Address != recreated.Address || n.HostInterfaceName != recreated.HostInterfaceName {
+		t.Fatalf("expected the updated network to be recorded in the state, got %+v", n)
+	}
+
+	ok(t, container.RemoveNetwork("eth1"))
+	stdinW.Close()
+	waitProcess(process, t)
+}
+
+func TestExecInLeakedFd(t *testing.T) {
+	if testing.Short() {
+		return
+	}
+	rootfs, err := newRootfs()
+	ok(t, err)
+	defer remove(rootfs)
+	config := newTemplateConfig(rootfs)
+	container, err := newContainer(config)
+	ok(t, err)
+	defer container.Destroy()
+
+	stdinR, stdinW, err := os.Pipe()
+	ok(t, err)
+	process := &libcontainer.Process{
+		Cwd:   "/",
+		Args:  []string{"cat"},
+		Env:   standardEnvironment,
+		Stdin: stdinR,
+	}
+	err = container.Run(process)
+	stdinR.Close()
+	defer stdinW.Close()
+	ok(t, err)
+
+	// A descriptor of the manager without O_CLOEXEC.
+	leaked, err := syscall.Open("/dev/null", syscall.O_RDONLY, 0)
+	ok(t, err)
+	defer syscall.Close(leaked)
+	extra, err := os.Open("/dev/null")
+	ok(t, err)
+	defer extra.Close()
+
+	buffers := newStdBuffers()
+	ls := &libcontainer.Process{
+		Cwd:        "/",
+		Args:       []string{"sh", "-c", "ls /proc/$$/fd"},
+		Env:        standardEnvironment,
+		Stdin:      buffers.Stdin,
+		Stdout:     buffers.Stdout,
+		Stderr:     buffers.Stderr,
+		ExtraFiles: []*os.File{extra},
+	}
+	err = container.Run(ls)
+	ok(t, err)
+	waitProcess(ls, t)
+	stdinW.Close()
+	waitProcess(process, t)
+
+	fds := " " + strings.Join(strings.Fields(buffers.Stdout.String()), " ") + " "
+	if !strings.Contains(fds, " 3 ") {
+		t.Fatalf("expected the passed file to be open, got %q", fds)
+	}
+	if strings.Contains(fds, " "+strconv.Itoa(leaked)+" ") {
+		t.Fatalf("expected fd %d of the manager to be closed, got %q", leaked, fds)
+	}
+}
+
+func TestNetnsPath(t *testing.T) {
+	if testing.Short() {
+		return
+	}
+	nsenter, err := exec.LookPath("nsenter")
+	if err != nil {
+		t.Skip("nsenter is not installed")
+	}
+	rootfs, err := newRootfs()
+	ok(t, err)
+	defer remove(rootfs)
+	dir, err := ioutil.TempDir("", "netns")
+	ok(t, err)
+	defer os.RemoveAll(dir)
+	config := newTemplateConfig(rootfs)
+	config.NetnsPath = filepath.Join(dir, "ct")
+	container, err := newContainer(config)
+	ok(t, err)
+	defer container.Destroy()
+
+	stdinR, stdinW, err := os.Pipe()
+	ok(t, err)
+	process := &libcontainer.Process{
+		Cwd:   "/",
+		Args:  []string{"cat"},
+		Env:   standardEnvironment,
+		Stdin: stdinR,
+	}
+	err = container.Run(process)
+	stdinR.Close()
+	defer stdinW.Close()
+	ok(t, err)
+
+	buffers, err := execInContainer(container, "readlink", "/proc/self/ns/net")
+	ok(t, err)
+	out, err := exec.Command(nsenter, "--net="+config.NetnsPath, "readlink", "/proc/self/ns/net").CombinedOutput()
+	if err != nil {
+		t.Fatalf("entering %s failed: %v: %s", config.NetnsPath, err, out)
+	}
+	if got, expected := strings.TrimSpace(string(out)), strings.TrimSpace(buffers.Stdout.String()); got != expected {
+		t.Fatalf("expected to enter %s through %s, got %s", expected, config.NetnsPath, got)
+	}
+
+	stdinW.Close()
+	waitProcess(process, t)
+	ok(t, container.Destroy())
+	if _, err := os.Stat(config.NetnsPath); !os.IsNotExist(err) {
+		t.Fatalf("expected %s to be removed on destroy, got %v", config.NetnsPath, err)
+	}
+}
+
+// newTestBridge creates an up bridge with the given address on the host.
+func newTestBridge(t *testing.T, name, address string) *netlink.Bridge {
+	bridge := &netlink.Bridge{LinkAttrs: netlink.LinkAttrs{Name: name}}
+	ok(t, netlink.LinkAdd(bridge))
+	addr, err := netlink.ParseAddr(address)
+	ok(t, err)
+	ok(t, netlink.AddrAdd(bridge, addr))
+	ok(t, netlink.LinkSetUp(bridge))
+	return bridge
+}
+
+// execInContainer runs args in the running container and waits for it to exit.
+func execInContainer(container libcontainer.Container, args ...string) (*stdBuffers, error) {
+	buffers := newStdBuffers()
+	p := &libcontainer.Process{
+		Cwd:    "/",
+		Args:   args,
+		Env:    standardEnvironment,
+		Stdin:  buffers.Stdin,
+		Stdout: buffers.Stdout,
+		Stderr: buffers.Stderr,
+	}
+	if err := container.Run(p); err != nil {
+		return buffers, err
+	}
+	_, err := p.Wait()
+	return buffers, err
+}
+
+func TestSignalExecInProcess(t *testing.T) {
+	if testing.Short() {
+		return
+	}
+	rootfs, err := newRootfs()
+	ok(t, err)
+	defer remove(rootfs)
+	config := newTemplateConfig(rootfs)
+	container, err := newContainer(config)
+	ok(t, err)
+	defer container.Destroy()
+
+	startCat := func() (*libcontainer.Process, *os.File) {
+		stdinR, stdinW, err := os.Pipe()
+		ok(t, err)
+		process := &libcontainer.Process{
+			Cwd:   "/",
+			Args:  []string{"cat"},
+			Env:   standardEnvironment,
+			Stdin: stdinR,
+		}
+		err = container.Run(process)
+		stdinR.Close()
+		ok(t, err)
+		return process, stdinW
+	}
+	initial, initialStdin := startCat()
+	defer initialStdin.Close()
+	target, targetStdin := startCat()
+	defer targetStdin.Close()
+	worker, workerStdin := startCat()
+	defer workerStdin.Close()
+
+	pid, err := target.Pid()
+	ok(t, err)
+	ok(t, container.SignalProcess(pid, syscall.SIGKILL))
+	state, err := target.Wait()
+	if err == nil {
+		t.Fatal("expected the signaled process to be killed")
+	}
+	if status := state.Sys().(syscall.WaitStatus); !status.Signaled() || status.Signal() != syscall.SIGKILL {
+		t.Fatalf("expected the process to be killed by SIGKILL, got %v", status)
+	}
+	if err := container.SignalProcess(pid, syscall.SIGKILL); err == nil {
+		t.Fatal("expected signaling an exited process to fail")
+	}
+
+	workerStdin.Close()
+	waitProcess(worker, t)
+	initialStdin.Close()
+	waitProcess(initial, t)
+}
+
+func TestExecSessions(t *testing.T) {
+	if testing.Short() {
+		return
+	}
+	rootfs, err := newRootfs()
+	ok(t, err)
+	defer remove(rootfs)
+	config := newTemplateConfig(rootfs)
+	container, err := newContainer(config)
+	ok(t, err)
+	defer container.Destroy()
+
+	stdinR, stdinW, err := os.Pipe()
+	ok(t, err)
+	process := &libcontainer.Process{
+		Cwd:   "/",
+		Args:  []string{"cat"},
+		Env:   standardEnvironment,
+		Stdin: stdinR,
+	}
+	err = container.Run(process)
+	stdinR.Close()
+	defer stdinW.Close()
+	ok(t, err)
+
+	var execs []*libcontainer.Process
+	for _, id := range []string{"first", "second", ""} {
+		p := &libcontainer.Process{
+			Cwd:    "/",
+			Args:   []string{"sleep", "100"},
+			Env:    standardEnvironment,
+			ExecID: id,
+		}
+		ok(t, container.Run(p))
+		execs = append(execs, p)
+	}
+	if execs[2].ExecID == "" {
+		t.Fatal("expected an id to be generated for the exec session")
+	}
+
+	sessions := container.ExecSessions()
+	if len(sessions) != len(execs) {
+		t.Fatalf("expected %d exec sessions, got %+v", len(execs), sessions)
+	}
+	for i, p := range execs {
+		pid, err := p.Pid()
+		ok(t, err)
+		if sessions[i].ID != p.ExecID || sessions[i].Pid != pid {
+			t.Fatalf("expected session %q with pid %d, got %+v", p.ExecID, pid, sessions[i])
+		}
+	}
+	if err := container.Run(&libcontainer.Process{
+		Cwd:    "/",
+		Args:   []string{"true"},
+		Env:    standardEnvironment,
+		ExecID: "first",
+	}); err == nil {
+		t.Fatal("expected the id of a running exec session to be rejected")
+	}
+
+	for _, p := range execs {
+		ok(t, p.Signal(syscall.SIGKILL))
+		p.Wait()
+	}
+	if sessions := container.ExecSessions(); len(sessions) != 0 {
+		t.Fatalf("expected the exec sessions to be gone once they exited, got %+v", sessions)
+	}
+
+	stdinW.Close()
+	waitProcess(process, t)
+}
+
+func TestExecInSubCgroup(t *testing.T) {
+	if testing.Short() {
+		return
+	}
+	rootfs, err := newRootfs()
+	ok(t, err)
+	defer remove(rootfs)
+	config := newTemplateConfig(rootfs)
+	container, err := newContainer(config)
+	ok(t, err)
+	defer container.Destroy()
+
+	stdinR, stdinW, err := os.Pipe()
+	ok(t, err)
+	process := &libcontainer.Process{
+		Cwd:   "/",
+		Args:  []string{"cat"},
+		Env:   standardEnvironment,
+		Stdin: stdinR,
+	}
+	err = container.Run(process)
+	stdinR.Close()
+	defer stdinW.Close()
+	ok(t, err)
+
+	debug := &libcontainer.Process{
+		Cwd:       "/",
+		Args:      []string{"sleep", "100"},
+		Env:       standardEnvironment,
+		SubCgroup: "debug",
+	}
+	ok(t, container.Run(debug))
+	pid, err := debug.Pid()
+	ok(t, err)
+
+	state, err := container.State()
+	ok(t, err)
+	for _, subsystem := range []string{"memory", "pids", "freezer"} {
+		pids, err := cgroups.GetPids(filepath.Join(state.CgroupPaths[subsystem], "debug"))
+		ok(t, err)
+		if len(pids) != 1 || pids[0] != pid {
+			t.Fatalf("expected the exec-in process %d in the %s sub-cgroup, got %v", pid, subsystem, pids)
+		}
+	}
+	initPids, err := cgroups.GetPids(state.CgroupPaths["memory"])
+	ok(t, err)
+	for _, p := range initPids {
+		if p == pid {
+			t.Fatal("expected the exec-in process not to be in the container's own cgroup")
+		}
+	}
+
+	ok(t, debug.Signal(syscall.SIGKILL))
+	debug.Wait()
+	stdinW.Close()
+	waitProcess(process, t)
+}
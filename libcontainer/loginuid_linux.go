@@ -0,0 +1,49 @@
+// +build linux
+
+package libcontainer
+
+import (
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/Sirupsen/logrus"
+)
+
+const loginUIDPath = "/proc/self/loginuid"
+
+// setLoginUID sets the audit loginuid of the calling process to uid. The
+// kernel refuses that without CAP_AUDIT_CONTROL in the host's user namespace
+// or once an immutable loginuid is set, in which case the process keeps its
+// loginuid and setLoginUID only warns.
+func setLoginUID(uid *uint32) error {
+	if uid == nil {
+		return nil
+	}
+	data, err := ioutil.ReadFile(loginUIDPath)
+	if os.IsNotExist(err) {
+		logrus.Warnf("not setting loginuid %d, the kernel does not support auditing", *uid)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if strings.TrimSpace(string(data)) == strconv.FormatUint(uint64(*uid), 10) {
+		return nil
+	}
+	f, err := os.OpenFile(loginUIDPath, os.O_WRONLY, 0)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if _, err := f.WriteString(strconv.FormatUint(uint64(*uid), 10)); err != nil {
+		if perr, ok := err.(*os.PathError); ok && (perr.Err == syscall.EPERM || perr.Err == syscall.EACCES) {
+			logrus.Warnf("audit policy does not allow setting loginuid %d: %v", *uid, err)
+			return nil
+		}
+		return err
+	}
+	return nil
+}
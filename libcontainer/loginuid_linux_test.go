@@ -0,0 +1,68 @@
+// +build linux
+
+package libcontainer
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+// TestSetLoginUID runs TestSetLoginUIDHelper, which sets its loginuid and
+// prints the loginuid and audit session it ends up with.
+func TestSetLoginUID(t *testing.T) {
+	before, err := ioutil.ReadFile(loginUIDPath)
+	if err != nil {
+		t.Skipf("auditing is unsupported: %v", err)
+	}
+	session, err := ioutil.ReadFile("/proc/self/sessionid")
+	if err != nil {
+		t.Skipf("auditing is unsupported: %v", err)
+	}
+	cmd := exec.Command(os.Args[0], "-test.run=^TestSetLoginUIDHelper$")
+	cmd.Env = append(os.Environ(), "LIBCONTAINER_TEST_LOGINUID=1")
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("loginuid helper failed: %v\n%s", err, out)
+	}
+	var loginUID, newSession string
+	if _, err := fmt.Sscan(string(out), &loginUID, &newSession); err != nil {
+		t.Fatalf("unexpected helper output %q", out)
+	}
+	if loginUID == strings.TrimSpace(string(before)) {
+		t.Skip("the audit policy does not allow setting the loginuid")
+	}
+	if loginUID != "1234" {
+		t.Fatalf("expected loginuid 1234, got %s", loginUID)
+	}
+	if newSession == strings.TrimSpace(string(session)) {
+		t.Fatalf("expected setting the loginuid to start a new audit session, still in %s", newSession)
+	}
+}
+
+func TestSetLoginUIDHelper(t *testing.T) {
+	if os.Getenv("LIBCONTAINER_TEST_LOGINUID") != "1" {
+		return
+	}
+	uid := uint32(1234)
+	if err := setLoginUID(&uid); err != nil {
+		t.Fatal(err)
+	}
+	// Setting the same loginuid again is a no-op even if it is immutable.
+	if err := setLoginUID(&uid); err != nil {
+		t.Fatal(err)
+	}
+	loginUID, err := ioutil.ReadFile(loginUIDPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	session, err := ioutil.ReadFile("/proc/self/sessionid")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fmt.Println(strings.TrimSpace(string(loginUID)), strings.TrimSpace(string(session)))
+	os.Exit(0)
+}
@@ -0,0 +1,49 @@
+// +build linux
+
+package libcontainer
+
+import (
+	"io"
+	"os"
+
+	"github.com/opencontainers/runc/libcontainer/system"
+)
+
+// reaperName is argv[0] of the reaper that a minimal init execs, which the
+// constructor of nsenter runs instead of the init binary.
+const reaperName = "runc:[reaper]"
+
+// newReaperFile copies the init binary at path into a sealed memfd for the
+// reaper to be exec'd from. The container's pid 1 then does not refer to the
+// binary on the host, which it could otherwise overwrite through
+// /proc/1/exe.
+func newReaperFile(path string) (*os.File, error) {
+	src, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer src.Close()
+	f, err := system.MemfdCreate(reaperName)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := io.Copy(f, src); err != nil {
+		f.Close()
+		return nil, err
+	}
+	if err := system.AddSeals(f, system.F_SEAL_SEAL|system.F_SEAL_SHRINK|system.F_SEAL_GROW|system.F_SEAL_WRITE); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return f, nil
+}
+
+// execReaper execs the reaper from fd, which runs name with args as its
+// child and stays behind as the container's pid 1. Exec'ing closes every
+// descriptor marked O_CLOEXEC and leaves the reaper a single thread with
+// the credentials of the init. It only returns on failure.
+func execReaper(fd int, name string, args []string, env []string) error {
+	argv := append([]string{reaperName}, args...)
+	env = append(env[:len(env):len(env)], "_LIBCONTAINER_REAPER="+name)
+	return system.Fexecve(fd, argv, env)
+}
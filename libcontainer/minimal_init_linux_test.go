@@ -0,0 +1,36 @@
+// +build linux
+
+package libcontainer
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"testing"
+)
+
+func TestNewReaperFile(t *testing.T) {
+	f, err := newReaperFile(os.Args[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	binary, err := ioutil.ReadFile(os.Args[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	copied, err := ioutil.ReadFile("/proc/self/fd/" + strconv.Itoa(int(f.Fd())))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(binary, copied) {
+		t.Fatalf("expected the reaper file to be a copy of %s", os.Args[0])
+	}
+	if _, err := f.WriteAt([]byte{0}, 0); err == nil {
+		t.Fatal("expected writing to the sealed reaper file to fail")
+	}
+	if err := f.Truncate(0); err == nil {
+		t.Fatal("expected truncating the sealed reaper file to fail")
+	}
+}
@@ -0,0 +1,195 @@
+// +build linux
+
+package libcontainer
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/docker/docker/pkg/mount"
+	"github.com/opencontainers/runc/libcontainer/configs"
+)
+
+// MountDrift is a difference between the mounts of a running container and
+// the mounts in its configuration.
+type MountDrift struct {
+	// Destination is the mount point inside the container.
+	Destination string
+
+	// Missing is set for a configured mount that is not mounted. Otherwise
+	// the mount is mounted but not part of the configuration.
+	Missing bool
+
+	// Device and Source describe the mount, as configured for a missing
+	// mount and as found in the container for an unexpected one.
+	Device string
+	Source string
+}
+
+func (c *linuxContainer) VerifyMounts() ([]MountDrift, error) {
+	c.m.Lock()
+	defer c.m.Unlock()
+	if _, err := c.runningState(); err != nil {
+		return nil, err
+	}
+	f, err := os.Open(fmt.Sprintf("/proc/%d/mountinfo", c.initProcess.pid()))
+	if err != nil {
+		return nil, newSystemErrorWithCause(err, "opening mountinfo of init process")
+	}
+	defer f.Close()
+	mounts, err := parseMountInfo(f)
+	if err != nil {
+		return nil, newSystemErrorWithCause(err, "parsing mountinfo of init process")
+	}
+	return compareMounts(c.config, mounts), nil
+}
+
+// compareMounts returns how the mounts differ from the ones that setting up
+// the rootfs for config creates.
+func compareMounts(config *configs.Config, mounts []*mount.Info) []MountDrift {
+	// Mounts that libcontainer creates in addition to the configured ones.
+	expected := map[string]bool{
+		"/":            true,
+		"/dev/console": true,
+	}
+	for _, p := range config.MaskPaths {
+		expected[filepath.Clean(p)] = true
+	}
+	for _, p := range config.ReadonlyPaths {
+		expected[filepath.Clean(p)] = true
+	}
+	for _, p := range hostProcMaskPaths(config) {
+		expected[filepath.Clean(p)] = true
+	}
+	for _, d := range config.Devices {
+		// Devices are bind mounted when they cannot be created.
+		expected[filepath.Clean(d.Path)] = true
+	}
+	// Anything may be mounted below a cgroup mount, which holds one mount per
+	// subsystem, and below a recursive bind mount, which brings the mounts
+	// below its source along. So does the cgroup view.
+	var trees []string
+	if config.CgroupView != nil {
+		trees = append(trees, filepath.Clean(config.CgroupView.Destination))
+	}
+	configured := config.AllMounts()
+	for _, m := range configured {
+		dest := filepath.Clean(m.Destination)
+		expected[dest] = true
+		if m.Device == "cgroup" || (m.Device == "bind" && m.Flags&syscall.MS_REC != 0) || (m.Device == "proc" && bindsHostProc(config)) {
+			trees = append(trees, dest)
+		}
+	}
+
+	var drift []MountDrift
+	mounted := make(map[string]bool, len(mounts))
+	for _, m := range mounts {
+		mounted[m.Mountpoint] = true
+		if expected[m.Mountpoint] || inTrees(m.Mountpoint, trees) {
+			continue
+		}
+		drift = append(drift, MountDrift{
+			Destination: m.Mountpoint,
+			Device:      m.Fstype,
+			Source:      m.Source,
+		})
+	}
+	for _, m := range configured {
+		if dest := filepath.Clean(m.Destination); !mounted[dest] {
+			drift = append(drift, MountDrift{
+				Destination: dest,
+				Missing:     true,
+				Device:      m.Device,
+				Source:      m.Source,
+			})
+		}
+	}
+	sort.Sort(driftByDestination(drift))
+	return drift
+}
+
+// driftByDestination sorts mount drift by destination.
+type driftByDestination []MountDrift
+
+func (d driftByDestination) Len() int           { return len(d) }
+func (d driftByDestination) Less(i, j int) bool { return d[i].Destination < d[j].Destination }
+func (d driftByDestination) Swap(i, j int)      { d[i], d[j] = d[j], d[i] }
+
+// inTrees reports whether path is strictly below one of the trees.
+func inTrees(path string, trees []string) bool {
+	for _, t := range trees {
+		if t == "/" || strings.HasPrefix(path, t+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// parseMountInfo parses the mount points, filesystem types and sources out of
+// a mountinfo file, see proc(5).
+func parseMountInfo(r io.Reader) ([]*mount.Info, error) {
+	var (
+		mounts []*mount.Info
+		s      = bufio.NewScanner(r)
+	)
+	for s.Scan() {
+		fields := strings.Fields(s.Text())
+		sep := -1
+		for i := 6; i < len(fields); i++ {
+			if fields[i] == "-" {
+				sep = i
+				break
+			}
+		}
+		if sep < 0 || sep+2 >= len(fields) {
+			return nil, fmt.Errorf("invalid mountinfo line %q", s.Text())
+		}
+		mountpoint, err := unescapeMountInfo(fields[4])
+		if err != nil {
+			return nil, err
+		}
+		source, err := unescapeMountInfo(fields[sep+2])
+		if err != nil {
+			return nil, err
+		}
+		mounts = append(mounts, &mount.Info{
+			Mountpoint: mountpoint,
+			Opts:       fields[5],
+			Fstype:     fields[sep+1],
+			Source:     source,
+		})
+	}
+	return mounts, s.Err()
+}
+
+// unescapeMountInfo decodes the octal escapes that mountinfo uses for spaces,
+// tabs, newlines and backslashes.
+func unescapeMountInfo(s string) (string, error) {
+	if !strings.Contains(s, `\`) {
+		return s, nil
+	}
+	b := make([]byte, 0, len(s))
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' {
+			b = append(b, s[i])
+			continue
+		}
+		if i+3 >= len(s) {
+			return "", fmt.Errorf("invalid escape in mountinfo field %q", s)
+		}
+		c, err := strconv.ParseUint(s[i+1:i+4], 8, 8)
+		if err != nil {
+			return "", fmt.Errorf("invalid escape in mountinfo field %q", s)
+		}
+		b = append(b, byte(c))
+		i += 3
+	}
+	return string(b), nil
+}
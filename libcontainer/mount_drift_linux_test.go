@@ -0,0 +1,65 @@
+// +build linux
+
+package libcontainer
+
+import (
+	"reflect"
+	"strings"
+	"syscall"
+	"testing"
+
+	"github.com/opencontainers/runc/libcontainer/configs"
+)
+
+const driftMountInfo = `100 99 8:1 /rootfs / rw,relatime - ext4 /dev/sda1 rw
+101 100 0:40 / /proc rw,nosuid,nodev,noexec - proc proc rw
+102 100 0:41 / /dev rw,nosuid - tmpfs tmpfs rw,mode=755
+103 102 0:42 / /dev/pts rw,nosuid,noexec - devpts devpts rw
+104 100 0:43 / /sys/fs/cgroup ro - tmpfs tmpfs ro
+105 104 0:44 / /sys/fs/cgroup/memory ro - cgroup cgroup ro,memory
+106 100 8:1 /home /home rw - ext4 /dev/sda1 rw
+107 106 0:45 / /home/user rw - tmpfs tmpfs rw
+108 100 0:46 / /injected rw - tmpfs host\040tmpfs rw
+109 101 0:40 /sys /proc/sys ro - proc proc rw
+110 100 0:47 / /var rw,nosuid,nodev - tmpfs tmpfs rw,mode=755
+111 100 0:44 /ct /run/cgroup/memory ro,nosuid,nodev,noexec - cgroup cgroup ro,memory
+`
+
+func TestCompareMounts(t *testing.T) {
+	mounts, err := parseMountInfo(strings.NewReader(driftMountInfo))
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := &configs.Config{
+		Mounts: []*configs.Mount{
+			{Source: "proc", Destination: "/proc", Device: "proc"},
+			{Source: "tmpfs", Destination: "/dev", Device: "tmpfs"},
+			{Source: "devpts", Destination: "/dev/pts", Device: "devpts"},
+			{Source: "cgroup", Destination: "/sys/fs/cgroup", Device: "cgroup"},
+			{Source: "/home", Destination: "/home/", Device: "bind", Flags: syscall.MS_BIND | syscall.MS_REC},
+			{Source: "/data", Destination: "/data", Device: "bind", Flags: syscall.MS_BIND},
+		},
+		TmpfsDirs:     []string{"/var", "/run"},
+		CgroupView:    &configs.CgroupView{Destination: "/run/cgroup/"},
+		ReadonlyPaths: []string{"/proc/sys"},
+	}
+	expected := []MountDrift{
+		{Destination: "/data", Missing: true, Device: "bind", Source: "/data"},
+		{Destination: "/injected", Device: "tmpfs", Source: "host tmpfs"},
+		{Destination: "/run", Missing: true, Device: "tmpfs", Source: "tmpfs"},
+	}
+	if drift := compareMounts(config, mounts); !reflect.DeepEqual(drift, expected) {
+		t.Fatalf("expected drift %+v, got %+v", expected, drift)
+	}
+}
+
+func TestParseMountInfoInvalid(t *testing.T) {
+	for _, line := range []string{
+		"100 99 8:1 / / rw",
+		"100 99 8:1 / /bad\\04 rw - ext4 /dev/sda1 rw",
+	} {
+		if _, err := parseMountInfo(strings.NewReader(line)); err == nil {
+			t.Errorf("expected an error for %q", line)
+		}
+	}
+}
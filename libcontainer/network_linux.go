@@ -6,18 +6,28 @@ import (
 	"fmt"
 	"io/ioutil"
 	"net"
+	"os"
 	"path/filepath"
+	"runtime"
 	"strconv"
 	"strings"
+	"syscall"
 
+	"github.com/docker/docker/pkg/mount"
 	"github.com/opencontainers/runc/libcontainer/configs"
+	"github.com/opencontainers/runc/libcontainer/system"
 	"github.com/opencontainers/runc/libcontainer/utils"
 	"github.com/vishvananda/netlink"
 )
 
+// sysfsNetPath is where the kernel exposes the network interfaces of the
+// current network namespace.
+var sysfsNetPath = "/sys/class/net"
+
 var strategies = map[string]networkStrategy{
 	"veth":     &veth{},
 	"loopback": &loopback{},
+	"macvlan":  &macvlan{},
 }
 
 // networkStrategy represents a specific network configuration for
@@ -25,8 +35,10 @@ var strategies = map[string]networkStrategy{
 type networkStrategy interface {
 	create(*network, int) error
 	initialize(*network) error
+	reconfigure(*configs.Network) error
 	detach(*configs.Network) error
 	attach(*configs.Network) error
+	remove(*configs.Network, int) error
 }
 
 // getStrategy returns the specific network strategy for the
@@ -39,6 +51,138 @@ func getStrategy(tpe string) (networkStrategy, error) {
 	return s, nil
 }
 
+// inNetns runs fn on a thread switched into the network namespace of pid and
+// waits for it to return. fn runs on a goroutine of its own that keeps the
+// thread locked, so that no other goroutine observes the foreign namespace.
+func inNetns(pid int, fn func() error) error {
+	return inNetnsPath(fmt.Sprintf("/proc/%d/ns/net", pid), fn)
+}
+
+// inNetnsPath is inNetns for the network namespace at path.
+func inNetnsPath(path string, fn func() error) error {
+	errc := make(chan error, 1)
+	go func() {
+		runtime.LockOSThread()
+		restored, err := runInNetns(path, fn)
+		// A thread that cannot be moved back must never be reused, so the
+		// goroutine exits with it locked and the runtime discards it.
+		if restored {
+			runtime.UnlockOSThread()
+		}
+		errc <- err
+	}()
+	return <-errc
+}
+
+// runInNetns runs fn with the locked calling thread in the network namespace
+// at path. It reports whether the thread is back in its original namespace.
+func runInNetns(path string, fn func() error) (bool, error) {
+	origin, err := os.Open("/proc/self/task/" + strconv.Itoa(syscall.Gettid()) + "/ns/net")
+	if err != nil {
+		return true, err
+	}
+	defer origin.Close()
+	target, err := os.Open(path)
+	if err != nil {
+		return true, err
+	}
+	defer target.Close()
+	if err := system.Setns(target.Fd(), syscall.CLONE_NEWNET); err != nil {
+		return true, err
+	}
+	err = fn()
+	if serr := system.Setns(origin.Fd(), syscall.CLONE_NEWNET); serr != nil {
+		if err == nil {
+			err = serr
+		}
+		return false, err
+	}
+	return true, err
+}
+
+// networkNeedsRecreate reports whether changing the network from old to n
+// requires the interface to be created again rather than reconfigured.
+func networkNeedsRecreate(old, n *configs.Network) bool {
+	return old.Type != n.Type ||
+		old.Name != n.Name ||
+		old.Bridge != n.Bridge ||
+		old.HostInterfaceName != n.HostInterfaceName ||
+		old.TxQueueLen != n.TxQueueLen ||
+		old.HairpinMode != n.HairpinMode ||
+		old.Parent != n.Parent ||
+		old.MacvlanMode != n.MacvlanMode
+}
+
+// networkRemovable reports whether the interface of the network can be
+// removed from a running container. The container's side of a veth pair is
+// removed through the host side, which needs a name for that.
+func networkRemovable(n *configs.Network) bool {
+	switch n.Type {
+	case "veth":
+		return n.HostInterfaceName != ""
+	case "macvlan":
+		return true
+	}
+	return false
+}
+
+// setHostInterfaceMTU sets the MTU of the named link in the caller's network
+// namespace.
+func setHostInterfaceMTU(name string, mtu int) error {
+	link, err := netlink.LinkByName(name)
+	if err != nil {
+		return err
+	}
+	return netlink.LinkSetMTU(link, mtu)
+}
+
+// deleteHostInterface deletes the named link in the caller's network namespace.
+func deleteHostInterface(name string) error {
+	link, err := netlink.LinkByName(name)
+	if err != nil {
+		return err
+	}
+	return netlink.LinkDel(link)
+}
+
+// bindNetns bind mounts the network namespace of pid at path. It refuses a
+// path that is a mount point already, which another container or the host may
+// be using.
+func bindNetns(path string, pid int) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	mounted, err := mount.Mounted(path)
+	if err != nil {
+		return err
+	}
+	if mounted {
+		return fmt.Errorf("%s is a mount point already", path)
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDONLY, 0444)
+	if err != nil {
+		return err
+	}
+	f.Close()
+	if err := syscall.Mount(fmt.Sprintf("/proc/%d/ns/net", pid), path, "", syscall.MS_BIND, ""); err != nil {
+		os.Remove(path)
+		return err
+	}
+	return nil
+}
+
+// unbindNetns removes the bind mount of a network namespace at path along
+// with the file.
+func unbindNetns(path string) error {
+	if err := syscall.Unmount(path, syscall.MNT_DETACH); err != nil && err != syscall.EINVAL && err != syscall.ENOENT {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
 // Returns the network statistics for the network interfaces represented by the NetworkRuntimeInfo.
 func getNetworkInterfaceStats(interfaceName string) (*NetworkInterface, error) {
 	out := &NetworkInterface{Name: interfaceName}
@@ -64,6 +208,8 @@ func getNetworkInterfaceStats(interfaceName string) (*NetworkInterface, error) {
 		{Out: &out.TxPackets, File: "rx_packets"},
 		{Out: &out.TxErrors, File: "rx_errors"},
 		{Out: &out.TxDropped, File: "rx_dropped"},
+
+		{Out: &out.Collisions, File: "collisions"},
 	}
 	for _, netStat := range netStats {
 		data, err := readSysfsNetworkStats(interfaceName, netStat.File)
@@ -77,13 +223,39 @@ func getNetworkInterfaceStats(interfaceName string) (*NetworkInterface, error) {
 
 // Reads the specified statistics available under /sys/class/net/<EthInterface>/statistics
 func readSysfsNetworkStats(ethInterface, statsFile string) (uint64, error) {
-	data, err := ioutil.ReadFile(filepath.Join("/sys/class/net", ethInterface, "statistics", statsFile))
+	data, err := ioutil.ReadFile(filepath.Join(sysfsNetPath, ethInterface, "statistics", statsFile))
 	if err != nil {
 		return 0, err
 	}
 	return strconv.ParseUint(strings.TrimSpace(string(data)), 10, 64)
 }
 
+// setupArp writes the ARP settings of the network to the sysctls of its
+// interface. It must be called from within the container's network namespace.
+func setupArp(n *network) error {
+	name := n.Name
+	if n.Type == "loopback" {
+		name = "lo"
+	}
+	conf := filepath.Join("/proc/sys/net/ipv4/conf", name)
+	settings := map[string]int{}
+	if n.ArpIgnore != 0 {
+		settings["arp_ignore"] = n.ArpIgnore
+	}
+	if n.ArpAnnounce != 0 {
+		settings["arp_announce"] = n.ArpAnnounce
+	}
+	if n.ProxyArp {
+		settings["proxy_arp"] = 1
+	}
+	for file, value := range settings {
+		if err := ioutil.WriteFile(filepath.Join(conf, file), []byte(strconv.Itoa(value)), 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // loopback is a network strategy that provides a basic loopback device
 type loopback struct {
 }
@@ -96,6 +268,10 @@ func (l *loopback) initialize(config *network) error {
 	return netlink.LinkSetUp(&netlink.Device{LinkAttrs: netlink.LinkAttrs{Name: "lo"}})
 }
 
+func (l *loopback) reconfigure(n *configs.Network) error {
+	return nil
+}
+
 func (l *loopback) attach(n *configs.Network) (err error) {
 	return nil
 }
@@ -104,6 +280,10 @@ func (l *loopback) detach(n *configs.Network) (err error) {
 	return nil
 }
 
+func (l *loopback) remove(n *configs.Network, nspid int) error {
+	return fmt.Errorf("loopback network %q cannot be removed", n.Name)
+}
+
 // veth is a network strategy that uses a bridge and creates
 // a veth pair, one that is attached to the bridge on the host and the other
 // is placed inside the container's namespace
@@ -114,6 +294,12 @@ func (v *veth) detach(n *configs.Network) (err error) {
 	return netlink.LinkSetMaster(&netlink.Device{LinkAttrs: netlink.LinkAttrs{Name: n.HostInterfaceName}}, nil)
 }
 
+// remove deletes the host side of the veth pair, which also deletes the peer
+// inside the container and so releases its addresses and routes.
+func (v *veth) remove(n *configs.Network, nspid int) error {
+	return deleteHostInterface(n.HostInterfaceName)
+}
+
 // attach a container network interface to an external network
 func (v *veth) attach(n *configs.Network) (err error) {
 	brl, err := netlink.LinkByName(n.Bridge)
@@ -148,9 +334,11 @@ func (v *veth) attach(n *configs.Network) (err error) {
 }
 
 func (v *veth) create(n *network, nspid int) (err error) {
-	tmpName, err := v.generateTempPeerName()
-	if err != nil {
-		return err
+	tmpName := n.PeerName
+	if tmpName == "" {
+		if tmpName, err = v.generateTempPeerName(); err != nil {
+			return err
+		}
 	}
 	n.TempVethPeerName = tmpName
 	if n.Bridge == "" {
@@ -186,6 +374,13 @@ func (v *veth) generateTempPeerName() (string, error) {
 }
 
 func (v *veth) initialize(config *network) error {
+	return initializeInterface(config)
+}
+
+// initializeInterface renames the interface that was placed into the
+// container's namespace under its temporary name and configures it. It must
+// be called from within the container's network namespace.
+func initializeInterface(config *network) error {
 	peer := config.TempVethPeerName
 	if peer == "" {
 		return fmt.Errorf("peer is not specified")
@@ -204,6 +399,127 @@ func (v *veth) initialize(config *network) error {
 	if child, err = netlink.LinkByName(config.Name); err != nil {
 		return err
 	}
+	return configureInterface(child, &config.Network)
+}
+
+// reconfigure replaces the addresses and gateways of the container's side of
+// the veth pair. It must be called from within the container's network
+// namespace.
+func (v *veth) reconfigure(config *configs.Network) error {
+	return reconfigureInterface(config)
+}
+
+// reconfigureInterface replaces the addresses and gateways of the container's
+// interface.
+func reconfigureInterface(config *configs.Network) error {
+	child, err := netlink.LinkByName(config.Name)
+	if err != nil {
+		return err
+	}
+	// Taking the interface down drops the routes through it so that they
+	// can be added again for the new addresses.
+	if err := netlink.LinkSetDown(child); err != nil {
+		return err
+	}
+	addrs, err := netlink.AddrList(child, netlink.FAMILY_ALL)
+	if err != nil {
+		return err
+	}
+	for _, addr := range addrs {
+		if addr.IP.IsLinkLocalUnicast() {
+			continue
+		}
+		if err := netlink.AddrDel(child, &addr); err != nil {
+			return err
+		}
+	}
+	return configureInterface(child, config)
+}
+
+// macvlan is a network strategy that creates a macvlan interface on a host
+// interface and places it inside the container's namespace. It has no host
+// side, so there is nothing to move off a bridge or clean up on the host.
+type macvlan struct {
+}
+
+// macvlanModes maps the modes of the config onto the kernel's.
+var macvlanModes = map[string]netlink.MacvlanMode{
+	"":         netlink.MACVLAN_MODE_BRIDGE,
+	"bridge":   netlink.MACVLAN_MODE_BRIDGE,
+	"private":  netlink.MACVLAN_MODE_PRIVATE,
+	"vepa":     netlink.MACVLAN_MODE_VEPA,
+	"passthru": netlink.MACVLAN_MODE_PASSTHRU,
+}
+
+func (m *macvlan) create(n *network, nspid int) (err error) {
+	mode, ok := macvlanModes[n.MacvlanMode]
+	if !ok {
+		return fmt.Errorf("unknown macvlan mode %q", n.MacvlanMode)
+	}
+	parent, err := netlink.LinkByName(n.Parent)
+	if err != nil {
+		return err
+	}
+	if n.TempVethPeerName, err = utils.GenerateRandomName("mvlan", 7); err != nil {
+		return err
+	}
+	link := &netlink.Macvlan{
+		LinkAttrs: netlink.LinkAttrs{
+			Name:        n.TempVethPeerName,
+			ParentIndex: parent.Attrs().Index,
+			TxQLen:      n.TxQueueLen,
+		},
+		Mode: mode,
+	}
+	if err := netlink.LinkAdd(link); err != nil {
+		return err
+	}
+	if err := netlink.LinkSetNsPid(link, nspid); err != nil {
+		netlink.LinkDel(link)
+		return err
+	}
+	return nil
+}
+
+// initialize configures the macvlan interface and deletes it if that fails,
+// as nothing on the host would delete it otherwise.
+func (m *macvlan) initialize(config *network) (err error) {
+	defer func() {
+		if err != nil {
+			for _, name := range []string{config.TempVethPeerName, config.Name} {
+				if deleteHostInterface(name) == nil {
+					break
+				}
+			}
+		}
+	}()
+	return initializeInterface(config)
+}
+
+func (m *macvlan) reconfigure(config *configs.Network) error {
+	return reconfigureInterface(config)
+}
+
+// attach and detach have nothing to do, the interface is only reachable
+// from inside the container.
+func (m *macvlan) attach(n *configs.Network) error {
+	return nil
+}
+
+func (m *macvlan) detach(n *configs.Network) error {
+	return nil
+}
+
+// remove deletes the macvlan interface inside the container's namespace.
+func (m *macvlan) remove(n *configs.Network, nspid int) error {
+	return inNetns(nspid, func() error {
+		return deleteHostInterface(n.Name)
+	})
+}
+
+// configureInterface sets the addresses, MTU and gateways of config on the
+// container's interface child and brings it up.
+func configureInterface(child netlink.Link, config *configs.Network) error {
 	if config.MacAddress != "" {
 		mac, err := net.ParseMAC(config.MacAddress)
 		if err != nil {
@@ -232,28 +548,64 @@ func (v *veth) initialize(config *network) error {
 	if err := netlink.LinkSetMTU(child, config.Mtu); err != nil {
 		return err
 	}
+	if err := setOffloads(child.Attrs().Name, config.Offloads); err != nil {
+		return err
+	}
 	if err := netlink.LinkSetUp(child); err != nil {
 		return err
 	}
 	if config.Gateway != "" {
 		gw := net.ParseIP(config.Gateway)
-		if err := netlink.RouteAdd(&netlink.Route{
+		if err := addRoute(&netlink.Route{
 			Scope:     netlink.SCOPE_UNIVERSE,
 			LinkIndex: child.Attrs().Index,
 			Gw:        gw,
-		}); err != nil {
+		}, config.RouteTable); err != nil {
 			return err
 		}
 	}
 	if config.IPv6Gateway != "" {
 		gw := net.ParseIP(config.IPv6Gateway)
-		if err := netlink.RouteAdd(&netlink.Route{
+		if err := addRoute(&netlink.Route{
 			Scope:     netlink.SCOPE_UNIVERSE,
 			LinkIndex: child.Attrs().Index,
 			Gw:        gw,
-		}); err != nil {
+		}, config.RouteTable); err != nil {
 			return err
 		}
 	}
+	return addRouteTableRules(config)
+}
+
+// addRoute adds route to the routing table, or to the main table if table is
+// zero.
+func addRoute(route *netlink.Route, table int) error {
+	if table == 0 {
+		return netlink.RouteAdd(route)
+	}
+	return addTableRoute(route, table)
+}
+
+// setOffloads applies the offload settings to the named interface.
+func setOffloads(name string, offloads *configs.Offloads) error {
+	if offloads == nil {
+		return nil
+	}
+	for _, o := range []struct {
+		offload system.Offload
+		on      *bool
+		name    string
+	}{
+		{system.OffloadTSO, offloads.TSO, "tso"},
+		{system.OffloadGSO, offloads.GSO, "gso"},
+		{system.OffloadGRO, offloads.GRO, "gro"},
+	} {
+		if o.on == nil {
+			continue
+		}
+		if err := system.SetOffload(name, o.offload, *o.on); err != nil {
+			return fmt.Errorf("setting %s of %s: %v", o.name, name, err)
+		}
+	}
 	return nil
 }
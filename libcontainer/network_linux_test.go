@@ -0,0 +1,337 @@
+// +build linux
+
+package libcontainer
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"syscall"
+	"testing"
+
+	"github.com/opencontainers/runc/libcontainer/configs"
+	"github.com/opencontainers/runc/libcontainer/system"
+	"github.com/vishvananda/netlink"
+)
+
+func TestGetNetworkInterfaceStats(t *testing.T) {
+	root, err := ioutil.TempDir("", "libcontainer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	statistics := filepath.Join(root, "veth0", "statistics")
+	if err := os.MkdirAll(statistics, 0755); err != nil {
+		t.Fatal(err)
+	}
+	for file, value := range map[string]string{
+		"rx_bytes":   "1000\n",
+		"rx_packets": "10\n",
+		"rx_errors":  "1\n",
+		"rx_dropped": "2\n",
+		"tx_bytes":   "2000\n",
+		"tx_packets": "20\n",
+		"tx_errors":  "3\n",
+		"tx_dropped": "4\n",
+		"collisions": "5\n",
+	} {
+		if err := ioutil.WriteFile(filepath.Join(statistics, file), []byte(value), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	defer func(path string) { sysfsNetPath = path }(sysfsNetPath)
+	sysfsNetPath = root
+
+	stats, err := getNetworkInterfaceStats("veth0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The host side of the veth pair sees the container's traffic in the
+	// opposite direction.
+	expected := &NetworkInterface{
+		Name:       "veth0",
+		RxBytes:    2000,
+		RxPackets:  20,
+		RxErrors:   3,
+		RxDropped:  4,
+		TxBytes:    1000,
+		TxPackets:  10,
+		TxErrors:   1,
+		TxDropped:  2,
+		Collisions: 5,
+	}
+	if !reflect.DeepEqual(stats, expected) {
+		t.Fatalf("expected %+v but received %+v", expected, stats)
+	}
+
+	if err := os.Remove(filepath.Join(statistics, "collisions")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := getNetworkInterfaceStats("veth0"); err == nil {
+		t.Fatal("expected an error when a statistics file is missing")
+	}
+}
+
+func TestNetworkNeedsRecreate(t *testing.T) {
+	old := &configs.Network{
+		Type:              "veth",
+		Name:              "eth0",
+		Bridge:            "br0",
+		HostInterfaceName: "veth0",
+		Address:           "10.0.0.2/24",
+		Gateway:           "10.0.0.1",
+		Mtu:               1500,
+	}
+	for _, test := range []struct {
+		change   func(n *configs.Network)
+		recreate bool
+	}{
+		{func(n *configs.Network) { n.Address = "10.0.0.3/24" }, false},
+		{func(n *configs.Network) { n.Gateway = "10.0.0.254" }, false},
+		{func(n *configs.Network) { n.Mtu = 9000 }, false},
+		{func(n *configs.Network) { n.MacAddress = "02:42:ac:11:00:02" }, false},
+		{func(n *configs.Network) { n.ProxyArp = true }, false},
+		{func(n *configs.Network) { n.Bridge = "br1" }, true},
+		{func(n *configs.Network) { n.Name = "eth1" }, true},
+		{func(n *configs.Network) { n.HostInterfaceName = "veth1" }, true},
+		{func(n *configs.Network) { n.TxQueueLen = 100 }, true},
+	} {
+		n := *old
+		test.change(&n)
+		if recreate := networkNeedsRecreate(old, &n); recreate != test.recreate {
+			t.Errorf("expected changing %+v to %+v to need recreating the interface: %v", old, n, test.recreate)
+		}
+	}
+}
+
+func TestVethPeerName(t *testing.T) {
+	if os.Getuid() != 0 {
+		t.Skip("creating interfaces requires root")
+	}
+	bridge := &netlink.Bridge{LinkAttrs: netlink.LinkAttrs{Name: "rctestpeerbr0"}}
+	if err := netlink.LinkAdd(bridge); err != nil {
+		t.Fatal(err)
+	}
+	defer netlink.LinkDel(bridge)
+	n := &network{Network: configs.Network{
+		Type:              "veth",
+		Name:              "eth0",
+		Bridge:            bridge.Name,
+		HostInterfaceName: "rctestpeerh0",
+		PeerName:          "rctestpeerc0",
+		Mtu:               1500,
+	}}
+	// Moving the peer into our own network namespace keeps it visible.
+	if err := (&veth{}).create(n, os.Getpid()); err != nil {
+		t.Fatal(err)
+	}
+	defer deleteHostInterface(n.HostInterfaceName)
+	if n.TempVethPeerName != n.PeerName {
+		t.Fatalf("expected the peer to be named %s, got %s", n.PeerName, n.TempVethPeerName)
+	}
+	if _, err := netlink.LinkByName(n.PeerName); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMacvlan(t *testing.T) {
+	if os.Getuid() != 0 {
+		t.Skip("creating interfaces requires root")
+	}
+	parent := &netlink.Veth{
+		LinkAttrs: netlink.LinkAttrs{Name: "rctestmvpar0"},
+		PeerName:  "rctestmvpar1",
+	}
+	if err := netlink.LinkAdd(parent); err != nil {
+		t.Fatal(err)
+	}
+	defer netlink.LinkDel(parent)
+	n := &network{Network: configs.Network{
+		Type:        "macvlan",
+		Name:        "rctestmv0",
+		Parent:      parent.Name,
+		MacvlanMode: "private",
+		Address:     "10.234.0.2/24",
+		Mtu:         1500,
+	}}
+	strategy := &macvlan{}
+	// Moving the interface into our own network namespace keeps it visible.
+	if err := strategy.create(n, os.Getpid()); err != nil {
+		t.Fatal(err)
+	}
+	if err := strategy.initialize(n); err != nil {
+		deleteHostInterface(n.TempVethPeerName)
+		t.Fatal(err)
+	}
+	defer deleteHostInterface(n.Name)
+	link, err := netlink.LinkByName(n.Name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mv, ok := link.(*netlink.Macvlan)
+	if !ok {
+		t.Fatalf("expected a macvlan interface, got %T", link)
+	}
+	host, err := netlink.LinkByName(parent.Name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mv.Mode != netlink.MACVLAN_MODE_PRIVATE || mv.ParentIndex != host.Attrs().Index {
+		t.Fatalf("expected a private macvlan on %s, got mode %d on parent %d", parent.Name, mv.Mode, mv.ParentIndex)
+	}
+	if err := strategy.remove(&n.Network, os.Getpid()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := netlink.LinkByName(n.Name); err == nil {
+		t.Fatalf("expected %s to be removed", n.Name)
+	}
+}
+
+func TestSetOffloads(t *testing.T) {
+	if os.Getuid() != 0 {
+		t.Skip("creating interfaces requires root")
+	}
+	pair := &netlink.Veth{
+		LinkAttrs: netlink.LinkAttrs{Name: "rctestoff0"},
+		PeerName:  "rctestoff1",
+	}
+	if err := netlink.LinkAdd(pair); err != nil {
+		t.Fatal(err)
+	}
+	defer netlink.LinkDel(pair)
+	off, on := false, true
+	if err := setOffloads(pair.Name, &configs.Offloads{TSO: &off, GRO: &on}); err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range []struct {
+		offload system.Offload
+		feature string
+		on      bool
+	}{
+		{system.OffloadTSO, "tcp-segmentation-offload", false},
+		{system.OffloadGRO, "generic-receive-offload", true},
+	} {
+		got, err := system.GetOffload(pair.Name, test.offload)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != test.on {
+			t.Errorf("expected %s to be %v, got %v", test.feature, test.on, got)
+		}
+		if _, err := exec.LookPath("ethtool"); err != nil {
+			continue
+		}
+		out, err := exec.Command("ethtool", "-k", pair.Name).CombinedOutput()
+		if err != nil {
+			t.Fatalf("%v: %s", err, out)
+		}
+		want := test.feature + ": off"
+		if test.on {
+			want = test.feature + ": on"
+		}
+		if !strings.Contains(string(out), want) {
+			t.Errorf("expected ethtool to show %q, got %s", want, out)
+		}
+	}
+}
+
+func TestBindNetns(t *testing.T) {
+	if os.Getuid() != 0 {
+		t.Skip("binding namespaces requires root")
+	}
+	dir, err := ioutil.TempDir("", "netns")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	cmd := exec.Command("sleep", "60")
+	cmd.SysProcAttr = &syscall.SysProcAttr{Cloneflags: syscall.CLONE_NEWNET}
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	ns, err := os.Stat(fmt.Sprintf("/proc/%d/ns/net", cmd.Process.Pid))
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "run", "ct")
+	err = bindNetns(path, cmd.Process.Pid)
+	cmd.Process.Kill()
+	cmd.Wait()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The namespace outlives its last process.
+	bound, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !os.SameFile(ns, bound) {
+		t.Fatalf("expected %s to be the network namespace of the process", path)
+	}
+	// A second namespace is not stacked on top of the first.
+	if err := bindNetns(path, os.Getpid()); err == nil {
+		t.Fatalf("expected binding over the mount point %s to fail", path)
+	}
+	if bound, err = os.Stat(path); err != nil {
+		t.Fatal(err)
+	}
+	if !os.SameFile(ns, bound) {
+		t.Fatalf("expected %s to be the network namespace of the process still", path)
+	}
+	for i := 0; i < 2; i++ {
+		if err := unbindNetns(path); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("expected %s to be removed, got %v", path, err)
+	}
+}
+
+func TestInNetns(t *testing.T) {
+	if os.Getuid() != 0 {
+		t.Skip("joining namespaces requires root")
+	}
+	cmd := exec.Command("sleep", "60")
+	cmd.SysProcAttr = &syscall.SysProcAttr{Cloneflags: syscall.CLONE_NEWNET}
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		cmd.Process.Kill()
+		cmd.Wait()
+	}()
+	target, err := os.Stat(fmt.Sprintf("/proc/%d/ns/net", cmd.Process.Pid))
+	if err != nil {
+		t.Fatal(err)
+	}
+	threadNetns := func() (os.FileInfo, error) {
+		return os.Stat(fmt.Sprintf("/proc/self/task/%d/ns/net", syscall.Gettid()))
+	}
+	if err := inNetns(cmd.Process.Pid, func() error {
+		ns, err := threadNetns()
+		if err != nil {
+			return err
+		}
+		if !os.SameFile(ns, target) {
+			return fmt.Errorf("expected fn to run in the network namespace of the process")
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	ns, err := threadNetns()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if os.SameFile(ns, target) {
+		t.Fatal("expected the caller to stay in its network namespace")
+	}
+}
@@ -0,0 +1,70 @@
+// +build linux
+
+package libcontainer
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// networkReadyFilename is the fifo in the container's state directory that
+// is written to once the network of the container is ready.
+const networkReadyFilename = "network-ready.fifo"
+
+// createNetworkReadyFifo creates the fifo at path, replacing one left behind
+// by an earlier start, and opens it for waitNetworkReady. Being open for
+// reading from the start, the fifo lets a prestart hook that signals the
+// network ready before it returns open it for writing without blocking.
+// Holding it open for writing as well keeps it from reporting EOF until the
+// writer shows up.
+func createNetworkReadyFifo(path string) (*os.File, error) {
+	if err := syscall.Unlink(path); err != nil && err != syscall.ENOENT {
+		return nil, err
+	}
+	if err := syscall.Mkfifo(path, 0600); err != nil {
+		return nil, err
+	}
+	fd, err := syscall.Open(path, syscall.O_RDWR|syscall.O_NONBLOCK|syscall.O_CLOEXEC, 0)
+	if err != nil {
+		os.Remove(path)
+		return nil, err
+	}
+	return os.NewFile(uintptr(fd), path), nil
+}
+
+// waitNetworkReady waits up to timeout for a write to fifo, which
+// createNetworkReadyFifo opened. The init only writes to pipe meanwhile to
+// report an error or closes it when it dies, so waitNetworkReady returns
+// false once pipe can be read from, for the caller to read what the init has
+// to say.
+func waitNetworkReady(fifo *os.File, pipe *os.File, timeout time.Duration) (bool, error) {
+	fd := int(fifo.Fd())
+	deadline := time.Now().Add(timeout)
+	for {
+		remaining := deadline.Sub(time.Now())
+		if remaining <= 0 {
+			return false, fmt.Errorf("network was not signalled ready within %s", timeout)
+		}
+		fds := []unix.PollFd{
+			{Fd: int32(fd), Events: unix.POLLIN},
+			{Fd: int32(pipe.Fd()), Events: unix.POLLIN},
+		}
+		_, err := unix.Poll(fds, int((remaining+time.Millisecond-1)/time.Millisecond))
+		if err == syscall.EINTR {
+			continue
+		}
+		if err != nil {
+			return false, err
+		}
+		if fds[1].Revents != 0 {
+			return false, nil
+		}
+		if fds[0].Revents != 0 {
+			return true, nil
+		}
+	}
+}
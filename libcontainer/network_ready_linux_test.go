@@ -0,0 +1,106 @@
+// +build linux
+
+package libcontainer
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWaitNetworkReady(t *testing.T) {
+	dir, err := ioutil.TempDir("", "libcontainer-netready")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, networkReadyFilename)
+	fifo, err := createNetworkReadyFifo(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pipe, initPipe, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pipe.Close()
+	defer initPipe.Close()
+
+	start := time.Now()
+	if _, err := waitNetworkReady(fifo, pipe, 100*time.Millisecond); err == nil {
+		t.Fatal("expected waiting without a signal to time out")
+	}
+	if elapsed := time.Since(start); elapsed < 100*time.Millisecond {
+		t.Fatalf("expected to wait for the timeout, returned after %s", elapsed)
+	}
+
+	// A stale fifo is replaced.
+	fifo.Close()
+	if fifo, err = createNetworkReadyFifo(path); err != nil {
+		t.Fatal(err)
+	}
+	signalled := make(chan struct{})
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		f, err := os.OpenFile(path, os.O_WRONLY, 0)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		close(signalled)
+		f.Write([]byte("ready"))
+		f.Close()
+	}()
+	ready, err := waitNetworkReady(fifo, pipe, 5*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ready {
+		t.Fatal("expected the network to be ready")
+	}
+	select {
+	case <-signalled:
+	default:
+		t.Fatal("expected to wait for the signal")
+	}
+
+	// A hook that signals before it returns, such as with
+	// "echo ready > $fifo", does not block on a reader.
+	fifo.Close()
+	if fifo, err = createNetworkReadyFifo(path); err != nil {
+		t.Fatal(err)
+	}
+	if err := exec.Command("sh", "-c", `echo ready > "$0"`, path).Run(); err != nil {
+		t.Fatal(err)
+	}
+	if ready, err = waitNetworkReady(fifo, pipe, 5*time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if !ready {
+		t.Fatal("expected the network to be ready after a synchronous signal")
+	}
+
+	// What the init writes meanwhile, such as an error, ends the wait.
+	fifo.Close()
+	if fifo, err = createNetworkReadyFifo(path); err != nil {
+		t.Fatal(err)
+	}
+	defer fifo.Close()
+	if _, err := initPipe.Write([]byte("{}")); err != nil {
+		t.Fatal(err)
+	}
+	start = time.Now()
+	ready, err = waitNetworkReady(fifo, pipe, 5*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ready {
+		t.Fatal("expected the network not to be ready")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("expected to stop waiting once the pipe is readable, returned after %s", elapsed)
+	}
+}
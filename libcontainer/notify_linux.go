@@ -3,10 +3,12 @@
 package libcontainer
 
 import (
+	"encoding/binary"
 	"fmt"
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"sync"
 	"syscall"
 )
 
@@ -20,15 +22,35 @@ const (
 	CriticalPressure
 )
 
+func (l PressureLevel) String() string {
+	switch l {
+	case LowPressure:
+		return "low"
+	case MediumPressure:
+		return "medium"
+	case CriticalPressure:
+		return "critical"
+	}
+	return fmt.Sprintf("PressureLevel(%d)", uint(l))
+}
+
 func registerMemoryEvent(cgDir string, evName string, arg string) (<-chan struct{}, error) {
+	ch, _, err := watchMemoryEvent(cgDir, evName, arg)
+	return ch, err
+}
+
+// watchMemoryEvent is like registerMemoryEvent but also returns a function
+// that unregisters the event, closing the channel and the underlying fds, and
+// waits for the notifying goroutine to return.
+func watchMemoryEvent(cgDir string, evName string, arg string) (<-chan struct{}, func(), error) {
 	evFile, err := os.Open(filepath.Join(cgDir, evName))
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	fd, _, syserr := syscall.RawSyscall(syscall.SYS_EVENTFD2, 0, syscall.FD_CLOEXEC, 0)
 	if syserr != 0 {
 		evFile.Close()
-		return nil, syserr
+		return nil, nil, syserr
 	}
 
 	eventfd := os.NewFile(fd, "eventfd")
@@ -38,29 +60,55 @@ func registerMemoryEvent(cgDir string, evName string, arg string) (<-chan struct
 	if err := ioutil.WriteFile(eventControlPath, []byte(data), 0700); err != nil {
 		eventfd.Close()
 		evFile.Close()
-		return nil, err
+		return nil, nil, err
 	}
-	ch := make(chan struct{})
+	var (
+		ch   = make(chan struct{})
+		done = make(chan struct{})
+		exit = make(chan struct{})
+		once sync.Once
+	)
 	go func() {
 		defer func() {
 			close(ch)
 			eventfd.Close()
 			evFile.Close()
+			close(exit)
 		}()
 		buf := make([]byte, 8)
 		for {
 			if _, err := eventfd.Read(buf); err != nil {
 				return
 			}
+			select {
+			case <-done:
+				return
+			default:
+			}
 			// When a cgroup is destroyed, an event is sent to eventfd.
 			// So if the control path is gone, return instead of notifying.
 			if _, err := os.Lstat(eventControlPath); os.IsNotExist(err) {
 				return
 			}
-			ch <- struct{}{}
+			select {
+			case ch <- struct{}{}:
+			case <-done:
+				return
+			}
 		}
 	}()
-	return ch, nil
+	stop := func() {
+		once.Do(func() {
+			close(done)
+			// Wake up the goroutine in case it is blocked reading the
+			// eventfd. This fails harmlessly if it has already returned.
+			buf := make([]byte, 8)
+			binary.LittleEndian.PutUint64(buf, 1)
+			eventfd.Write(buf)
+		})
+		<-exit
+	}
+	return ch, stop, nil
 }
 
 // notifyOnOOM returns channel on which you can expect event about OOM,
@@ -84,6 +132,5 @@ func notifyMemoryPressure(paths map[string]string, level PressureLevel) (<-chan
 		return nil, fmt.Errorf("invalid pressure level %d", level)
 	}
 
-	levelStr := []string{"low", "medium", "critical"}[level]
-	return registerMemoryEvent(dir, "memory.pressure_level", levelStr)
+	return registerMemoryEvent(dir, "memory.pressure_level", level.String())
 }
@@ -5,7 +5,9 @@ package nsenter
 /*
 #cgo CFLAGS: -Wall
 extern void nsexec();
+extern void reaper(void);
 void __attribute__((constructor)) init(void) {
+	reaper();
 	nsexec();
 }
 */
@@ -0,0 +1,156 @@
+#define _GNU_SOURCE
+#include <errno.h>
+#include <fcntl.h>
+#include <signal.h>
+#include <stdio.h>
+#include <stdlib.h>
+#include <string.h>
+#include <unistd.h>
+
+#include <sys/prctl.h>
+#include <sys/types.h>
+#include <sys/wait.h>
+
+extern char **environ;
+
+#define bail(fmt, ...)								\
+	do {									\
+		fprintf(stderr, "reaper: " fmt ": %m\n", ##__VA_ARGS__);	\
+		exit(127);							\
+	} while(0)
+
+/*
+ * read_cmdline returns the arguments of the process, read from
+ * /proc/self/cmdline, as a NULL terminated array, and sets *argc to their
+ * number. Constructors cannot rely on getting argc and argv, which only some
+ * libcs such as glibc pass.
+ */
+static char **read_cmdline(int *argc)
+{
+	char *data = NULL, **argv, *arg;
+	size_t len = 0, size = 0;
+	int fd, i;
+
+	fd = open("/proc/self/cmdline", O_RDONLY | O_CLOEXEC);
+	if (fd < 0)
+		bail("opening /proc/self/cmdline");
+	for (;;) {
+		ssize_t n;
+
+		if (len == size) {
+			size = size ? size * 2 : 4096;
+			data = realloc(data, size + 1);
+			if (data == NULL)
+				bail("reading /proc/self/cmdline");
+		}
+		n = read(fd, data + len, size - len);
+		if (n < 0) {
+			if (errno == EINTR)
+				continue;
+			bail("reading /proc/self/cmdline");
+		}
+		if (n == 0)
+			break;
+		len += n;
+	}
+	close(fd);
+	/* An argument that is cut off still gets its terminator. */
+	if (len > 0 && data[len - 1] != '\0')
+		data[len++] = '\0';
+
+	*argc = 0;
+	for (arg = data; arg < data + len; arg += strlen(arg) + 1)
+		(*argc)++;
+	argv = calloc(*argc + 1, sizeof(char *));
+	if (argv == NULL)
+		bail("reading /proc/self/cmdline");
+	for (i = 0, arg = data; i < *argc; i++, arg += strlen(arg) + 1)
+		argv[i] = arg;
+	return argv;
+}
+
+/*
+ * reaper is the pid 1 of a container with a minimal init. The init execs a
+ * sealed copy of its binary with _LIBCONTAINER_REAPER set to the path of the
+ * user's process and argv[1:] set to its arguments, and the constructor calls
+ * reaper before the Go runtime starts. The arguments are read from /proc, so
+ * it needs /proc mounted in the container. It returns at once for every
+ * other process.
+ *
+ * reaper never returns otherwise: it runs the user's process as a child in its
+ * own process group, forwards every signal it gets to that process group,
+ * reaps the processes that are reparented to it and exits like the child,
+ * with 128 plus the signal number if a signal killed it. Being a single
+ * thread, it runs with the credentials the init set up for the whole process.
+ */
+void reaper(void)
+{
+	char *path, **argv;
+	sigset_t all, mask;
+	pid_t child;
+	int argc;
+
+	path = getenv("_LIBCONTAINER_REAPER");
+	if (path == NULL)
+		return;
+	argv = read_cmdline(&argc);
+	if (argc < 2) {
+		fprintf(stderr, "reaper: no process to run\n");
+		exit(127);
+	}
+	path = strdup(path);
+	if (path == NULL)
+		bail("copying the path of the process");
+	unsetenv("_LIBCONTAINER_REAPER");
+	if (prctl(PR_SET_NAME, (unsigned long)"runc:[reaper]", 0, 0, 0) < 0)
+		bail("setting the name of the reaper");
+
+	/* The child gets back the signal mask the init set up. */
+	sigfillset(&all);
+	if (sigprocmask(SIG_SETMASK, &all, &mask) < 0)
+		bail("blocking signals");
+
+	child = fork();
+	if (child < 0)
+		bail("forking %s", path);
+	if (child == 0) {
+		setpgid(0, 0);
+		/* Keep the child able to read from the terminal. */
+		if (isatty(STDIN_FILENO))
+			tcsetpgrp(STDIN_FILENO, getpid());
+		sigprocmask(SIG_SETMASK, &mask, NULL);
+		execve(path, argv + 1, environ);
+		bail("exec %s", path);
+	}
+	/* Also done here so that no signal is sent before the child did it. */
+	setpgid(child, child);
+	free(path);
+
+	for (;;) {
+		int sig = sigwaitinfo(&all, NULL);
+
+		if (sig < 0) {
+			if (errno == EINTR)
+				continue;
+			bail("waiting for signals");
+		}
+		if (sig != SIGCHLD) {
+			kill(-child, sig);
+			continue;
+		}
+		for (;;) {
+			int status;
+			pid_t pid = waitpid(-1, &status, WNOHANG);
+
+			if (pid < 0 && errno == EINTR)
+				continue;
+			if (pid <= 0)
+				break;
+			if (pid != child)
+				continue;
+			if (WIFSIGNALED(status))
+				exit(128 + WTERMSIG(status));
+			exit(WEXITSTATUS(status));
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package nsenter
+
+import (
+	"bufio"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// reaperScript orphans a short lived process and exits with 42 on SIGTERM,
+// which only the trap handles as the process group gets it too.
+const reaperScript = `sh -c 'sleep 0.1 &'; trap 'exit 42' TERM; echo ready; while :; do sleep 0.05; done`
+
+// reaperCommand runs the test binary as the reaper of sh with args, as pid 1
+// of a new PID namespace like in a container.
+func reaperCommand(t *testing.T, args ...string) *exec.Cmd {
+	if os.Getuid() != 0 {
+		t.Skip("creating a PID namespace requires root")
+	}
+	sh, err := exec.LookPath("sh")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &exec.Cmd{
+		Path:        os.Args[0],
+		Args:        append([]string{"runc:[reaper]", "sh"}, args...),
+		Env:         []string{"_LIBCONTAINER_REAPER=" + sh, "PATH=" + os.Getenv("PATH")},
+		Stderr:      os.Stderr,
+		SysProcAttr: &syscall.SysProcAttr{Cloneflags: syscall.CLONE_NEWPID},
+	}
+}
+
+func TestReaper(t *testing.T) {
+	cmd := reaperCommand(t, "-c", reaperScript)
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer cmd.Process.Kill()
+
+	ready := make(chan bool)
+	go func() {
+		s := bufio.NewScanner(stdout)
+		for s.Scan() {
+			if s.Text() == "ready" {
+				ready <- true
+			}
+		}
+		close(ready)
+	}()
+	select {
+	case ok := <-ready:
+		if !ok {
+			cmd.Wait()
+			t.Fatal("reaper exited before the child was ready")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("child not ready after 5s")
+	}
+
+	status, err := ioutil.ReadFile("/proc/" + strconv.Itoa(cmd.Process.Pid) + "/status")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(status), "Name:\trunc:[reaper]\n") || !strings.Contains(string(status), "Threads:\t1\n") {
+		t.Fatalf("expected a single threaded reaper, got %s", status)
+	}
+	time.Sleep(500 * time.Millisecond)
+	if children, err := ioutil.ReadFile("/proc/" + strconv.Itoa(cmd.Process.Pid) + "/task/" + strconv.Itoa(cmd.Process.Pid) + "/children"); err != nil {
+		t.Fatal(err)
+	} else if len(strings.Fields(string(children))) != 1 {
+		t.Fatalf("expected the orphan to be reaped, the reaper has children %q", children)
+	}
+
+	if err := cmd.Process.Signal(syscall.SIGTERM); err != nil {
+		t.Fatal(err)
+	}
+	err = cmd.Wait()
+	exitErr, ok := err.(*exec.ExitError)
+	if !ok {
+		t.Fatalf("expected the reaper to exit with the child's exit code, got %v", err)
+	}
+	if status := exitErr.Sys().(syscall.WaitStatus).ExitStatus(); status != 42 {
+		t.Fatalf("expected the forwarded signal to make the child exit with 42, got %d", status)
+	}
+}
+
+func TestReaperSignaledChild(t *testing.T) {
+	cmd := reaperCommand(t, "-c", "kill -KILL $$")
+	err := cmd.Run()
+	exitErr, ok := err.(*exec.ExitError)
+	if !ok {
+		t.Fatalf("expected the reaper to fail with the child, got %v", err)
+	}
+	if status := exitErr.Sys().(syscall.WaitStatus).ExitStatus(); status != 128+int(syscall.SIGKILL) {
+		t.Fatalf("expected exit code %d for the killed child, got %d", 128+int(syscall.SIGKILL), status)
+	}
+}
@@ -0,0 +1,234 @@
+// +build linux
+
+package libcontainer
+
+import (
+	"io/ioutil"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+const (
+	// parentPollInterval is how often the init checks whether its parent is
+	// still alive.
+	parentPollInterval = 100 * time.Millisecond
+
+	defaultParentDeathTimeout = time.Second
+)
+
+// parentDeathCleanup removes the cgroups of the container when the parent
+// of the init dies during setup.
+type parentDeathCleanup struct {
+	parentPid int
+	signal    syscall.Signal
+	timeout   time.Duration
+	cgroups   []cgroupDir
+	signals   chan os.Signal
+	done      chan struct{}
+	stopOnce  sync.Once
+	cleanOnce sync.Once
+}
+
+// cgroupDir holds a cgroup open, so that the init can still remove it after
+// the host's cgroup mounts are gone from its mount namespace.
+type cgroupDir struct {
+	parent int
+	name   string
+	dir    int
+}
+
+// watchParentDeath starts to watch the parent of the init if the config asks
+// for cleanup on parent death. It returns nil otherwise.
+func watchParentDeath(config *initConfig, parentPid int) (*parentDeathCleanup, error) {
+	cleanup := config.Config.ParentDeathCleanup
+	if cleanup == nil {
+		return nil, nil
+	}
+	w := &parentDeathCleanup{
+		parentPid: parentPid,
+		signal:    syscall.Signal(cleanup.Signal),
+		timeout:   cleanup.Timeout,
+		done:      make(chan struct{}),
+	}
+	if w.signal == 0 {
+		w.signal = syscall.SIGKILL
+	}
+	if w.timeout <= 0 {
+		w.timeout = defaultParentDeathTimeout
+	}
+	seen := make(map[string]bool)
+	for _, path := range config.CgroupPaths {
+		if seen[path] {
+			continue
+		}
+		seen[path] = true
+		dir, err := openCgroupDir(path)
+		if err != nil {
+			w.closeCgroups()
+			return nil, newSystemErrorWithCausef(err, "opening cgroup %s", path)
+		}
+		w.cgroups = append(w.cgroups, dir)
+	}
+	// The kernel sends the parent death signal after the init was
+	// reparented, so it only speeds up noticing that the parent is gone.
+	if sig := config.Config.ParentDeathSignal; sig > 0 {
+		w.signals = make(chan os.Signal, 1)
+		signal.Notify(w.signals, syscall.Signal(sig))
+	}
+	go w.watch()
+	return w, nil
+}
+
+func openCgroupDir(path string) (cgroupDir, error) {
+	parent, err := syscall.Open(filepath.Dir(path), syscall.O_RDONLY|syscall.O_DIRECTORY|syscall.O_CLOEXEC, 0)
+	if err != nil {
+		return cgroupDir{}, err
+	}
+	dir, err := syscall.Openat(parent, filepath.Base(path), syscall.O_RDONLY|syscall.O_DIRECTORY|syscall.O_CLOEXEC, 0)
+	if err != nil {
+		syscall.Close(parent)
+		return cgroupDir{}, err
+	}
+	return cgroupDir{parent: parent, name: filepath.Base(path), dir: dir}, nil
+}
+
+func (w *parentDeathCleanup) watch() {
+	ticker := time.NewTicker(parentPollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-w.done:
+			return
+		case <-w.signals:
+		case <-ticker.C:
+		}
+		if syscall.Getppid() != w.parentPid {
+			w.cleanup()
+			os.Exit(1)
+		}
+	}
+}
+
+// setupFailed cleans up if the setup failed because the parent died, which
+// the init can notice through the pipe before it is reparented.
+func (w *parentDeathCleanup) setupFailed() {
+	if w == nil {
+		return
+	}
+	select {
+	case <-w.done:
+		return
+	default:
+	}
+	for deadline := time.Now().Add(parentPollInterval); ; time.Sleep(10 * time.Millisecond) {
+		if syscall.Getppid() != w.parentPid {
+			w.cleanup()
+			return
+		}
+		if time.Now().After(deadline) {
+			return
+		}
+	}
+}
+
+// stop ends the watch once the parent no longer needs to be alive.
+func (w *parentDeathCleanup) stop() {
+	if w == nil {
+		return
+	}
+	w.stopOnce.Do(func() {
+		close(w.done)
+		if w.signals != nil {
+			signal.Stop(w.signals)
+		}
+	})
+}
+
+// cleanup signals the other processes in the container's cgroups, moves the
+// init to the parent cgroups and removes the cgroups. It is best effort, a
+// cgroup that cannot be removed is left behind.
+func (w *parentDeathCleanup) cleanup() {
+	if w == nil {
+		return
+	}
+	w.cleanOnce.Do(func() {
+		w.stop()
+		for _, cg := range w.cgroups {
+			for _, pid := range readCgroupPids(cg.dir) {
+				syscall.Kill(pid, w.signal)
+			}
+		}
+		for deadline := time.Now().Add(w.timeout); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+			if !w.othersLeft() {
+				break
+			}
+		}
+		for _, cg := range w.cgroups {
+			// Writing 0 moves the writing process.
+			writeCgroupFile(cg.parent, "cgroup.procs", "0")
+			unix.Unlinkat(cg.parent, cg.name, unix.AT_REMOVEDIR)
+		}
+		w.closeCgroups()
+	})
+}
+
+func (w *parentDeathCleanup) othersLeft() bool {
+	for _, cg := range w.cgroups {
+		if len(readCgroupPids(cg.dir)) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func (w *parentDeathCleanup) closeCgroups() {
+	for _, cg := range w.cgroups {
+		syscall.Close(cg.dir)
+		syscall.Close(cg.parent)
+	}
+	w.cgroups = nil
+}
+
+// readCgroupPids returns the processes in the cgroup other than the init.
+// Processes outside of the init's pid namespace show up as 0 and are left
+// out as well.
+func readCgroupPids(dir int) []int {
+	fd, err := syscall.Openat(dir, "cgroup.procs", syscall.O_RDONLY|syscall.O_CLOEXEC, 0)
+	if err != nil {
+		return nil
+	}
+	f := os.NewFile(uintptr(fd), "cgroup.procs")
+	defer f.Close()
+	data, err := ioutil.ReadAll(f)
+	if err != nil {
+		return nil
+	}
+	self := syscall.Getpid()
+	var pids []int
+	for _, field := range strings.Fields(string(data)) {
+		pid, err := strconv.Atoi(field)
+		if err != nil || pid == 0 || pid == self {
+			continue
+		}
+		pids = append(pids, pid)
+	}
+	return pids
+}
+
+func writeCgroupFile(dir int, name, data string) error {
+	fd, err := syscall.Openat(dir, name, syscall.O_WRONLY|syscall.O_CLOEXEC, 0)
+	if err != nil {
+		return err
+	}
+	defer syscall.Close(fd)
+	_, err = syscall.Write(fd, []byte(data))
+	return err
+}
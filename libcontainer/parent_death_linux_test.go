@@ -0,0 +1,132 @@
+// +build linux
+
+package libcontainer
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/opencontainers/runc/libcontainer/cgroups"
+	"github.com/opencontainers/runc/libcontainer/configs"
+	"github.com/opencontainers/runc/libcontainer/system"
+)
+
+// TestParentDeathCleanup kills the parent of an init that joined a cgroup
+// and started another process in it, and expects the init to remove the
+// cgroup.
+func TestParentDeathCleanup(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("creating cgroups requires root")
+	}
+	mnt, err := cgroups.FindCgroupMountpoint("pids")
+	if err != nil {
+		t.Skip("pids cgroup is not mounted")
+	}
+	dir := filepath.Join(mnt, fmt.Sprintf("libct-parent-death-%d", os.Getpid()))
+	if err := os.Mkdir(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if pids, err := cgroups.GetPids(dir); err == nil {
+			for _, pid := range pids {
+				syscall.Kill(pid, syscall.SIGKILL)
+			}
+		}
+		os.Remove(dir)
+	}()
+
+	cmd := exec.Command(os.Args[0], "-test.run=^TestParentDeathCleanupHelper$")
+	cmd.Env = append(os.Environ(), "LIBCONTAINER_TEST_PARENT_DEATH=parent", "LIBCONTAINER_TEST_CGROUP="+dir)
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer cmd.Process.Kill()
+
+	ready := make(chan int)
+	go func() {
+		s := bufio.NewScanner(stdout)
+		for s.Scan() {
+			if fields := strings.Fields(s.Text()); len(fields) == 2 && fields[0] == "ready" {
+				pid, _ := strconv.Atoi(fields[1])
+				ready <- pid
+			}
+		}
+		close(ready)
+	}()
+	var other int
+	select {
+	case pid, ok := <-ready:
+		if !ok {
+			cmd.Wait()
+			t.Fatal("init exited before it was ready")
+		}
+		other = pid
+	case <-time.After(5 * time.Second):
+		t.Fatal("init not ready after 5s")
+	}
+
+	if err := cmd.Process.Kill(); err != nil {
+		t.Fatal(err)
+	}
+	cmd.Wait()
+	for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(50 * time.Millisecond) {
+		if _, err := os.Stat(dir); os.IsNotExist(err) {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("expected the init to remove the cgroup after its parent died")
+		}
+	}
+	if err := syscall.Kill(other, 0); err == nil {
+		if zombie, err := system.IsZombie(other); err == nil && !zombie {
+			t.Fatalf("expected the other process %d in the cgroup to be killed", other)
+		}
+	}
+}
+
+func TestParentDeathCleanupHelper(t *testing.T) {
+	dir := os.Getenv("LIBCONTAINER_TEST_CGROUP")
+	switch os.Getenv("LIBCONTAINER_TEST_PARENT_DEATH") {
+	case "parent":
+		cmd := exec.Command(os.Args[0], "-test.run=^TestParentDeathCleanupHelper$")
+		cmd.Env = append(os.Environ(), "LIBCONTAINER_TEST_PARENT_DEATH=init")
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+		if err := cmd.Start(); err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(time.Minute)
+	case "init":
+		if err := cgroups.WriteCgroupProc(dir, os.Getpid()); err != nil {
+			t.Fatal(err)
+		}
+		other := exec.Command("sleep", "100")
+		if err := other.Start(); err != nil {
+			t.Fatal(err)
+		}
+		config := &initConfig{
+			Config: &configs.Config{
+				ParentDeathCleanup: &configs.ParentDeathCleanup{},
+			},
+			CgroupPaths: map[string]string{"pids": dir},
+		}
+		if _, err := watchParentDeath(config, os.Getppid()); err != nil {
+			t.Fatal(err)
+		}
+		fmt.Printf("ready %d\n", other.Process.Pid)
+		time.Sleep(time.Minute)
+		t.Fatal("expected the init to exit after its parent died")
+	}
+}
@@ -68,6 +68,30 @@ type Process struct {
 	// ConsoleSocket provides the masterfd console.
 	ConsoleSocket *os.File
 
+	// ConsoleSocketPath is the path of a unix socket that the masterfd
+	// console is sent to over SCM_RIGHTS, letting another program own the
+	// console. It is only used when ConsoleSocket is not set.
+	ConsoleSocketPath string
+
+	// PidWriter, if set, is written the pid of the process in decimal once
+	// the process has started, for supervisors that track processes through
+	// a pipe or a file they opened themselves. It is not closed. Unlike
+	// Config.PidFile it is given per process, also for processes exec'd in a
+	// running container.
+	PidWriter *os.File
+
+	// ExecID names the process in ExecSessions when it is started in a
+	// running container. It must not be used by another exec session that is
+	// still running. If empty, an id is generated and stored here once the
+	// process has started.
+	ExecID string
+
+	// SubCgroup places a process started in a running container in the
+	// named child cgroup of the container's cgroup in every hierarchy, for
+	// example to account for debugging tools separately. The child cgroups
+	// are created if needed and are still subject to the container's limits.
+	SubCgroup string
+
 	ops processOperations
 }
 
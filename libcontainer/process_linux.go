@@ -11,7 +11,9 @@ import (
 	"os/exec"
 	"path/filepath"
 	"strconv"
+	"sync"
 	"syscall"
+	"time"
 
 	"github.com/opencontainers/runc/libcontainer/cgroups"
 	"github.com/opencontainers/runc/libcontainer/configs"
@@ -72,6 +74,8 @@ func (p *setnsProcess) start() (err error) {
 	if err != nil {
 		return newSystemErrorWithCause(err, "starting setns process")
 	}
+	deadline := newSetupDeadline(p.config.Config.SetupTimeout, p.parentPipe, p.cmd.Process)
+	defer deadline.stop()
 	if p.bootstrapData != nil {
 		if _, err := io.Copy(p.parentPipe, p.bootstrapData); err != nil {
 			return newSystemErrorWithCause(err, "copying bootstrap data to pipe")
@@ -80,6 +84,7 @@ func (p *setnsProcess) start() (err error) {
 	if err = p.execSetns(); err != nil {
 		return newSystemErrorWithCause(err, "executing setns process")
 	}
+	deadline.setProcess(p.cmd.Process)
 	// We can't join cgroups if we're in a rootless container.
 	if !p.config.Rootless && len(p.cgroupPaths) > 0 {
 		if err := cgroups.EnterPid(p.cgroupPaths, p.pid()); err != nil {
@@ -108,6 +113,9 @@ func (p *setnsProcess) start() (err error) {
 		}
 	})
 
+	if deadline.stop() {
+		return newSystemError(fmt.Errorf("process did not finish setting up within %s", p.config.Config.SetupTimeout))
+	}
 	if err := syscall.Shutdown(int(p.parentPipe.Fd()), syscall.SHUT_WR); err != nil {
 		return newSystemErrorWithCause(err, "calling shutdown on init pipe")
 	}
@@ -143,6 +151,7 @@ func (p *setnsProcess) execSetns() error {
 		return err
 	}
 	p.cmd.Process = process
+	trackChild(pid.Pid)
 	p.process.ops = p
 	return nil
 }
@@ -162,6 +171,7 @@ func (p *setnsProcess) terminate() error {
 
 func (p *setnsProcess) wait() (*os.ProcessState, error) {
 	err := p.cmd.Wait()
+	untrackChild(p.pid())
 
 	// Return actual ProcessState even on Wait error
 	return p.cmd.ProcessState, err
@@ -191,6 +201,13 @@ type initProcess struct {
 	bootstrapData io.Reader
 	sharePidns    bool
 	rootDir       *os.File
+	reaper        *os.File
+
+	// The init is waited for once, by the first of the process and the
+	// container asking; the others get the same result.
+	waitOnce  sync.Once
+	waitState *os.ProcessState
+	waitErr   error
 }
 
 func (p *initProcess) pid() int {
@@ -226,6 +243,7 @@ func (p *initProcess) execSetns() error {
 		return err
 	}
 	p.cmd.Process = process
+	trackChild(pid.Pid)
 	p.process.ops = p
 	return nil
 }
@@ -236,16 +254,22 @@ func (p *initProcess) start() error {
 	p.process.ops = p
 	p.childPipe.Close()
 	p.rootDir.Close()
+	if p.reaper != nil {
+		p.reaper.Close()
+	}
 	if err != nil {
 		p.process.ops = nil
 		return newSystemErrorWithCause(err, "starting init process command")
 	}
+	deadline := newSetupDeadline(p.config.Config.SetupTimeout, p.parentPipe, p.cmd.Process)
+	defer deadline.stop()
 	if _, err := io.Copy(p.parentPipe, p.bootstrapData); err != nil {
 		return newSystemErrorWithCause(err, "copying bootstrap data to pipe")
 	}
 	if err := p.execSetns(); err != nil {
 		return newSystemErrorWithCause(err, "running exec setns process for init")
 	}
+	deadline.setProcess(p.cmd.Process)
 	// Save the standard descriptor names before the container process
 	// can potentially move them (e.g., via dup2()).  If we don't do this now,
 	// we won't know at checkpoint time which file descriptor to look up.
@@ -266,9 +290,68 @@ func (p *initProcess) start() error {
 			p.manager.Destroy()
 		}
 	}()
+	if err := trackContainer(p.pid()); err != nil {
+		return newSystemErrorWithCause(err, "tracking cgroups of init for the reaper")
+	}
+	if err := p.delegateCgroups(); err != nil {
+		return newSystemErrorWithCause(err, "delegating cgroups")
+	}
 	if err := p.createNetworkInterfaces(); err != nil {
 		return newSystemErrorWithCause(err, "creating network interfaces")
 	}
+	var started bool
+	if path := p.config.Config.NetnsPath; path != "" {
+		// The namespace of an init that ran before is bound still.
+		if p.container.initProcess != nil {
+			if err := unbindNetns(path); err != nil {
+				return newSystemErrorWithCausef(err, "unbinding previous network namespace from %s", path)
+			}
+		}
+		if err := bindNetns(path, p.pid()); err != nil {
+			return newSystemErrorWithCausef(err, "binding network namespace to %s", path)
+		}
+		defer func() {
+			if !started {
+				unbindNetns(path)
+			}
+		}()
+	}
+	if err := setupRootfsOwnership(p.config.Config); err != nil {
+		return newSystemErrorWithCause(err, "setting up rootfs ownership")
+	}
+	if overlay := newRootfsOverlay(p.config.Config, p.container.root); overlay != nil {
+		if err := overlay.create(p.config.Config); err != nil {
+			return newSystemErrorWithCause(err, "creating rootfs overlay directories")
+		}
+		p.config.Overlay = overlay
+	}
+	if image := p.config.Config.RootfsImage; image != nil {
+		// The loop device of an init that ran before is not mounted anymore.
+		if err := detachRootfsImage(p.container.rootfsImage); err != nil {
+			return newSystemErrorWithCause(err, "detaching previous rootfs image")
+		}
+		attached, err := attachRootfsImage(image)
+		if err != nil {
+			return newSystemErrorWithCausef(err, "attaching rootfs image %s", image.Path)
+		}
+		p.config.RootfsImage = attached
+		p.container.rootfsImage = attached
+	}
+	// The init can only remove cgroups that were created for the container.
+	if p.config.Config.ParentDeathCleanup != nil && p.config.Config.Cgroups.Paths == nil {
+		p.config.CgroupPaths = p.manager.GetPaths()
+	}
+	networkReady := filepath.Join(p.container.root, networkReadyFilename)
+	var networkReadyFifo *os.File
+	if p.config.Config.NetworkReadyTimeout > 0 {
+		fifo, err := createNetworkReadyFifo(networkReady)
+		if err != nil {
+			return newSystemErrorWithCause(err, "creating network ready fifo")
+		}
+		defer os.Remove(networkReady)
+		defer fifo.Close()
+		networkReadyFifo = fifo
+	}
 	if err := p.sendConfig(); err != nil {
 		return newSystemErrorWithCause(err, "sending config to init process")
 	}
@@ -283,6 +366,12 @@ func (p *initProcess) start() error {
 			if err := p.manager.Set(p.config.Config); err != nil {
 				return newSystemErrorWithCause(err, "setting cgroup config for ready process")
 			}
+			// The init has mounted the rootfs image by now.
+			if image := p.config.RootfsImage; image != nil {
+				if err := image.autoclear(); err != nil {
+					return newSystemErrorWithCause(err, "setting autoclear on rootfs image")
+				}
+			}
 			// set rlimits, this has to be done here because we lose permissions
 			// to raise the limits once we enter a user-namespace
 			if err := setupRlimits(p.config.Rlimits, p.pid()); err != nil {
@@ -291,12 +380,7 @@ func (p *initProcess) start() error {
 			// call prestart hooks
 			if !p.config.Config.Namespaces.Contains(configs.NEWNS) {
 				if p.config.Config.Hooks != nil {
-					s := configs.HookState{
-						Version: p.container.config.Version,
-						ID:      p.container.id,
-						Pid:     p.pid(),
-						Bundle:  utils.SearchLabels(p.config.Config.Labels, "bundle"),
-					}
+					s := p.hookState(networkReady)
 					for i, hook := range p.config.Config.Hooks.Prestart {
 						if err := hook.Run(s); err != nil {
 							return newSystemErrorWithCausef(err, "running prestart hook %d", i)
@@ -309,14 +393,24 @@ func (p *initProcess) start() error {
 				return newSystemErrorWithCause(err, "writing syncT 'run'")
 			}
 			sentRun = true
+			// The init finishes its setup meanwhile and waits for the
+			// network before exec'ing the user's process. An error it
+			// runs into first is read from the pipe next.
+			if timeout := p.config.Config.NetworkReadyTimeout; timeout > 0 {
+				ready, err := waitNetworkReady(networkReadyFifo, p.parentPipe, timeout)
+				if err != nil {
+					return newSystemErrorWithCause(err, "waiting for the network to be ready")
+				}
+				if !ready {
+					return nil
+				}
+				if err := writeSync(p.parentPipe, procNetworkReady); err != nil {
+					return newSystemErrorWithCause(err, "writing syncT 'network ready'")
+				}
+			}
 		case procHooks:
 			if p.config.Config.Hooks != nil {
-				s := configs.HookState{
-					Version: p.container.config.Version,
-					ID:      p.container.id,
-					Pid:     p.pid(),
-					Bundle:  utils.SearchLabels(p.config.Config.Labels, "bundle"),
-				}
+				s := p.hookState(networkReady)
 				for i, hook := range p.config.Config.Hooks.Prestart {
 					if err := hook.Run(s); err != nil {
 						return newSystemErrorWithCausef(err, "running prestart hook %d", i)
@@ -328,6 +422,14 @@ func (p *initProcess) start() error {
 				return newSystemErrorWithCause(err, "writing syncT 'resume'")
 			}
 			sentResume = true
+		case procIdmapRootfs:
+			if err := p.sendRootfsTree(); err != nil {
+				return newSystemErrorWithCause(err, "sending idmapped rootfs")
+			}
+		case procIdmapMounts:
+			if err := p.sendMountTrees(); err != nil {
+				return newSystemErrorWithCause(err, "sending idmapped mounts")
+			}
 		default:
 			return newSystemError(fmt.Errorf("invalid JSON payload from child"))
 		}
@@ -335,6 +437,9 @@ func (p *initProcess) start() error {
 		return nil
 	})
 
+	if deadline.stop() {
+		return newSystemError(fmt.Errorf("container init did not finish setting up within %s", p.config.Config.SetupTimeout))
+	}
 	if !sentRun {
 		return newSystemErrorWithCause(ierr, "container init")
 	}
@@ -350,19 +455,93 @@ func (p *initProcess) start() error {
 		p.wait()
 		return ierr
 	}
+	started = true
 	return nil
 }
 
-func (p *initProcess) wait() (*os.ProcessState, error) {
-	err := p.cmd.Wait()
-	if err != nil {
-		return p.cmd.ProcessState, err
+// hookState is the state passed to the prestart hooks, which signal the
+// network ready on the fifo at networkReady.
+func (p *initProcess) hookState(networkReady string) configs.HookState {
+	s := configs.HookState{
+		Version: p.container.config.Version,
+		ID:      p.container.id,
+		Pid:     p.pid(),
+		Bundle:  utils.SearchLabels(p.config.Config.Labels, "bundle"),
+	}
+	if p.config.Config.NetworkReadyTimeout > 0 {
+		s.Annotations = map[string]string{configs.NetworkReadyAnnotation: networkReady}
+	}
+	return s
+}
+
+// setupDeadline kills the process that sets up the container and shuts down
+// the pipe to it unless stop is called within the timeout, so that a parent
+// blocked on the pipe gets to return.
+type setupDeadline struct {
+	mu      sync.Mutex
+	timer   *time.Timer
+	pipe    *os.File
+	process *os.Process
+	stopped bool
+	expired bool
+}
+
+// newSetupDeadline starts the deadline for process, unless timeout is not
+// positive.
+func newSetupDeadline(timeout time.Duration, pipe *os.File, process *os.Process) *setupDeadline {
+	d := &setupDeadline{pipe: pipe, process: process}
+	if timeout > 0 {
+		d.timer = time.AfterFunc(timeout, d.expire)
 	}
-	// we should kill all processes in cgroup when init is died if we use host PID namespace
-	if p.sharePidns {
-		signalAllProcesses(p.manager, syscall.SIGKILL)
+	return d
+}
+
+func (d *setupDeadline) expire() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.stopped {
+		return
+	}
+	d.expired = true
+	d.process.Kill()
+	syscall.Shutdown(int(d.pipe.Fd()), syscall.SHUT_RDWR)
+}
+
+// setProcess makes the deadline kill process instead, such as the init that
+// the bootstrap process forked.
+func (d *setupDeadline) setProcess(process *os.Process) {
+	d.mu.Lock()
+	d.process = process
+	d.mu.Unlock()
+}
+
+// stop stops the deadline and reports whether it expired before.
+func (d *setupDeadline) stop() bool {
+	if d.timer != nil {
+		d.timer.Stop()
 	}
-	return p.cmd.ProcessState, nil
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.stopped = true
+	return d.expired
+}
+
+func (p *initProcess) wait() (*os.ProcessState, error) {
+	p.waitOnce.Do(func() {
+		defer untrackContainer(p.pid())
+		err := p.cmd.Wait()
+		untrackChild(p.pid())
+		if err != nil {
+			p.waitState, p.waitErr = p.cmd.ProcessState, err
+			return
+		}
+		// we should kill all processes in cgroup when init is died if we use host PID namespace
+		if p.sharePidns {
+			signalAllProcesses(p.manager, syscall.SIGKILL)
+		}
+		p.waitState = p.cmd.ProcessState
+	})
+	return p.waitState, p.waitErr
 }
 
 func (p *initProcess) terminate() error {
@@ -376,6 +555,24 @@ func (p *initProcess) terminate() error {
 	return err
 }
 
+// delegateCgroups gives the container's root user ownership of the
+// container's cgroups if the configuration asks for them to be delegated.
+func (p *initProcess) delegateCgroups() error {
+	config := p.config.Config
+	if config.Cgroups == nil || !config.Cgroups.Delegate {
+		return nil
+	}
+	uid, err := config.HostRootUID()
+	if err != nil {
+		return err
+	}
+	gid, err := config.HostRootGID()
+	if err != nil {
+		return err
+	}
+	return cgroups.Delegate(p.manager.GetPaths(), uid, gid)
+}
+
 func (p *initProcess) startTime() (string, error) {
 	return system.GetProcessStartTime(p.pid())
 }
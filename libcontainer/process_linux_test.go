@@ -0,0 +1,99 @@
+// +build linux
+
+package libcontainer
+
+import (
+	"os/exec"
+	"testing"
+	"time"
+
+	"github.com/opencontainers/runc/libcontainer/utils"
+)
+
+func TestSetupDeadline(t *testing.T) {
+	parent, child, err := utils.NewSockPair("deadline")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer parent.Close()
+	defer child.Close()
+	cmd := exec.Command("sleep", "100")
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer cmd.Process.Kill()
+
+	// The child never writes to the pipe, like an init stuck in a mount.
+	deadline := newSetupDeadline(50*time.Millisecond, parent, cmd.Process)
+	start := time.Now()
+	buf := make([]byte, 1)
+	if n, _ := parent.Read(buf); n != 0 {
+		t.Fatalf("expected no data from the pipe, got %d bytes", n)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("expected the read to be unblocked by the deadline, took %s", elapsed)
+	}
+	if !deadline.stop() {
+		t.Fatal("expected the deadline to be reported as expired")
+	}
+	if err := cmd.Wait(); err == nil {
+		t.Fatal("expected the process to be killed")
+	}
+}
+
+func TestSetupDeadlineStopped(t *testing.T) {
+	parent, child, err := utils.NewSockPair("deadline")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer parent.Close()
+	defer child.Close()
+	cmd := exec.Command("sleep", "100")
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer cmd.Process.Kill()
+
+	deadline := newSetupDeadline(50*time.Millisecond, parent, cmd.Process)
+	if deadline.stop() {
+		t.Fatal("expected the deadline not to have expired")
+	}
+	time.Sleep(100 * time.Millisecond)
+	if deadline.stop() {
+		t.Fatal("expected a stopped deadline to never expire")
+	}
+	if _, err := child.Write([]byte{1}); err != nil {
+		t.Fatalf("expected the pipe to be left open: %v", err)
+	}
+	if newSetupDeadline(0, parent, cmd.Process).stop() {
+		t.Fatal("expected no deadline without a timeout")
+	}
+}
+
+func TestSetupDeadlineSetProcess(t *testing.T) {
+	parent, child, err := utils.NewSockPair("deadline")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer parent.Close()
+	defer child.Close()
+	bootstrap := exec.Command("true")
+	if err := bootstrap.Run(); err != nil {
+		t.Fatal(err)
+	}
+	cmd := exec.Command("sleep", "100")
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer cmd.Process.Kill()
+
+	// Like execSetns, swap the reaped bootstrap process for the real one.
+	deadline := newSetupDeadline(50*time.Millisecond, parent, bootstrap.Process)
+	deadline.setProcess(cmd.Process)
+	if err := cmd.Wait(); err == nil {
+		t.Fatal("expected the current process to be killed")
+	}
+	if !deadline.stop() {
+		t.Fatal("expected the deadline to be reported as expired")
+	}
+}
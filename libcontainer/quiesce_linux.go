@@ -0,0 +1,190 @@
+// +build linux
+
+package libcontainer
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// maxSymlinks is how many symlinks openInRoot follows before it gives up
+// with ELOOP, like the kernel does.
+const maxSymlinks = 40
+
+func (c *linuxContainer) Quiesce(s os.Signal, timeout time.Duration) error {
+	root, err := c.signalQuiesce(s)
+	if err != nil {
+		return err
+	}
+	defer root.Close()
+	deadline := time.Now().Add(timeout)
+	for {
+		if quiesced(root, c.config.QuiescedFile) {
+			return nil
+		}
+		status, err := c.Status()
+		if err != nil {
+			return err
+		}
+		if status == Stopped {
+			return nil
+		}
+		if !time.Now().Before(deadline) {
+			return newGenericError(fmt.Errorf("container did not quiesce within %s", timeout), SystemError)
+		}
+		time.Sleep(stopPollInterval)
+	}
+}
+
+// signalQuiesce removes the quiesced file and signals the init of the running
+// container. It returns the root of the init's mount namespace, which the
+// quiesced file is looked up below.
+func (c *linuxContainer) signalQuiesce(s os.Signal) (*os.File, error) {
+	c.m.Lock()
+	defer c.m.Unlock()
+	if c.config.QuiescedFile == "" {
+		return nil, newGenericError(fmt.Errorf("no quiesced file is configured"), ConfigInvalid)
+	}
+	if _, err := c.runningState(); err != nil {
+		return nil, err
+	}
+	root, err := os.OpenFile(filepath.Join("/proc", strconv.Itoa(c.initProcess.pid()), "root"), unix.O_PATH|syscall.O_DIRECTORY|syscall.O_CLOEXEC, 0)
+	if err != nil {
+		return nil, newSystemErrorWithCause(err, "opening root of init process")
+	}
+	// A file left from an earlier quiesce would hide that this one has not
+	// finished.
+	if err := removeInRoot(root, c.config.QuiescedFile); err != nil && !os.IsNotExist(err) {
+		root.Close()
+		return nil, newSystemErrorWithCause(err, "removing quiesced file")
+	}
+	if err := c.initProcess.signal(s); err != nil {
+		root.Close()
+		return nil, newSystemErrorWithCause(err, "signaling init process")
+	}
+	return root, nil
+}
+
+// quiesced reports whether the quiesced file exists below root.
+func quiesced(root *os.File, path string) bool {
+	dir, err := openInRoot(root, filepath.Dir(path))
+	if err != nil {
+		return false
+	}
+	defer dir.Close()
+	fd, err := unix.Openat(int(dir.Fd()), filepath.Base(path), unix.O_PATH|syscall.O_NOFOLLOW|syscall.O_CLOEXEC, 0)
+	if err != nil {
+		return false
+	}
+	syscall.Close(fd)
+	return true
+}
+
+// removeInRoot removes the file at path below root. A symlink is removed
+// itself rather than what it points to.
+func removeInRoot(root *os.File, path string) error {
+	dir, err := openInRoot(root, filepath.Dir(path))
+	if err != nil {
+		return err
+	}
+	defer dir.Close()
+	if err := unix.Unlinkat(int(dir.Fd()), filepath.Base(path), 0); err != nil {
+		return &os.PathError{Op: "unlinkat", Path: path, Err: err}
+	}
+	return nil
+}
+
+// openInRoot opens the directory at path below root with O_PATH. Symlinks
+// and ".." are resolved one component at a time as if root were /, so that
+// the container cannot point the lookup at a file of the host.
+func openInRoot(root *os.File, path string) (*os.File, error) {
+	return walkInRoot(root, path, false)
+}
+
+// mkdirAllInRoot opens the directory at path below root like openInRoot,
+// creating every missing directory relative to its parent on the way.
+func mkdirAllInRoot(root *os.File, path string) (*os.File, error) {
+	return walkInRoot(root, path, true)
+}
+
+func walkInRoot(root *os.File, path string, create bool) (*os.File, error) {
+	var (
+		dirs  []int
+		links int
+		parts = strings.Split(path, "/")
+	)
+	current := func() int {
+		if len(dirs) == 0 {
+			return int(root.Fd())
+		}
+		return dirs[len(dirs)-1]
+	}
+	defer func() {
+		for _, fd := range dirs {
+			syscall.Close(fd)
+		}
+	}()
+	for len(parts) > 0 {
+		part := parts[0]
+		parts = parts[1:]
+		switch part {
+		case "", ".":
+			continue
+		case "..":
+			if len(dirs) > 0 {
+				syscall.Close(dirs[len(dirs)-1])
+				dirs = dirs[:len(dirs)-1]
+			}
+			continue
+		}
+		fd, err := unix.Openat(current(), part, unix.O_PATH|syscall.O_NOFOLLOW|syscall.O_CLOEXEC, 0)
+		if err == syscall.ENOENT && create {
+			if err := unix.Mkdirat(current(), part, 0755); err != nil && err != syscall.EEXIST {
+				return nil, &os.PathError{Op: "mkdirat", Path: path, Err: err}
+			}
+			fd, err = unix.Openat(current(), part, unix.O_PATH|syscall.O_NOFOLLOW|syscall.O_CLOEXEC, 0)
+		}
+		if err != nil {
+			return nil, &os.PathError{Op: "openat", Path: path, Err: err}
+		}
+		var st syscall.Stat_t
+		if err := syscall.Fstat(fd, &st); err != nil {
+			syscall.Close(fd)
+			return nil, &os.PathError{Op: "fstat", Path: path, Err: err}
+		}
+		if st.Mode&syscall.S_IFMT != syscall.S_IFLNK {
+			dirs = append(dirs, fd)
+			continue
+		}
+		buf := make([]byte, syscall.PathMax)
+		n, err := unix.Readlinkat(fd, "", buf)
+		syscall.Close(fd)
+		if err != nil {
+			return nil, &os.PathError{Op: "readlinkat", Path: path, Err: err}
+		}
+		if links++; links > maxSymlinks {
+			return nil, &os.PathError{Op: "openat", Path: path, Err: syscall.ELOOP}
+		}
+		target := string(buf[:n])
+		if filepath.IsAbs(target) {
+			for _, fd := range dirs {
+				syscall.Close(fd)
+			}
+			dirs = nil
+		}
+		parts = append(strings.Split(target, "/"), parts...)
+	}
+	fd, err := syscall.Dup(current())
+	if err != nil {
+		return nil, &os.PathError{Op: "dup", Path: path, Err: err}
+	}
+	syscall.CloseOnExec(fd)
+	return os.NewFile(uintptr(fd), path), nil
+}
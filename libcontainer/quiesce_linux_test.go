@@ -0,0 +1,177 @@
+// +build linux
+
+package libcontainer
+
+import (
+	"bufio"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// killingProcess is an init that is signaled for real.
+type killingProcess struct {
+	mockProcess
+}
+
+func (p *killingProcess) signal(s os.Signal) error {
+	return syscall.Kill(p._pid, s.(syscall.Signal))
+}
+
+// quiescingContainer returns a container whose init runs script with the
+// quiesced file as $0, once the script has printed a line.
+func quiescingContainer(t *testing.T, root, script string) (*linuxContainer, *exec.Cmd) {
+	marker := filepath.Join(root, "quiesced")
+	cmd := exec.Command("sh", "-c", script, marker)
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := bufio.NewReader(stdout).ReadString('\n'); err != nil {
+		t.Fatal(err)
+	}
+	c := newMockContainer(t, cmd.Process.Pid, nil)
+	c.root = root
+	c.config.QuiescedFile = marker
+	c.initProcess = &killingProcess{*c.initProcess.(*mockProcess)}
+	return c, cmd
+}
+
+func TestQuiesceWaitsForQuiescedFile(t *testing.T) {
+	root, err := ioutil.TempDir("", "libcontainer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	c, cmd := quiescingContainer(t, root, `trap 'sleep 0.2; touch "$0"' USR1; echo ready; while :; do sleep 0.01; done`)
+	defer cmd.Wait()
+	defer cmd.Process.Kill()
+
+	start := time.Now()
+	if err := c.Quiesce(syscall.SIGUSR1, 10*time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < 200*time.Millisecond {
+		t.Fatalf("expected Quiesce to wait for the quiesced file, returned after %s", elapsed)
+	}
+	if _, err := os.Stat(c.config.QuiescedFile); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestQuiesceTimeout(t *testing.T) {
+	root, err := ioutil.TempDir("", "libcontainer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	c, cmd := quiescingContainer(t, root, `trap '' USR1; echo ready; while :; do sleep 0.01; done`)
+	defer cmd.Wait()
+	defer cmd.Process.Kill()
+
+	if err := c.Quiesce(syscall.SIGUSR1, 100*time.Millisecond); err == nil {
+		t.Fatal("expected Quiesce to time out while the init did not quiesce")
+	}
+}
+
+func TestQuiesceDoesNotLockContainer(t *testing.T) {
+	root, err := ioutil.TempDir("", "libcontainer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	c, cmd := quiescingContainer(t, root, `trap '' USR1; echo ready; while :; do sleep 0.01; done`)
+	defer cmd.Wait()
+	defer cmd.Process.Kill()
+
+	done := make(chan error, 1)
+	go func() { done <- c.Quiesce(syscall.SIGUSR1, 2*time.Second) }()
+	time.Sleep(100 * time.Millisecond)
+	status := make(chan Status, 1)
+	go func() {
+		s, _ := c.Status()
+		status <- s
+	}()
+	select {
+	case s := <-status:
+		if s != Running {
+			t.Fatalf("expected the container to be running, got %s", s)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected the container to be usable while Quiesce waits")
+	}
+	<-done
+}
+
+func TestRemoveInRootStaysInRoot(t *testing.T) {
+	dir, err := ioutil.TempDir("", "libcontainer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	host := filepath.Join(dir, "host")
+	rootfs := filepath.Join(dir, "rootfs")
+	for _, d := range []string{host, filepath.Join(rootfs, "run")} {
+		if err := os.MkdirAll(d, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	victim := filepath.Join(host, "victim")
+	if err := ioutil.WriteFile(victim, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	// Both point at the host directory when resolved against the host root.
+	if err := os.Symlink(host, filepath.Join(rootfs, "run", "abs")); err != nil {
+		t.Fatal(err)
+	}
+	rel, err := filepath.Rel(filepath.Join(rootfs, "run"), host)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(strings.Repeat("../", 10)+rel, filepath.Join(rootfs, "run", "rel")); err != nil {
+		t.Fatal(err)
+	}
+	root, err := os.Open(rootfs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer root.Close()
+
+	for _, path := range []string{"/run/abs/victim", "/run/rel/victim", "/../../../" + rel + "/victim"} {
+		if err := removeInRoot(root, path); !os.IsNotExist(err) {
+			t.Errorf("expected %s not to exist below the root, got %v", path, err)
+		}
+		if quiesced(root, path) {
+			t.Errorf("expected %s not to be found below the root", path)
+		}
+	}
+	if _, err := os.Stat(victim); err != nil {
+		t.Fatalf("expected the host file to be left alone: %v", err)
+	}
+
+	// The same paths resolve below the root once it has the directory.
+	inRoot := filepath.Join(rootfs, host, "victim")
+	if err := os.MkdirAll(filepath.Dir(inRoot), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(inRoot, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if !quiesced(root, "/run/abs/victim") {
+		t.Fatal("expected the file to be found through the symlink below the root")
+	}
+	if err := removeInRoot(root, "/run/abs/victim"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(inRoot); !os.IsNotExist(err) {
+		t.Fatalf("expected the file below the root to be removed, got %v", err)
+	}
+}
@@ -0,0 +1,200 @@
+// +build linux
+
+package libcontainer
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/opencontainers/runc/libcontainer/cgroups"
+	"github.com/opencontainers/runc/libcontainer/system"
+)
+
+// reapInterval is how often the reaper looks for running processes of
+// containers that were reparented to the manager. Only those are reaped once
+// they exit, as an exited process cannot be told apart from the other
+// children of the manager anymore.
+const reapInterval = time.Second
+
+// reaper reaps the orphans of containers that are reparented to the manager
+// once it is a subreaper.
+var reaper = struct {
+	sync.Mutex
+	started bool
+	// own are the children that libcontainer waits for, which are the init
+	// and setns processes.
+	own map[int]bool
+	// containers maps the inits of the containers that were started by the
+	// manager to their cgroups, as listed in /proc/<pid>/cgroup.
+	containers map[int]map[string]string
+	// orphans are the children that were found in the cgroups of a
+	// container, which the reaper waits for.
+	orphans map[int]bool
+}{
+	own:        make(map[int]bool),
+	containers: make(map[int]map[string]string),
+	orphans:    make(map[int]bool),
+}
+
+// trackChild keeps the reaper from reaping the child, which libcontainer
+// waits for.
+func trackChild(pid int) {
+	reaper.Lock()
+	reaper.own[pid] = true
+	reaper.Unlock()
+}
+
+// untrackChild undoes trackChild once the child was waited for.
+func untrackChild(pid int) {
+	reaper.Lock()
+	delete(reaper.own, pid)
+	reaper.Unlock()
+}
+
+// trackContainer makes the reaper reap the children of the manager that are
+// in the cgroups of the init with pid, once the init has joined them.
+func trackContainer(pid int) error {
+	reaper.Lock()
+	defer reaper.Unlock()
+	if !reaper.started {
+		return nil
+	}
+	paths, err := cgroups.ParseCgroupFile(fmt.Sprintf("/proc/%d/cgroup", pid))
+	if err != nil {
+		return err
+	}
+	reaper.containers[pid] = paths
+	return nil
+}
+
+// untrackContainer undoes trackContainer once the init was waited for.
+func untrackContainer(pid int) {
+	reaper.Lock()
+	delete(reaper.containers, pid)
+	reaper.Unlock()
+}
+
+// startReaper starts to reap orphaned children in the background, once.
+func startReaper() {
+	reaper.Lock()
+	defer reaper.Unlock()
+	if reaper.started {
+		return
+	}
+	reaper.started = true
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGCHLD)
+	go func() {
+		for {
+			reaper.Lock()
+			idle := len(reaper.containers) == 0 && len(reaper.orphans) == 0
+			reaper.Unlock()
+			if idle {
+				<-signals
+			} else {
+				select {
+				case <-signals:
+				case <-time.After(reapInterval):
+				}
+			}
+			reapOrphans()
+		}
+	}()
+}
+
+// reapOrphans reaps the exited children that were found in the cgroups of a
+// container before, and returns their pids. It records the running children
+// that are in the cgroups of a container now. Any other child is left to
+// whoever started it.
+func reapOrphans() []int {
+	children := childPids(os.Getpid())
+	current := make(map[int]bool, len(children))
+	var reaped []int
+	reaper.Lock()
+	defer reaper.Unlock()
+	for _, pid := range children {
+		if reaper.own[pid] {
+			continue
+		}
+		current[pid] = true
+		if !reaper.orphans[pid] {
+			if !inContainerCgroups(pid) {
+				continue
+			}
+			reaper.orphans[pid] = true
+		}
+		if zombie, err := system.IsZombie(pid); err != nil || !zombie {
+			continue
+		}
+		var status syscall.WaitStatus
+		if wpid, err := syscall.Wait4(pid, &status, syscall.WNOHANG, nil); err == nil && wpid == pid {
+			reaped = append(reaped, pid)
+		}
+		delete(current, pid)
+	}
+	// Forget the orphans that someone else has waited for meanwhile.
+	for pid := range reaper.orphans {
+		if !current[pid] {
+			delete(reaper.orphans, pid)
+		}
+	}
+	return reaped
+}
+
+// inContainerCgroups reports whether the process is in the cgroups of one of
+// the tracked containers, or below them. An exited process is listed in the
+// root cgroups, so it is never found.
+func inContainerCgroups(pid int) bool {
+	paths, err := cgroups.ParseCgroupFile(fmt.Sprintf("/proc/%d/cgroup", pid))
+	if err != nil {
+		return false
+	}
+	for _, container := range reaper.containers {
+		if inCgroups(paths, container) {
+			return true
+		}
+	}
+	return false
+}
+
+// inCgroups reports whether the cgroups of a process, paths, are the cgroups
+// of a container or below them in one of the hierarchies. The root cgroups
+// are shared with the host and do not count.
+func inCgroups(paths, container map[string]string) bool {
+	for subsystem, path := range container {
+		if path == "/" {
+			continue
+		}
+		p, ok := paths[subsystem]
+		if ok && (p == path || strings.HasPrefix(p, path+"/")) {
+			return true
+		}
+	}
+	return false
+}
+
+// childPids returns the children of the process.
+func childPids(ppid int) []int {
+	dirs, err := filepath.Glob("/proc/[0-9]*")
+	if err != nil {
+		return nil
+	}
+	var pids []int
+	for _, dir := range dirs {
+		pid, err := strconv.Atoi(filepath.Base(dir))
+		if err != nil {
+			continue
+		}
+		if parent, err := system.GetParentPid(pid); err == nil && parent == ppid {
+			pids = append(pids, pid)
+		}
+	}
+	return pids
+}
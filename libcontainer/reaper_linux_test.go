@@ -0,0 +1,140 @@
+// +build linux
+
+package libcontainer
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/opencontainers/runc/libcontainer/cgroups"
+	"github.com/opencontainers/runc/libcontainer/system"
+)
+
+// startZombie starts a child that exits right away and does not wait for it.
+func startZombie(t *testing.T) int {
+	cmd := exec.Command("true")
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	pid := cmd.Process.Pid
+	for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(10 * time.Millisecond) {
+		if zombie, err := system.IsZombie(pid); err == nil && zombie {
+			return pid
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("child %d did not exit", pid)
+		}
+	}
+}
+
+func TestReapOrphansLeavesOtherChildren(t *testing.T) {
+	child := startZombie(t)
+	defer syscall.Wait4(child, nil, 0, nil)
+
+	for _, pid := range reapOrphans() {
+		if pid == child {
+			t.Fatal("expected a child outside of the cgroups of containers not to be reaped")
+		}
+	}
+	var status syscall.WaitStatus
+	if pid, err := syscall.Wait4(child, &status, syscall.WNOHANG, nil); err != nil || pid != child {
+		t.Fatalf("expected the child to be left for whoever started it, got %d %v", pid, err)
+	}
+}
+
+func TestInCgroups(t *testing.T) {
+	container := map[string]string{"memory": "/ctr", "cpu": "/"}
+	for _, test := range []struct {
+		paths    map[string]string
+		expected bool
+	}{
+		{map[string]string{"memory": "/ctr"}, true},
+		{map[string]string{"memory": "/ctr/sub"}, true},
+		{map[string]string{"memory": "/ctr2"}, false},
+		{map[string]string{"memory": "/"}, false},
+		// The root cgroups of the container are shared with everything else.
+		{map[string]string{"cpu": "/"}, false},
+		{map[string]string{"pids": "/ctr"}, false},
+	} {
+		if got := inCgroups(test.paths, container); got != test.expected {
+			t.Errorf("expected %v to be in the cgroups of the container to be %v", test.paths, test.expected)
+		}
+	}
+}
+
+// TestSubreaper runs TestSubreaperHelper, which orphans a process in the
+// cgroup of a container, and expects it to reap the orphan.
+func TestSubreaper(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("creating a cgroup needs root")
+	}
+	if _, err := cgroups.FindCgroupMountpoint("pids"); err != nil {
+		t.Skip("pids cgroup is not mounted")
+	}
+	cmd := exec.Command(os.Args[0], "-test.run=^TestSubreaperHelper$", "-test.v")
+	cmd.Env = append(os.Environ(), "LIBCONTAINER_TEST_SUBREAPER=1")
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("subreaper helper failed: %v\n%s", err, out)
+	}
+	if !strings.Contains(string(out), "--- PASS: TestSubreaperHelper") {
+		t.Fatalf("subreaper helper did not run:\n%s", out)
+	}
+}
+
+func TestSubreaperHelper(t *testing.T) {
+	if os.Getenv("LIBCONTAINER_TEST_SUBREAPER") != "1" {
+		return
+	}
+	if err := Subreaper(&LinuxFactory{}); err != nil {
+		t.Fatal(err)
+	}
+	own, err := cgroups.GetOwnCgroup("pids")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ownPath, err := cgroups.GetOwnCgroupPath("pids")
+	if err != nil {
+		t.Fatal(err)
+	}
+	name := "libcontainer-reaper-" + strconv.Itoa(os.Getpid())
+	dir := filepath.Join(ownPath, name)
+	if err := os.Mkdir(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(dir)
+	// Stand in for the init of a container in dir.
+	reaper.Lock()
+	reaper.containers[-1] = map[string]string{"pids": filepath.Join(own, name)}
+	reaper.Unlock()
+	defer untrackContainer(-1)
+
+	// Only the orphan joins the cgroup, the shell that starts it is left
+	// for exec.
+	out, err := exec.Command("sh", "-c", `sh -c 'echo $$ > "$0/cgroup.procs" && exec sleep 2' "$0" >/dev/null 2>&1 & echo $!`, dir).Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	orphan, err := strconv.Atoi(strings.TrimSpace(string(out)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	parent, err := system.GetParentPid(orphan)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parent != os.Getpid() {
+		t.Fatalf("expected the orphan to be reparented to the subreaper, got parent %d", parent)
+	}
+	for deadline := time.Now().Add(5 * time.Second); syscall.Kill(orphan, 0) == nil; time.Sleep(50 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected the orphan %d to be reaped", orphan)
+		}
+	}
+}
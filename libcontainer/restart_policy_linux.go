@@ -0,0 +1,163 @@
+// +build linux
+
+package libcontainer
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// oomGracePeriod is how long Supervise waits for an OOM notification after
+// the init exited, as the notification may be forwarded after the exit.
+const oomGracePeriod = 100 * time.Millisecond
+
+// RestartReason is why a RestartPolicy is asked to restart the init.
+type RestartReason int
+
+const (
+	// ExitReason is given when the init exited without an OOM kill in the
+	// container since it was started.
+	ExitReason RestartReason = iota
+	// OOMReason is given when the OOM killer killed a process of the
+	// container, the init or any other.
+	OOMReason
+)
+
+func (r RestartReason) String() string {
+	switch r {
+	case ExitReason:
+		return "exit"
+	case OOMReason:
+		return "oom"
+	}
+	return fmt.Sprintf("RestartReason(%d)", int(r))
+}
+
+// RestartPolicy decides whether Supervise restarts the init of a container.
+type RestartPolicy interface {
+	// Restart reports whether the init is restarted once it exits for
+	// reason. It is also asked on an OOM kill ahead of the exit, for the
+	// intent reported to the manager.
+	Restart(reason RestartReason) bool
+}
+
+// RestartPolicyFunc is a RestartPolicy that calls the function.
+type RestartPolicyFunc func(reason RestartReason) bool
+
+// Restart calls f(reason).
+func (f RestartPolicyFunc) Restart(reason RestartReason) bool {
+	return f(reason)
+}
+
+var (
+	// RestartNever never restarts the init.
+	RestartNever RestartPolicy = RestartPolicyFunc(func(RestartReason) bool { return false })
+	// RestartOnOOM restarts the init if it exited after an OOM kill in the
+	// container.
+	RestartOnOOM RestartPolicy = RestartPolicyFunc(func(reason RestartReason) bool { return reason == OOMReason })
+	// RestartAlways restarts the init whenever it exits.
+	RestartAlways RestartPolicy = RestartPolicyFunc(func(RestartReason) bool { return true })
+)
+
+// RestartEvent is what Supervise reports to the manager of the container.
+type RestartEvent struct {
+	Reason RestartReason
+
+	// Exited is set when the init exited, and not set for an OOM kill that
+	// the init may survive.
+	Exited bool
+
+	// Restart is the decision of the policy. Without Exited it is what
+	// happens once the init exits.
+	Restart bool
+
+	// State is the exit state of the init, set with Exited.
+	State *os.ProcessState
+
+	// Process is the restarted init, and Err the error restarting it or
+	// registering for its OOM notifications. Supervising stops after an
+	// error.
+	Process *Process
+	Err     error
+}
+
+// Supervise waits for the init of the running container c, process, to
+// exit and restarts it with the config stored in c as policy asks, by
+// running a copy of process. notify is called for every OOM kill and every
+// exit of the init, from a goroutine of Supervise; the manager must not wait
+// on the processes of the init itself. Supervising ends when the init is not
+// restarted or the returned function is called, which does not stop the
+// container.
+func Supervise(c Container, process *Process, policy RestartPolicy, notify func(RestartEvent)) (func(), error) {
+	oom, err := c.NotifyOOM()
+	if err != nil {
+		return nil, newSystemErrorWithCause(err, "registering for OOM notifications")
+	}
+	var (
+		once sync.Once
+		done = make(chan struct{})
+	)
+	go func() {
+		oomed := false
+		for {
+			exited := make(chan *os.ProcessState, 1)
+			go func(p *Process) {
+				state, _ := p.Wait()
+				exited <- state
+			}(process)
+			var state *os.ProcessState
+		wait:
+			for {
+				select {
+				case _, ok := <-oom:
+					if !ok {
+						oom = nil
+						continue
+					}
+					oomed = true
+					notify(RestartEvent{Reason: OOMReason, Restart: policy.Restart(OOMReason)})
+				case state = <-exited:
+					break wait
+				case <-done:
+					return
+				}
+			}
+			if !oomed {
+				select {
+				case _, ok := <-oom:
+					oomed = ok
+				case <-time.After(oomGracePeriod):
+				}
+			}
+			ev := RestartEvent{Reason: ExitReason, Exited: true, State: state}
+			if oomed {
+				ev.Reason = OOMReason
+			}
+			select {
+			case <-done:
+				return
+			default:
+			}
+			if ev.Restart = policy.Restart(ev.Reason); ev.Restart {
+				next := *process
+				next.ops = nil
+				if ev.Err = c.Run(&next); ev.Err == nil {
+					ev.Process = &next
+					// The channel of the exited init closes with its
+					// cgroup, so the restarted init needs a new one.
+					if oom, err = c.NotifyOOM(); err != nil {
+						ev.Err = newSystemErrorWithCause(err, "registering for OOM notifications")
+					}
+				}
+			}
+			notify(ev)
+			if ev.Process == nil || ev.Err != nil {
+				return
+			}
+			process, oomed = ev.Process, false
+		}
+	}()
+	return func() { once.Do(func() { close(done) }) }, nil
+}
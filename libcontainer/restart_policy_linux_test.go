@@ -0,0 +1,156 @@
+// +build linux
+
+package libcontainer
+
+import (
+	"errors"
+	"os"
+	"testing"
+	"time"
+)
+
+type fakeOps struct {
+	exit chan struct{}
+}
+
+func (o *fakeOps) wait() (*os.ProcessState, error) {
+	<-o.exit
+	return nil, nil
+}
+
+func (o *fakeOps) signal(os.Signal) error { return nil }
+
+func (o *fakeOps) pid() int { return 1 }
+
+// fakeSupervised is a container for Supervise whose OOM notifications are
+// sent by the test and whose restarted inits exit when the test says so.
+type fakeSupervised struct {
+	Container
+	ooms   chan chan struct{}
+	runErr error
+	runs   chan *Process
+}
+
+// NotifyOOM hands the test a new channel for every registration.
+func (c *fakeSupervised) NotifyOOM() (<-chan struct{}, error) {
+	oom := make(chan struct{}, 1)
+	c.ooms <- oom
+	return oom, nil
+}
+
+func (c *fakeSupervised) Run(p *Process) error {
+	if c.runErr != nil {
+		return c.runErr
+	}
+	p.ops = &fakeOps{exit: make(chan struct{})}
+	c.runs <- p
+	return nil
+}
+
+func supervise(t *testing.T, c *fakeSupervised, policy RestartPolicy) (*fakeOps, <-chan RestartEvent, func()) {
+	ops := &fakeOps{exit: make(chan struct{})}
+	events := make(chan RestartEvent, 1)
+	stop, err := Supervise(c, &Process{Args: []string{"service"}, ops: ops}, policy, func(ev RestartEvent) {
+		events <- ev
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ops, events, stop
+}
+
+func nextEvent(t *testing.T, events <-chan RestartEvent) RestartEvent {
+	select {
+	case ev := <-events:
+		return ev
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for a restart event")
+	}
+	return RestartEvent{}
+}
+
+func TestSuperviseRestartOnOOM(t *testing.T) {
+	c := &fakeSupervised{ooms: make(chan chan struct{}, 2), runs: make(chan *Process, 1)}
+	ops, events, stop := supervise(t, c, RestartOnOOM)
+	defer stop()
+
+	oom := <-c.ooms
+	oom <- struct{}{}
+	if ev := nextEvent(t, events); ev.Reason != OOMReason || ev.Exited || !ev.Restart {
+		t.Fatalf("expected the OOM kill to be reported with restart intent, got %+v", ev)
+	}
+	close(ops.exit)
+	ev := nextEvent(t, events)
+	if ev.Reason != OOMReason || !ev.Exited || !ev.Restart || ev.Err != nil {
+		t.Fatalf("expected the init to be restarted after the OOM kill, got %+v", ev)
+	}
+	restarted := <-c.runs
+	if ev.Process != restarted || restarted.Args[0] != "service" {
+		t.Fatalf("expected a copy of the init to be run, got %+v", restarted)
+	}
+
+	// Without another OOM kill the restarted init is not restarted again.
+	close(restarted.ops.(*fakeOps).exit)
+	if ev := nextEvent(t, events); ev.Reason != ExitReason || !ev.Exited || ev.Restart || ev.Process != nil {
+		t.Fatalf("expected the plain exit not to restart the init, got %+v", ev)
+	}
+}
+
+func TestSuperviseRestartOnOOMAgain(t *testing.T) {
+	c := &fakeSupervised{ooms: make(chan chan struct{}, 2), runs: make(chan *Process, 1)}
+	ops, events, stop := supervise(t, c, RestartOnOOM)
+	defer stop()
+
+	oom := <-c.ooms
+	oom <- struct{}{}
+	nextEvent(t, events)
+	close(ops.exit)
+	if ev := nextEvent(t, events); ev.Process == nil || ev.Err != nil {
+		t.Fatalf("expected the init to be restarted after the OOM kill, got %+v", ev)
+	}
+	restarted := <-c.runs
+	close(oom)
+
+	// The restarted init is registered for OOM kills of its own.
+	select {
+	case oom = <-c.ooms:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the restarted init to be registered for OOM notifications")
+	}
+	oom <- struct{}{}
+	if ev := nextEvent(t, events); ev.Reason != OOMReason || ev.Exited || !ev.Restart {
+		t.Fatalf("expected the OOM kill of the restarted init to be reported, got %+v", ev)
+	}
+	close(restarted.ops.(*fakeOps).exit)
+	if ev := nextEvent(t, events); ev.Reason != OOMReason || !ev.Exited || ev.Process == nil {
+		t.Fatalf("expected the restarted init to be restarted after its OOM kill, got %+v", ev)
+	}
+}
+
+func TestSuperviseRestartNever(t *testing.T) {
+	c := &fakeSupervised{ooms: make(chan chan struct{}, 2), runs: make(chan *Process, 1)}
+	ops, events, stop := supervise(t, c, RestartNever)
+	defer stop()
+
+	oom := <-c.ooms
+	oom <- struct{}{}
+	if ev := nextEvent(t, events); ev.Reason != OOMReason || ev.Restart {
+		t.Fatalf("expected the OOM kill to be reported without restart intent, got %+v", ev)
+	}
+	close(ops.exit)
+	if ev := nextEvent(t, events); ev.Reason != OOMReason || !ev.Exited || ev.Restart || ev.Process != nil {
+		t.Fatalf("expected the init not to be restarted, got %+v", ev)
+	}
+}
+
+func TestSuperviseRestartAlwaysError(t *testing.T) {
+	c := &fakeSupervised{ooms: make(chan chan struct{}, 1), runErr: errors.New("no rootfs"), runs: make(chan *Process, 1)}
+	ops, events, stop := supervise(t, c, RestartAlways)
+	defer stop()
+
+	close(ops.exit)
+	ev := nextEvent(t, events)
+	if ev.Reason != ExitReason || !ev.Restart || ev.Err != c.runErr || ev.Process != nil {
+		t.Fatalf("expected the error restarting the init, got %+v", ev)
+	}
+}
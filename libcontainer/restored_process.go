@@ -5,6 +5,7 @@ package libcontainer
 import (
 	"fmt"
 	"os"
+	"syscall"
 
 	"github.com/opencontainers/runc/libcontainer/system"
 )
@@ -105,7 +106,20 @@ func (p *nonChildProcess) startTime() (string, error) {
 	return p.processStartTime, nil
 }
 
+// signal sends s through a pidfd when the kernel supports them, as the
+// process is not our child and its pid may have been reused since the state
+// was loaded.
 func (p *nonChildProcess) signal(s os.Signal) error {
+	if sig, ok := s.(syscall.Signal); ok {
+		fd, err := openPidFd(p.processPid, p.processStartTime)
+		if err == nil {
+			defer syscall.Close(fd)
+			return system.PidfdSendSignal(fd, sig)
+		}
+		if err != syscall.ENOSYS {
+			return err
+		}
+	}
 	proc, err := os.FindProcess(p.processPid)
 	if err != nil {
 		return err
@@ -0,0 +1,170 @@
+// +build linux
+
+package libcontainer
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+	"unsafe"
+
+	"github.com/opencontainers/runc/libcontainer/configs"
+	"github.com/opencontainers/selinux/go-selinux/label"
+)
+
+const (
+	loopControlPath = "/dev/loop-control"
+
+	loopSetFd       = 0x4c00
+	loopClrFd       = 0x4c01
+	loopSetStatus64 = 0x4c04
+	loopGetStatus64 = 0x4c05
+	loopCtlGetFree  = 0x4c82
+
+	loFlagsAutoclear = 4
+
+	// loopAttachAttempts is how often a free loop device is looked for, as
+	// another process can take the one found before it is attached.
+	loopAttachAttempts = 10
+)
+
+// loopInfo64 is struct loop_info64 of linux/loop.h.
+type loopInfo64 struct {
+	device         uint64
+	inode          uint64
+	rdevice        uint64
+	offset         uint64
+	sizelimit      uint64
+	number         uint32
+	encryptType    uint32
+	encryptKeySize uint32
+	flags          uint32
+	fileName       [64]byte
+	cryptName      [64]byte
+	encryptKey     [32]byte
+	init           [2]uint64
+}
+
+// rootfsImage is the loop device that the image file of the rootfs is
+// attached to, for the init to mount. BackingDev and BackingInode identify
+// the file it was attached to, which the path of the image may not refer to
+// anymore.
+type rootfsImage struct {
+	Device       string `json:"device"`
+	Type         string `json:"type"`
+	BackingDev   uint64 `json:"backing_dev"`
+	BackingInode uint64 `json:"backing_inode"`
+}
+
+// attachRootfsImage attaches the image file of the rootfs to a free loop
+// device. The device is read-only as the file is opened read-only, and stays
+// attached until autoclear has been set once it is mounted, or until
+// detachRootfsImage.
+func attachRootfsImage(image *configs.Image) (*rootfsImage, error) {
+	backing, err := os.Open(image.Path)
+	if err != nil {
+		return nil, err
+	}
+	defer backing.Close()
+	var st syscall.Stat_t
+	if err := syscall.Fstat(int(backing.Fd()), &st); err != nil {
+		return nil, os.NewSyscallError("fstat", err)
+	}
+	control, err := os.OpenFile(loopControlPath, os.O_RDWR, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer control.Close()
+	for i := 0; i < loopAttachAttempts; i++ {
+		n, _, errno := syscall.Syscall(syscall.SYS_IOCTL, control.Fd(), loopCtlGetFree, 0)
+		if errno != 0 {
+			return nil, os.NewSyscallError("LOOP_CTL_GET_FREE", errno)
+		}
+		device := fmt.Sprintf("/dev/loop%d", n)
+		loop, err := os.OpenFile(device, os.O_RDONLY, 0)
+		if err != nil {
+			return nil, err
+		}
+		_, _, errno = syscall.Syscall(syscall.SYS_IOCTL, loop.Fd(), loopSetFd, backing.Fd())
+		loop.Close()
+		if errno == syscall.EBUSY {
+			continue
+		}
+		if errno != 0 {
+			return nil, os.NewSyscallError("LOOP_SET_FD", errno)
+		}
+		return &rootfsImage{
+			Device:       device,
+			Type:         image.Type,
+			BackingDev:   uint64(st.Dev),
+			BackingInode: st.Ino,
+		}, nil
+	}
+	return nil, fmt.Errorf("no free loop device for %s after %d attempts", image.Path, loopAttachAttempts)
+}
+
+// mount mounts the loop device read-only at the rootfs.
+func (i *rootfsImage) mount(rootfs, mountLabel string) error {
+	return syscall.Mount(i.Device, rootfs, i.Type, syscall.MS_RDONLY, label.FormatMountLabel("", mountLabel))
+}
+
+// autoclear makes the kernel detach the loop device once the last mount of
+// it is gone, so that the device is not left behind when the container's
+// mount namespace goes away without a Destroy. It is set once the init has
+// mounted the device, as the device would be detached right away otherwise.
+func (i *rootfsImage) autoclear() error {
+	loop, info, err := i.open()
+	if err != nil || loop == nil {
+		return err
+	}
+	defer loop.Close()
+	info.flags |= loFlagsAutoclear
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, loop.Fd(), loopSetStatus64, uintptr(unsafe.Pointer(info))); errno != 0 {
+		return os.NewSyscallError("LOOP_SET_STATUS64", errno)
+	}
+	return nil
+}
+
+// open opens the loop device and returns its status, unless it has been
+// detached or attached to another file since, when the returned file is nil.
+func (i *rootfsImage) open() (*os.File, *loopInfo64, error) {
+	loop, err := os.OpenFile(i.Device, os.O_RDONLY, 0)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil, nil
+		}
+		return nil, nil, err
+	}
+	var info loopInfo64
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, loop.Fd(), loopGetStatus64, uintptr(unsafe.Pointer(&info))); errno != 0 {
+		loop.Close()
+		if errno == syscall.ENXIO {
+			return nil, nil, nil
+		}
+		return nil, nil, os.NewSyscallError("LOOP_GET_STATUS64", errno)
+	}
+	if info.device != i.BackingDev || info.inode != i.BackingInode {
+		loop.Close()
+		return nil, nil, nil
+	}
+	return loop, &info, nil
+}
+
+// detachRootfsImage detaches the loop device of image if it is still
+// attached to the file it was attached to, as the device may have been
+// detached and reused for another file already. A device that is still
+// mounted somewhere is detached by the kernel once it is unmounted.
+func detachRootfsImage(image *rootfsImage) error {
+	if image == nil || image.Device == "" {
+		return nil
+	}
+	loop, _, err := image.open()
+	if err != nil || loop == nil {
+		return err
+	}
+	defer loop.Close()
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, loop.Fd(), loopClrFd, 0); errno != 0 && errno != syscall.ENXIO {
+		return os.NewSyscallError("LOOP_CLR_FD", errno)
+	}
+	return nil
+}
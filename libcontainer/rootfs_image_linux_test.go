@@ -0,0 +1,137 @@
+// +build linux
+
+package libcontainer
+
+import (
+	"io/ioutil"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/opencontainers/runc/libcontainer/configs"
+)
+
+// loopBacking returns the image file that device is attached to, or an empty
+// string if it is detached.
+func loopBacking(t *testing.T, device string) string {
+	out, err := ioutil.ReadFile("/sys/block/" + device[len("/dev/"):] + "/loop/backing_file")
+	if os.IsNotExist(err) {
+		return ""
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(out[:len(out)-1])
+}
+
+func TestAttachRootfsImage(t *testing.T) {
+	if os.Getuid() != 0 {
+		t.Skip("attaching loop devices requires root")
+	}
+	if _, err := os.Stat(loopControlPath); err != nil {
+		t.Skipf("no loop devices: %v", err)
+	}
+	path := newRootfsImageFile(t)
+	defer os.Remove(path)
+
+	image := &configs.Image{Path: path, Type: "squashfs"}
+	attached, err := attachRootfsImage(image)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer detachRootfsImage(attached)
+	if attached.Type != image.Type || loopBacking(t, attached.Device) != image.Path {
+		t.Fatalf("expected %s to be attached to %s, got %+v", attached.Device, image.Path, attached)
+	}
+	var st syscall.Stat_t
+	if err := syscall.Stat(image.Path, &st); err != nil {
+		t.Fatal(err)
+	}
+	if attached.BackingDev != uint64(st.Dev) || attached.BackingInode != st.Ino {
+		t.Errorf("expected %s to record the image file, got %+v", attached.Device, attached)
+	}
+	ro, err := ioutil.ReadFile("/sys/block/" + attached.Device[len("/dev/"):] + "/ro")
+	if err != nil || string(ro) != "1\n" {
+		t.Errorf("expected %s to be read-only: %q %v", attached.Device, ro, err)
+	}
+}
+
+// newRootfsImageFile returns the path of an empty image file.
+func newRootfsImageFile(t *testing.T) string {
+	f, err := ioutil.TempFile("", "rootfs-image")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if err := f.Truncate(1 << 20); err != nil {
+		os.Remove(f.Name())
+		t.Fatal(err)
+	}
+	return f.Name()
+}
+
+func TestDetachRootfsImage(t *testing.T) {
+	if os.Getuid() != 0 {
+		t.Skip("attaching loop devices requires root")
+	}
+	if _, err := os.Stat(loopControlPath); err != nil {
+		t.Skipf("no loop devices: %v", err)
+	}
+	path := newRootfsImageFile(t)
+	defer os.Remove(path)
+	attached, err := attachRootfsImage(&configs.Image{Path: path, Type: "erofs"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer detachRootfsImage(attached)
+
+	// A device reused for another file is left alone.
+	other := *attached
+	other.BackingInode++
+	if err := detachRootfsImage(&other); err != nil {
+		t.Fatal(err)
+	}
+	if backing := loopBacking(t, attached.Device); backing != path {
+		t.Fatalf("expected %s to stay attached to %s, got %q", attached.Device, path, backing)
+	}
+	// The device is detached even once the image file is gone.
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	if err := detachRootfsImage(attached); err != nil {
+		t.Fatal(err)
+	}
+	if backing := loopBacking(t, attached.Device); backing != "" {
+		t.Fatalf("expected %s to be detached, got %s", attached.Device, backing)
+	}
+	if err := detachRootfsImage(attached); err != nil {
+		t.Fatalf("expected detaching a detached device to succeed: %v", err)
+	}
+}
+
+func TestRootfsImageAutoclear(t *testing.T) {
+	if os.Getuid() != 0 {
+		t.Skip("attaching loop devices requires root")
+	}
+	if _, err := os.Stat(loopControlPath); err != nil {
+		t.Skipf("no loop devices: %v", err)
+	}
+	path := newRootfsImageFile(t)
+	defer os.Remove(path)
+	attached, err := attachRootfsImage(&configs.Image{Path: path, Type: "erofs"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer detachRootfsImage(attached)
+	if err := attached.autoclear(); err != nil {
+		t.Fatal(err)
+	}
+	// Nothing has the device mounted, so the kernel detaches it once
+	// autoclear has closed it.
+	for deadline := time.Now().Add(5 * time.Second); loopBacking(t, attached.Device) != ""; time.Sleep(10 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected %s to be detached with autoclear", attached.Device)
+		}
+	}
+}
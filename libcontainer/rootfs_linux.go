@@ -3,6 +3,7 @@
 package libcontainer
 
 import (
+	"bufio"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -10,6 +11,7 @@ import (
 	"os/exec"
 	"path"
 	"path/filepath"
+	"regexp"
 	"strings"
 	"syscall"
 	"time"
@@ -28,7 +30,7 @@ const defaultMountFlags = syscall.MS_NOEXEC | syscall.MS_NOSUID | syscall.MS_NOD
 
 // needsSetupDev returns true if /dev needs to be set up.
 func needsSetupDev(config *configs.Config) bool {
-	for _, m := range config.Mounts {
+	for _, m := range config.AllMounts() {
 		if m.Device == "bind" && libcontainerUtils.CleanPath(m.Destination) == "/dev" {
 			return false
 		}
@@ -40,21 +42,60 @@ func needsSetupDev(config *configs.Config) bool {
 // inside a new mount namespace. It doesn't set anything as ro or pivot_root,
 // because console setup happens inside the caller. You must call
 // finalizeRootfs in order to finish the rootfs setup.
-func prepareRootfs(pipe io.ReadWriter, config *configs.Config) (err error) {
-	if err := prepareRoot(config); err != nil {
+func prepareRootfs(pipe *os.File, config *configs.Config, image *rootfsImage, overlay *rootfsOverlay) (err error) {
+	traceStep("preparing rootfs %s", config.Rootfs)
+	var tree *os.File
+	if config.RootfsOwnership == configs.RootfsIdmap {
+		traceStep("asking for an idmapped rootfs")
+		if tree, err = recvRootfsTree(pipe); err != nil {
+			return newSystemErrorWithCause(err, "receiving idmapped rootfs")
+		}
+	}
+	if err := prepareRoot(config, tree); err != nil {
 		return newSystemErrorWithCause(err, "preparing rootfs")
 	}
+	if image != nil {
+		traceStep("mounting %s image from %s", image.Type, image.Device)
+		if err := image.mount(config.Rootfs, config.MountLabel); err != nil {
+			return newSystemErrorWithCause(err, "mounting rootfs image")
+		}
+	}
+	if overlay != nil {
+		traceStep("mounting overlay with upper directory %s", overlay.UpperDir)
+		if err := overlay.mount(config.Rootfs, config.MountLabel); err != nil {
+			return newSystemErrorWithCause(err, "mounting rootfs overlay")
+		}
+	}
+	trees, err := recvMountTrees(pipe, config)
+	if err != nil {
+		return newSystemErrorWithCause(err, "receiving idmapped mounts")
+	}
+	defer closeMountTrees(trees)
 
+	mounts, err := configs.OrderMounts(config.AllMounts())
+	if err != nil {
+		return newSystemErrorWithCause(err, "ordering mounts")
+	}
 	setupDev := needsSetupDev(config)
-	for _, m := range config.Mounts {
+	for _, m := range mounts {
 		for _, precmd := range m.PremountCmds {
 			if err := mountCmd(precmd); err != nil {
 				return newSystemErrorWithCause(err, "running premount command")
 			}
 		}
 
-		if err := mountToRootfs(m, config.Rootfs, config.MountLabel); err != nil {
-			return newSystemErrorWithCausef(err, "mounting %q to rootfs %q at %q", m.Source, config.Rootfs, m.Destination)
+		if tree, ok := trees[m]; ok {
+			traceStep("mounting idmapped %s at %s", m.Source, m.Destination)
+			if err := bindMount(m, config.Rootfs, config.MountLabel, tree); err != nil {
+				return newSystemErrorWithCausef(err, "mounting idmapped %q to rootfs %q at %q", m.Source, config.Rootfs, m.Destination)
+			}
+		} else {
+			m = shmMount(config, m)
+			m = procMount(config, m)
+			traceStep("mounting %s %s at %s", m.Device, m.Source, m.Destination)
+			if err := mountToRootfs(m, config.Rootfs, config.MountLabel); err != nil {
+				return newSystemErrorWithCausef(err, "mounting %q to rootfs %q at %q", m.Source, config.Rootfs, m.Destination)
+			}
 		}
 
 		for _, postcmd := range m.PostmountCmds {
@@ -64,10 +105,24 @@ func prepareRootfs(pipe io.ReadWriter, config *configs.Config) (err error) {
 		}
 	}
 
+	if config.CgroupView != nil {
+		if err := mountCgroupView(config); err != nil {
+			return newSystemErrorWithCause(err, "mounting cgroup view")
+		}
+	}
+
+	if err := setupFileCapabilities(config); err != nil {
+		return newSystemErrorWithCause(err, "setting file capabilities")
+	}
+
 	if setupDev {
+		traceStep("setting up /dev")
 		if err := createDevices(config); err != nil {
 			return newSystemErrorWithCause(err, "creating device nodes")
 		}
+		if err := setupDevpts(config); err != nil {
+			return newSystemErrorWithCause(err, "mounting devpts")
+		}
 		if err := setupPtmx(config); err != nil {
 			return newSystemErrorWithCause(err, "setting up ptmx")
 		}
@@ -96,6 +151,7 @@ func prepareRootfs(pipe io.ReadWriter, config *configs.Config) (err error) {
 		return newSystemErrorWithCausef(err, "changing dir to %q", config.Rootfs)
 	}
 
+	traceStep("changing root to %s", config.Rootfs)
 	if config.NoPivotRoot {
 		err = msMoveRoot(config.Rootfs)
 	} else {
@@ -118,7 +174,7 @@ func prepareRootfs(pipe io.ReadWriter, config *configs.Config) (err error) {
 // to ro if necessary. You must call prepareRootfs first.
 func finalizeRootfs(config *configs.Config) (err error) {
 	// remount dev as ro if specified
-	for _, m := range config.Mounts {
+	for _, m := range config.AllMounts() {
 		if libcontainerUtils.CleanPath(m.Destination) == "/dev" {
 			if m.Flags&syscall.MS_RDONLY == syscall.MS_RDONLY {
 				if err := remountReadonly(m); err != nil {
@@ -135,6 +191,11 @@ func finalizeRootfs(config *configs.Config) (err error) {
 			return newSystemErrorWithCause(err, "setting rootfs as readonly")
 		}
 	}
+	if config.RootfsMountFlags != 0 {
+		if err := remountRoot(config.RootfsMountFlags); err != nil {
+			return newSystemErrorWithCause(err, "setting rootfs mount flags")
+		}
+	}
 
 	syscall.Umask(0022)
 	return nil
@@ -164,7 +225,14 @@ func mountToRootfs(m *configs.Mount, rootfs, mountLabel string) error {
 			return err
 		}
 		// Selinux kernels do not support labeling of /proc or /sys
-		return mountPropagate(m, rootfs, "")
+		if err := mountPropagate(m, rootfs, ""); err != nil {
+			return err
+		}
+		// The host's /proc bound by procMount takes the flags with a remount.
+		if m.Flags&syscall.MS_BIND != 0 && m.Flags&^(syscall.MS_REC|syscall.MS_REMOUNT|syscall.MS_BIND) != 0 {
+			return remount(m, rootfs)
+		}
+		return nil
 	case "mqueue":
 		if err := os.MkdirAll(dest, 0755); err != nil {
 			return err
@@ -220,48 +288,7 @@ func mountToRootfs(m *configs.Mount, rootfs, mountLabel string) error {
 		}
 		return nil
 	case "bind":
-		stat, err := os.Stat(m.Source)
-		if err != nil {
-			// error out if the source of a bind mount does not exist as we will be
-			// unable to bind anything to it.
-			return err
-		}
-		// ensure that the destination of the bind mount is resolved of symlinks at mount time because
-		// any previous mounts can invalidate the next mount's destination.
-		// this can happen when a user specifies mounts within other mounts to cause breakouts or other
-		// evil stuff to try to escape the container's rootfs.
-		if dest, err = symlink.FollowSymlinkInScope(dest, rootfs); err != nil {
-			return err
-		}
-		if err := checkMountDestination(rootfs, dest); err != nil {
-			return err
-		}
-		// update the mount with the correct dest after symlinks are resolved.
-		m.Destination = dest
-		if err := createIfNotExists(dest, stat.IsDir()); err != nil {
-			return err
-		}
-		if err := mountPropagate(m, rootfs, mountLabel); err != nil {
-			return err
-		}
-		// bind mount won't change mount options, we need remount to make mount options effective.
-		// first check that we have non-default options required before attempting a remount
-		if m.Flags&^(syscall.MS_REC|syscall.MS_REMOUNT|syscall.MS_BIND) != 0 {
-			// only remount if unique mount options are set
-			if err := remount(m, rootfs); err != nil {
-				return err
-			}
-		}
-
-		if m.Relabel != "" {
-			if err := label.Validate(m.Relabel); err != nil {
-				return err
-			}
-			shared := label.IsShared(m.Relabel)
-			if err := label.Relabel(m.Source, mountLabel, shared); err != nil {
-				return err
-			}
-		}
+		return bindMount(m, rootfs, mountLabel, nil)
 	case "cgroup":
 		binds, err := getCgroupMounts(m)
 		if err != nil {
@@ -329,11 +356,151 @@ func mountToRootfs(m *configs.Mount, rootfs, mountLabel string) error {
 		if err := os.MkdirAll(dest, 0755); err != nil {
 			return err
 		}
+		if err := checkFilesystemAvailable(m.Device); err != nil {
+			return err
+		}
 		return mountPropagate(m, rootfs, mountLabel)
 	}
 	return nil
 }
 
+// checkFilesystemAvailable returns an error if the kernel can not mount
+// filesystems of type fstype. A type is available if it is registered in
+// /proc/filesystems or if modprobe knows a module providing it, in which case
+// the kernel loads the module on the first mount. Subtypes such as fuse.sshfs
+// are checked against their base type.
+func checkFilesystemAvailable(fstype string) error {
+	if fstype == "" {
+		return nil
+	}
+	fstype = strings.SplitN(fstype, ".", 2)[0]
+	f, err := os.Open("/proc/filesystems")
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	registered, err := parseFilesystems(f)
+	if err != nil {
+		return err
+	}
+	if registered[fstype] {
+		return nil
+	}
+	release, err := ioutil.ReadFile("/proc/sys/kernel/osrelease")
+	if err != nil {
+		return err
+	}
+	aliases, err := os.Open(filepath.Join("/lib/modules", strings.TrimSpace(string(release)), "modules.alias"))
+	if err == nil {
+		defer aliases.Close()
+		if ok, err := hasFilesystemModule(aliases, fstype); err != nil || ok {
+			return err
+		}
+	}
+	return fmt.Errorf("filesystem type %q is not supported by the kernel and no module provides it", fstype)
+}
+
+// parseFilesystems parses the format of /proc/filesystems, returning the set
+// of filesystem types registered with the kernel.
+func parseFilesystems(r io.Reader) (map[string]bool, error) {
+	types := make(map[string]bool)
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		fields := strings.Fields(s.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		types[fields[len(fields)-1]] = true
+	}
+	return types, s.Err()
+}
+
+// hasFilesystemModule reports whether a modules.alias file read from r has a
+// fs-<fstype> alias, which is what the kernel requests from modprobe when
+// mounting an unregistered filesystem type.
+func hasFilesystemModule(r io.Reader, fstype string) (bool, error) {
+	alias := "fs-" + fstype
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		fields := strings.Fields(s.Text())
+		if len(fields) >= 3 && fields[0] == "alias" && fields[1] == alias {
+			return true, nil
+		}
+	}
+	return false, s.Err()
+}
+
+// bindMount bind mounts the source of m, or attaches tree in its place if
+// it is not nil.
+func bindMount(m *configs.Mount, rootfs, mountLabel string, tree *os.File) error {
+	dest := m.Destination
+	if !strings.HasPrefix(dest, rootfs) {
+		dest = filepath.Join(rootfs, dest)
+	}
+	stat, err := os.Stat(m.Source)
+	if err != nil {
+		// error out if the source of a bind mount does not exist as we will be
+		// unable to bind anything to it.
+		return err
+	}
+	// ensure that the destination of the bind mount is resolved of symlinks at mount time because
+	// any previous mounts can invalidate the next mount's destination.
+	// this can happen when a user specifies mounts within other mounts to cause breakouts or other
+	// evil stuff to try to escape the container's rootfs.
+	if dest, err = symlink.FollowSymlinkInScope(dest, rootfs); err != nil {
+		return err
+	}
+	if err := checkMountDestination(rootfs, dest); err != nil {
+		return err
+	}
+	// update the mount with the correct dest after symlinks are resolved.
+	m.Destination = dest
+	if err := createIfNotExists(dest, stat.IsDir()); err != nil {
+		return err
+	}
+	if tree != nil {
+		err = mountTree(m, tree)
+	} else {
+		err = mountPropagate(m, rootfs, mountLabel)
+	}
+	if err != nil {
+		return err
+	}
+	// bind mount won't change mount options, we need remount to make mount options effective.
+	// first check that we have non-default options required before attempting a remount
+	if m.Flags&^(syscall.MS_REC|syscall.MS_REMOUNT|syscall.MS_BIND) != 0 {
+		// only remount if unique mount options are set
+		if err := remount(m, rootfs); err != nil {
+			return err
+		}
+	}
+
+	if m.Relabel != "" {
+		if err := label.Validate(m.Relabel); err != nil {
+			return err
+		}
+		shared := label.IsShared(m.Relabel)
+		if err := label.Relabel(m.Source, mountLabel, shared); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// mountTree attaches the detached mount tree at the destination of m, which
+// is resolved already, and applies the propagation flags of m.
+func mountTree(m *configs.Mount, tree *os.File) error {
+	if err := system.MoveMount(int(tree.Fd()), m.Destination); err != nil {
+		return err
+	}
+	for _, pflag := range m.PropagationFlags {
+		if err := syscall.Mount("", m.Destination, "", uintptr(pflag), ""); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func getCgroupMounts(m *configs.Mount) ([]*configs.Mount, error) {
 	mounts, err := cgroups.GetCgroupMounts(false)
 	if err != nil {
@@ -368,6 +535,46 @@ func getCgroupMounts(m *configs.Mount) ([]*configs.Mount, error) {
 	return binds, nil
 }
 
+// mountCgroupView bind mounts the container's own cgroup of each hierarchy
+// selected by the cgroup view read-only into the rootfs.
+func mountCgroupView(config *configs.Config) error {
+	view := config.CgroupView
+	binds, err := getCgroupMounts(&configs.Mount{
+		Destination: view.Destination,
+		Flags:       syscall.MS_RDONLY | syscall.MS_NOSUID | syscall.MS_NODEV | syscall.MS_NOEXEC,
+	})
+	if err != nil {
+		return err
+	}
+	mounted := 0
+	for _, b := range binds {
+		if len(view.Subsystems) > 0 && !hasSubsystem(filepath.Base(b.Destination), view.Subsystems) {
+			continue
+		}
+		if err := mountToRootfs(b, config.Rootfs, config.MountLabel); err != nil {
+			return err
+		}
+		mounted++
+	}
+	if mounted == 0 {
+		return fmt.Errorf("no cgroup hierarchy for subsystems %v", view.Subsystems)
+	}
+	return nil
+}
+
+// hasSubsystem reports whether the hierarchy, named after its comma separated
+// subsystems, includes any of the subsystems.
+func hasSubsystem(hierarchy string, subsystems []string) bool {
+	for _, ss := range strings.Split(hierarchy, ",") {
+		for _, s := range subsystems {
+			if ss == s {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 // checkMountDestination checks to ensure that the mount destination is not over the top of /proc.
 // dest is required to be an abs path and have any symlinks resolved before calling this function.
 func checkMountDestination(rootfs, dest string) error {
@@ -508,6 +715,18 @@ func createDeviceNode(rootfs string, node *configs.Device, bind bool) error {
 }
 
 func mknodDevice(dest string, node *configs.Device) error {
+	fileMode, err := deviceFileMode(node)
+	if err != nil {
+		return err
+	}
+	if err := syscall.Mknod(dest, fileMode, node.Mkdev()); err != nil {
+		return err
+	}
+	return syscall.Chown(dest, int(node.Uid), int(node.Gid))
+}
+
+// deviceFileMode returns the mode to create the node of the device with.
+func deviceFileMode(node *configs.Device) (uint32, error) {
 	fileMode := node.FileMode
 	switch node.Type {
 	case 'c', 'u':
@@ -517,12 +736,9 @@ func mknodDevice(dest string, node *configs.Device) error {
 	case 'p':
 		fileMode |= syscall.S_IFIFO
 	default:
-		return fmt.Errorf("%c is not a valid device type for device %s", node.Type, node.Path)
-	}
-	if err := syscall.Mknod(dest, uint32(fileMode), node.Mkdev()); err != nil {
-		return err
+		return 0, fmt.Errorf("%c is not a valid device type for device %s", node.Type, node.Path)
 	}
-	return syscall.Chown(dest, int(node.Uid), int(node.Gid))
+	return uint32(fileMode), nil
 }
 
 func getMountInfo(mountinfo []*mount.Info, dir string) *mount.Info {
@@ -595,7 +811,12 @@ func rootfsParentMountPrivate(rootfs string) error {
 	return nil
 }
 
-func prepareRoot(config *configs.Config) error {
+// prepareRoot makes the rootfs a private mount point, which is the idmapped
+// mount tree if there is one.
+func prepareRoot(config *configs.Config, tree *os.File) error {
+	if tree != nil {
+		defer tree.Close()
+	}
 	flag := syscall.MS_SLAVE | syscall.MS_REC
 	if config.RootPropagation != 0 {
 		flag = config.RootPropagation
@@ -611,6 +832,9 @@ func prepareRoot(config *configs.Config) error {
 		return err
 	}
 
+	if tree != nil {
+		return system.MoveMount(int(tree.Fd()), config.Rootfs)
+	}
 	return syscall.Mount(config.Rootfs, config.Rootfs, "bind", syscall.MS_BIND|syscall.MS_REC, "")
 }
 
@@ -618,6 +842,190 @@ func setReadonly() error {
 	return syscall.Mount("/", "/", "bind", syscall.MS_BIND|syscall.MS_REMOUNT|syscall.MS_RDONLY|syscall.MS_REC, "")
 }
 
+// rootStatfsFlags maps the statfs flags of a mount onto the mount flags that
+// a remount has to keep, as a user namespace may not clear them.
+var rootStatfsFlags = []struct {
+	statfs uint64
+	mount  int
+}{
+	{1, syscall.MS_RDONLY},
+	{2, syscall.MS_NOSUID},
+	{4, syscall.MS_NODEV},
+	{8, syscall.MS_NOEXEC},
+	{1024, syscall.MS_NOATIME},
+	{2048, syscall.MS_NODIRATIME},
+	{4096, syscall.MS_RELATIME},
+}
+
+// remountRoot adds the flags to the ones the rootfs is mounted with.
+func remountRoot(flags int) error {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs("/", &st); err != nil {
+		return err
+	}
+	for _, f := range rootStatfsFlags {
+		if uint64(st.Flags)&f.statfs != 0 {
+			flags |= f.mount
+		}
+	}
+	return syscall.Mount("/", "/", "bind", uintptr(flags|syscall.MS_BIND|syscall.MS_REMOUNT), "")
+}
+
+// setupFileCapabilities writes the security.capability xattr of the files
+// listed in the config, resolving their paths inside the rootfs. Files on a
+// bind mount are rejected, as the xattr would change the file of the host.
+func setupFileCapabilities(config *configs.Config) error {
+	for _, fc := range config.FileCapabilities {
+		path, err := symlink.FollowSymlinkInScope(filepath.Join(config.Rootfs, fc.Path), config.Rootfs)
+		if err != nil {
+			return err
+		}
+		bind, err := bindMountOf(config, path)
+		if err != nil {
+			return err
+		}
+		if bind != "" {
+			return fmt.Errorf("cannot set capabilities on %s because it is on the bind mount of %s", fc.Path, bind)
+		}
+		data, err := fileCapabilityData(fc.Capabilities)
+		if err != nil {
+			return err
+		}
+		if err := system.Lsetxattr(path, "security.capability", data, 0); err != nil {
+			return fmt.Errorf("set capabilities on %s: %v", fc.Path, err)
+		}
+	}
+	return nil
+}
+
+// bindMountOf returns the destination of the bind mount of the config that
+// path, resolved inside the rootfs, is on, or "" if it is on none.
+func bindMountOf(config *configs.Config, path string) (string, error) {
+	for _, m := range config.AllMounts() {
+		if m.Device != "bind" {
+			continue
+		}
+		dest, err := symlink.FollowSymlinkInScope(filepath.Join(config.Rootfs, m.Destination), config.Rootfs)
+		if err != nil {
+			return "", err
+		}
+		rel, err := filepath.Rel(dest, path)
+		if err != nil {
+			return "", err
+		}
+		if rel == "." || !strings.HasPrefix(rel, "..") {
+			return m.Destination, nil
+		}
+	}
+	return "", nil
+}
+
+// setupDevpts mounts a private devpts instance on /dev/pts unless the config
+// already mounts something there, so that the /dev/ptmx symlink created by
+// setupPtmx allocates ptys that are invisible to the host and other containers.
+func setupDevpts(config *configs.Config) error {
+	for _, m := range config.AllMounts() {
+		if libcontainerUtils.CleanPath(m.Destination) == "/dev/pts" {
+			return nil
+		}
+	}
+	dest := filepath.Join(config.Rootfs, "dev/pts")
+	if err := os.MkdirAll(dest, 0755); err != nil {
+		return err
+	}
+	data := label.FormatMountLabel(devptsOptions(config), config.MountLabel)
+	return syscall.Mount("devpts", dest, "devpts", syscall.MS_NOSUID|syscall.MS_NOEXEC, data)
+}
+
+// devptsOptions returns the options of the default /dev/pts mount, which
+// gives the ptys the tty group, gid 5, unless it is not mapped into the
+// user namespace of the container.
+func devptsOptions(config *configs.Config) string {
+	options := "newinstance,ptmxmode=0666,mode=0620"
+	if _, err := config.HostGID(5); err == nil {
+		options += ",gid=5"
+	}
+	return options
+}
+
+// shmMount returns the mount to use in place of m. A tmpfs mount on /dev/shm
+// gives the container a fresh /dev/shm, which is replaced by a bind mount of
+// the /dev/shm that belongs to the IPC namespace the container shares, so that
+// POSIX shared memory is shared along with the SysV IPC objects.
+func shmMount(config *configs.Config, m *configs.Mount) *configs.Mount {
+	if m.Device != "tmpfs" || libcontainerUtils.CleanPath(m.Destination) != "/dev/shm" {
+		return m
+	}
+	source := sharedShmSource(config.Namespaces)
+	if source == "" {
+		return m
+	}
+	return &configs.Mount{
+		Source:           source,
+		Destination:      m.Destination,
+		Device:           "bind",
+		Flags:            m.Flags | syscall.MS_BIND,
+		PropagationFlags: m.PropagationFlags,
+	}
+}
+
+// procMount returns the mount to use in place of m. A proc mount of a
+// container without a PID namespace of its own becomes a recursive bind
+// mount of the host's /proc if the container asks for it with HostPidProc.
+func procMount(config *configs.Config, m *configs.Mount) *configs.Mount {
+	if m.Device != "proc" || !bindsHostProc(config) {
+		return m
+	}
+	return &configs.Mount{
+		Source:           "/proc",
+		Destination:      m.Destination,
+		Device:           "proc",
+		Flags:            m.Flags | syscall.MS_BIND | syscall.MS_REC,
+		PropagationFlags: m.PropagationFlags,
+	}
+}
+
+func bindsHostProc(config *configs.Config) bool {
+	if config.Namespaces.Contains(configs.NEWPID) {
+		return false
+	}
+	return config.HostPidProc == configs.HostPidProcBind || config.HostPidProc == configs.HostPidProcMasked
+}
+
+// hostProcMaskPaths returns the paths that HostPidProcMasked masks below the
+// proc mounts of the container.
+func hostProcMaskPaths(config *configs.Config) []string {
+	if !bindsHostProc(config) || config.HostPidProc != configs.HostPidProcMasked {
+		return nil
+	}
+	var paths []string
+	for _, m := range config.AllMounts() {
+		if m.Device != "proc" {
+			continue
+		}
+		for _, name := range configs.HostProcMaskedFiles {
+			paths = append(paths, filepath.Join(m.Destination, name))
+		}
+	}
+	return paths
+}
+
+var ipcNsProcPath = regexp.MustCompile(`^/proc/(\d+)/ns/ipc$`)
+
+// sharedShmSource returns the /dev/shm of the IPC namespace the container
+// shares with the host or another process. It returns "" if the container has
+// a private IPC namespace or the namespace it joins does not name a process.
+func sharedShmSource(namespaces configs.Namespaces) string {
+	if !namespaces.Contains(configs.NEWIPC) {
+		return "/dev/shm"
+	}
+	match := ipcNsProcPath.FindStringSubmatch(namespaces.PathOf(configs.NEWIPC))
+	if match == nil {
+		return ""
+	}
+	return filepath.Join("/proc", match[1], "root/dev/shm")
+}
+
 func setupPtmx(config *configs.Config) error {
 	ptmx := filepath.Join(config.Rootfs, "dev/ptmx")
 	if err := os.Remove(ptmx); err != nil && !os.IsNotExist(err) {
@@ -776,12 +1184,61 @@ func remount(m *configs.Mount, rootfs string) error {
 	if !strings.HasPrefix(dest, rootfs) {
 		dest = filepath.Join(rootfs, dest)
 	}
-	if err := syscall.Mount(m.Source, dest, m.Device, uintptr(m.Flags|syscall.MS_REMOUNT), ""); err != nil {
-		return err
+	flags := m.Flags | syscall.MS_REMOUNT
+	if err := syscall.Mount(m.Source, dest, m.Device, uintptr(flags), ""); err != nil {
+		if err != syscall.EPERM || m.Flags&syscall.MS_BIND == 0 {
+			return err
+		}
+		// Inside a user namespace the kernel refuses to clear flags that are
+		// locked on the source mount, so keep them and try again.
+		var st syscall.Statfs_t
+		if serr := syscall.Statfs(m.Source, &st); serr != nil {
+			return err
+		}
+		flags |= lockedMountFlags(uint64(st.Flags), m.Flags)
+		return syscall.Mount(m.Source, dest, m.Device, uintptr(flags), "")
 	}
 	return nil
 }
 
+// lockedMountFlags converts the statfs(2) flags of a mount into the mount
+// flags a bind remount of it has to keep. The atime flags are only carried
+// over when the requested flags do not pick an atime behaviour themselves.
+func lockedMountFlags(stFlags uint64, requested int) int {
+	const (
+		stRdonly     = 0x1
+		stNosuid     = 0x2
+		stNodev      = 0x4
+		stNoexec     = 0x8
+		stNoatime    = 0x400
+		stNodiratime = 0x800
+		stRelatime   = 0x1000
+	)
+	var flags int
+	for st, ms := range map[uint64]int{
+		stRdonly: syscall.MS_RDONLY,
+		stNosuid: syscall.MS_NOSUID,
+		stNodev:  syscall.MS_NODEV,
+		stNoexec: syscall.MS_NOEXEC,
+	} {
+		if stFlags&st != 0 {
+			flags |= ms
+		}
+	}
+	if requested&(syscall.MS_NOATIME|syscall.MS_RELATIME|syscall.MS_STRICTATIME) == 0 {
+		for st, ms := range map[uint64]int{
+			stNoatime:    syscall.MS_NOATIME,
+			stNodiratime: syscall.MS_NODIRATIME,
+			stRelatime:   syscall.MS_RELATIME,
+		} {
+			if stFlags&st != 0 {
+				flags |= ms
+			}
+		}
+	}
+	return flags
+}
+
 // Do the mount operation followed by additional mounts required to take care
 // of propagation flags.
 func mountPropagate(m *configs.Mount, rootfs string, mountLabel string) error {
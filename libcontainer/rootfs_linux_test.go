@@ -3,8 +3,14 @@
 package libcontainer
 
 import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
 	"testing"
 
+	"github.com/docker/docker/pkg/symlink"
 	"github.com/opencontainers/runc/libcontainer/configs"
 )
 
@@ -91,3 +97,205 @@ func TestNeedsSetupDevStrangeSourceDest(t *testing.T) {
 		t.Fatal("expected needsSetupDev to be true, got false")
 	}
 }
+
+func TestLockedMountFlags(t *testing.T) {
+	// nosuid,nodev,relatime on the source
+	st := uint64(0x2 | 0x4 | 0x1000)
+	expected := syscall.MS_NOSUID | syscall.MS_NODEV | syscall.MS_RELATIME
+	if flags := lockedMountFlags(st, syscall.MS_BIND|syscall.MS_NOEXEC); flags != expected {
+		t.Fatalf("expected locked flags %#x but received %#x", expected, flags)
+	}
+	// an explicit atime choice is left alone
+	expected = syscall.MS_NOSUID | syscall.MS_NODEV
+	if flags := lockedMountFlags(st, syscall.MS_BIND|syscall.MS_NOATIME); flags != expected {
+		t.Fatalf("expected locked flags %#x but received %#x", expected, flags)
+	}
+}
+
+func TestParseFilesystems(t *testing.T) {
+	const filesystems = "nodev\tsysfs\nnodev\ttmpfs\n\text4\nnodev\tfuse\n\n\tsquashfs\n"
+	types, err := parseFilesystems(strings.NewReader(filesystems))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, fstype := range []string{"sysfs", "tmpfs", "ext4", "fuse", "squashfs"} {
+		if !types[fstype] {
+			t.Errorf("expected %q to be registered", fstype)
+		}
+	}
+	if types["nodev"] || types["ceph"] {
+		t.Errorf("unexpected filesystem types in %v", types)
+	}
+}
+
+func TestHasFilesystemModule(t *testing.T) {
+	const aliases = "alias fs-ceph ceph\nalias net-pf-10 ipv6\nalias fs-nfs4 nfsv4\n"
+	for fstype, expected := range map[string]bool{
+		"ceph": true,
+		"nfs4": true,
+		"ipv6": false,
+		"nfs":  false,
+	} {
+		ok, err := hasFilesystemModule(strings.NewReader(aliases), fstype)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ok != expected {
+			t.Errorf("expected module for %q to be %v, got %v", fstype, expected, ok)
+		}
+	}
+}
+
+func TestCheckFilesystemAvailable(t *testing.T) {
+	if err := checkFilesystemAvailable("proc"); err != nil {
+		t.Fatal(err)
+	}
+	if err := checkFilesystemAvailable("runc-no-such-fs"); err == nil {
+		t.Fatal("expected an unknown filesystem type to be rejected")
+	}
+}
+
+func TestShmMount(t *testing.T) {
+	shm := &configs.Mount{
+		Source:      "shm",
+		Destination: "/dev/shm",
+		Device:      "tmpfs",
+		Data:        "mode=1777,size=65536k",
+		Flags:       syscall.MS_NOSUID | syscall.MS_NODEV | syscall.MS_NOEXEC,
+	}
+	for _, test := range []struct {
+		namespaces configs.Namespaces
+		source     string
+	}{
+		{configs.Namespaces{{Type: configs.NEWIPC}}, ""},
+		{configs.Namespaces{}, "/dev/shm"},
+		{configs.Namespaces{{Type: configs.NEWIPC, Path: "/proc/1234/ns/ipc"}}, "/proc/1234/root/dev/shm"},
+		{configs.Namespaces{{Type: configs.NEWIPC, Path: "/var/run/ipcns/shared"}}, ""},
+	} {
+		m := shmMount(&configs.Config{Namespaces: test.namespaces}, shm)
+		if test.source == "" {
+			if m != shm {
+				t.Errorf("expected a private ipc namespace %v to keep the tmpfs, got %+v", test.namespaces, m)
+			}
+			continue
+		}
+		if m.Device != "bind" || m.Source != test.source || m.Flags != shm.Flags|syscall.MS_BIND {
+			t.Errorf("expected ipc namespace %v to bind mount %s, got %+v", test.namespaces, test.source, m)
+		}
+	}
+}
+
+func TestBindMountOf(t *testing.T) {
+	rootfs, err := ioutil.TempDir("", "rootfs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(rootfs)
+	if err := os.MkdirAll(filepath.Join(rootfs, "usr/bin"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	// /bin points into the bind mount.
+	if err := os.Symlink("usr/bin", filepath.Join(rootfs, "bin")); err != nil {
+		t.Fatal(err)
+	}
+	config := &configs.Config{
+		Rootfs: rootfs,
+		Mounts: []*configs.Mount{
+			{Source: "proc", Destination: "/proc", Device: "proc"},
+			{Source: "/usr/bin", Destination: "/usr/bin", Device: "bind", Flags: syscall.MS_BIND},
+		},
+	}
+	for path, expected := range map[string]string{
+		"/usr/bin":       "/usr/bin",
+		"/usr/bin/ping":  "/usr/bin",
+		"/bin/ping":      "/usr/bin",
+		"/usr/binary":    "",
+		"/usr/sbin/ping": "",
+		"/proc/1/exe":    "",
+	} {
+		resolved, err := symlink.FollowSymlinkInScope(filepath.Join(rootfs, path), rootfs)
+		if err != nil {
+			t.Fatal(err)
+		}
+		bind, err := bindMountOf(config, resolved)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if bind != expected {
+			t.Errorf("expected %s to be on the bind mount %q, got %q", path, expected, bind)
+		}
+	}
+}
+
+func TestDevptsOptions(t *testing.T) {
+	userns := configs.Namespaces{{Type: configs.NEWUSER}}
+	for _, test := range []struct {
+		config *configs.Config
+		gid    bool
+	}{
+		{&configs.Config{}, true},
+		{&configs.Config{Namespaces: userns, GidMappings: []configs.IDMap{{ContainerID: 0, HostID: 100000, Size: 65536}}}, true},
+		{&configs.Config{Namespaces: userns, GidMappings: []configs.IDMap{{ContainerID: 0, HostID: 1000, Size: 1}}}, false},
+	} {
+		options := devptsOptions(test.config)
+		if strings.Contains(options, "gid=5") != test.gid {
+			t.Errorf("expected gid=5 to be %v for gid mappings %v, got %q", test.gid, test.config.GidMappings, options)
+		}
+	}
+}
+
+func TestProcMount(t *testing.T) {
+	proc := &configs.Mount{
+		Source:      "proc",
+		Destination: "/proc",
+		Device:      "proc",
+		Flags:       syscall.MS_NOSUID | syscall.MS_NODEV | syscall.MS_NOEXEC,
+	}
+	pidns := configs.Namespaces{{Type: configs.NEWPID}}
+	for _, test := range []struct {
+		namespaces configs.Namespaces
+		mode       configs.HostPidProc
+		bind       bool
+		masked     bool
+	}{
+		{pidns, "", false, false},
+		{pidns, configs.HostPidProcMasked, false, false},
+		{configs.Namespaces{}, "", false, false},
+		{configs.Namespaces{}, configs.HostPidProcMount, false, false},
+		{configs.Namespaces{}, configs.HostPidProcBind, true, false},
+		{configs.Namespaces{}, configs.HostPidProcMasked, true, true},
+	} {
+		config := &configs.Config{
+			Namespaces:  test.namespaces,
+			HostPidProc: test.mode,
+			Mounts:      []*configs.Mount{proc},
+		}
+		m := procMount(config, proc)
+		if !test.bind && m != proc {
+			t.Errorf("expected namespaces %v with mode %q to mount a new proc, got %+v", test.namespaces, test.mode, m)
+		}
+		if test.bind && (m.Source != "/proc" || m.Flags != proc.Flags|syscall.MS_BIND|syscall.MS_REC) {
+			t.Errorf("expected namespaces %v with mode %q to bind the host's /proc, got %+v", test.namespaces, test.mode, m)
+		}
+		paths := hostProcMaskPaths(config)
+		if test.masked != (len(paths) == len(configs.HostProcMaskedFiles)) || (!test.masked && len(paths) != 0) {
+			t.Errorf("expected namespaces %v with mode %q to mask %v, got %v", test.namespaces, test.mode, test.masked, paths)
+		}
+		if test.masked && paths[0] != "/proc/kcore" {
+			t.Errorf("expected the masked paths to be below /proc, got %v", paths)
+		}
+	}
+}
+
+func TestHasSubsystem(t *testing.T) {
+	for hierarchy, expected := range map[string]bool{
+		"memory":      true,
+		"cpu,cpuacct": true,
+		"cpuset":      false,
+		"systemd":     false,
+	} {
+		if ok := hasSubsystem(hierarchy, []string{"memory", "cpuacct"}); ok != expected {
+			t.Errorf("expected hierarchy %q to match %v, got %v", hierarchy, expected, ok)
+		}
+	}
+}
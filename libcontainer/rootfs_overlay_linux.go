@@ -0,0 +1,102 @@
+// +build linux
+
+package libcontainer
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"syscall"
+
+	"github.com/opencontainers/runc/libcontainer/configs"
+	"github.com/opencontainers/selinux/go-selinux/label"
+)
+
+// overlayDir is the directory in the container's state directory that holds
+// the overlay directories libcontainer creates.
+const overlayDir = "overlay"
+
+// rootfsOverlay are the directories of the overlay over the rootfs.
+type rootfsOverlay struct {
+	UpperDir string `json:"upper_dir"`
+	WorkDir  string `json:"work_dir"`
+	// Owned is set if libcontainer creates the directories, and so removes
+	// them again on Destroy.
+	Owned bool `json:"owned"`
+	// Tmpfs is set if the directories are on a tmpfs that the init mounts
+	// over their parent directory.
+	Tmpfs bool `json:"tmpfs,omitempty"`
+}
+
+// newRootfsOverlay returns the directories of the overlay the config asks
+// for, in the state directory root unless the config names them. It returns
+// nil without a rootfs overlay.
+func newRootfsOverlay(config *configs.Config, root string) *rootfsOverlay {
+	overlay := config.RootfsOverlay
+	if overlay == nil {
+		return nil
+	}
+	if overlay.UpperDir != "" {
+		return &rootfsOverlay{UpperDir: overlay.UpperDir, WorkDir: overlay.WorkDir}
+	}
+	return &rootfsOverlay{
+		UpperDir: filepath.Join(root, overlayDir, "upper"),
+		WorkDir:  filepath.Join(root, overlayDir, "work"),
+		Owned:    true,
+		Tmpfs:    overlay.Tmpfs,
+	}
+}
+
+// create creates the directories libcontainer owns, for the root user of the
+// container. For a tmpfs only the mount point is created.
+func (o *rootfsOverlay) create(config *configs.Config) error {
+	if !o.Owned {
+		return nil
+	}
+	if o.Tmpfs {
+		return os.MkdirAll(filepath.Dir(o.UpperDir), 0700)
+	}
+	uid, err := config.HostRootUID()
+	if err != nil {
+		return err
+	}
+	gid, err := config.HostRootGID()
+	if err != nil {
+		return err
+	}
+	for _, dir := range []string{o.UpperDir, o.WorkDir} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+		if err := os.Chown(dir, uid, gid); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// mount mounts the overlay over the rootfs, which becomes its lower layer.
+func (o *rootfsOverlay) mount(rootfs, mountLabel string) error {
+	if o.Tmpfs {
+		if err := syscall.Mount("tmpfs", filepath.Dir(o.UpperDir), "tmpfs", 0, label.FormatMountLabel("mode=700", mountLabel)); err != nil {
+			return err
+		}
+		for _, dir := range []string{o.UpperDir, o.WorkDir} {
+			if err := os.Mkdir(dir, 0755); err != nil {
+				return err
+			}
+		}
+	}
+	data := fmt.Sprintf("lowerdir=%s,upperdir=%s,workdir=%s", rootfs, o.UpperDir, o.WorkDir)
+	return syscall.Mount("overlay", rootfs, "overlay", 0, label.FormatMountLabel(data, mountLabel))
+}
+
+// remove removes the directories libcontainer created and leaves the ones
+// the config named alone. The overlay and its tmpfs are only mounted in the
+// container's mount namespace, so there is nothing to unmount on the host.
+func (o *rootfsOverlay) remove() error {
+	if o == nil || !o.Owned {
+		return nil
+	}
+	return os.RemoveAll(filepath.Dir(o.UpperDir))
+}
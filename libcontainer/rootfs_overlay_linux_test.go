@@ -0,0 +1,113 @@
+// +build linux
+
+package libcontainer
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/opencontainers/runc/libcontainer/configs"
+)
+
+func TestRootfsOverlayOwnedDirs(t *testing.T) {
+	root, err := ioutil.TempDir("", "rootfs-overlay")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	config := &configs.Config{RootfsOverlay: &configs.Overlay{}}
+	overlay := newRootfsOverlay(config, root)
+	if !overlay.Owned {
+		t.Fatal("expected the overlay directories to be owned without directories in the config")
+	}
+	if err := overlay.create(config); err != nil {
+		t.Fatal(err)
+	}
+	for _, dir := range []string{overlay.UpperDir, overlay.WorkDir} {
+		if fi, err := os.Stat(dir); err != nil || !fi.IsDir() {
+			t.Fatalf("expected %s to be created: %v", dir, err)
+		}
+		if filepath.Dir(dir) != filepath.Join(root, overlayDir) {
+			t.Fatalf("expected %s in the state directory %s", dir, root)
+		}
+	}
+	if err := ioutil.WriteFile(filepath.Join(overlay.UpperDir, "file"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := overlay.remove(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(root, overlayDir)); !os.IsNotExist(err) {
+		t.Fatalf("expected the overlay directories to be removed, got %v", err)
+	}
+}
+
+func TestRootfsOverlayExternalDirs(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rootfs-overlay")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	upper, work := filepath.Join(dir, "upper"), filepath.Join(dir, "work")
+	config := &configs.Config{RootfsOverlay: &configs.Overlay{UpperDir: upper, WorkDir: work}}
+	overlay := newRootfsOverlay(config, filepath.Join(dir, "state"))
+	if overlay.Owned || overlay.UpperDir != upper || overlay.WorkDir != work {
+		t.Fatalf("expected the external directories %s and %s, got %+v", upper, work, overlay)
+	}
+	if err := overlay.create(config); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(upper); !os.IsNotExist(err) {
+		t.Fatalf("expected external directories not to be created, got %v", err)
+	}
+	for _, d := range []string{upper, work} {
+		if err := os.Mkdir(d, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := overlay.remove(); err != nil {
+		t.Fatal(err)
+	}
+	for _, d := range []string{upper, work} {
+		if _, err := os.Stat(d); err != nil {
+			t.Fatalf("expected external directory %s to be preserved: %v", d, err)
+		}
+	}
+
+	if overlay := newRootfsOverlay(&configs.Config{}, dir); overlay != nil {
+		t.Fatalf("expected no overlay without one in the config, got %+v", overlay)
+	}
+}
+
+func TestRootfsOverlayTmpfs(t *testing.T) {
+	root, err := ioutil.TempDir("", "rootfs-overlay")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	config := &configs.Config{RootfsOverlay: &configs.Overlay{Tmpfs: true}}
+	overlay := newRootfsOverlay(config, root)
+	if !overlay.Owned || !overlay.Tmpfs {
+		t.Fatalf("expected owned directories on a tmpfs, got %+v", overlay)
+	}
+	if err := overlay.create(config); err != nil {
+		t.Fatal(err)
+	}
+	// The directories are created on the tmpfs by the init.
+	if fi, err := os.Stat(filepath.Dir(overlay.UpperDir)); err != nil || !fi.IsDir() {
+		t.Fatalf("expected the tmpfs mount point to be created: %v", err)
+	}
+	for _, dir := range []string{overlay.UpperDir, overlay.WorkDir} {
+		if _, err := os.Stat(dir); !os.IsNotExist(err) {
+			t.Fatalf("expected %s not to be created on the host, got %v", dir, err)
+		}
+	}
+	if err := overlay.remove(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(root, overlayDir)); !os.IsNotExist(err) {
+		t.Fatalf("expected the tmpfs mount point to be removed, got %v", err)
+	}
+}
@@ -0,0 +1,215 @@
+// +build linux
+
+package libcontainer
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"syscall"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/opencontainers/runc/libcontainer/configs"
+	"github.com/opencontainers/runc/libcontainer/system"
+	"github.com/opencontainers/runc/libcontainer/utils"
+	"golang.org/x/sys/unix"
+)
+
+// The names of the files that the parent sends for procIdmapRootfs and
+// procIdmapMounts tell the init whether to mount them.
+const (
+	rootfsIdmapped = "rootfs-idmapped"
+	rootfsChowned  = "rootfs-chowned"
+	mountIdmapped  = "mount-idmapped"
+	mountPlain     = "mount-plain"
+)
+
+// setupRootfsOwnership changes the owner of the rootfs as the config asks
+// for. Idmapped rootfs mounts are set up once the init asks for them.
+func setupRootfsOwnership(config *configs.Config) error {
+	switch config.RootfsOwnership {
+	case configs.RootfsChown:
+		return chownRootfs(config, false)
+	case configs.RootfsChownRecursive:
+		return chownRootfs(config, true)
+	}
+	return nil
+}
+
+// chownRootfs gives the rootfs directory to the root user of the container,
+// or shifts the owners of all the files in the rootfs if recursive is set.
+func chownRootfs(config *configs.Config, recursive bool) error {
+	if !recursive {
+		uid, err := config.HostRootUID()
+		if err != nil {
+			return err
+		}
+		gid, err := config.HostRootGID()
+		if err != nil {
+			return err
+		}
+		return os.Lchown(config.Rootfs, uid, gid)
+	}
+	return filepath.Walk(config.Rootfs, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		st, ok := info.Sys().(*syscall.Stat_t)
+		if !ok {
+			return nil
+		}
+		uid, uidOk := shiftID(config.UidMappings, int(st.Uid))
+		gid, gidOk := shiftID(config.GidMappings, int(st.Gid))
+		if !uidOk && !gidOk {
+			return nil
+		}
+		return os.Lchown(path, uid, gid)
+	})
+}
+
+// shiftID returns the host id that id maps to. It returns false if id is a
+// host id of the mappings already or has no mapping.
+func shiftID(mappings []configs.IDMap, id int) (int, bool) {
+	for _, m := range mappings {
+		if id >= m.HostID && id < m.HostID+m.Size {
+			return id, false
+		}
+	}
+	for _, m := range mappings {
+		if id >= m.ContainerID && id < m.ContainerID+m.Size {
+			return m.HostID + id - m.ContainerID, true
+		}
+	}
+	return id, false
+}
+
+// idmappedTree returns a detached mount of path, named name, that maps ids
+// like the user namespace of the process pid. The mounts below path are
+// included with system.AT_RECURSIVE in flags.
+func idmappedTree(name, path string, pid, flags int) (*os.File, error) {
+	userns, err := os.Open(fmt.Sprintf("/proc/%d/ns/user", pid))
+	if err != nil {
+		return nil, err
+	}
+	defer userns.Close()
+	fd, err := system.OpenTreeClone(path, flags)
+	if err != nil {
+		return nil, err
+	}
+	tree := os.NewFile(uintptr(fd), name)
+	if err := system.MountSetattrIdmap(fd, int(userns.Fd()), flags); err != nil {
+		tree.Close()
+		return nil, err
+	}
+	return tree, nil
+}
+
+// sendRootfsTree sends the init an idmapped mount of the rootfs. Without
+// idmapped mounts it shifts the owners of the files in the rootfs and sends
+// the rootfs directory, which the init does not mount.
+func (p *initProcess) sendRootfsTree() error {
+	tree, err := idmappedTree(rootfsIdmapped, p.config.Config.Rootfs, p.pid(), 0)
+	if err != nil {
+		logrus.Debugf("idmapped rootfs unavailable, shifting the owners of its files: %v", err)
+		if err := chownRootfs(p.config.Config, true); err != nil {
+			return newSystemErrorWithCause(err, "shifting rootfs ownership")
+		}
+		fd, err := syscall.Open(p.config.Config.Rootfs, syscall.O_RDONLY|syscall.O_DIRECTORY|syscall.O_CLOEXEC, 0)
+		if err != nil {
+			return err
+		}
+		dir := os.NewFile(uintptr(fd), rootfsChowned)
+		defer dir.Close()
+		return utils.SendFd(p.parentPipe, dir)
+	}
+	defer tree.Close()
+	return utils.SendFd(p.parentPipe, tree)
+}
+
+// recvRootfsTree asks the parent for an idmapped mount of the rootfs. It
+// returns nil if the parent shifted the owners of the files instead.
+func recvRootfsTree(pipe *os.File) (*os.File, error) {
+	if err := writeSync(pipe, procIdmapRootfs); err != nil {
+		return nil, err
+	}
+	f, err := utils.RecvFd(pipe)
+	if err != nil {
+		return nil, err
+	}
+	if f.Name() != rootfsIdmapped {
+		f.Close()
+		return nil, nil
+	}
+	return f, nil
+}
+
+// idmapMounts returns the mounts of the config that ask to be idmapped.
+func idmapMounts(config *configs.Config) []*configs.Mount {
+	var mounts []*configs.Mount
+	for _, m := range config.AllMounts() {
+		if m.Device == "bind" && m.Extensions&configs.EXT_IDMAP != 0 {
+			mounts = append(mounts, m)
+		}
+	}
+	return mounts
+}
+
+// sendMountTrees sends the init an idmapped mount of the source of every
+// mount that asks for one. Without idmapped mounts it sends the source
+// instead, which the init bind mounts as usual.
+func (p *initProcess) sendMountTrees() error {
+	for _, m := range idmapMounts(p.config.Config) {
+		flags := 0
+		if m.Flags&syscall.MS_REC != 0 {
+			flags = system.AT_RECURSIVE
+		}
+		tree, err := idmappedTree(mountIdmapped, m.Source, p.pid(), flags)
+		if err != nil {
+			logrus.Warnf("idmapped mount of %s unavailable, bind mounting it as is: %v", m.Source, err)
+			fd, err := unix.Open(m.Source, unix.O_PATH|unix.O_CLOEXEC, 0)
+			if err != nil {
+				return err
+			}
+			tree = os.NewFile(uintptr(fd), mountPlain)
+		}
+		err = utils.SendFd(p.parentPipe, tree)
+		tree.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// recvMountTrees asks the parent for idmapped mounts of the sources of the
+// mounts that ask for them. The mounts that the parent could not idmap are
+// left out.
+func recvMountTrees(pipe *os.File, config *configs.Config) (map[*configs.Mount]*os.File, error) {
+	mounts := idmapMounts(config)
+	if len(mounts) == 0 {
+		return nil, nil
+	}
+	if err := writeSync(pipe, procIdmapMounts); err != nil {
+		return nil, err
+	}
+	trees := make(map[*configs.Mount]*os.File, len(mounts))
+	for _, m := range mounts {
+		f, err := utils.RecvFd(pipe)
+		if err != nil {
+			closeMountTrees(trees)
+			return nil, err
+		}
+		if f.Name() != mountIdmapped {
+			f.Close()
+			continue
+		}
+		trees[m] = f
+	}
+	return trees, nil
+}
+
+func closeMountTrees(trees map[*configs.Mount]*os.File) {
+	for _, f := range trees {
+		f.Close()
+	}
+}
@@ -0,0 +1,140 @@
+// +build linux
+
+package libcontainer
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"syscall"
+	"testing"
+
+	"github.com/opencontainers/runc/libcontainer/configs"
+	"github.com/opencontainers/runc/libcontainer/system"
+)
+
+var testRootfsMappings = []configs.IDMap{
+	{ContainerID: 0, HostID: 100000, Size: 65536},
+}
+
+func TestShiftID(t *testing.T) {
+	for _, test := range []struct {
+		id      int
+		want    int
+		shifted bool
+	}{
+		{0, 100000, true},
+		{1000, 101000, true},
+		{65535, 165535, true},
+		{100000, 100000, false},
+		{165535, 165535, false},
+		{200000, 200000, false},
+	} {
+		got, shifted := shiftID(testRootfsMappings, test.id)
+		if got != test.want || shifted != test.shifted {
+			t.Errorf("shiftID(%d) = %d, %v; want %d, %v", test.id, got, shifted, test.want, test.shifted)
+		}
+	}
+}
+
+func TestChownRootfsRecursive(t *testing.T) {
+	if os.Getuid() != 0 {
+		t.Skip("changing owners requires root")
+	}
+	rootfs, err := ioutil.TempDir("", "rootfs-ownership")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(rootfs)
+	file := filepath.Join(rootfs, "home", "user", "file")
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(file, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chown(file, 1000, 1000); err != nil {
+		t.Fatal(err)
+	}
+	config := &configs.Config{
+		Rootfs:      rootfs,
+		UidMappings: testRootfsMappings,
+		GidMappings: testRootfsMappings,
+	}
+	// A second run must not shift the ids again.
+	for i := 0; i < 2; i++ {
+		if err := chownRootfs(config, true); err != nil {
+			t.Fatal(err)
+		}
+		checkOwner(t, rootfs, 100000)
+		checkOwner(t, filepath.Join(rootfs, "home"), 100000)
+		checkOwner(t, file, 101000)
+	}
+}
+
+func TestIdmappedTree(t *testing.T) {
+	if os.Getuid() != 0 {
+		t.Skip("idmapped mounts require root")
+	}
+	if _, err := os.Stat("/proc/self/ns/user"); os.IsNotExist(err) {
+		t.Skip("userns is unsupported")
+	}
+	dir, err := ioutil.TempDir("", "idmapped-tree")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	source, target := filepath.Join(dir, "source"), filepath.Join(dir, "target")
+	for _, d := range []string{source, target} {
+		if err := os.Mkdir(d, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := ioutil.WriteFile(filepath.Join(source, "file"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cmd := exec.Command("sleep", "60")
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		Cloneflags: syscall.CLONE_NEWUSER,
+		UidMappings: []syscall.SysProcIDMap{
+			{ContainerID: 0, HostID: 100000, Size: 65536},
+		},
+		GidMappings: []syscall.SysProcIDMap{
+			{ContainerID: 0, HostID: 100000, Size: 65536},
+		},
+	}
+	if err := cmd.Start(); err != nil {
+		t.Skipf("cannot start a process in a user namespace: %v", err)
+	}
+	defer func() {
+		cmd.Process.Kill()
+		cmd.Wait()
+	}()
+
+	tree, err := idmappedTree(rootfsIdmapped, source, cmd.Process.Pid, 0)
+	if err != nil {
+		if err == syscall.ENOSYS || err == syscall.EINVAL || err == syscall.EPERM {
+			t.Skipf("idmapped mounts are unsupported: %v", err)
+		}
+		t.Fatal(err)
+	}
+	defer tree.Close()
+	if err := system.MoveMount(int(tree.Fd()), target); err != nil {
+		t.Fatal(err)
+	}
+	defer syscall.Unmount(target, syscall.MNT_DETACH)
+	checkOwner(t, filepath.Join(target, "file"), 100000)
+	checkOwner(t, filepath.Join(source, "file"), 0)
+}
+
+func checkOwner(t *testing.T, path string, id int) {
+	var st syscall.Stat_t
+	if err := syscall.Lstat(path, &st); err != nil {
+		t.Fatal(err)
+	}
+	if int(st.Uid) != id || int(st.Gid) != id {
+		t.Errorf("expected %s to be owned by %d:%d, got %d:%d", path, id, id, st.Uid, st.Gid)
+	}
+}
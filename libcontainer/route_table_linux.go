@@ -0,0 +1,221 @@
+// +build linux
+
+package libcontainer
+
+import (
+	"fmt"
+	"net"
+	"syscall"
+
+	"github.com/opencontainers/runc/libcontainer/configs"
+	"github.com/vishvananda/netlink"
+	"github.com/vishvananda/netlink/nl"
+)
+
+// The vendored netlink package only knows of the main routing table and has
+// no rules, so the messages for other tables are built here. A rule message,
+// struct fib_rule_hdr, has the layout of struct rtmsg with the action in
+// place of the type.
+const (
+	fraSrc     = 2  // FRA_SRC
+	fraTable   = 15 // FRA_TABLE
+	frActToTbl = 1  // FR_ACT_TO_TBL
+)
+
+// rawMessage is a message dumped by the kernel, sent back as it is.
+type rawMessage []byte
+
+func (m rawMessage) Len() int { return len(m) }
+
+func (m rawMessage) Serialize() []byte { return m }
+
+func uint32Attr(attrType int, v int) *nl.RtAttr {
+	b := make([]byte, 4)
+	nl.NativeEndian().PutUint32(b, uint32(v))
+	return nl.NewRtAttr(attrType, b)
+}
+
+func ipData(ip net.IP) []byte {
+	if v4 := ip.To4(); v4 != nil {
+		return v4
+	}
+	return ip.To16()
+}
+
+// addTableRoute adds route to the routing table.
+func addTableRoute(route *netlink.Route, table int) error {
+	req := nl.NewNetlinkRequest(syscall.RTM_NEWROUTE, syscall.NLM_F_CREATE|syscall.NLM_F_EXCL|syscall.NLM_F_ACK)
+	msg := nl.NewRtMsg()
+	msg.Table = syscall.RT_TABLE_UNSPEC
+	msg.Scope = uint8(route.Scope)
+	var attrs []*nl.RtAttr
+	if route.Dst != nil && route.Dst.IP != nil {
+		ones, _ := route.Dst.Mask.Size()
+		msg.Dst_len = uint8(ones)
+		msg.Family = uint8(nl.GetIPFamily(route.Dst.IP))
+		attrs = append(attrs, nl.NewRtAttr(syscall.RTA_DST, ipData(route.Dst.IP)))
+	}
+	if route.Src != nil {
+		msg.Family = uint8(nl.GetIPFamily(route.Src))
+		attrs = append(attrs, nl.NewRtAttr(syscall.RTA_PREFSRC, ipData(route.Src)))
+	}
+	if route.Gw != nil {
+		msg.Family = uint8(nl.GetIPFamily(route.Gw))
+		attrs = append(attrs, nl.NewRtAttr(syscall.RTA_GATEWAY, ipData(route.Gw)))
+	}
+	req.AddData(msg)
+	for _, attr := range attrs {
+		req.AddData(attr)
+	}
+	req.AddData(uint32Attr(syscall.RTA_OIF, route.LinkIndex))
+	req.AddData(uint32Attr(syscall.RTA_TABLE, table))
+	_, err := req.Execute(syscall.NETLINK_ROUTE, 0)
+	return err
+}
+
+// tableRule adds or deletes, as request says, the rule that looks up the
+// traffic from src in the routing table.
+func tableRule(request, flags int, src *net.IPNet, table int) error {
+	req := nl.NewNetlinkRequest(request, flags)
+	msg := &nl.RtMsg{}
+	ones, _ := src.Mask.Size()
+	msg.Family = uint8(nl.GetIPFamily(src.IP))
+	msg.Src_len = uint8(ones)
+	msg.Table = syscall.RT_TABLE_UNSPEC
+	msg.Type = frActToTbl
+	req.AddData(msg)
+	req.AddData(nl.NewRtAttr(fraSrc, ipData(src.IP)))
+	req.AddData(uint32Attr(fraTable, table))
+	_, err := req.Execute(syscall.NETLINK_ROUTE, 0)
+	return err
+}
+
+// addRouteTableRules directs the traffic from the addresses of the network to
+// its routing table.
+func addRouteTableRules(n *configs.Network) error {
+	return routeTableRules(n, func(src *net.IPNet) error {
+		if err := tableRule(syscall.RTM_NEWRULE, syscall.NLM_F_CREATE|syscall.NLM_F_EXCL|syscall.NLM_F_ACK, src, n.RouteTable); err != nil {
+			return fmt.Errorf("adding rule for %s to route table %d: %v", src.IP, n.RouteTable, err)
+		}
+		return nil
+	})
+}
+
+// delRouteTableRules deletes the rules added by addRouteTableRules, leaving
+// the rules of other networks using the same table.
+func delRouteTableRules(n *configs.Network) error {
+	return routeTableRules(n, func(src *net.IPNet) error {
+		if err := tableRule(syscall.RTM_DELRULE, syscall.NLM_F_ACK, src, n.RouteTable); err != nil && err != syscall.ENOENT {
+			return err
+		}
+		return nil
+	})
+}
+
+// routeTableRules calls fn with the host prefix of each address of the
+// network that has a routing table.
+func routeTableRules(n *configs.Network, fn func(*net.IPNet) error) error {
+	if n.RouteTable == 0 {
+		return nil
+	}
+	for _, addr := range []string{n.Address, n.IPv6Address} {
+		if addr == "" {
+			continue
+		}
+		ip, _, err := net.ParseCIDR(addr)
+		if err != nil {
+			return err
+		}
+		bits := 128
+		if ip.To4() != nil {
+			bits = 32
+		}
+		if err := fn(&net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// tableMessages dumps the routes or the rules, as dumped by request, of both
+// families that belong to the routing table.
+func tableMessages(request, result uint16, table int) ([][]byte, error) {
+	var msgs [][]byte
+	for _, family := range []int{netlink.FAMILY_V4, netlink.FAMILY_V6} {
+		req := nl.NewNetlinkRequest(int(request), syscall.NLM_F_DUMP)
+		msg := &nl.RtMsg{}
+		msg.Family = uint8(family)
+		req.AddData(msg)
+		dump, err := req.Execute(syscall.NETLINK_ROUTE, result)
+		if err != nil {
+			if err == syscall.EAFNOSUPPORT {
+				continue
+			}
+			return nil, err
+		}
+		for _, m := range dump {
+			id := int(nl.DeserializeRtMsg(m).Table)
+			attrs, err := nl.ParseRouteAttr(m[syscall.SizeofRtMsg:])
+			if err != nil {
+				return nil, err
+			}
+			for _, attr := range attrs {
+				// The table attribute holds the ids that do not fit into
+				// the header.
+				if attr.Attr.Type == syscall.RTA_TABLE {
+					id = int(nl.NativeEndian().Uint32(attr.Value))
+				}
+			}
+			if id == table {
+				msgs = append(msgs, m)
+			}
+		}
+	}
+	return msgs, nil
+}
+
+// removeRouteTable deletes the rules that lead to the routing table and
+// flushes its routes.
+func removeRouteTable(table int) error {
+	for _, t := range []struct {
+		dump, result, del uint16
+	}{
+		{syscall.RTM_GETRULE, syscall.RTM_NEWRULE, syscall.RTM_DELRULE},
+		{syscall.RTM_GETROUTE, syscall.RTM_NEWROUTE, syscall.RTM_DELROUTE},
+	} {
+		msgs, err := tableMessages(t.dump, t.result, table)
+		if err != nil {
+			return err
+		}
+		for _, m := range msgs {
+			req := nl.NewNetlinkRequest(int(t.del), syscall.NLM_F_ACK)
+			req.AddData(rawMessage(m))
+			if _, err := req.Execute(syscall.NETLINK_ROUTE, 0); err != nil && err != syscall.ESRCH && err != syscall.ENOENT {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// removeRouteTables removes the routing tables of the networks from the
+// network namespace at path.
+func removeRouteTables(path string, networks []*configs.Network) error {
+	tables := make(map[int]bool)
+	for _, n := range networks {
+		if n.RouteTable != 0 {
+			tables[n.RouteTable] = true
+		}
+	}
+	if len(tables) == 0 {
+		return nil
+	}
+	return inNetnsPath(path, func() error {
+		for table := range tables {
+			if err := removeRouteTable(table); err != nil {
+				return fmt.Errorf("removing route table %d: %v", table, err)
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,155 @@
+// +build linux
+
+package libcontainer
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"syscall"
+	"testing"
+
+	"github.com/opencontainers/runc/libcontainer/configs"
+	"github.com/vishvananda/netlink"
+)
+
+func countTableMessages(t *testing.T, pid int, table int) (routes, rules int) {
+	if err := inNetns(pid, func() error {
+		r, err := tableMessages(syscall.RTM_GETROUTE, syscall.RTM_NEWROUTE, table)
+		if err != nil {
+			return err
+		}
+		l, err := tableMessages(syscall.RTM_GETRULE, syscall.RTM_NEWRULE, table)
+		if err != nil {
+			return err
+		}
+		routes, rules = len(r), len(l)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	return routes, rules
+}
+
+func TestRouteTable(t *testing.T) {
+	if os.Getuid() != 0 {
+		t.Skip("creating interfaces requires root")
+	}
+	cmd := exec.Command("sleep", "60")
+	cmd.SysProcAttr = &syscall.SysProcAttr{Cloneflags: syscall.CLONE_NEWNET}
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer cmd.Wait()
+	defer cmd.Process.Kill()
+	pid := cmd.Process.Pid
+
+	n := &configs.Network{
+		Type:       "veth",
+		Name:       "rctestrt0",
+		Address:    "10.236.0.2/24",
+		Gateway:    "10.236.0.1",
+		Mtu:        1500,
+		RouteTable: 100,
+	}
+	config := &configs.Config{
+		Namespaces: configs.Namespaces{{Type: configs.NEWNET, Path: fmt.Sprintf("/proc/%d/ns/net", pid)}},
+		Networks:   []*configs.Network{n},
+		Routes: []*configs.Route{
+			{Destination: "10.237.0.0/16", Source: "10.236.0.2", Gateway: "10.236.0.1", InterfaceName: n.Name},
+		},
+	}
+	if err := inNetns(pid, func() error {
+		pair := &netlink.Veth{LinkAttrs: netlink.LinkAttrs{Name: n.Name}, PeerName: "rctestrt1"}
+		if err := netlink.LinkAdd(pair); err != nil {
+			return err
+		}
+		peer, err := netlink.LinkByName(pair.PeerName)
+		if err != nil {
+			return err
+		}
+		if err := netlink.LinkSetUp(peer); err != nil {
+			return err
+		}
+		child, err := netlink.LinkByName(n.Name)
+		if err != nil {
+			return err
+		}
+		if err := configureInterface(child, n); err != nil {
+			return err
+		}
+		if err := setupRoute(config); err != nil {
+			return err
+		}
+		// The gateway is left out of the main table.
+		main, err := netlink.RouteList(nil, netlink.FAMILY_V4)
+		if err != nil {
+			return err
+		}
+		for _, r := range main {
+			if r.Gw != nil {
+				return fmt.Errorf("expected no routes through a gateway in the main table, got %s", r)
+			}
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if routes, rules := countTableMessages(t, pid, n.RouteTable); routes != 2 || rules != 1 {
+		t.Fatalf("expected the gateway and the configured route in the table with one rule, got %d routes and %d rules", routes, rules)
+	}
+
+	root, err := ioutil.TempDir("", "libcontainer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	c := &linuxContainer{
+		root:          filepath.Join(root, "state"),
+		config:        config,
+		cgroupManager: &mockCgroupManager{},
+	}
+	if err := destroy(c); err != nil {
+		t.Fatal(err)
+	}
+	if routes, rules := countTableMessages(t, pid, n.RouteTable); routes != 0 || rules != 0 {
+		t.Fatalf("expected destroy to remove the table, got %d routes and %d rules", routes, rules)
+	}
+}
+
+func TestDelRouteTableRules(t *testing.T) {
+	if os.Getuid() != 0 {
+		t.Skip("adding rules requires root")
+	}
+	cmd := exec.Command("sleep", "60")
+	cmd.SysProcAttr = &syscall.SysProcAttr{Cloneflags: syscall.CLONE_NEWNET}
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer cmd.Wait()
+	defer cmd.Process.Kill()
+	pid := cmd.Process.Pid
+
+	kept := &configs.Network{Name: "eth0", Address: "10.236.0.2/24", RouteTable: 100}
+	removed := &configs.Network{Name: "eth1", Address: "10.236.1.2/24", IPv6Address: "fd00::2/64", RouteTable: 100}
+	if err := inNetns(pid, func() error {
+		for _, n := range []*configs.Network{kept, removed} {
+			if err := addRouteTableRules(n); err != nil {
+				return err
+			}
+		}
+		for i := 0; i < 2; i++ {
+			if err := delRouteTableRules(removed); err != nil {
+				return err
+			}
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if _, rules := countTableMessages(t, pid, 100); rules != 1 {
+		t.Fatalf("expected the rule of the other network to be kept, got %d rules", rules)
+	}
+}
@@ -0,0 +1,101 @@
+// +build linux
+
+package seccomp
+
+import (
+	"fmt"
+	"runtime"
+	"syscall"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+const (
+	seccompModeFilter     = 2
+	seccompGetActionAvail = 2
+	seccompRetAllow       = 0x7fff0000
+	seccompRetLog         = 0x7ffc0000
+
+	// Offsets of the syscall number and the architecture in struct
+	// seccomp_data.
+	seccompDataNr   = 0
+	seccompDataArch = 4
+)
+
+// auditArchs are the AUDIT_ARCH_* values of the architectures Go runs on.
+var auditArchs = map[string]uint32{
+	"386":     0x40000003,
+	"amd64":   0xc000003e,
+	"arm":     0x40000028,
+	"arm64":   0xc00000b7,
+	"ppc64":   0x80000015,
+	"ppc64le": 0xc0000015,
+	"s390x":   0x80000016,
+}
+
+// logFilter returns a classic BPF program that returns SECCOMP_RET_LOG for
+// the native syscalls numbered syscalls and SECCOMP_RET_ALLOW for any other.
+func logFilter(arch uint32, syscalls []uint32) []syscall.SockFilter {
+	prog := []syscall.SockFilter{
+		bpfStmt(syscall.BPF_LD|syscall.BPF_W|syscall.BPF_ABS, seccompDataArch),
+		bpfJump(syscall.BPF_JMP|syscall.BPF_JEQ|syscall.BPF_K, arch, 1, 0),
+		bpfStmt(syscall.BPF_RET|syscall.BPF_K, seccompRetAllow),
+		bpfStmt(syscall.BPF_LD|syscall.BPF_W|syscall.BPF_ABS, seccompDataNr),
+	}
+	for _, nr := range syscalls {
+		prog = append(prog,
+			bpfJump(syscall.BPF_JMP|syscall.BPF_JEQ|syscall.BPF_K, nr, 0, 1),
+			bpfStmt(syscall.BPF_RET|syscall.BPF_K, seccompRetLog),
+		)
+	}
+	return append(prog, bpfStmt(syscall.BPF_RET|syscall.BPF_K, seccompRetAllow))
+}
+
+// loadLogFilter loads a seccomp filter that has the kernel log the native
+// syscalls numbered syscalls to the audit log. It is loaded in addition to
+// the filter of the config, which decides whether the syscalls are allowed;
+// the kernel takes the action of highest precedence of all filters, and
+// only allowing a syscall ranks below logging it.
+func loadLogFilter(syscalls []uint32) error {
+	arch, ok := auditArchs[runtime.GOARCH]
+	if !ok {
+		return fmt.Errorf("the log action is not supported on %s", runtime.GOARCH)
+	}
+	if err := checkLogAction(); err != nil {
+		return err
+	}
+	prog := logFilter(arch, syscalls)
+	fprog := syscall.SockFprog{
+		Len:    uint16(len(prog)),
+		Filter: &prog[0],
+	}
+	if _, _, errno := syscall.RawSyscall(syscall.SYS_PRCTL, syscall.PR_SET_SECCOMP, seccompModeFilter, uintptr(unsafe.Pointer(&fprog))); errno != 0 {
+		return fmt.Errorf("error loading seccomp log filter into kernel: %s", errno)
+	}
+	return nil
+}
+
+// checkLogAction returns an error unless the kernel supports
+// SECCOMP_RET_LOG, which it added in 4.14. Older kernels would kill the
+// process on a syscall the filter is to log.
+func checkLogAction() error {
+	action := uint32(seccompRetLog)
+	_, _, errno := syscall.RawSyscall(unix.SYS_SECCOMP, seccompGetActionAvail, 0, uintptr(unsafe.Pointer(&action)))
+	switch errno {
+	case 0:
+		return nil
+	case syscall.ENOSYS, syscall.EINVAL, syscall.EOPNOTSUPP:
+		return fmt.Errorf("the log action is not supported by the kernel, it requires Linux 4.14 or later")
+	default:
+		return fmt.Errorf("error checking the seccomp log action: %s", errno)
+	}
+}
+
+func bpfStmt(code uint16, k uint32) syscall.SockFilter {
+	return syscall.SockFilter{Code: code, K: k}
+}
+
+func bpfJump(code uint16, k uint32, jt, jf uint8) syscall.SockFilter {
+	return syscall.SockFilter{Code: code, Jt: jt, Jf: jf, K: k}
+}
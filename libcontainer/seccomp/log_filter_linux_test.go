@@ -0,0 +1,121 @@
+// +build linux
+
+package seccomp
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+const prSetNoNewPrivs = 0x26
+
+// TestLoadLogFilter runs TestLoadLogFilterHelper, which loads a filter
+// logging getcwd and calls it, and expects getcwd to be allowed and logged.
+func TestLoadLogFilter(t *testing.T) {
+	if _, ok := auditArchs[runtime.GOARCH]; !ok {
+		t.Skipf("the log action is not supported on %s", runtime.GOARCH)
+	}
+	if err := checkLogAction(); err != nil {
+		t.Skip(err)
+	}
+	cmd := exec.Command(os.Args[0], "-test.run=^TestLoadLogFilterHelper$")
+	cmd.Env = append(os.Environ(), "LIBCONTAINER_TEST_SECCOMP_LOG=1")
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("log filter helper failed: %v\n%s", err, out)
+	}
+	if !strings.Contains(string(out), "Seccomp:\t2") {
+		t.Fatalf("expected the helper to run with a seccomp filter:\n%s", out)
+	}
+
+	// Without an audit daemon the kernel writes audit records to kmsg.
+	record := fmt.Sprintf("pid=%d ", cmd.Process.Pid)
+	syscallNr := fmt.Sprintf("syscall=%d ", syscall.SYS_GETCWD)
+	found, err := findKmsg(func(line string) bool {
+		return strings.Contains(line, "type=1326") && strings.Contains(line, record) && strings.Contains(line, syscallNr)
+	})
+	if err != nil {
+		t.Skipf("cannot read kmsg: %v", err)
+	}
+	if !found {
+		t.Skip("no audit record of getcwd in kmsg, the records may go to an audit daemon")
+	}
+}
+
+func TestLoadLogFilterHelper(t *testing.T) {
+	if os.Getenv("LIBCONTAINER_TEST_SECCOMP_LOG") != "1" {
+		return
+	}
+	if _, _, errno := syscall.RawSyscall(syscall.SYS_PRCTL, prSetNoNewPrivs, 1, 0); errno != 0 {
+		t.Fatal(errno)
+	}
+	if err := loadLogFilter([]uint32{syscall.SYS_GETCWD}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := syscall.Getwd(); err != nil {
+		t.Fatalf("expected getcwd to be allowed: %v", err)
+	}
+	status, err := parseSeccompStatus()
+	if err != nil {
+		t.Fatal(err)
+	}
+	fmt.Println(status)
+}
+
+func parseSeccompStatus() (string, error) {
+	f, err := os.Open("/proc/self/status")
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		if strings.HasPrefix(s.Text(), "Seccomp:") {
+			return s.Text(), nil
+		}
+	}
+	return "", s.Err()
+}
+
+// findKmsg reports whether a record in the kernel's log buffer matches.
+func findKmsg(match func(string) bool) (bool, error) {
+	// Records can show up in kmsg a little after the syscall.
+	for deadline := time.Now().Add(time.Second); ; time.Sleep(50 * time.Millisecond) {
+		found, err := scanKmsg(match)
+		if err != nil || found || time.Now().After(deadline) {
+			return found, err
+		}
+	}
+}
+
+func scanKmsg(match func(string) bool) (bool, error) {
+	fd, err := syscall.Open("/dev/kmsg", syscall.O_RDONLY|syscall.O_NONBLOCK, 0)
+	if err != nil {
+		return false, err
+	}
+	defer syscall.Close(fd)
+	buf := make([]byte, 8192)
+	for {
+		n, err := syscall.Read(fd, buf)
+		switch err {
+		case nil:
+		case syscall.EAGAIN:
+			return false, nil
+		case syscall.EPIPE:
+			// The record was overwritten while reading; skip it.
+			continue
+		default:
+			return false, err
+		}
+		if match(string(buf[:n])) {
+			return true, nil
+		}
+	}
+}
@@ -33,14 +33,76 @@ func InitSeccomp(config *configs.Seccomp) error {
 		return fmt.Errorf("cannot initialize Seccomp - nil config passed")
 	}
 
+	filter, err := newFilter(config)
+	if err != nil {
+		return err
+	}
+	defer filter.Release()
+
+	// The log filter goes first, as the filter of the config may not allow
+	// loading another one.
+	logged, err := logSyscalls(config)
+	if err != nil {
+		return err
+	}
+	if len(logged) > 0 {
+		if err := loadLogFilter(logged); err != nil {
+			return err
+		}
+	}
+
+	if err = filter.Load(); err != nil {
+		return fmt.Errorf("error loading seccomp filter into kernel: %s", err)
+	}
+
+	return nil
+}
+
+// SyscallKnown reports whether name is a syscall of the native architecture
+// or of one of arches, as profiles list the syscalls of every architecture
+// they support.
+func SyscallKnown(name string, arches []string) (bool, error) {
+	if _, err := libseccomp.GetSyscallFromName(name); err == nil {
+		return true, nil
+	}
+	for _, arch := range arches {
+		scmpArch, err := libseccomp.GetArchFromString(arch)
+		if err != nil {
+			return false, err
+		}
+		if _, err := libseccomp.GetSyscallFromNameByArch(name, scmpArch); err == nil {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// newFilter compiles config into a filter for the native architecture and
+// the extra ones in config.Architectures. Syscalls made with the ABI of any
+// other architecture kill the process.
+func newFilter(config *configs.Seccomp) (*libseccomp.ScmpFilter, error) {
+	if config.DefaultAction == configs.Log {
+		return nil, fmt.Errorf("error initializing seccomp - log cannot be the default action")
+	}
 	defaultAction, err := getAction(config.DefaultAction)
 	if err != nil {
-		return fmt.Errorf("error initializing seccomp - invalid default action")
+		return nil, fmt.Errorf("error initializing seccomp - invalid default action")
 	}
 
 	filter, err := libseccomp.NewFilter(defaultAction)
 	if err != nil {
-		return fmt.Errorf("error creating filter: %s", err)
+		return nil, fmt.Errorf("error creating filter: %s", err)
+	}
+	if err := setupFilter(filter, config); err != nil {
+		filter.Release()
+		return nil, err
+	}
+	return filter, nil
+}
+
+func setupFilter(filter *libseccomp.ScmpFilter, config *configs.Seccomp) error {
+	if err := filter.SetBadArchAction(actKill); err != nil {
+		return fmt.Errorf("error setting bad architecture action: %s", err)
 	}
 
 	// Add extra architectures
@@ -66,18 +128,40 @@ func InitSeccomp(config *configs.Seccomp) error {
 			return fmt.Errorf("encountered nil syscall while initializing Seccomp")
 		}
 
-		if err = matchCall(filter, call); err != nil {
+		// Logged syscalls are allowed by this filter and logged by the
+		// one of loadLogFilter; a rule allowing them is rejected by
+		// libseccomp if that is already the default.
+		if call.Action == configs.Log && config.DefaultAction == configs.Allow {
+			continue
+		}
+		if err := matchCall(filter, call); err != nil {
 			return err
 		}
 	}
 
-	if err = filter.Load(); err != nil {
-		return fmt.Errorf("error loading seccomp filter into kernel: %s", err)
-	}
-
 	return nil
 }
 
+// logSyscalls returns the native numbers of the syscalls config logs.
+// Syscalls unknown to this kernel are left out, like matchCall does.
+func logSyscalls(config *configs.Seccomp) ([]uint32, error) {
+	var logged []uint32
+	for _, call := range config.Syscalls {
+		if call == nil || call.Action != configs.Log {
+			continue
+		}
+		if len(call.Args) > 0 {
+			return nil, fmt.Errorf("log action of syscall %s cannot have argument conditions", call.Name)
+		}
+		callNum, err := libseccomp.GetSyscallFromName(call.Name)
+		if err != nil {
+			continue
+		}
+		logged = append(logged, uint32(callNum))
+	}
+	return logged, nil
+}
+
 // IsEnabled returns if the kernel has been configured to support seccomp.
 func IsEnabled() bool {
 	// Try to read from /proc/self/status for kernels > 3.8
@@ -109,6 +193,9 @@ func getAction(act configs.Action) (libseccomp.ScmpAction, error) {
 		return actAllow, nil
 	case configs.Trace:
 		return actTrace, nil
+	case configs.Log:
+		// The filter of loadLogFilter does the logging.
+		return actAllow, nil
 	default:
 		return libseccomp.ActInvalid, fmt.Errorf("invalid action, cannot use in rule")
 	}
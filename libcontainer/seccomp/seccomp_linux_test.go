@@ -2,7 +2,12 @@
 
 package seccomp
 
-import "testing"
+import (
+	"testing"
+
+	"github.com/opencontainers/runc/libcontainer/configs"
+	libseccomp "github.com/seccomp/libseccomp-golang"
+)
 
 func TestParseStatusFile(t *testing.T) {
 	s, err := parseStatusFile("fixtures/proc_self_status")
@@ -15,3 +20,72 @@ func TestParseStatusFile(t *testing.T) {
 		t.Fatal("expected to find 'Seccomp' in the map but did not.")
 	}
 }
+
+func TestFilterArchitectures(t *testing.T) {
+	native, err := libseccomp.GetNativeArch()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if native != libseccomp.ArchAMD64 {
+		t.Skip("test requires an x86_64 host")
+	}
+
+	config := &configs.Seccomp{DefaultAction: configs.Allow}
+	filter, err := newFilter(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer filter.Release()
+	if present, err := filter.IsArchPresent(libseccomp.ArchX86); err != nil || present {
+		t.Fatalf("expected x86 to be left out of the filter, present %v (%v)", present, err)
+	}
+	if action, err := filter.GetBadArchAction(); err != nil || action != libseccomp.ActKill {
+		t.Fatalf("expected syscalls of other architectures to kill, got %s (%v)", action, err)
+	}
+
+	config.Architectures = []string{"x86"}
+	withX86, err := newFilter(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer withX86.Release()
+	if present, err := withX86.IsArchPresent(libseccomp.ArchX86); err != nil || !present {
+		t.Fatalf("expected x86 to be added to the filter, present %v (%v)", present, err)
+	}
+}
+
+func TestLogSyscalls(t *testing.T) {
+	if _, err := newFilter(&configs.Seccomp{DefaultAction: configs.Log}); err == nil {
+		t.Fatal("expected log to be rejected as the default action")
+	}
+
+	config := &configs.Seccomp{
+		DefaultAction: configs.Allow,
+		Syscalls: []*configs.Syscall{
+			{Name: "getcwd", Action: configs.Log},
+			{Name: "mkdir", Action: configs.Errno},
+			{Name: "no_such_syscall", Action: configs.Log},
+		},
+	}
+	logged, err := logSyscalls(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	getcwd, err := libseccomp.GetSyscallFromName("getcwd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(logged) != 1 || logged[0] != uint32(getcwd) {
+		t.Fatalf("expected only getcwd to be logged, got %v", logged)
+	}
+	filter, err := newFilter(config)
+	if err != nil {
+		t.Fatalf("expected a logged syscall to be left out of a filter allowing by default: %v", err)
+	}
+	filter.Release()
+
+	config.Syscalls[0].Args = []*configs.Arg{{Index: 0, Value: 0, Op: configs.EqualTo}}
+	if _, err := logSyscalls(config); err == nil {
+		t.Fatal("expected a logged syscall with argument conditions to be rejected")
+	}
+}
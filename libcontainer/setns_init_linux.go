@@ -32,7 +32,11 @@ func (l *linuxSetnsInit) Init() error {
 			return err
 		}
 	}
+	if err := setLoginUID(l.config.Config.LoginUID); err != nil {
+		return newSystemErrorWithCause(err, "setting loginuid")
+	}
 	if l.config.CreateConsole {
+		traceStep("setting up console")
 		if err := setupConsole(l.consoleSocket, l.config, false); err != nil {
 			return err
 		}
@@ -46,10 +50,12 @@ func (l *linuxSetnsInit) Init() error {
 		}
 	}
 	if l.config.Config.Seccomp != nil {
+		traceStep("loading seccomp filter")
 		if err := seccomp.InitSeccomp(l.config.Config.Seccomp); err != nil {
 			return err
 		}
 	}
+	traceStep("setting up user and capabilities")
 	if err := finalizeNamespace(l.config); err != nil {
 		return err
 	}
@@ -59,5 +65,24 @@ func (l *linuxSetnsInit) Init() error {
 	if err := label.SetProcessLabel(l.config.ProcessLabel); err != nil {
 		return err
 	}
-	return system.Execv(l.config.Args[0], l.config.Args[0:], os.Environ())
+	env, err := execEnv(l.config)
+	if err != nil {
+		return err
+	}
+	if err := hardenExec(l.config); err != nil {
+		return err
+	}
+	// The process joins a container that is running already, so anything
+	// the manager left open would stay open in it for as long as the
+	// process runs.
+	if err := closeInheritedFds(l.config); err != nil {
+		return newSystemErrorWithCause(err, "closing inherited descriptors")
+	}
+	if err := setSignalMask(l.config.Config); err != nil {
+		return err
+	}
+	if err := system.Execv(l.config.Args[0], l.config.Args[0:], env); err != nil {
+		return newExecError(l.config.Args[0], err)
+	}
+	return nil
 }
@@ -0,0 +1,109 @@
+// +build linux
+
+package libcontainer
+
+import (
+	"fmt"
+	"log/syslog"
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+const (
+	// maxTrailSteps and maxTrailStepLen bound the setup trail that the init
+	// sends to the parent along with its error.
+	maxTrailSteps   = 16
+	maxTrailStepLen = 256
+)
+
+// setupTrail holds the latest setup steps of the init. Only the init process
+// records steps, from the goroutine that sets up the container.
+var setupTrail []string
+
+// setupLog is the host's syslog if the config asks for the setup to be
+// logged there.
+var setupLog *syslog.Writer
+
+// syslogSocket is the socket of the host's syslog, the default one if empty.
+var syslogSocket string
+
+// openSetupLog connects to the host's syslog if the config asks for it. The
+// init has to connect before it moves into the rootfs of the container.
+func openSetupLog(config *initConfig) error {
+	if config.Config == nil || config.Config.SetupSyslog == nil {
+		return nil
+	}
+	tag := config.Config.SetupSyslog.Tag
+	if tag == "" {
+		tag = config.ContainerId
+	}
+	network := ""
+	if syslogSocket != "" {
+		network = "unixgram"
+	}
+	w, err := syslog.Dial(network, syslogSocket, syslog.LOG_INFO|syslog.LOG_DAEMON, tag)
+	if err != nil {
+		return err
+	}
+	setupLog = w
+	return nil
+}
+
+// logSetupError sends the error the setup failed with to the host's syslog.
+func logSetupError(err error) {
+	if setupLog != nil && err != nil {
+		setupLog.Err(fmt.Sprintf("setup failed: %v", err))
+	}
+}
+
+// traceStep records that the init started the setup step.
+func traceStep(format string, v ...interface{}) {
+	step := fmt.Sprintf(format, v...)
+	if len(step) > maxTrailStepLen {
+		step = step[:maxTrailStepLen] + "..."
+	}
+	if setupLog != nil {
+		setupLog.Info(step)
+	}
+	if len(setupTrail) == maxTrailSteps {
+		setupTrail = append(setupTrail[:0], setupTrail[1:]...)
+	}
+	setupTrail = append(setupTrail, step)
+}
+
+// finishTrail returns the recorded setup steps with the errno of err added
+// to the last one, which is the step that failed.
+func finishTrail(err error) []string {
+	if len(setupTrail) == 0 {
+		return nil
+	}
+	trail := append([]string(nil), setupTrail...)
+	if errno, ok := errnoOf(err); ok {
+		trail[len(trail)-1] += fmt.Sprintf(": %s (errno %d)", errno, int(errno))
+	}
+	return trail
+}
+
+// errnoOf returns the errno that err wraps, if any.
+func errnoOf(err error) (syscall.Errno, bool) {
+	for err != nil {
+		switch e := err.(type) {
+		case syscall.Errno:
+			return e, true
+		case *genericError:
+			err = e.Err
+		case *exec.Error:
+			err = e.Err
+		case *os.PathError:
+			err = e.Err
+		case *os.SyscallError:
+			err = e.Err
+		case *os.LinkError:
+			err = e.Err
+		default:
+			return 0, false
+		}
+	}
+	return 0, false
+}
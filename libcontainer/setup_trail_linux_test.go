@@ -0,0 +1,145 @@
+// +build linux
+
+package libcontainer
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/opencontainers/runc/libcontainer/configs"
+)
+
+func TestSetupTrail(t *testing.T) {
+	defer func() { setupTrail = nil }()
+	setupTrail = nil
+
+	for i := 0; i < maxTrailSteps+2; i++ {
+		traceStep("step %d", i)
+	}
+	traceStep("mounting %s", strings.Repeat("x", 2*maxTrailStepLen))
+	if len(setupTrail) != maxTrailSteps {
+		t.Fatalf("expected the trail to keep the last %d steps, got %d", maxTrailSteps, len(setupTrail))
+	}
+	if setupTrail[0] != "step 3" {
+		t.Fatalf("expected the oldest steps to be dropped, first step is %q", setupTrail[0])
+	}
+	if last := setupTrail[len(setupTrail)-1]; len(last) != maxTrailStepLen+len("...") {
+		t.Fatalf("expected a long step to be truncated, got %d bytes", len(last))
+	}
+
+	setupTrail = []string{"setting up network", "mounting bind /missing at /data"}
+	err := newSystemErrorWithCause(&os.PathError{Op: "stat", Path: "/missing", Err: syscall.ENOENT}, "mounting")
+	expected := []string{
+		"setting up network",
+		fmt.Sprintf("mounting bind /missing at /data: no such file or directory (errno %d)", syscall.ENOENT),
+	}
+	trail := finishTrail(err)
+	if !reflect.DeepEqual(trail, expected) {
+		t.Fatalf("expected trail %q, got %q", expected, trail)
+	}
+	// So does an errno below a failed exec or syscall.
+	for _, err := range []error{
+		&exec.Error{Name: "/bin/missing", Err: &os.PathError{Op: "stat", Path: "/bin/missing", Err: syscall.ENOENT}},
+		os.NewSyscallError("setns", syscall.EPERM),
+	} {
+		if _, ok := errnoOf(newSystemErrorWithCause(err, "exec user process")); !ok {
+			t.Errorf("expected the errno of %v to be found", err)
+		}
+	}
+	if trail := finishTrail(fmt.Errorf("no errno")); !reflect.DeepEqual(trail, setupTrail) {
+		t.Fatalf("expected the trail to be unchanged without an errno, got %q", trail)
+	}
+
+	// The trail survives wrapping of the init's error in the parent.
+	ierr := newGenericError(fmt.Errorf("init failed"), SystemError).(*genericError)
+	ierr.Trail = trail
+	wrapped := newSystemErrorWithCause(ierr, "starting container process")
+	if got := SetupTrail(wrapped); !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected the wrapped error to carry trail %q, got %q", expected, got)
+	}
+	if got := SetupTrail(fmt.Errorf("other")); got != nil {
+		t.Fatalf("expected no trail for other errors, got %q", got)
+	}
+}
+
+func TestSetupSyslog(t *testing.T) {
+	dir, err := ioutil.TempDir("", "setup-syslog")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	socket := filepath.Join(dir, "log")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		t.Skipf("cannot listen on a unixgram socket: %v", err)
+	}
+	defer conn.Close()
+
+	syslogSocket = socket
+	defer func() {
+		if setupLog != nil {
+			setupLog.Close()
+		}
+		setupLog, syslogSocket, setupTrail = nil, "", nil
+	}()
+
+	config := &initConfig{ContainerId: "ctr", Config: &configs.Config{}}
+	if err := openSetupLog(config); err != nil {
+		t.Fatal(err)
+	}
+	if setupLog != nil {
+		t.Fatal("expected no syslog without SetupSyslog in the config")
+	}
+	for _, test := range []struct {
+		tag, want string
+	}{
+		{"", "ctr"},
+		{"custom", "custom"},
+	} {
+		config.Config.SetupSyslog = &configs.SetupSyslog{Tag: test.tag}
+		if err := openSetupLog(config); err != nil {
+			t.Fatal(err)
+		}
+		if setupLog == nil {
+			t.Fatal("expected the init to connect to syslog")
+		}
+		traceStep("mounting %s", "/data")
+		logSetupError(fmt.Errorf("no such device"))
+		for _, want := range []string{"mounting /data", "setup failed: no such device"} {
+			msg := readSyslog(t, conn)
+			if !strings.Contains(msg, " "+test.want+"[") || !strings.HasSuffix(strings.TrimSpace(msg), "]: "+want) {
+				t.Errorf("expected syslog message %q tagged %q, got %q", want, test.want, msg)
+			}
+		}
+		setupLog.Close()
+		setupLog = nil
+	}
+
+	syslogSocket = socket + ".missing"
+	if err := openSetupLog(config); err == nil {
+		t.Fatal("expected connecting to a missing syslog to fail")
+	}
+	if setupLog != nil {
+		t.Fatal("expected no syslog when it cannot be reached")
+	}
+}
+
+// readSyslog returns the next message sent to the syslog socket.
+func readSyslog(t *testing.T, conn *net.UnixConn) string {
+	buf := make([]byte, 4096)
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	n, err := conn.Read(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(buf[:n])
+}
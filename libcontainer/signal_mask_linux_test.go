@@ -0,0 +1,48 @@
+// +build linux
+
+package libcontainer
+
+import (
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"syscall"
+	"testing"
+
+	"github.com/opencontainers/runc/libcontainer/configs"
+)
+
+// TestSetSignalMask runs TestSetSignalMaskHelper, which blocks SIGHUP, sets
+// the signal mask to SIGUSR1 and SIGTERM and execs grep, and expects grep to
+// block only those.
+func TestSetSignalMask(t *testing.T) {
+	cmd := exec.Command(os.Args[0], "-test.run=^TestSetSignalMaskHelper$")
+	cmd.Env = append(os.Environ(), "LIBCONTAINER_TEST_SIGNAL_MASK=1")
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("signal mask helper failed: %v\n%s", err, out)
+	}
+	// SIGUSR1 is bit 9 and SIGTERM bit 14.
+	if fields := strings.Fields(string(out)); len(fields) != 2 || fields[0] != "SigBlk:" || fields[1] != "0000000000004200" {
+		t.Fatalf("expected only SIGUSR1 and SIGTERM to be blocked, got %q", out)
+	}
+}
+
+func TestSetSignalMaskHelper(t *testing.T) {
+	if os.Getenv("LIBCONTAINER_TEST_SIGNAL_MASK") != "1" {
+		return
+	}
+	runtime.LockOSThread()
+	if err := setSignalMask(&configs.Config{BlockedSignals: []int{int(syscall.SIGHUP)}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := setSignalMask(&configs.Config{BlockedSignals: []int{int(syscall.SIGUSR1), int(syscall.SIGTERM)}}); err != nil {
+		t.Fatal(err)
+	}
+	grep, err := exec.LookPath("grep")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Fatal(syscall.Exec(grep, []string{"grep", "SigBlk", "/proc/self/status"}, nil))
+}
@@ -5,6 +5,7 @@
 package specconv
 
 import (
+	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -148,9 +149,105 @@ type CreateOpts struct {
 	Rootless         bool
 }
 
+// LoadSpec reads an OCI specification from the config.json at path.
+func LoadSpec(path string) (*specs.Spec, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("JSON specification file %s not found", path)
+		}
+		return nil, err
+	}
+	defer f.Close()
+	var spec *specs.Spec
+	if err := json.NewDecoder(f).Decode(&spec); err != nil {
+		return nil, fmt.Errorf("decoding %s: %v", path, err)
+	}
+	if spec == nil {
+		return nil, fmt.Errorf("JSON specification file %s is empty", path)
+	}
+	return spec, nil
+}
+
+// checkSupported returns an error naming the fields of the specification
+// that cannot be represented in a libcontainer configuration, rather than
+// have them silently dropped.
+func checkSupported(spec *specs.Spec) error {
+	if spec.Linux == nil {
+		return fmt.Errorf("linux section is missing from the spec")
+	}
+	var fields []string
+	if spec.Platform.OS != "" && spec.Platform.OS != "linux" {
+		fields = append(fields, fmt.Sprintf("platform.os=%s", spec.Platform.OS))
+	}
+	if spec.Process.User.Username != "" {
+		fields = append(fields, "process.user.username")
+	}
+	if s := spec.Solaris; s != nil {
+		n := len(fields)
+		if s.Milestone != "" {
+			fields = append(fields, "solaris.milestone")
+		}
+		if s.LimitPriv != "" {
+			fields = append(fields, "solaris.limitpriv")
+		}
+		if s.MaxShmMemory != "" {
+			fields = append(fields, "solaris.maxShmMemory")
+		}
+		if len(s.Anet) > 0 {
+			fields = append(fields, "solaris.anet")
+		}
+		if s.CappedCPU != nil {
+			fields = append(fields, "solaris.cappedCPU")
+		}
+		if s.CappedMemory != nil {
+			fields = append(fields, "solaris.cappedMemory")
+		}
+		if len(fields) == n {
+			fields = append(fields, "solaris")
+		}
+	}
+	if w := spec.Windows; w != nil {
+		n := len(fields)
+		if r := w.Resources; r != nil {
+			if r.Memory != nil {
+				fields = append(fields, "windows.resources.memory")
+			}
+			if r.CPU != nil {
+				fields = append(fields, "windows.resources.cpu")
+			}
+			if r.Storage != nil {
+				fields = append(fields, "windows.resources.storage")
+			}
+			if r.Network != nil {
+				fields = append(fields, "windows.resources.network")
+			}
+		}
+		if len(fields) == n {
+			fields = append(fields, "windows")
+		}
+	}
+	for i, ns := range spec.Linux.Namespaces {
+		if _, exists := namespaceMapping[ns.Type]; !exists {
+			fields = append(fields, fmt.Sprintf("linux.namespaces[%d].type=%s", i, ns.Type))
+		}
+	}
+	if _, exists := mountPropagationMapping[spec.Linux.RootfsPropagation]; !exists {
+		fields = append(fields, fmt.Sprintf("linux.rootfsPropagation=%s", spec.Linux.RootfsPropagation))
+	}
+	if len(fields) > 0 {
+		return fmt.Errorf("unsupported fields in the spec: %s", strings.Join(fields, ", "))
+	}
+	return nil
+}
+
 // CreateLibcontainerConfig creates a new libcontainer configuration from a
 // given specification and a cgroup name
 func CreateLibcontainerConfig(opts *CreateOpts) (*configs.Config, error) {
+	spec := opts.Spec
+	if err := checkSupported(spec); err != nil {
+		return nil, err
+	}
 	// runc's cwd will always be the bundle path
 	rcwd, err := os.Getwd()
 	if err != nil {
@@ -160,7 +257,6 @@ func CreateLibcontainerConfig(opts *CreateOpts) (*configs.Config, error) {
 	if err != nil {
 		return nil, err
 	}
-	spec := opts.Spec
 	rootfsPath := spec.Root.Path
 	if !filepath.IsAbs(rootfsPath) {
 		rootfsPath = filepath.Join(cwd, rootfsPath)
@@ -679,6 +775,7 @@ func parseMountOptions(options []string) (int, []int, string, int) {
 		flag  int
 	}{
 		"tmpcopyup": {false, configs.EXT_COPYUP},
+		"idmap":     {false, configs.EXT_IDMAP},
 	}
 	for _, o := range options {
 		// If the option does not exist in the flags table or the flag
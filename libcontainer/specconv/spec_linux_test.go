@@ -3,8 +3,15 @@
 package specconv
 
 import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"syscall"
 	"testing"
 
+	"github.com/opencontainers/runc/libcontainer/configs"
 	"github.com/opencontainers/runc/libcontainer/configs/validate"
 	"github.com/opencontainers/runtime-spec/specs-go"
 )
@@ -118,3 +125,169 @@ func TestRootlessSpecconvValidate(t *testing.T) {
 		t.Errorf("Expected specconv to produce valid rootless container config: %v", err)
 	}
 }
+
+const ociSpec = `{
+	"ociVersion": "1.0.0-rc5",
+	"platform": {"os": "linux", "arch": "amd64"},
+	"process": {
+		"args": ["sh"],
+		"cwd": "/",
+		"selinuxLabel": "system_u:system_r:svirt_lxc_net_t:s0:c124,c675",
+		"capabilities": {
+			"bounding": ["CAP_KILL", "CAP_NET_BIND_SERVICE"],
+			"effective": ["CAP_KILL"],
+			"permitted": ["CAP_KILL", "CAP_NET_BIND_SERVICE"],
+			"inheritable": ["CAP_KILL"],
+			"ambient": ["CAP_KILL"]
+		}
+	},
+	"root": {"path": "/var/lib/test/rootfs", "readonly": true},
+	"hostname": "oci",
+	"mounts": [
+		{"destination": "/proc", "type": "proc", "source": "proc"},
+		{"destination": "/data", "type": "bind", "source": "/srv/data", "options": ["rbind", "ro"]}
+	],
+	"linux": {
+		"namespaces": [{"type": "pid"}, {"type": "mount"}, {"type": "network", "path": "/var/run/netns/test"}],
+		"resources": {
+			"oomScoreAdj": 100,
+			"memory": {"limit": 536870912},
+			"cpu": {"shares": 512, "quota": 50000, "period": 100000},
+			"pids": {"limit": 64}
+		},
+		"sysctl": {"net.ipv4.ip_forward": "1"},
+		"maskedPaths": ["/proc/kcore"],
+		"readonlyPaths": ["/proc/sys"],
+		"mountLabel": "system_u:object_r:svirt_sandbox_file_t:s0:c124,c675"
+	}
+}`
+
+func TestCreateLibcontainerConfigFromOCISpec(t *testing.T) {
+	dir, err := ioutil.TempDir("", "specconv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "config.json")
+	if err := ioutil.WriteFile(path, []byte(ociSpec), 0644); err != nil {
+		t.Fatal(err)
+	}
+	spec, err := LoadSpec(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config, err := CreateLibcontainerConfig(&CreateOpts{
+		CgroupName: "ContainerID",
+		Spec:       spec,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if config.Rootfs != "/var/lib/test/rootfs" || !config.Readonlyfs {
+		t.Errorf("unexpected rootfs %q (readonly %v)", config.Rootfs, config.Readonlyfs)
+	}
+	if config.Hostname != "oci" {
+		t.Errorf("expected hostname oci, got %q", config.Hostname)
+	}
+	if config.ProcessLabel != spec.Process.SelinuxLabel || config.MountLabel != spec.Linux.MountLabel {
+		t.Errorf("unexpected labels %q and %q", config.ProcessLabel, config.MountLabel)
+	}
+	expectedCaps := &configs.Capabilities{
+		Bounding:    []string{"CAP_KILL", "CAP_NET_BIND_SERVICE"},
+		Effective:   []string{"CAP_KILL"},
+		Permitted:   []string{"CAP_KILL", "CAP_NET_BIND_SERVICE"},
+		Inheritable: []string{"CAP_KILL"},
+		Ambient:     []string{"CAP_KILL"},
+	}
+	if !reflect.DeepEqual(config.Capabilities, expectedCaps) {
+		t.Errorf("expected capabilities %+v, got %+v", expectedCaps, config.Capabilities)
+	}
+
+	if len(config.Mounts) != 2 {
+		t.Fatalf("expected 2 mounts, got %d", len(config.Mounts))
+	}
+	if m := config.Mounts[0]; m.Destination != "/proc" || m.Device != "proc" || m.Source != "proc" {
+		t.Errorf("unexpected proc mount %+v", m)
+	}
+	if m := config.Mounts[1]; m.Destination != "/data" || m.Device != "bind" || m.Source != "/srv/data" ||
+		m.Flags != syscall.MS_BIND|syscall.MS_REC|syscall.MS_RDONLY {
+		t.Errorf("unexpected bind mount %+v", m)
+	}
+
+	expectedNs := configs.Namespaces{
+		{Type: configs.NEWPID},
+		{Type: configs.NEWNS},
+		{Type: configs.NEWNET, Path: "/var/run/netns/test"},
+	}
+	if !reflect.DeepEqual(config.Namespaces, expectedNs) {
+		t.Errorf("expected namespaces %+v, got %+v", expectedNs, config.Namespaces)
+	}
+	if len(config.Networks) != 1 || config.Networks[0].Type != "loopback" {
+		t.Errorf("expected a loopback network, got %+v", config.Networks)
+	}
+
+	r := config.Cgroups.Resources
+	if config.Cgroups.Name != "ContainerID" {
+		t.Errorf("expected cgroup name ContainerID, got %q", config.Cgroups.Name)
+	}
+	if r.Memory != 536870912 || r.CpuShares != 512 || r.CpuQuota != 50000 || r.CpuPeriod != 100000 || r.PidsLimit != 64 {
+		t.Errorf("unexpected resources %+v", r)
+	}
+	if config.OomScoreAdj != 100 {
+		t.Errorf("expected oom_score_adj 100, got %d", config.OomScoreAdj)
+	}
+	if config.Sysctl["net.ipv4.ip_forward"] != "1" {
+		t.Errorf("unexpected sysctls %v", config.Sysctl)
+	}
+	if !reflect.DeepEqual(config.MaskPaths, []string{"/proc/kcore"}) || !reflect.DeepEqual(config.ReadonlyPaths, []string{"/proc/sys"}) {
+		t.Errorf("unexpected masked %v or readonly %v paths", config.MaskPaths, config.ReadonlyPaths)
+	}
+}
+
+func TestCreateLibcontainerConfigUnsupported(t *testing.T) {
+	for name, spec := range map[string]*specs.Spec{
+		"no linux section": {},
+		"solaris section":  {Linux: &specs.Linux{}, Solaris: &specs.Solaris{}},
+		"windows section":  {Linux: &specs.Linux{}, Windows: &specs.Windows{}},
+		"windows platform": {Platform: specs.Platform{OS: "windows"}, Linux: &specs.Linux{}},
+		"cgroup namespace": {Linux: &specs.Linux{Namespaces: []specs.LinuxNamespace{{Type: "cgroup"}}}},
+	} {
+		if _, err := CreateLibcontainerConfig(&CreateOpts{Spec: spec}); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestCheckSupportedNamesFields(t *testing.T) {
+	spec := &specs.Spec{
+		Platform: specs.Platform{OS: "windows"},
+		Process:  specs.Process{User: specs.User{Username: "admin"}},
+		Solaris:  &specs.Solaris{Milestone: "svc:/milestone/container:default", CappedCPU: &specs.SolarisCappedCPU{Ncpus: "2"}},
+		Windows:  &specs.Windows{Resources: &specs.WindowsResources{Storage: &specs.WindowsStorageResources{}}},
+		Linux: &specs.Linux{
+			Namespaces:        []specs.LinuxNamespace{{Type: "pid"}, {Type: "cgroup"}},
+			RootfsPropagation: "bogus",
+		},
+	}
+	err := checkSupported(spec)
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	for _, field := range []string{
+		"platform.os=windows",
+		"process.user.username",
+		"solaris.milestone",
+		"solaris.cappedCPU",
+		"windows.resources.storage",
+		"linux.namespaces[1].type=cgroup",
+		"linux.rootfsPropagation=bogus",
+	} {
+		if !strings.Contains(err.Error(), field) {
+			t.Errorf("expected %q in the error: %v", field, err)
+		}
+	}
+	if strings.Contains(err.Error(), "linux.namespaces[0]") {
+		t.Errorf("expected the pid namespace to be supported: %v", err)
+	}
+}
@@ -44,8 +44,19 @@ func (l *linuxStandardInit) getSessionRingParams() (string, uint32, uint32) {
 // the kernel
 const PR_SET_NO_NEW_PRIVS = 0x26
 
-func (l *linuxStandardInit) Init() error {
+func (l *linuxStandardInit) Init() (err error) {
+	parentDeath, err := watchParentDeath(l.config, l.parentPid)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			parentDeath.setupFailed()
+		}
+		parentDeath.stop()
+	}()
 	if !l.config.Config.NoNewKeyring {
+		traceStep("joining session keyring")
 		ringname, keepperms, newperms := l.getSessionRingParams()
 
 		// do not inherit the parent's session keyring
@@ -59,9 +70,17 @@ func (l *linuxStandardInit) Init() error {
 		}
 	}
 
+	// The init may not keep CAP_AUDIT_CONTROL, so set the loginuid first.
+	traceStep("setting loginuid")
+	if err := setLoginUID(l.config.Config.LoginUID); err != nil {
+		return newSystemErrorWithCause(err, "setting loginuid")
+	}
+
+	traceStep("setting up network")
 	if err := setupNetwork(l.config); err != nil {
 		return err
 	}
+	traceStep("setting up routes")
 	if err := setupRoute(l.config.Config); err != nil {
 		return err
 	}
@@ -70,7 +89,7 @@ func (l *linuxStandardInit) Init() error {
 
 	// prepareRootfs() can be executed only for a new mount namespace.
 	if l.config.Config.Namespaces.Contains(configs.NEWNS) {
-		if err := prepareRootfs(l.pipe, l.config.Config); err != nil {
+		if err := prepareRootfs(l.pipe, l.config.Config, l.config.RootfsImage, l.config.Overlay); err != nil {
 			return err
 		}
 	}
@@ -79,6 +98,7 @@ func (l *linuxStandardInit) Init() error {
 	// but *after* we've given the user the chance to set up all of the mounts
 	// they wanted.
 	if l.config.CreateConsole {
+		traceStep("setting up console")
 		if err := setupConsole(l.consoleSocket, l.config, true); err != nil {
 			return err
 		}
@@ -87,18 +107,30 @@ func (l *linuxStandardInit) Init() error {
 		}
 	}
 
+	// The daemon files are opened in the container's rootfs, before it is
+	// made read-only, so that they can be created.
+	if daemon := l.config.Config.Daemon; daemon != nil {
+		traceStep("redirecting stdio for daemon mode")
+		if err := setupDaemon(daemon); err != nil {
+			return err
+		}
+	}
+
 	// Finish the rootfs setup.
 	if l.config.Config.Namespaces.Contains(configs.NEWNS) {
+		traceStep("finalizing rootfs")
 		if err := finalizeRootfs(l.config.Config); err != nil {
 			return err
 		}
 	}
 
 	if hostname := l.config.Config.Hostname; hostname != "" {
+		traceStep("setting hostname %q", hostname)
 		if err := syscall.Sethostname([]byte(hostname)); err != nil {
 			return err
 		}
 	}
+	traceStep("applying security labels")
 	if err := apparmor.ApplyProfile(l.config.AppArmorProfile); err != nil {
 		return err
 	}
@@ -106,17 +138,31 @@ func (l *linuxStandardInit) Init() error {
 		return err
 	}
 
+	if buffers := l.config.Config.SocketBuffers; buffers != nil {
+		for key, value := range buffers.Sysctl() {
+			if _, ok := l.config.Config.Sysctl[key]; ok {
+				continue
+			}
+			if err := writeSystemProperty(key, value); err != nil {
+				return err
+			}
+		}
+	}
 	for key, value := range l.config.Config.Sysctl {
+		traceStep("setting sysctl %s", key)
 		if err := writeSystemProperty(key, value); err != nil {
 			return err
 		}
 	}
 	for _, path := range l.config.Config.ReadonlyPaths {
+		traceStep("making %s read-only", path)
 		if err := readonlyPath(path); err != nil {
 			return err
 		}
 	}
-	for _, path := range l.config.Config.MaskPaths {
+	maskPaths := append(append([]string(nil), l.config.Config.MaskPaths...), hostProcMaskPaths(l.config.Config)...)
+	for _, path := range maskPaths {
+		traceStep("masking %s", path)
 		if err := maskPath(path); err != nil {
 			return err
 		}
@@ -140,10 +186,20 @@ func (l *linuxStandardInit) Init() error {
 	// do this before dropping capabilities; otherwise do it as late as possible
 	// just before execve so as few syscalls take place after it as possible.
 	if l.config.Config.Seccomp != nil && !l.config.NoNewPrivileges {
+		traceStep("loading seccomp filter")
 		if err := seccomp.InitSeccomp(l.config.Config.Seccomp); err != nil {
 			return err
 		}
 	}
+	// Removing the cgroups takes the privileges that finalizeNamespace
+	// drops, so the watch ends here and a parent that dies later only makes
+	// the init exit.
+	if syscall.Getppid() != l.parentPid {
+		parentDeath.cleanup()
+		return syscall.Kill(syscall.Getpid(), syscall.SIGKILL)
+	}
+	parentDeath.stop()
+	traceStep("setting up user and capabilities")
 	if err := finalizeNamespace(l.config); err != nil {
 		return err
 	}
@@ -160,10 +216,32 @@ func (l *linuxStandardInit) Init() error {
 	}
 	// check for the arg before waiting to make sure it exists and it is returned
 	// as a create time error.
+	traceStep("looking up %s", l.config.Args[0])
 	name, err := exec.LookPath(l.config.Args[0])
 	if err != nil {
+		return newExecError(l.config.Args[0], err)
+	}
+	env, err := execEnv(l.config)
+	if err != nil {
+		return err
+	}
+	if err := hardenExec(l.config); err != nil {
+		return err
+	}
+	// finalizeNamespace did this already, but the descriptors opened since
+	// then, such as by applying the security labels, may lack O_CLOEXEC.
+	if err := closeInheritedFds(l.config); err != nil {
+		return newSystemErrorWithCause(err, "closing inherited descriptors")
+	}
+	if err := setSignalMask(l.config.Config); err != nil {
 		return err
 	}
+	if l.config.Config.NetworkReadyTimeout > 0 {
+		traceStep("waiting for the network to be ready")
+		if err := readSync(l.pipe, procNetworkReady); err != nil {
+			return err
+		}
+	}
 	// close the pipe to signal that we have completed our init.
 	l.pipe.Close()
 	// wait for the fifo to be opened on the other side before
@@ -172,6 +250,10 @@ func (l *linuxStandardInit) Init() error {
 	if err != nil {
 		return newSystemErrorWithCause(err, "openat exec fifo")
 	}
+	// The fifo is written before seccomp is applied, so that the profile
+	// cannot block the handshake. As the fifo is opened with O_CLOEXEC, the
+	// reader still sees EOF exactly when the execve(2) succeeds (or when we
+	// die trying).
 	if _, err := syscall.Write(fd, []byte("0")); err != nil {
 		return newSystemErrorWithCause(err, "write 0 exec fifo")
 	}
@@ -183,8 +265,16 @@ func (l *linuxStandardInit) Init() error {
 	// close the statedir fd before exec because the kernel resets dumpable in the wrong order
 	// https://github.com/torvalds/linux/blob/v4.9/fs/exec.c#L1290-L1318
 	syscall.Close(l.stateDirFD)
-	if err := syscall.Exec(name, l.config.Args[0:], os.Environ()); err != nil {
-		return newSystemErrorWithCause(err, "exec user process")
+	// The pipe is already closed at this point, so the parent only finds out
+	// about a failure here through the exit status of the init.
+	if l.config.Config.MinimalInit {
+		if err := execReaper(l.config.ReaperFd, name, l.config.Args[0:], env); err != nil {
+			return newSystemErrorWithCause(err, "exec reaper")
+		}
+		return nil
+	}
+	if err := syscall.Exec(name, l.config.Args[0:], env); err != nil {
+		return newExecError(name, err)
 	}
 	return nil
 }
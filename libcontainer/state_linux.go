@@ -7,8 +7,10 @@ import (
 	"os"
 	"path/filepath"
 	"syscall"
+	"time"
 
 	"github.com/Sirupsen/logrus"
+	"github.com/opencontainers/runc/libcontainer/cgroups"
 	"github.com/opencontainers/runc/libcontainer/configs"
 	"github.com/opencontainers/runc/libcontainer/utils"
 )
@@ -38,15 +40,42 @@ type containerState interface {
 }
 
 func destroy(c *linuxContainer) error {
-	if !c.config.Namespaces.Contains(configs.NEWPID) {
+	if timeout := c.config.CgroupDrainTimeout; timeout > 0 {
+		if err := drainCgroups(c.cgroupManager, timeout); err != nil {
+			logrus.Warn(err)
+		}
+	} else if !c.config.Namespaces.Contains(configs.NEWPID) {
 		if err := signalAllProcesses(c.cgroupManager, syscall.SIGKILL); err != nil {
 			logrus.Warn(err)
 		}
 	}
 	err := c.cgroupManager.Destroy()
+	if rerr := newRootfsOverlay(c.config, c.root).remove(); err == nil {
+		err = rerr
+	}
+	if rerr := detachRootfsImage(c.rootfsImage); err == nil {
+		err = rerr
+	}
 	if rerr := os.RemoveAll(c.root); err == nil {
 		err = rerr
 	}
+	if c.config.PidFile != "" {
+		if rerr := os.Remove(c.config.PidFile); rerr != nil && !os.IsNotExist(rerr) && err == nil {
+			err = rerr
+		}
+	}
+	if c.config.NetnsPath != "" {
+		if rerr := unbindNetns(c.config.NetnsPath); rerr != nil && err == nil {
+			err = rerr
+		}
+	}
+	// A network namespace that was joined outlives the container, along with
+	// the routing tables set up in it.
+	if path := c.config.Namespaces.PathOf(configs.NEWNET); path != "" {
+		if rerr := removeRouteTables(path, c.config.Networks); rerr != nil && err == nil {
+			err = rerr
+		}
+	}
 	c.initProcess = nil
 	if herr := runPoststopHooks(c); err == nil {
 		err = herr
@@ -55,6 +84,48 @@ func destroy(c *linuxContainer) error {
 	return err
 }
 
+// cgroupKillTimeout is how long drainCgroups waits for the cgroups to be
+// emptied once their processes are killed.
+const cgroupKillTimeout = time.Second
+
+// drainCgroups waits up to timeout for the cgroups of m to have no processes
+// left, and then kills the ones that are and waits for them as well.
+func drainCgroups(m cgroups.Manager, timeout time.Duration) error {
+	if waitCgroupsEmpty(m, timeout) {
+		return nil
+	}
+	if err := signalAllProcesses(m, syscall.SIGKILL); err != nil {
+		return err
+	}
+	if !waitCgroupsEmpty(m, cgroupKillTimeout) {
+		return fmt.Errorf("processes are left in the cgroups after killing them")
+	}
+	return nil
+}
+
+// waitCgroupsEmpty reports whether the cgroups of m have no processes within
+// timeout.
+func waitCgroupsEmpty(m cgroups.Manager, timeout time.Duration) bool {
+	deadline := time.Now().Add(timeout)
+	for delay := time.Millisecond; ; delay *= 2 {
+		pids, err := m.GetAllPids()
+		if err == nil && len(pids) == 0 {
+			return true
+		}
+		remaining := deadline.Sub(time.Now())
+		if remaining <= 0 {
+			return false
+		}
+		if delay > 100*time.Millisecond {
+			delay = 100 * time.Millisecond
+		}
+		if delay > remaining {
+			delay = remaining
+		}
+		time.Sleep(delay)
+	}
+}
+
 func runPoststopHooks(c *linuxContainer) error {
 	if c.config.Hooks != nil {
 		s := configs.HookState{
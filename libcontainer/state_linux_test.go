@@ -2,7 +2,17 @@
 
 package libcontainer
 
-import "testing"
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/opencontainers/runc/libcontainer/configs"
+)
 
 func TestStateStatus(t *testing.T) {
 	states := map[containerState]Status{
@@ -78,3 +88,119 @@ func TestRestoredStateTransition(t *testing.T) {
 		t.Fatal("expected stateTransitionError")
 	}
 }
+
+func TestDestroyRemovesPidFile(t *testing.T) {
+	root, err := ioutil.TempDir("", "libcontainer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	pidFile := filepath.Join(root, "init.pid")
+	if err := writePidFile(pidFile, os.Getpid()); err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadFile(pidFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != strconv.Itoa(os.Getpid()) {
+		t.Fatalf("expected pid file to contain %d but found %q", os.Getpid(), data)
+	}
+
+	c := &linuxContainer{
+		root:          filepath.Join(root, "state"),
+		config:        &configs.Config{PidFile: pidFile},
+		cgroupManager: &mockCgroupManager{},
+	}
+	if err := destroy(c); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(pidFile); !os.IsNotExist(err) {
+		t.Fatalf("expected pid file to be removed: %v", err)
+	}
+}
+
+// lingeringCgroupManager reports the process of cmd in the cgroups until it
+// exits, and records whether the cgroups were destroyed with it running.
+type lingeringCgroupManager struct {
+	mockCgroupManager
+	cmd           *exec.Cmd
+	exited        chan struct{}
+	destroyedBusy bool
+}
+
+func newLingeringCgroupManager(t *testing.T, args ...string) *lingeringCgroupManager {
+	m := &lingeringCgroupManager{cmd: exec.Command(args[0], args[1:]...), exited: make(chan struct{})}
+	if err := m.cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		m.cmd.Wait()
+		close(m.exited)
+	}()
+	return m
+}
+
+func (m *lingeringCgroupManager) running() bool {
+	select {
+	case <-m.exited:
+		return false
+	default:
+		return true
+	}
+}
+
+func (m *lingeringCgroupManager) GetAllPids() ([]int, error) {
+	if m.running() {
+		return []int{m.cmd.Process.Pid}, nil
+	}
+	return nil, nil
+}
+
+func (m *lingeringCgroupManager) Destroy() error {
+	m.destroyedBusy = m.running()
+	return nil
+}
+
+func destroyDrained(t *testing.T, m *lingeringCgroupManager, timeout time.Duration) time.Duration {
+	root, err := ioutil.TempDir("", "libcontainer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	c := &linuxContainer{
+		root:          filepath.Join(root, "state"),
+		config:        &configs.Config{CgroupDrainTimeout: timeout},
+		cgroupManager: m,
+	}
+	start := time.Now()
+	if err := destroy(c); err != nil {
+		t.Fatal(err)
+	}
+	if m.destroyedBusy {
+		t.Fatal("expected the cgroups to be destroyed after the lingering process exited")
+	}
+	return time.Since(start)
+}
+
+func TestDestroyWaitsForCgroupProcesses(t *testing.T) {
+	m := newLingeringCgroupManager(t, "sleep", "0.3")
+	elapsed := destroyDrained(t, m, 10*time.Second)
+	if elapsed < 300*time.Millisecond {
+		t.Fatalf("expected destroy to wait for the lingering process, returned after %s", elapsed)
+	}
+	if !m.cmd.ProcessState.Success() {
+		t.Fatalf("expected the lingering process to exit by itself, got %s", m.cmd.ProcessState)
+	}
+}
+
+func TestDestroyKillsCgroupProcessesAfterTimeout(t *testing.T) {
+	m := newLingeringCgroupManager(t, "sleep", "100")
+	elapsed := destroyDrained(t, m, 200*time.Millisecond)
+	if elapsed < 200*time.Millisecond || elapsed > 10*time.Second {
+		t.Fatalf("expected destroy to kill the lingering process after the timeout, returned after %s", elapsed)
+	}
+	if m.running() {
+		t.Fatal("expected the lingering process to be killed")
+	}
+}
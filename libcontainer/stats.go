@@ -12,4 +12,8 @@ type NetworkInterface struct {
 	TxPackets uint64
 	TxErrors  uint64
 	TxDropped uint64
+
+	// Collisions is the number of collisions detected on the interface. It
+	// is not split by direction.
+	Collisions uint64
 }
@@ -1,8 +1,128 @@
 package libcontainer
 
-import "github.com/opencontainers/runc/libcontainer/cgroups"
+import (
+	"time"
+
+	"github.com/opencontainers/runc/libcontainer/cgroups"
+)
 
 type Stats struct {
 	Interfaces  []*NetworkInterface
 	CgroupStats *cgroups.Stats
 }
+
+// CPUPercent returns the CPU usage of the container between the two
+// snapshots, which were taken interval apart, as a percentage of all the
+// online CPUs of the host. The usage is the one DiffStats computes. It
+// returns 0 if a snapshot has no cgroup stats.
+func CPUPercent(prev, cur *Stats, interval time.Duration) float64 {
+	if prev == nil || cur == nil || prev.CgroupStats == nil || cur.CgroupStats == nil || interval <= 0 {
+		return 0
+	}
+	usage := DiffStats(prev, cur).CpuUsage
+	// PercpuUsage has an entry for every possible CPU, which includes the
+	// offline ones.
+	return float64(usage.TotalUsage) / float64(interval.Nanoseconds()) / float64(onlineCPUs()) * 100
+}
+
+// StatsDelta holds how much the counters of a container grew between two
+// Stats snapshots. A counter that went backwards, such as after the cgroup
+// or an interface was recreated, is taken to have restarted from zero.
+type StatsDelta struct {
+	CpuUsage       cgroups.CpuUsage
+	ThrottlingData cgroups.ThrottlingData
+	// Interfaces holds the interfaces of the later snapshot. An interface
+	// that only the later snapshot has counts from zero.
+	Interfaces []*NetworkInterface
+	// IoServiceBytes and IoServiced hold the entries of the later snapshot,
+	// matched to the earlier one by device and operation.
+	IoServiceBytes []cgroups.BlkioStatEntry
+	IoServiced     []cgroups.BlkioStatEntry
+}
+
+// DiffStats returns the growth of the counters from prev to cur. The
+// cgroup counters are left zero if a snapshot has no cgroup stats.
+func DiffStats(prev, cur *Stats) *StatsDelta {
+	delta := &StatsDelta{}
+	if prev == nil {
+		prev = &Stats{}
+	}
+	if cur == nil {
+		return delta
+	}
+	prevIfaces := make(map[string]*NetworkInterface, len(prev.Interfaces))
+	for _, iface := range prev.Interfaces {
+		prevIfaces[iface.Name] = iface
+	}
+	for _, iface := range cur.Interfaces {
+		p, ok := prevIfaces[iface.Name]
+		if !ok {
+			p = &NetworkInterface{}
+		}
+		delta.Interfaces = append(delta.Interfaces, &NetworkInterface{
+			Name:       iface.Name,
+			RxBytes:    counterDelta(p.RxBytes, iface.RxBytes),
+			RxPackets:  counterDelta(p.RxPackets, iface.RxPackets),
+			RxErrors:   counterDelta(p.RxErrors, iface.RxErrors),
+			RxDropped:  counterDelta(p.RxDropped, iface.RxDropped),
+			TxBytes:    counterDelta(p.TxBytes, iface.TxBytes),
+			TxPackets:  counterDelta(p.TxPackets, iface.TxPackets),
+			TxErrors:   counterDelta(p.TxErrors, iface.TxErrors),
+			TxDropped:  counterDelta(p.TxDropped, iface.TxDropped),
+			Collisions: counterDelta(p.Collisions, iface.Collisions),
+		})
+	}
+	if prev.CgroupStats == nil || cur.CgroupStats == nil {
+		return delta
+	}
+	prevCpu, curCpu := &prev.CgroupStats.CpuStats, &cur.CgroupStats.CpuStats
+	delta.CpuUsage = cgroups.CpuUsage{
+		TotalUsage:        counterDelta(prevCpu.CpuUsage.TotalUsage, curCpu.CpuUsage.TotalUsage),
+		UsageInKernelmode: counterDelta(prevCpu.CpuUsage.UsageInKernelmode, curCpu.CpuUsage.UsageInKernelmode),
+		UsageInUsermode:   counterDelta(prevCpu.CpuUsage.UsageInUsermode, curCpu.CpuUsage.UsageInUsermode),
+	}
+	for i, usage := range curCpu.CpuUsage.PercpuUsage {
+		var p uint64
+		if i < len(prevCpu.CpuUsage.PercpuUsage) {
+			p = prevCpu.CpuUsage.PercpuUsage[i]
+		}
+		delta.CpuUsage.PercpuUsage = append(delta.CpuUsage.PercpuUsage, counterDelta(p, usage))
+	}
+	delta.ThrottlingData = cgroups.ThrottlingData{
+		Periods:          counterDelta(prevCpu.ThrottlingData.Periods, curCpu.ThrottlingData.Periods),
+		ThrottledPeriods: counterDelta(prevCpu.ThrottlingData.ThrottledPeriods, curCpu.ThrottlingData.ThrottledPeriods),
+		ThrottledTime:    counterDelta(prevCpu.ThrottlingData.ThrottledTime, curCpu.ThrottlingData.ThrottledTime),
+	}
+	prevBlkio, curBlkio := &prev.CgroupStats.BlkioStats, &cur.CgroupStats.BlkioStats
+	delta.IoServiceBytes = blkioDelta(prevBlkio.IoServiceBytesRecursive, curBlkio.IoServiceBytesRecursive)
+	delta.IoServiced = blkioDelta(prevBlkio.IoServicedRecursive, curBlkio.IoServicedRecursive)
+	return delta
+}
+
+// blkioDelta returns the growth of the entries of cur over the entries of
+// prev for the same device and operation.
+func blkioDelta(prev, cur []cgroups.BlkioStatEntry) []cgroups.BlkioStatEntry {
+	type key struct {
+		major, minor uint64
+		op           string
+	}
+	values := make(map[key]uint64, len(prev))
+	for _, e := range prev {
+		values[key{e.Major, e.Minor, e.Op}] = e.Value
+	}
+	var entries []cgroups.BlkioStatEntry
+	for _, e := range cur {
+		e.Value = counterDelta(values[key{e.Major, e.Minor, e.Op}], e.Value)
+		entries = append(entries, e)
+	}
+	return entries
+}
+
+// counterDelta returns how much a counter grew, taking a counter that went
+// backwards to have been reset.
+func counterDelta(prev, cur uint64) uint64 {
+	if cur < prev {
+		return cur
+	}
+	return cur - prev
+}
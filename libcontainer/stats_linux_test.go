@@ -0,0 +1,109 @@
+package libcontainer
+
+import (
+	"math"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/opencontainers/runc/libcontainer/cgroups"
+)
+
+func cpuSnapshot(total uint64, percpu ...uint64) *Stats {
+	stats := &Stats{CgroupStats: &cgroups.Stats{}}
+	stats.CgroupStats.CpuStats.CpuUsage.TotalUsage = total
+	stats.CgroupStats.CpuStats.CpuUsage.PercpuUsage = percpu
+	return stats
+}
+
+func TestCPUPercent(t *testing.T) {
+	second := uint64(time.Second)
+	// The percentage of all the online CPUs that one busy CPU makes.
+	cpu := 100 / float64(onlineCPUs())
+	for _, test := range []struct {
+		name      string
+		prev, cur *Stats
+		interval  time.Duration
+		expected  float64
+	}{
+		{"one cpu busy", cpuSnapshot(second, 0, 0, 0, 0), cpuSnapshot(3*second, 0, 0, 0, 0), 2 * time.Second, cpu},
+		{"two cpus busy", cpuSnapshot(0, 0, 0), cpuSnapshot(2*second, 0, 0), time.Second, 2 * cpu},
+		{"idle", cpuSnapshot(second, 0, 0), cpuSnapshot(second, 0, 0), time.Second, 0},
+		{"half of one cpu", cpuSnapshot(0, 0), cpuSnapshot(second/4, 0), 500 * time.Millisecond, cpu / 2},
+		{"offline cpus", cpuSnapshot(0, 0, 0, 0, 0, 0, 0, 0, 0), cpuSnapshot(second, 0, 0, 0, 0, 0, 0, 0, 0), time.Second, cpu},
+		{"no percpu usage", cpuSnapshot(0), cpuSnapshot(second), time.Second, cpu},
+		{"usage was reset", cpuSnapshot(2*second, 0), cpuSnapshot(second/2, 0), time.Second, cpu / 2},
+		{"no interval", cpuSnapshot(0, 0), cpuSnapshot(second, 0), 0, 0},
+		{"no cgroup stats", &Stats{}, cpuSnapshot(second, 0), time.Second, 0},
+		{"no snapshot", nil, cpuSnapshot(second, 0), time.Second, 0},
+	} {
+		if got := CPUPercent(test.prev, test.cur, test.interval); math.Abs(got-test.expected) > 1e-9 {
+			t.Errorf("%s: expected %v%%, got %v%%", test.name, test.expected, got)
+		}
+	}
+}
+
+func TestDiffStats(t *testing.T) {
+	prev := cpuSnapshot(1000, 600, 400)
+	prev.CgroupStats.CpuStats.CpuUsage.UsageInUsermode = 700
+	prev.CgroupStats.CpuStats.ThrottlingData = cgroups.ThrottlingData{Periods: 10, ThrottledPeriods: 2, ThrottledTime: 50}
+	prev.CgroupStats.BlkioStats.IoServiceBytesRecursive = []cgroups.BlkioStatEntry{
+		{Major: 8, Minor: 0, Op: "Read", Value: 4096},
+		{Major: 8, Minor: 0, Op: "Write", Value: 8192},
+	}
+	prev.Interfaces = []*NetworkInterface{
+		{Name: "eth0", RxBytes: 100, TxBytes: 200},
+		{Name: "eth1", RxBytes: 5000, TxPackets: 40},
+	}
+
+	cur := cpuSnapshot(1500, 900, 600)
+	cur.CgroupStats.CpuStats.CpuUsage.UsageInUsermode = 1000
+	cur.CgroupStats.CpuStats.ThrottlingData = cgroups.ThrottlingData{Periods: 15, ThrottledPeriods: 2, ThrottledTime: 80}
+	cur.CgroupStats.BlkioStats.IoServiceBytesRecursive = []cgroups.BlkioStatEntry{
+		{Major: 8, Minor: 0, Op: "Read", Value: 6144},
+		{Major: 8, Minor: 0, Op: "Write", Value: 8192},
+		{Major: 8, Minor: 16, Op: "Read", Value: 512},
+	}
+	cur.Interfaces = []*NetworkInterface{
+		{Name: "eth0", RxBytes: 150, TxBytes: 260},
+		// eth1 was recreated, so its counters restarted.
+		{Name: "eth1", RxBytes: 300, TxPackets: 3},
+		{Name: "eth2", RxBytes: 10},
+	}
+
+	delta := DiffStats(prev, cur)
+	expectedCpu := cgroups.CpuUsage{TotalUsage: 500, PercpuUsage: []uint64{300, 200}, UsageInUsermode: 300}
+	if !reflect.DeepEqual(delta.CpuUsage, expectedCpu) {
+		t.Errorf("expected cpu delta %+v, got %+v", expectedCpu, delta.CpuUsage)
+	}
+	expectedThrottling := cgroups.ThrottlingData{Periods: 5, ThrottledTime: 30}
+	if delta.ThrottlingData != expectedThrottling {
+		t.Errorf("expected throttling delta %+v, got %+v", expectedThrottling, delta.ThrottlingData)
+	}
+	expectedIo := []cgroups.BlkioStatEntry{
+		{Major: 8, Minor: 0, Op: "Read", Value: 2048},
+		{Major: 8, Minor: 0, Op: "Write", Value: 0},
+		{Major: 8, Minor: 16, Op: "Read", Value: 512},
+	}
+	if !reflect.DeepEqual(delta.IoServiceBytes, expectedIo) {
+		t.Errorf("expected io delta %+v, got %+v", expectedIo, delta.IoServiceBytes)
+	}
+	expectedIfaces := []*NetworkInterface{
+		{Name: "eth0", RxBytes: 50, TxBytes: 60},
+		{Name: "eth1", RxBytes: 300, TxPackets: 3},
+		{Name: "eth2", RxBytes: 10},
+	}
+	if !reflect.DeepEqual(delta.Interfaces, expectedIfaces) {
+		t.Errorf("expected interface deltas %+v, got %+v", expectedIfaces, delta.Interfaces)
+	}
+}
+
+func TestDiffStatsCgroupReset(t *testing.T) {
+	delta := DiffStats(cpuSnapshot(5000, 5000), cpuSnapshot(200, 200))
+	if delta.CpuUsage.TotalUsage != 200 || delta.CpuUsage.PercpuUsage[0] != 200 {
+		t.Errorf("expected a reset counter to count from zero, got %+v", delta.CpuUsage)
+	}
+	if delta := DiffStats(&Stats{}, cpuSnapshot(200)); delta.CpuUsage.TotalUsage != 0 {
+		t.Errorf("expected no cpu delta without earlier cgroup stats, got %+v", delta.CpuUsage)
+	}
+}
@@ -0,0 +1,109 @@
+// +build linux
+
+package libcontainer
+
+import (
+	"fmt"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// stopPollInterval is how often StopContainers checks whether a container has
+// stopped.
+const stopPollInterval = 10 * time.Millisecond
+
+// stopKillTimeout is how long StopContainers waits for a container to stop
+// after it has been sent SIGKILL.
+const stopKillTimeout = 10 * time.Second
+
+// StopResult is the outcome of stopping a single container with
+// StopContainers.
+type StopResult struct {
+	// ID is the ID of the container.
+	ID string
+
+	// Killed is set if the container was still running at the deadline and
+	// had to be killed.
+	Killed bool
+
+	// Err is set if the container could not be signaled or did not stop.
+	Err error
+}
+
+// StopContainers stops the containers concurrently. The init of each
+// container is sent SIGTERM, and the processes of every container that is
+// still running once grace has passed are sent SIGKILL. All containers share
+// the same deadline, so stopping any number of containers that honour SIGTERM
+// takes at most grace. The results are in the same order as containers.
+func StopContainers(containers []Container, grace time.Duration) []StopResult {
+	var (
+		wg       sync.WaitGroup
+		deadline = time.Now().Add(grace)
+		results  = make([]StopResult, len(containers))
+	)
+	for i, c := range containers {
+		wg.Add(1)
+		go func(i int, c Container) {
+			defer wg.Done()
+			results[i] = stopContainer(c, deadline)
+		}(i, c)
+	}
+	wg.Wait()
+	return results
+}
+
+func stopContainer(c Container, deadline time.Time) StopResult {
+	result := StopResult{ID: c.ID()}
+	if err := signalUnlessStopped(c, syscall.SIGTERM, false); err != nil {
+		result.Err = err
+		return result
+	}
+	stopped, err := waitStopped(c, deadline)
+	if err != nil || stopped {
+		result.Err = err
+		return result
+	}
+	result.Killed = true
+	if err := signalUnlessStopped(c, syscall.SIGKILL, true); err != nil {
+		result.Err = err
+		return result
+	}
+	stopped, err = waitStopped(c, time.Now().Add(stopKillTimeout))
+	if err == nil && !stopped {
+		err = fmt.Errorf("container %s did not stop after being killed", c.ID())
+	}
+	result.Err = err
+	return result
+}
+
+// signalUnlessStopped sends s to the container, ignoring the failure to do so
+// if the container stopped in the meantime.
+func signalUnlessStopped(c Container, s syscall.Signal, all bool) error {
+	err := c.Signal(s, all)
+	if err == nil {
+		return nil
+	}
+	if status, serr := c.Status(); serr == nil && status == Stopped {
+		return nil
+	}
+	return err
+}
+
+// waitStopped polls the status of the container until it has stopped or the
+// deadline has passed.
+func waitStopped(c Container, deadline time.Time) (bool, error) {
+	for {
+		status, err := c.Status()
+		if err != nil {
+			return false, err
+		}
+		if status == Stopped {
+			return true, nil
+		}
+		if !time.Now().Before(deadline) {
+			return false, nil
+		}
+		time.Sleep(stopPollInterval)
+	}
+}
@@ -0,0 +1,80 @@
+// +build linux
+
+package libcontainer
+
+import (
+	"os"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// stubbornContainer is a container that stops on SIGKILL and, unless it
+// ignores it, on SIGTERM.
+type stubbornContainer struct {
+	Container
+	id         string
+	ignoreTerm bool
+
+	mu      sync.Mutex
+	stopped bool
+	signals []os.Signal
+}
+
+func (c *stubbornContainer) ID() string {
+	return c.id
+}
+
+func (c *stubbornContainer) Signal(s os.Signal, all bool) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.signals = append(c.signals, s)
+	if s == syscall.SIGKILL || !c.ignoreTerm {
+		c.stopped = true
+	}
+	return nil
+}
+
+func (c *stubbornContainer) Status() (Status, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.stopped {
+		return Stopped, nil
+	}
+	return Running, nil
+}
+
+func TestStopContainersEscalatesToKill(t *testing.T) {
+	containers := []*stubbornContainer{
+		{id: "polite"},
+		{id: "stubborn", ignoreTerm: true},
+		{id: "also-polite"},
+		{id: "also-stubborn", ignoreTerm: true},
+	}
+	var list []Container
+	for _, c := range containers {
+		list = append(list, c)
+	}
+	grace := 100 * time.Millisecond
+	start := time.Now()
+	results := StopContainers(list, grace)
+	if elapsed := time.Since(start); elapsed > grace+time.Second {
+		t.Fatalf("expected all containers to stop shortly after the %s deadline, took %s", grace, elapsed)
+	}
+	for i, c := range containers {
+		r := results[i]
+		if r.ID != c.id || r.Err != nil {
+			t.Fatalf("unexpected result %+v for %s", r, c.id)
+		}
+		if r.Killed != c.ignoreTerm {
+			t.Errorf("expected %s to be killed: %v, got %v", c.id, c.ignoreTerm, r.Killed)
+		}
+		if status, _ := c.Status(); status != Stopped {
+			t.Errorf("expected %s to be stopped", c.id)
+		}
+		if c.signals[0] != syscall.SIGTERM {
+			t.Errorf("expected %s to be sent SIGTERM first, got %v", c.id, c.signals)
+		}
+	}
+}
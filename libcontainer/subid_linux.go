@@ -0,0 +1,71 @@
+// +build linux
+
+package libcontainer
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+
+	"github.com/opencontainers/runc/libcontainer/configs"
+	"github.com/opencontainers/runc/libcontainer/user"
+)
+
+// SubIDMappings checks that the uid and gid mappings of config fit in the
+// subordinate ids that /etc/subuid and /etc/subgid allocate to the current
+// user, who may also map their own uid and gid, and returns the mappings as
+// SysProcIDMap entries. Mappings like these can be set up without privileges
+// through newuidmap(1) and newgidmap(1); outside the allocations writing them
+// fails with EPERM.
+//
+// errors:
+// ConfigInvalid - a mapping does not fit in the allocated ranges,
+// SystemError - System error.
+func SubIDMappings(config *configs.Config) ([]syscall.SysProcIDMap, []syscall.SysProcIDMap, error) {
+	subuids, err := user.CurrentUserSubUIDs()
+	if err != nil && !os.IsNotExist(err) {
+		return nil, nil, newSystemErrorWithCause(err, "reading subordinate uids")
+	}
+	subgids, err := user.CurrentUserSubGIDs()
+	if err != nil && !os.IsNotExist(err) {
+		return nil, nil, newSystemErrorWithCause(err, "reading subordinate gids")
+	}
+	return subIDMappings(config, os.Getuid(), os.Getgid(), subuids, subgids)
+}
+
+func subIDMappings(config *configs.Config, uid, gid int, subuids, subgids []user.SubID) ([]syscall.SysProcIDMap, []syscall.SysProcIDMap, error) {
+	uids, err := checkSubIDMappings("uid", config.UidMappings, uid, subuids)
+	if err != nil {
+		return nil, nil, err
+	}
+	gids, err := checkSubIDMappings("gid", config.GidMappings, gid, subgids)
+	if err != nil {
+		return nil, nil, err
+	}
+	return uids, gids, nil
+}
+
+// checkSubIDMappings checks that every mapping is of the id own alone or is
+// within one of the ranges.
+func checkSubIDMappings(kind string, mappings []configs.IDMap, own int, ranges []user.SubID) ([]syscall.SysProcIDMap, error) {
+	maps := make([]syscall.SysProcIDMap, 0, len(mappings))
+	for _, m := range mappings {
+		if m.Size <= 0 {
+			return nil, newGenericError(fmt.Errorf("%s mapping of container %s %d has no size", kind, kind, m.ContainerID), ConfigInvalid)
+		}
+		if !(m.HostID == own && m.Size == 1) && !inSubIDRange(m, ranges) {
+			return nil, newGenericError(fmt.Errorf("%s mapping of host %ss %d-%d is not within the subordinate %ss of the user", kind, kind, m.HostID, m.HostID+m.Size-1, kind), ConfigInvalid)
+		}
+		maps = append(maps, syscall.SysProcIDMap{ContainerID: m.ContainerID, HostID: m.HostID, Size: m.Size})
+	}
+	return maps, nil
+}
+
+func inSubIDRange(m configs.IDMap, ranges []user.SubID) bool {
+	for _, r := range ranges {
+		if m.HostID >= r.SubID && m.HostID+m.Size <= r.SubID+r.Count {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,81 @@
+// +build linux
+
+package libcontainer
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"syscall"
+	"testing"
+
+	"github.com/opencontainers/runc/libcontainer/configs"
+	"github.com/opencontainers/runc/libcontainer/user"
+)
+
+func writeSubIDFile(t *testing.T, dir, name, data string) []user.SubID {
+	path := filepath.Join(dir, name)
+	if err := ioutil.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	ids, err := user.ParseSubIDFileFilter(path, func(s user.SubID) bool {
+		return s.Name == "alice" || s.Name == "1000"
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ids
+}
+
+func TestSubIDMappings(t *testing.T) {
+	dir, err := ioutil.TempDir("", "libcontainer-subid")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	subuids := writeSubIDFile(t, dir, "subuid", "alice:100000:65536\nbob:165536:65536\n")
+	subgids := writeSubIDFile(t, dir, "subgid", "1000:200000:1000\n")
+
+	config := &configs.Config{
+		UidMappings: []configs.IDMap{
+			{ContainerID: 0, HostID: 1000, Size: 1},
+			{ContainerID: 1, HostID: 100000, Size: 65536},
+		},
+		GidMappings: []configs.IDMap{
+			{ContainerID: 0, HostID: 1000, Size: 1},
+			{ContainerID: 1, HostID: 200500, Size: 500},
+		},
+	}
+	uids, gids, err := subIDMappings(config, 1000, 1000, subuids, subgids)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []syscall.SysProcIDMap{
+		{ContainerID: 0, HostID: 1000, Size: 1},
+		{ContainerID: 1, HostID: 100000, Size: 65536},
+	}
+	if !reflect.DeepEqual(uids, expected) {
+		t.Fatalf("expected uid mappings %+v, got %+v", expected, uids)
+	}
+	if len(gids) != 2 || gids[1].HostID != 200500 {
+		t.Fatalf("unexpected gid mappings %+v", gids)
+	}
+
+	for _, invalid := range []struct {
+		uids, gids []configs.IDMap
+	}{
+		// Past the end of the range of alice.
+		{uids: []configs.IDMap{{ContainerID: 0, HostID: 100000, Size: 65537}}},
+		// The range of bob.
+		{uids: []configs.IDMap{{ContainerID: 0, HostID: 165536, Size: 1}}},
+		// More than the own gid.
+		{gids: []configs.IDMap{{ContainerID: 0, HostID: 1000, Size: 2}}},
+		{gids: []configs.IDMap{{ContainerID: 0, HostID: 200000, Size: 0}}},
+	} {
+		config := &configs.Config{UidMappings: invalid.uids, GidMappings: invalid.gids}
+		if _, _, err := subIDMappings(config, 1000, 1000, subuids, subgids); err == nil {
+			t.Errorf("expected uid mappings %+v and gid mappings %+v to be rejected", invalid.uids, invalid.gids)
+		}
+	}
+}
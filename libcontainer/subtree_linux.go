@@ -0,0 +1,174 @@
+// +build linux
+
+package libcontainer
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/opencontainers/runc/libcontainer/cgroups"
+	"github.com/opencontainers/runc/libcontainer/cgroups/fs"
+	"github.com/opencontainers/runc/libcontainer/configs"
+)
+
+func (c *linuxContainer) PauseSubtree(subtree string) error {
+	c.m.Lock()
+	defer c.m.Unlock()
+	dir, err := c.subtreePath(subtree)
+	if err != nil {
+		return err
+	}
+	if err := freezeSubtree(dir, configs.Frozen); err != nil {
+		return newSystemErrorWithCausef(err, "freezing cgroup subtree %q", subtree)
+	}
+	return nil
+}
+
+func (c *linuxContainer) ResumeSubtree(subtree string) error {
+	c.m.Lock()
+	defer c.m.Unlock()
+	dir, err := c.subtreePath(subtree)
+	if err != nil {
+		return err
+	}
+	if err := freezeSubtree(dir, configs.Thawed); err != nil {
+		return newSystemErrorWithCausef(err, "thawing cgroup subtree %q", subtree)
+	}
+	return nil
+}
+
+func (c *linuxContainer) SignalSubtree(subtree string, s os.Signal) error {
+	c.m.Lock()
+	defer c.m.Unlock()
+	dir, err := c.subtreePath(subtree)
+	if err != nil {
+		return err
+	}
+	// Freeze the subtree so that no process escapes the signal by forking.
+	if err := freezeSubtree(dir, configs.Frozen); err != nil {
+		logrus.Warn(err)
+	}
+	defer func() {
+		if err := freezeSubtree(dir, configs.Thawed); err != nil {
+			logrus.Warn(err)
+		}
+	}()
+	pids, err := cgroups.GetAllPids(dir)
+	if err != nil {
+		return newSystemErrorWithCausef(err, "getting pids of cgroup subtree %q", subtree)
+	}
+	for _, pid := range pids {
+		p, err := os.FindProcess(pid)
+		if err != nil {
+			logrus.Warn(err)
+			continue
+		}
+		if err := p.Signal(s); err != nil {
+			logrus.Warn(err)
+		}
+	}
+	return nil
+}
+
+// subtreePath checks that the container is running and returns the path of
+// the freezer cgroup subtree, which is relative to the container's own.
+func (c *linuxContainer) subtreePath(subtree string) (string, error) {
+	if c.config.Rootless {
+		return "", newGenericError(fmt.Errorf("cannot manage cgroup subtrees of rootless container"), SystemError)
+	}
+	if _, err := c.runningState(); err != nil {
+		return "", err
+	}
+	cleaned, err := cleanSubtree(subtree)
+	if err != nil {
+		return "", err
+	}
+	root := c.cgroupManager.GetPaths()["freezer"]
+	if root == "" {
+		return "", newGenericError(fmt.Errorf("container has no freezer cgroup"), SystemError)
+	}
+	dir := filepath.Join(root, cleaned)
+	if _, err := os.Stat(dir); err != nil {
+		return "", newGenericError(fmt.Errorf("cgroup subtree %q does not exist: %v", subtree, err), ConfigInvalid)
+	}
+	return dir, nil
+}
+
+// cleanSubtree returns the cleaned subtree if it names a child cgroup.
+func cleanSubtree(subtree string) (string, error) {
+	cleaned := filepath.Clean(subtree)
+	if filepath.IsAbs(cleaned) || cleaned == "." || cleaned == ".." || strings.HasPrefix(cleaned, "../") {
+		return "", newGenericError(fmt.Errorf("invalid cgroup subtree %q", subtree), ConfigInvalid)
+	}
+	return cleaned, nil
+}
+
+// subtreePaths returns the paths of the child cgroup subtree in each of the
+// hierarchies in paths, creating the cgroups that do not exist yet.
+func subtreePaths(paths map[string]string, subtree string) (map[string]string, error) {
+	cleaned, err := cleanSubtree(subtree)
+	if err != nil {
+		return nil, err
+	}
+	subPaths := make(map[string]string, len(paths))
+	for name, root := range paths {
+		if !cgroups.PathExists(root) {
+			continue
+		}
+		dir := filepath.Join(root, cleaned)
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, newSystemErrorWithCausef(err, "creating cgroup subtree %q", subtree)
+		}
+		if name == "cpuset" {
+			// Processes can only join a cpuset with cpus and mems.
+			if err := copyCpuset(root, dir); err != nil {
+				return nil, newSystemErrorWithCausef(err, "setting up cpuset of cgroup subtree %q", subtree)
+			}
+		}
+		subPaths[name] = dir
+	}
+	return subPaths, nil
+}
+
+// copyCpuset gives the cpuset cgroup dir, and the cgroups between it and
+// root, the cpus and mems of root where they have none.
+func copyCpuset(root, dir string) error {
+	if dir == root {
+		return nil
+	}
+	if err := copyCpuset(root, filepath.Dir(dir)); err != nil {
+		return err
+	}
+	for _, file := range []string{"cpuset.cpus", "cpuset.mems"} {
+		current, err := ioutil.ReadFile(filepath.Join(dir, file))
+		if err != nil {
+			return err
+		}
+		if len(strings.TrimSpace(string(current))) > 0 {
+			continue
+		}
+		parent, err := ioutil.ReadFile(filepath.Join(filepath.Dir(dir), file))
+		if err != nil {
+			return err
+		}
+		if err := ioutil.WriteFile(filepath.Join(dir, file), parent, 0700); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// freezeSubtree sets the freezer state of the cgroup at dir, which applies to
+// all of its descendants.
+func freezeSubtree(dir string, state configs.FreezerState) error {
+	freezer := &fs.FreezerGroup{}
+	return freezer.Set(dir, &configs.Cgroup{
+		Resources: &configs.Resources{
+			Freezer: state,
+		},
+	})
+}
@@ -0,0 +1,170 @@
+// +build linux
+
+package libcontainer
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+
+	"github.com/opencontainers/runc/libcontainer/configs"
+)
+
+// newSubtreeContainer returns a running container whose freezer cgroup is a
+// fake directory with a "job" child cgroup.
+func newSubtreeContainer(t *testing.T) (*linuxContainer, string) {
+	dir, err := ioutil.TempDir("", "testsubtree")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, d := range []string{dir, filepath.Join(dir, "job")} {
+		if err := os.MkdirAll(d, 0755); err != nil {
+			t.Fatal(err)
+		}
+		for _, name := range []string{"freezer.state", "cgroup.procs"} {
+			if err := ioutil.WriteFile(filepath.Join(d, name), []byte{}, 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	return newMockContainer(t, os.Getpid(), map[string]string{"freezer": dir}), dir
+}
+
+func readFreezerState(t *testing.T, dir string) string {
+	data, err := ioutil.ReadFile(filepath.Join(dir, "freezer.state"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.TrimSpace(string(data))
+}
+
+func TestPauseSubtree(t *testing.T) {
+	container, dir := newSubtreeContainer(t)
+	defer os.RemoveAll(dir)
+
+	if err := container.PauseSubtree("job"); err != nil {
+		t.Fatal(err)
+	}
+	if state := readFreezerState(t, filepath.Join(dir, "job")); state != string(configs.Frozen) {
+		t.Fatalf("expected the subtree to be frozen, got %q", state)
+	}
+	if state := readFreezerState(t, dir); state != "" {
+		t.Fatalf("expected the rest of the container to be left alone, got %q", state)
+	}
+	if err := container.ResumeSubtree("job"); err != nil {
+		t.Fatal(err)
+	}
+	if state := readFreezerState(t, filepath.Join(dir, "job")); state != string(configs.Thawed) {
+		t.Fatalf("expected the subtree to be thawed, got %q", state)
+	}
+
+	for _, subtree := range []string{"", ".", "..", "../other", "/job", "missing"} {
+		err := container.PauseSubtree(subtree)
+		if lerr, ok := err.(Error); !ok || lerr.Code() != ConfigInvalid {
+			t.Errorf("expected ConfigInvalid pausing subtree %q but received %v", subtree, err)
+		}
+	}
+}
+
+func TestSignalSubtree(t *testing.T) {
+	container, dir := newSubtreeContainer(t)
+	defer os.RemoveAll(dir)
+
+	inside := exec.Command("sleep", "100")
+	if err := inside.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer inside.Process.Kill()
+	outside := exec.Command("sleep", "100")
+	if err := outside.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer outside.Process.Kill()
+	if err := ioutil.WriteFile(filepath.Join(dir, "job", "cgroup.procs"), []byte(strconv.Itoa(inside.Process.Pid)+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "cgroup.procs"), []byte(strconv.Itoa(outside.Process.Pid)+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := container.SignalSubtree("job", syscall.SIGKILL); err != nil {
+		t.Fatal(err)
+	}
+	if err := inside.Wait(); err == nil {
+		t.Fatal("expected the process in the subtree to be killed")
+	}
+	if isGone(outside.Process.Pid) {
+		t.Fatal("expected the process outside of the subtree to keep running")
+	}
+	if state := readFreezerState(t, filepath.Join(dir, "job")); state != string(configs.Thawed) {
+		t.Fatalf("expected the subtree to be thawed after signaling, got %q", state)
+	}
+}
+
+func TestSubtreePaths(t *testing.T) {
+	root, err := ioutil.TempDir("", "testsubtreepaths")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	paths := map[string]string{
+		"memory":  filepath.Join(root, "memory"),
+		"cpuset":  filepath.Join(root, "cpuset"),
+		"missing": filepath.Join(root, "missing"),
+	}
+	for _, name := range []string{"memory", "cpuset"} {
+		if err := os.Mkdir(paths[name], 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for file, value := range map[string]string{"cpuset.cpus": "0-1\n", "cpuset.mems": "0\n"} {
+		if err := ioutil.WriteFile(filepath.Join(paths["cpuset"], file), []byte(value), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// A real cpuset cgroup is created with empty cpus and mems.
+	for _, dir := range []string{"debug", "debug/tools"} {
+		if err := os.Mkdir(filepath.Join(paths["cpuset"], dir), 0755); err != nil {
+			t.Fatal(err)
+		}
+		for _, file := range []string{"cpuset.cpus", "cpuset.mems"} {
+			if err := ioutil.WriteFile(filepath.Join(paths["cpuset"], dir, file), nil, 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	subPaths, err := subtreePaths(paths, "debug/tools/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]string{
+		"memory": filepath.Join(paths["memory"], "debug/tools"),
+		"cpuset": filepath.Join(paths["cpuset"], "debug/tools"),
+	}
+	if !reflect.DeepEqual(subPaths, expected) {
+		t.Fatalf("expected sub-cgroup paths %v, got %v", expected, subPaths)
+	}
+	if _, err := os.Stat(expected["memory"]); err != nil {
+		t.Fatalf("expected the sub-cgroup to be created: %v", err)
+	}
+	for _, dir := range []string{"debug", "debug/tools"} {
+		cpus, err := ioutil.ReadFile(filepath.Join(paths["cpuset"], dir, "cpuset.cpus"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(cpus) != "0-1\n" {
+			t.Fatalf("expected cpuset %s to get the container's cpus, got %q", dir, cpus)
+		}
+	}
+
+	if _, err := subtreePaths(paths, "../escape"); err == nil {
+		t.Fatal("expected a sub-cgroup outside of the container's cgroup to be rejected")
+	}
+}
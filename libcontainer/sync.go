@@ -24,14 +24,27 @@ type syncType string
 //  [send(fd)] --> [recv(fd)]
 //             <-- procConsoleAck
 //
+// procIdmapRootfs --> [idmap or chown rootfs]
+//                 <-- [send(fd)]
+//
+// procIdmapMounts --> [idmap bind mounts]
+//                 <-- [send(fd)] for each
+//
 // procReady   --> [final setup]
 //             <-- procRun
+//
+//  [wait]     <-- procNetworkReady
 const (
 	procError  syncType = "procError"
 	procReady  syncType = "procReady"
 	procRun    syncType = "procRun"
 	procHooks  syncType = "procHooks"
 	procResume syncType = "procResume"
+
+	procNetworkReady syncType = "procNetworkReady"
+
+	procIdmapRootfs syncType = "procIdmapRootfs"
+	procIdmapMounts syncType = "procIdmapMounts"
 )
 
 type syncT struct {
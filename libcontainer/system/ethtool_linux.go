@@ -0,0 +1,71 @@
+// +build linux
+
+package system
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+const SIOCETHTOOL = 0x8946
+
+// Offload is a pair of the ethtool commands that get and set an offload of
+// a network interface.
+type Offload struct {
+	get, set uint32
+}
+
+var (
+	OffloadTSO = Offload{get: 0x1e, set: 0x1f}
+	OffloadGSO = Offload{get: 0x23, set: 0x24}
+	OffloadGRO = Offload{get: 0x2b, set: 0x2c}
+)
+
+// ethtoolValue is struct ethtool_value.
+type ethtoolValue struct {
+	cmd  uint32
+	data uint32
+}
+
+// ifreq is struct ifreq with the ifr_data member.
+type ifreq struct {
+	name [syscall.IFNAMSIZ]byte
+	data uintptr
+	_    [16]byte
+}
+
+func ethtool(name string, value *ethtoolValue) error {
+	fd, err := syscall.Socket(syscall.AF_INET, syscall.SOCK_DGRAM|syscall.SOCK_CLOEXEC, 0)
+	if err != nil {
+		return err
+	}
+	defer syscall.Close(fd)
+	var req ifreq
+	if len(name) >= len(req.name) {
+		return syscall.EINVAL
+	}
+	copy(req.name[:], name)
+	req.data = uintptr(unsafe.Pointer(value))
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd), SIOCETHTOOL, uintptr(unsafe.Pointer(&req))); errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// GetOffload returns whether the offload of the named interface is on.
+func GetOffload(name string, offload Offload) (bool, error) {
+	value := ethtoolValue{cmd: offload.get}
+	if err := ethtool(name, &value); err != nil {
+		return false, err
+	}
+	return value.data != 0, nil
+}
+
+// SetOffload turns the offload of the named interface on or off.
+func SetOffload(name string, offload Offload, on bool) error {
+	value := ethtoolValue{cmd: offload.set}
+	if on {
+		value.data = 1
+	}
+	return ethtool(name, &value)
+}
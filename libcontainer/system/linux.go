@@ -24,6 +24,13 @@ import (
 // termination status.
 const PR_SET_CHILD_SUBREAPER = 36
 
+// The pidfd syscalls were added after the syscall tables of all
+// architectures were unified, so they share the same numbers everywhere.
+const (
+	SYS_PIDFD_SEND_SIGNAL = 424
+	SYS_PIDFD_OPEN        = 434
+)
+
 type ParentDeathSignal int
 
 func (p ParentDeathSignal) Restore() error {
@@ -85,6 +92,14 @@ func SetKeepCaps() error {
 	return nil
 }
 
+// SetSecurebits sets the securebits of the calling thread.
+func SetSecurebits(bits uint) error {
+	if _, _, err := syscall.RawSyscall(syscall.SYS_PRCTL, syscall.PR_SET_SECUREBITS, uintptr(bits), 0); err != 0 {
+		return err
+	}
+	return nil
+}
+
 func ClearKeepCaps() error {
 	if _, _, err := syscall.RawSyscall(syscall.SYS_PRCTL, syscall.PR_SET_KEEPCAPS, 0, 0); err != 0 {
 		return err
@@ -129,6 +144,25 @@ func RunningInUserNS() bool {
 	return true
 }
 
+// PidfdOpen returns a file descriptor referring to the process pid. Unlike
+// the pid itself, the descriptor cannot come to refer to another process once
+// pid exits. It fails with ENOSYS on kernels older than 5.3.
+func PidfdOpen(pid int) (int, error) {
+	fd, _, err := syscall.Syscall(SYS_PIDFD_OPEN, uintptr(pid), 0, 0)
+	if err != 0 {
+		return -1, err
+	}
+	return int(fd), nil
+}
+
+// PidfdSendSignal sends sig to the process referred to by the pidfd fd.
+func PidfdSendSignal(fd int, sig syscall.Signal) error {
+	if _, _, err := syscall.Syscall6(SYS_PIDFD_SEND_SIGNAL, uintptr(fd), uintptr(sig), 0, 0, 0, 0); err != 0 {
+		return err
+	}
+	return nil
+}
+
 // SetSubreaper sets the value i as the subreaper setting for the calling process
 func SetSubreaper(i int) error {
 	return Prctl(PR_SET_CHILD_SUBREAPER, uintptr(i), 0, 0, 0)
@@ -141,3 +175,68 @@ func Prctl(option int, arg2, arg3, arg4, arg5 uintptr) (err error) {
 	}
 	return
 }
+
+// SchedSetaffinity restricts the thread tid, or the calling thread if tid is
+// 0, to run on the given CPUs.
+func SchedSetaffinity(tid int, cpus []int) error {
+	max := 0
+	for _, cpu := range cpus {
+		if cpu < 0 {
+			return fmt.Errorf("invalid cpu %d", cpu)
+		}
+		if cpu > max {
+			max = cpu
+		}
+	}
+	mask := make([]uint64, max/64+1)
+	for _, cpu := range cpus {
+		mask[cpu/64] |= 1 << uint(cpu%64)
+	}
+	if _, _, err := syscall.RawSyscall(syscall.SYS_SCHED_SETAFFINITY, uintptr(tid), uintptr(len(mask)*8), uintptr(unsafe.Pointer(&mask[0]))); err != 0 {
+		return err
+	}
+	return nil
+}
+
+// sigaction is struct sigaction as the rt_sigaction syscall takes it.
+type sigaction struct {
+	handler  uintptr
+	flags    uintptr
+	restorer uintptr
+	mask     uint64
+}
+
+// SetSignalMask sets the signals blocked by the calling thread to mask, in
+// which bit n-1 stands for signal n. The mask is kept across execve(2).
+func SetSignalMask(mask uint64) error {
+	const sigSetmask = 2
+	if _, _, err := syscall.RawSyscall6(syscall.SYS_RT_SIGPROCMASK, sigSetmask, uintptr(unsafe.Pointer(&mask)), 0, 8, 0, 0); err != 0 {
+		return err
+	}
+	return nil
+}
+
+// ResetIgnoredSignals sets the signals that the process ignores back to their
+// default action. Unlike handled signals, ignored ones stay ignored across
+// execve(2). The signals that the C library reserves for itself are reset
+// too, as the kernel does not tell them apart.
+func ResetIgnoredSignals() error {
+	const sigIgn = 1
+	for sig := 1; sig <= 64; sig++ {
+		if sig == int(syscall.SIGKILL) || sig == int(syscall.SIGSTOP) {
+			continue
+		}
+		var old sigaction
+		if _, _, err := syscall.RawSyscall6(syscall.SYS_RT_SIGACTION, uintptr(sig), 0, uintptr(unsafe.Pointer(&old)), 8, 0, 0); err != 0 {
+			return err
+		}
+		if old.handler != sigIgn {
+			continue
+		}
+		var dfl sigaction
+		if _, _, err := syscall.RawSyscall6(syscall.SYS_RT_SIGACTION, uintptr(sig), uintptr(unsafe.Pointer(&dfl)), 0, 8, 0, 0); err != 0 {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+// +build linux
+
+package system
+
+import (
+	"os"
+	"syscall"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+const (
+	MFD_CLOEXEC       = 0x1
+	MFD_ALLOW_SEALING = 0x2
+
+	F_ADD_SEALS   = 1033
+	F_SEAL_SEAL   = 0x1
+	F_SEAL_SHRINK = 0x2
+	F_SEAL_GROW   = 0x4
+	F_SEAL_WRITE  = 0x8
+)
+
+// MemfdCreate creates an anonymous file that can be sealed, with O_CLOEXEC.
+func MemfdCreate(name string) (*os.File, error) {
+	p, err := syscall.BytePtrFromString(name)
+	if err != nil {
+		return nil, err
+	}
+	fd, _, e1 := syscall.Syscall(unix.SYS_MEMFD_CREATE, uintptr(unsafe.Pointer(p)), MFD_CLOEXEC|MFD_ALLOW_SEALING, 0)
+	if e1 != 0 {
+		return nil, os.NewSyscallError("memfd_create", e1)
+	}
+	return os.NewFile(fd, "memfd:"+name), nil
+}
+
+// AddSeals adds the F_SEAL_* seals to the memfd f.
+func AddSeals(f *os.File, seals int) error {
+	if _, _, e1 := syscall.Syscall(syscall.SYS_FCNTL, f.Fd(), F_ADD_SEALS, uintptr(seals)); e1 != 0 {
+		return os.NewSyscallError("fcntl F_ADD_SEALS", e1)
+	}
+	return nil
+}
+
+// Fexecve execs the program that the file descriptor fd refers to. It only
+// returns on failure.
+func Fexecve(fd int, argv []string, envv []string) error {
+	path, err := syscall.BytePtrFromString("")
+	if err != nil {
+		return err
+	}
+	argvp, err := syscall.SlicePtrFromStrings(argv)
+	if err != nil {
+		return err
+	}
+	envvp, err := syscall.SlicePtrFromStrings(envv)
+	if err != nil {
+		return err
+	}
+	_, _, e1 := syscall.Syscall6(unix.SYS_EXECVEAT, uintptr(fd), uintptr(unsafe.Pointer(path)),
+		uintptr(unsafe.Pointer(&argvp[0])), uintptr(unsafe.Pointer(&envvp[0])), AT_EMPTY_PATH, 0)
+	return os.NewSyscallError("execveat", e1)
+}
@@ -0,0 +1,81 @@
+// +build linux
+
+package system
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+// The new mount API was added after the syscall tables of all architectures
+// were unified as well.
+const (
+	SYS_OPEN_TREE     = 428
+	SYS_MOVE_MOUNT    = 429
+	SYS_MOUNT_SETATTR = 442
+)
+
+const (
+	OPEN_TREE_CLONE         = 0x1
+	MOVE_MOUNT_F_EMPTY_PATH = 0x4
+	MOUNT_ATTR_IDMAP        = 0x100000
+	AT_EMPTY_PATH           = 0x1000
+	AT_RECURSIVE            = 0x8000
+	mountAttrSize           = 32
+)
+
+// atFdcwd is AT_FDCWD, a variable as the negative constant does not convert
+// to the uintptr of a syscall argument.
+var atFdcwd = -0x64
+
+// mountAttr is struct mount_attr of mount_setattr(2).
+type mountAttr struct {
+	attrSet     uint64
+	attrClr     uint64
+	propagation uint64
+	usernsFd    uint64
+}
+
+// OpenTreeClone returns a detached copy of the mount at path, or of the
+// tree below it with AT_RECURSIVE in flags. It fails with ENOSYS on kernels
+// older than 5.2.
+func OpenTreeClone(path string, flags int) (int, error) {
+	p, err := syscall.BytePtrFromString(path)
+	if err != nil {
+		return -1, err
+	}
+	fd, _, errno := syscall.Syscall(SYS_OPEN_TREE, uintptr(atFdcwd), uintptr(unsafe.Pointer(p)), uintptr(OPEN_TREE_CLONE|syscall.O_CLOEXEC|flags))
+	if errno != 0 {
+		return -1, errno
+	}
+	return int(fd), nil
+}
+
+// MountSetattrIdmap makes the detached mount fd, or the tree below it with
+// AT_RECURSIVE in flags, show the ids of files as mapped by the user
+// namespace usernsFd. It fails with ENOSYS on kernels older than 5.12 and
+// with EINVAL on filesystems without idmapped mounts.
+func MountSetattrIdmap(fd, usernsFd, flags int) error {
+	attr := mountAttr{
+		attrSet:  MOUNT_ATTR_IDMAP,
+		usernsFd: uint64(usernsFd),
+	}
+	empty := [1]byte{}
+	if _, _, errno := syscall.Syscall6(SYS_MOUNT_SETATTR, uintptr(fd), uintptr(unsafe.Pointer(&empty[0])), uintptr(AT_EMPTY_PATH|flags), uintptr(unsafe.Pointer(&attr)), mountAttrSize, 0); errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// MoveMount attaches the detached mount fd at path.
+func MoveMount(fd int, path string) error {
+	empty := [1]byte{}
+	p, err := syscall.BytePtrFromString(path)
+	if err != nil {
+		return err
+	}
+	if _, _, errno := syscall.Syscall6(SYS_MOVE_MOUNT, uintptr(fd), uintptr(unsafe.Pointer(&empty[0])), uintptr(atFdcwd), uintptr(unsafe.Pointer(p)), MOVE_MOUNT_F_EMPTY_PATH, 0); errno != 0 {
+		return errno
+	}
+	return nil
+}
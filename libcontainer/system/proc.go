@@ -7,14 +7,59 @@ import (
 	"strings"
 )
 
+func readProcessStat(pid int) (string, error) {
+	data, err := ioutil.ReadFile(filepath.Join("/proc", strconv.Itoa(pid), "stat"))
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
 // look in /proc to find the process start time so that we can verify
 // that this pid has started after ourself
 func GetProcessStartTime(pid int) (string, error) {
-	data, err := ioutil.ReadFile(filepath.Join("/proc", strconv.Itoa(pid), "stat"))
+	stat, err := readProcessStat(pid)
 	if err != nil {
 		return "", err
 	}
-	return parseStartTime(string(data))
+	return parseStartTime(stat)
+}
+
+// GetParentPid returns the pid of the parent of the given process.
+func GetParentPid(pid int) (int, error) {
+	stat, err := readProcessStat(pid)
+	if err != nil {
+		return -1, err
+	}
+	return parseParentPid(stat)
+}
+
+// IsZombie reports whether the process has exited but was not reaped yet.
+func IsZombie(pid int) (bool, error) {
+	stat, err := readProcessStat(pid)
+	if err != nil {
+		return false, err
+	}
+	return parseState(stat) == "Z", nil
+}
+
+// statFields returns the fields of /proc/<pid>/stat following the
+// parenthesised comm field, so that the first entry is field 3 (state).
+func statFields(stat string) []string {
+	s := strings.Split(stat, ")")
+	return strings.Split(strings.TrimSpace(s[len(s)-1]), " ")
+}
+
+func parseState(stat string) string {
+	// state %c
+	// (3) One of the characters indicating the process state, Z for zombie.
+	return statFields(stat)[3-3]
+}
+
+func parseParentPid(stat string) (int, error) {
+	// ppid %d
+	// (4) The PID of the parent of this process.
+	return strconv.Atoi(statFields(stat)[4-3])
 }
 
 func parseStartTime(stat string) (string, error) {
@@ -37,7 +82,6 @@ func parseStartTime(stat string) (string, error) {
 	// 89653 (gunicorn: maste) S 89630 89653 89653 0 -1 4194560 29689 28896 0 3 146 32 76 19 20 0 1 0 2971844 52965376 3920 18446744073709551615 1 1 0 0 0 0 0 16781312 137447943 0 0 0 17 1 0 0 0 0 0 0 0 0 0 0 0 0 0
 
 	// get parts after last `)`:
-	s := strings.Split(stat, ")")
-	parts := strings.Split(strings.TrimSpace(s[len(s)-1]), " ")
+	parts := statFields(stat)
 	return parts[22-3], nil // starts at 3 (after the filename pos `2`)
 }
@@ -0,0 +1,56 @@
+// +build linux
+
+package libcontainer
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/opencontainers/runc/libcontainer/system"
+)
+
+// uptimePath is where the kernel reports the time since boot.
+const uptimePath = "/proc/uptime"
+
+func (c *linuxContainer) Uptime() (time.Duration, error) {
+	c.m.Lock()
+	defer c.m.Unlock()
+	if _, err := c.runningState(); err != nil {
+		return 0, err
+	}
+	ticks, err := strconv.ParseUint(c.initProcessStartTime, 10, 64)
+	if err != nil {
+		return 0, newSystemErrorWithCause(err, "parsing init process start time")
+	}
+	boot, err := sinceBoot()
+	if err != nil {
+		return 0, newSystemErrorWithCause(err, "reading time since boot")
+	}
+	started := time.Duration(ticks) * time.Second / time.Duration(system.GetClockTicks())
+	// The start time is rounded to a tick, so a fresh init may seem to
+	// start after now.
+	if started > boot {
+		return 0, nil
+	}
+	return boot - started, nil
+}
+
+// sinceBoot returns the time since boot, the first field of uptimePath.
+func sinceBoot() (time.Duration, error) {
+	data, err := ioutil.ReadFile(uptimePath)
+	if err != nil {
+		return 0, err
+	}
+	fields := strings.Fields(string(data))
+	if len(fields) == 0 {
+		return 0, fmt.Errorf("%s is empty", uptimePath)
+	}
+	seconds, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(seconds * float64(time.Second)), nil
+}
@@ -0,0 +1,34 @@
+// +build linux
+
+package libcontainer
+
+import (
+	"os/exec"
+	"testing"
+	"time"
+)
+
+func TestUptime(t *testing.T) {
+	cmd := exec.Command("sleep", "10")
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer cmd.Wait()
+	defer cmd.Process.Kill()
+	c := newMockContainer(t, cmd.Process.Pid, nil)
+
+	time.Sleep(300 * time.Millisecond)
+	uptime, err := c.Uptime()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The start time and the time since boot are both rounded to 10ms.
+	if uptime < 250*time.Millisecond || uptime > 5*time.Second {
+		t.Fatalf("expected an uptime of about 300ms, got %s", uptime)
+	}
+
+	c.initProcess = nil
+	if _, err := c.Uptime(); err == nil {
+		t.Fatal("expected the uptime of a stopped container to fail")
+	}
+}
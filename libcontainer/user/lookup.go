@@ -2,6 +2,7 @@ package user
 
 import (
 	"errors"
+	"strconv"
 	"syscall"
 )
 
@@ -108,3 +109,34 @@ func LookupGid(gid int) (Group, error) {
 		return g.Gid == gid
 	})
 }
+
+// CurrentUserSubUIDs looks up the ranges of subordinate uids allocated to the
+// current user, by their name or user id, in /etc/subuid.
+func CurrentUserSubUIDs() ([]SubID, error) {
+	path, err := GetSubuidPath()
+	if err != nil {
+		return nil, err
+	}
+	return currentUserSubIDs(path)
+}
+
+// CurrentUserSubGIDs looks up the ranges of subordinate gids allocated to the
+// current user, by their name or user id, in /etc/subgid.
+func CurrentUserSubGIDs() ([]SubID, error) {
+	path, err := GetSubgidPath()
+	if err != nil {
+		return nil, err
+	}
+	return currentUserSubIDs(path)
+}
+
+func currentUserSubIDs(path string) ([]SubID, error) {
+	u, err := CurrentUser()
+	if err != nil {
+		return nil, err
+	}
+	uid := strconv.Itoa(u.Uid)
+	return ParseSubIDFileFilter(path, func(s SubID) bool {
+		return s.Name == u.Name || s.Name == uid
+	})
+}
@@ -11,6 +11,8 @@ import (
 const (
 	unixPasswdPath = "/etc/passwd"
 	unixGroupPath  = "/etc/group"
+	unixSubuidPath = "/etc/subuid"
+	unixSubgidPath = "/etc/subgid"
 )
 
 func GetPasswdPath() (string, error) {
@@ -28,3 +30,11 @@ func GetGroupPath() (string, error) {
 func GetGroup() (io.ReadCloser, error) {
 	return os.Open(unixGroupPath)
 }
+
+func GetSubuidPath() (string, error) {
+	return unixSubuidPath, nil
+}
+
+func GetSubgidPath() (string, error) {
+	return unixSubgidPath, nil
+}
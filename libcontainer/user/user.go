@@ -35,6 +35,14 @@ type Group struct {
 	List []string
 }
 
+// SubID is a range of subordinate ids allocated to a user in /etc/subuid or
+// /etc/subgid. Name is the name or the id of the user.
+type SubID struct {
+	Name  string
+	SubID int
+	Count int
+}
+
 func parseLine(line string, v ...interface{}) {
 	if line == "" {
 		return
@@ -187,6 +195,61 @@ func ParseGroupFilter(r io.Reader, filter func(Group) bool) ([]Group, error) {
 	return out, nil
 }
 
+func ParseSubIDFile(path string) ([]SubID, error) {
+	subid, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer subid.Close()
+	return ParseSubID(subid)
+}
+
+func ParseSubID(subid io.Reader) ([]SubID, error) {
+	return ParseSubIDFilter(subid, nil)
+}
+
+func ParseSubIDFileFilter(path string, filter func(SubID) bool) ([]SubID, error) {
+	subid, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer subid.Close()
+	return ParseSubIDFilter(subid, filter)
+}
+
+func ParseSubIDFilter(r io.Reader, filter func(SubID) bool) ([]SubID, error) {
+	if r == nil {
+		return nil, fmt.Errorf("nil source for subid-formatted data")
+	}
+
+	var (
+		s   = bufio.NewScanner(r)
+		out = []SubID{}
+	)
+
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		// see: man 5 subuid
+		//  login_name_or_uid:first_subordinate_id:count
+		//  alice:100000:65536
+		p := SubID{}
+		parseLine(line, &p.Name, &p.SubID, &p.Count)
+
+		if filter == nil || filter(p) {
+			out = append(out, p)
+		}
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+
+	return out, nil
+}
+
 type ExecUser struct {
 	Uid   int
 	Gid   int
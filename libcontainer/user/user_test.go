@@ -99,6 +99,26 @@ this is just some garbage data
 	}
 }
 
+func TestUserParseSubID(t *testing.T) {
+	ids, err := ParseSubIDFilter(strings.NewReader(`
+# allocated by useradd
+alice:100000:65536
+1001:165536:65536
+`), nil)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(ids) != 2 {
+		t.Fatalf("Expected 2 ranges, got %v", len(ids))
+	}
+	if ids[0].Name != "alice" || ids[0].SubID != 100000 || ids[0].Count != 65536 {
+		t.Fatalf("Expected ids[0] to be alice - 100000 - 65536, got %v - %v - %v", ids[0].Name, ids[0].SubID, ids[0].Count)
+	}
+	if ids[1].Name != "1001" || ids[1].SubID != 165536 {
+		t.Fatalf("Expected ids[1] to be 1001 - 165536, got %v - %v", ids[1].Name, ids[1].SubID)
+	}
+}
+
 func TestValidGetExecUser(t *testing.T) {
 	const passwdContent = `
 root:x:0:0:root user:/root:/bin/bash
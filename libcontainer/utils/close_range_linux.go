@@ -0,0 +1,21 @@
+// +build linux
+
+package utils
+
+import "syscall"
+
+const (
+	sysCloseRange     = 436
+	closeRangeCloexec = 0x4
+	closeRangeMaxFd   = ^uint(0)
+)
+
+// closeExecRange marks the descriptors from minFd on close-on-exec with a
+// single close_range(2) call. It fails with ENOSYS on kernels older than
+// 5.9, and with EINVAL on kernels older than 5.11 that lack the flag.
+func closeExecRange(minFd int) error {
+	if _, _, err := syscall.Syscall(sysCloseRange, uintptr(minFd), uintptr(closeRangeMaxFd), closeRangeCloexec); err != 0 {
+		return err
+	}
+	return nil
+}
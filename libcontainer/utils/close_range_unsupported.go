@@ -0,0 +1,9 @@
+// +build !linux,!windows
+
+package utils
+
+import "syscall"
+
+func closeExecRange(minFd int) error {
+	return syscall.ENOSYS
+}
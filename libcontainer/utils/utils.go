@@ -48,6 +48,13 @@ func ExitStatus(status syscall.WaitStatus) int {
 	return status.ExitStatus()
 }
 
+// ExitCode returns the exit code of the exited process described by ps,
+// following the same 128+signal convention as ExitStatus for processes
+// that were killed by a signal.
+func ExitCode(ps *os.ProcessState) int {
+	return ExitStatus(ps.Sys().(syscall.WaitStatus))
+}
+
 // WriteJSON writes the provided struct v to w using standard json marshaling
 func WriteJSON(w io.Writer, v interface{}) error {
 	data, err := json.Marshal(v)
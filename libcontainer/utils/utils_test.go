@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"fmt"
 	"os"
+	"os/exec"
 	"path/filepath"
 	"syscall"
 	"testing"
@@ -109,6 +110,26 @@ func TestExitStatusSignaled(t *testing.T) {
 	}
 }
 
+func TestExitCode(t *testing.T) {
+	cmd := exec.Command("sh", "-c", "exit 3")
+	cmd.Run()
+	if ex := ExitCode(cmd.ProcessState); ex != 3 {
+		t.Errorf("expected exit code to equal 3 and received %d", ex)
+	}
+
+	for _, sig := range []syscall.Signal{syscall.SIGHUP, syscall.SIGINT, syscall.SIGKILL, syscall.SIGTERM} {
+		cmd := exec.Command("sleep", "100")
+		if err := cmd.Start(); err != nil {
+			t.Fatal(err)
+		}
+		cmd.Process.Signal(sig)
+		cmd.Wait()
+		if ex := ExitCode(cmd.ProcessState); ex != 128+int(sig) {
+			t.Errorf("expected exit code for %v to equal %d and received %d", sig, 128+int(sig), ex)
+		}
+	}
+}
+
 func TestWriteJSON(t *testing.T) {
 	person := struct {
 		Name string
@@ -151,3 +172,30 @@ func TestCleanPath(t *testing.T) {
 		t.Errorf("expected to receive '/var' and received %s", path)
 	}
 }
+
+func TestCloseExecFrom(t *testing.T) {
+	var fds [2]int
+	for i := range fds {
+		fd, err := syscall.Open("/dev/null", syscall.O_RDONLY, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer syscall.Close(fd)
+		fds[i] = fd
+	}
+	if fds[0] > fds[1] {
+		fds[0], fds[1] = fds[1], fds[0]
+	}
+	if err := CloseExecFrom(fds[1]); err != nil {
+		t.Fatal(err)
+	}
+	for i, cloexec := range []bool{false, true} {
+		flags, _, errno := syscall.Syscall(syscall.SYS_FCNTL, uintptr(fds[i]), syscall.F_GETFD, 0)
+		if errno != 0 {
+			t.Fatal(errno)
+		}
+		if got := flags&syscall.FD_CLOEXEC != 0; got != cloexec {
+			t.Errorf("expected fd %d to be close-on-exec %v, got %v", fds[i], cloexec, got)
+		}
+	}
+}
@@ -9,7 +9,13 @@ import (
 	"syscall"
 )
 
+// CloseExecFrom marks the descriptors from minFd on close-on-exec, so that
+// they are closed when the process execs. It uses close_range(2) where the
+// kernel has it and goes through /proc/self/fd otherwise.
 func CloseExecFrom(minFd int) error {
+	if err := closeExecRange(minFd); err == nil {
+		return nil
+	}
 	fdList, err := ioutil.ReadDir("/proc/self/fd")
 	if err != nil {
 		return err
@@ -0,0 +1,93 @@
+// +build linux
+
+package libcontainer
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/opencontainers/runc/libcontainer/utils"
+)
+
+// exitStatusGracePeriod is how long Wait gives the parent of the init of a
+// loaded container to save the exit code after the init has exited.
+const exitStatusGracePeriod = 100 * time.Millisecond
+
+func (c *linuxContainer) Wait() (int, error) {
+	c.m.Lock()
+	init, status := c.initProcess, c.exitStatus
+	c.m.Unlock()
+	if status != nil {
+		return *status, nil
+	}
+	if init == nil {
+		return -1, newGenericError(fmt.Errorf("container not running"), ContainerNotRunning)
+	}
+	var code int
+	if _, ok := init.(*nonChildProcess); ok {
+		saved, err := c.waitNonChild()
+		if err != nil {
+			return -1, err
+		}
+		if saved == nil {
+			return -1, newGenericError(fmt.Errorf("exit status of init process was not saved"), SystemError)
+		}
+		code = *saved
+	} else {
+		// An init that exited with an error code is reported with an error
+		// along with its state.
+		state, err := init.wait()
+		if state == nil {
+			return -1, newSystemErrorWithCause(err, "waiting for init process")
+		}
+		code = utils.ExitCode(state)
+	}
+	c.m.Lock()
+	defer c.m.Unlock()
+	c.exitStatus = &code
+	// The state is gone once the container has been destroyed.
+	if _, err := os.Stat(filepath.Join(c.root, stateFilename)); err == nil {
+		if err := c.persistState(); err != nil {
+			return code, err
+		}
+	}
+	return code, nil
+}
+
+// waitNonChild polls for the init of a container loaded from its state to
+// exit, as only its parent can wait for it, and returns the exit code saved
+// in the state by the parent.
+func (c *linuxContainer) waitNonChild() (*int, error) {
+	for {
+		status, err := c.Status()
+		if err != nil {
+			return nil, err
+		}
+		if status == Stopped {
+			break
+		}
+		time.Sleep(stopPollInterval)
+	}
+	// What the init leaves in the cgroups is the parent's to clean up, as
+	// it may still need it.
+	deadline := time.Now().Add(exitStatusGracePeriod)
+	for {
+		f, err := os.Open(filepath.Join(c.root, stateFilename))
+		if err != nil {
+			return nil, newSystemErrorWithCause(err, "opening state")
+		}
+		var state State
+		err = json.NewDecoder(f).Decode(&state)
+		f.Close()
+		if err != nil {
+			return nil, newSystemErrorWithCause(err, "decoding state")
+		}
+		if state.ExitStatus != nil || !time.Now().Before(deadline) {
+			return state.ExitStatus, nil
+		}
+		time.Sleep(stopPollInterval)
+	}
+}
@@ -0,0 +1,105 @@
+// +build linux
+
+package libcontainer
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"syscall"
+	"testing"
+
+	"github.com/opencontainers/runc/libcontainer/configs"
+)
+
+// waitingContainer returns a container in root whose init is the process of
+// cmd, waited for like a restored init.
+func waitingContainer(t *testing.T, root string, cmd *exec.Cmd) *linuxContainer {
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	init, err := newRestoredProcess(cmd.Process.Pid, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := &linuxContainer{
+		id:                   "myid",
+		root:                 root,
+		config:               &configs.Config{},
+		cgroupManager:        &mockCgroupManager{},
+		initProcess:          init,
+		initProcessStartTime: init.processStartTime,
+	}
+	c.state = &runningState{c: c}
+	if err := c.persistState(); err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestWaitExitStatus(t *testing.T) {
+	root, err := ioutil.TempDir("", "libcontainer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	c := waitingContainer(t, root, exec.Command("sh", "-c", "exit 3"))
+	for i := 0; i < 2; i++ {
+		code, err := c.Wait()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if code != 3 {
+			t.Fatalf("expected exit code 3, got %d", code)
+		}
+	}
+
+	// A container loaded from the state gets the saved exit code.
+	loaded := &linuxContainer{
+		id:     c.id,
+		root:   root,
+		config: c.config,
+		initProcess: &nonChildProcess{
+			processPid:       c.initProcess.pid(),
+			processStartTime: c.initProcessStartTime,
+		},
+		initProcessStartTime: c.initProcessStartTime,
+		cgroupManager:        &mockCgroupManager{},
+	}
+	loaded.state = &loadedState{c: loaded}
+	code, err := loaded.Wait()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if code != 3 {
+		t.Fatalf("expected the saved exit code 3, got %d", code)
+	}
+}
+
+func TestWaitSignaled(t *testing.T) {
+	root, err := ioutil.TempDir("", "libcontainer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	c := waitingContainer(t, root, exec.Command("sleep", "100"))
+	if err := c.initProcess.signal(syscall.SIGKILL); err != nil {
+		t.Fatal(err)
+	}
+	code, err := c.Wait()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if code != 128+int(syscall.SIGKILL) {
+		t.Fatalf("expected exit code %d, got %d", 128+int(syscall.SIGKILL), code)
+	}
+}
+
+func TestWaitNotStarted(t *testing.T) {
+	c := &linuxContainer{id: "myid", config: &configs.Config{}, cgroupManager: &mockCgroupManager{}}
+	c.state = &stoppedState{c: c}
+	_, err := c.Wait()
+	if e, ok := err.(Error); !ok || e.Code() != ContainerNotRunning {
+		t.Fatalf("expected a ContainerNotRunning error, got %v", err)
+	}
+}
@@ -118,17 +118,9 @@ generate a proper rootless spec file.`,
 func sPtr(s string) *string { return &s }
 
 // loadSpec loads the specification from the provided path.
-func loadSpec(cPath string) (spec *specs.Spec, err error) {
-	cf, err := os.Open(cPath)
+func loadSpec(cPath string) (*specs.Spec, error) {
+	spec, err := specconv.LoadSpec(cPath)
 	if err != nil {
-		if os.IsNotExist(err) {
-			return nil, fmt.Errorf("JSON specification file %s not found", cPath)
-		}
-		return nil, err
-	}
-	defer cf.Close()
-
-	if err = json.NewDecoder(cf).Decode(&spec); err != nil {
 		return nil, err
 	}
 	if err = validatePlatform(&spec.Platform); err != nil {
@@ -5,7 +5,6 @@ package main
 import (
 	"errors"
 	"fmt"
-	"net"
 	"os"
 	"path/filepath"
 	"strconv"
@@ -133,21 +132,7 @@ func setupIO(process *libcontainer.Process, rootuid, rootgid int, createTTY, det
 			}()
 		} else {
 			// the caller of runc will handle receiving the console master
-			conn, err := net.Dial("unix", sockpath)
-			if err != nil {
-				return nil, err
-			}
-			uc, ok := conn.(*net.UnixConn)
-			if !ok {
-				return nil, fmt.Errorf("casting to UnixConn failed")
-			}
-			t.postStart = append(t.postStart, uc)
-			socket, err := uc.File()
-			if err != nil {
-				return nil, err
-			}
-			t.postStart = append(t.postStart, socket)
-			process.ConsoleSocket = socket
+			process.ConsoleSocketPath = sockpath
 		}
 		return t, nil
 	}